@@ -1,12 +1,21 @@
 package main
 
 import (
+	"flag"
 	"net/http"
+	"simple-database/internal/commandhandler"
+	"simple-database/internal/engine"
+	"simple-database/internal/engine/table"
 	"simple-database/internal/platform/helper"
 	"simple-database/internal/server"
 )
 
 func main() {
+	bufferPoolSize := flag.Int64("buffer-pool", engine.DefaultBufferPoolSize, "bytes of pages the database caches")
+	sortMemory := flag.Int64("sort-memory", table.DefaultSortMemory, "bytes of rows a select sorts in memory before writing them to run files")
+	flag.Parse()
+	commandhandler.Configure(commandhandler.Config{BufferPoolSize: *bufferPoolSize, SortMemory: *sortMemory})
+
 	helper.Log.Infof("Server started on port 8080")
 	err := http.ListenAndServe(":8080", server.GetServer())
 	if err != nil {
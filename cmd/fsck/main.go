@@ -0,0 +1,106 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"simple-database/internal/engine"
+	"simple-database/internal/engine/table"
+	platformerror "simple-database/internal/platform/error"
+	"sort"
+)
+
+// fsck checks the table and index files of a database under engine.BaseDir and prints what it finds. With
+// -repair, the indexes of tables with index problems are rebuilt from the table files
+func main() {
+	repair := flag.Bool("repair", false, "rebuild the indexes of tables whose indexes have problems")
+	flag.Usage = func() {
+		_, _ = fmt.Fprintf(flag.CommandLine.Output(), "Usage: fsck [-repair] <database>\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	problems, err := run(flag.Arg(0), *repair)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "fsck: %s\n", platformerror.Message(err))
+		os.Exit(2)
+	}
+	if problems > 0 {
+		os.Exit(1)
+	}
+}
+
+// run checks every table of database name and returns the number of problems left
+func run(name string, repair bool) (int, error) {
+	if _, err := os.Stat(filepath.Join(engine.BaseDir, name)); err != nil {
+		return 0, platformerror.NewStackTraceError(fmt.Sprintf("Database %s not existed", name), platformerror.DatabaseNotExistsErrorCode)
+	}
+	db, err := engine.NewDatabase(name)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "fsck: %s\n", platformerror.Message(err))
+		}
+	}()
+
+	names := make([]string, 0, len(db.Tables))
+	for tableName := range db.Tables {
+		names = append(names, tableName)
+	}
+	sort.Strings(names)
+
+	problems := 0
+	for _, tableName := range names {
+		t := db.Tables[tableName]
+		result, err := t.Check()
+		if err != nil {
+			return 0, err
+		}
+		printResult(result)
+		if repair && len(result.Problems) > 0 && len(result.IndexProblems) > 0 {
+			fmt.Printf("  not rebuilding indexes of %s, its table file is damaged\n", tableName)
+		} else if repair && len(result.IndexProblems) > 0 {
+			fmt.Printf("  rebuilding indexes of %s\n", tableName)
+			if err = t.RebuildIndexes(); err != nil {
+				return 0, err
+			}
+			if result, err = t.Check(); err != nil {
+				return 0, err
+			}
+			printResult(result)
+		}
+		problems += result.ProblemCount()
+	}
+	fmt.Printf("%d tables checked, %d problems\n", len(names), problems)
+	return problems, nil
+}
+
+func printResult(result *table.CheckResult) {
+	status := "ok"
+	if n := result.ProblemCount(); n > 0 {
+		status = fmt.Sprintf("%d problems", n)
+	}
+	fmt.Printf("%s: %d rows, %d pages, %d overflow pages: %s\n", result.TableName, result.Rows, result.Pages, result.OverflowPages, status)
+
+	indexes := make([]string, 0, len(result.IndexEntries))
+	for name := range result.IndexEntries {
+		indexes = append(indexes, name)
+	}
+	sort.Strings(indexes)
+	for _, problem := range result.Problems {
+		fmt.Printf("  %s\n", problem)
+	}
+	for _, name := range indexes {
+		fmt.Printf("  index %s: %d entries\n", name, result.IndexEntries[name])
+		for _, problem := range result.IndexProblems[name] {
+			fmt.Printf("    %s\n", problem)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+grammar CreateIndexSqlGrammar;
+
+query
+    : createIndexStatement EOF
+    ;
+
+createIndexStatement
+    : CREATE UNIQUE? INDEX indexName ON tableName LPAREN columnName (COMMA columnName)* RPAREN includeClause?
+    ;
+
+includeClause
+    : INCLUDE LPAREN columnName (COMMA columnName)* RPAREN
+    ;
+
+indexName
+    : IDENTIFIER
+    ;
+
+tableName
+    : IDENTIFIER
+    ;
+
+columnName
+    : IDENTIFIER
+    ;
+
+CREATE : [Cc][Rr][Ee][Aa][Tt][Ee];
+UNIQUE : [Uu][Nn][Ii][Qq][Uu][Ee];
+INDEX  : [Ii][Nn][Dd][Ee][Xx];
+ON     : [Oo][Nn];
+INCLUDE : [Ii][Nn][Cc][Ll][Uu][Dd][Ee];
+COMMA  : ',';
+LPAREN : '(';
+RPAREN : ')';
+
+IDENTIFIER
+    : [a-zA-Z_][a-zA-Z0-9_]*
+    ;
+
+WS : [ \t\r\n]+ -> skip;
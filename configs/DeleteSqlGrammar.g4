@@ -9,7 +9,7 @@ query
     ;
 
 deleteStatement
-    : DELETE FROM tableName WHERE whereClause?
+    : DELETE FROM tableName whereClause?
     ;
 
 whereClause
@@ -24,6 +24,8 @@ expression
 
 predicate
     : operand comparator operand
+    | operand BETWEEN operand AND operand
+    | operand IN LPAREN operand (COMMA operand)* RPAREN
     | LPAREN expression RPAREN
     ;
 
@@ -67,6 +69,8 @@ FROM    : [Ff][Rr][Oo][Mm];
 WHERE   : [Ww][Hh][Ee][Rr][Ee];
 AND     : [Aa][Nn][Dd];
 OR      : [Oo][Rr];
+BETWEEN : [Bb][Ee][Tt][Ww][Ee][Ee][Nn];
+IN      : [Ii][Nn];
 
 INT32    : [Ii][Nn][Tt] '32';
 INT64    : [Ii][Nn][Tt] '64';
@@ -0,0 +1,27 @@
+grammar DropIndexSqlGrammar;
+
+query
+    : dropIndexStatement EOF
+    ;
+
+dropIndexStatement
+    : DROP INDEX indexName (ON tableName)?
+    ;
+
+indexName
+    : IDENTIFIER
+    ;
+
+tableName
+    : IDENTIFIER
+    ;
+
+DROP  : [Dd][Rr][Oo][Pp];
+INDEX : [Ii][Nn][Dd][Ee][Xx];
+ON    : [Oo][Nn];
+
+IDENTIFIER
+    : [a-zA-Z_][a-zA-Z0-9_]*
+    ;
+
+WS : [ \t\r\n]+ -> skip;
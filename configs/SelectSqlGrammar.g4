@@ -9,18 +9,80 @@ query
     ;
 
 selectStatement
-    : SELECT selectList FROM tableName whereClause? limitClause?
+    : SELECT selectList FROM tableReference joinClause* whereClause? groupByClause? havingClause? orderByClause? limitClause?
+    ;
+
+tableReference
+    : tableName (AS? IDENTIFIER)?
+    ;
+
+joinClause
+    : joinType? JOIN tableReference ON expression
+    ;
+
+joinType
+    : INNER
+    | LEFT OUTER?
+    | RIGHT OUTER?
     ;
 
 selectList
     : STAR
-    | column (COMMA column)*
+    | selectItem (COMMA selectItem)*
+    ;
+
+selectItem
+    : valueExpression (AS IDENTIFIER)?
+    ;
+
+valueExpression
+    : valueExpression (STAR | SLASH) valueExpression
+    | valueExpression (PLUS | MINUS) valueExpression
+    | LPAREN valueExpression RPAREN
+    | typedLiteral
+    | typeName LPAREN valueExpression RPAREN
+    | aggregate
+    | functionCall
+    | column
+    ;
+
+functionCall
+    : IDENTIFIER LPAREN (valueExpression (COMMA valueExpression)*)? RPAREN
+    ;
+
+aggregate
+    : aggregateFunction LPAREN (STAR | aggregateArgument) RPAREN
+    ;
+
+aggregateFunction
+    : COUNT | SUM | AVG | MIN | MAX
+    ;
+
+aggregateArgument
+    : typeName LPAREN column RPAREN
+    | column
     ;
 
 whereClause
     : WHERE expression
     ;
 
+groupByClause
+    : GROUP BY column (COMMA column)*
+    ;
+
+havingClause
+    : HAVING expression
+    ;
+
+orderByClause
+    : ORDER BY orderItem (COMMA orderItem)*
+    ;
+
+orderItem
+    : (aggregate | column) (ASC | DESC)?
+    ;
+
 limitClause
      : LIMIT INTEGER
      ;
@@ -34,12 +96,15 @@ expression
 
 predicate
     : operand comparator operand
+    | operand BETWEEN operand AND operand
+    | operand IN LPAREN operand (COMMA operand)* RPAREN
     | LPAREN expression RPAREN
     ;
 
 operand
     : MINUS operand
     | typedLiteral
+    | aggregate
     | column
     ;
 
@@ -56,7 +121,7 @@ comparator
     ;
 
 column
-    : IDENTIFIER
+    : IDENTIFIER (DOT IDENTIFIER)?
     ;
 
 tableName
@@ -78,7 +143,27 @@ FROM    : [Ff][Rr][Oo][Mm];
 WHERE   : [Ww][Hh][Ee][Rr][Ee];
 AND     : [Aa][Nn][Dd];
 OR      : [Oo][Rr];
+BETWEEN : [Bb][Ee][Tt][Ww][Ee][Ee][Nn];
+IN      : [Ii][Nn];
 LIMIT   : [Ll][Ii][Mm][Ii][Tt];
+ORDER   : [Oo][Rr][Dd][Ee][Rr];
+BY      : [Bb][Yy];
+ASC     : [Aa][Ss][Cc];
+DESC    : [Dd][Ee][Ss][Cc];
+GROUP   : [Gg][Rr][Oo][Uu][Pp];
+HAVING  : [Hh][Aa][Vv][Ii][Nn][Gg];
+COUNT   : [Cc][Oo][Uu][Nn][Tt];
+SUM     : [Ss][Uu][Mm];
+AVG     : [Aa][Vv][Gg];
+MIN     : [Mm][Ii][Nn];
+MAX     : [Mm][Aa][Xx];
+AS      : [Aa][Ss];
+JOIN    : [Jj][Oo][Ii][Nn];
+INNER   : [Ii][Nn][Nn][Ee][Rr];
+LEFT    : [Ll][Ee][Ff][Tt];
+RIGHT   : [Rr][Ii][Gg][Hh][Tt];
+OUTER   : [Oo][Uu][Tt][Ee][Rr];
+ON      : [Oo][Nn];
 
 INT32    : [Ii][Nn][Tt] '32';
 INT64    : [Ii][Nn][Tt] '64';
@@ -98,6 +183,9 @@ LT      : '<';
 GTE     : '>=';
 GT      : '>';
 MINUS   : '-';
+PLUS    : '+';
+SLASH   : '/';
+DOT     : '.';
 
 IDENTIFIER
     : [a-zA-Z_][a-zA-Z0-9_]*
@@ -0,0 +1,18 @@
+grammar TransactionSqlGrammar;
+
+query
+    : transactionStatement EOF
+    ;
+
+transactionStatement
+    : BEGIN TRANSACTION?
+    | COMMIT TRANSACTION?
+    | ROLLBACK TRANSACTION?
+    ;
+
+BEGIN       : [Bb][Ee][Gg][Ii][Nn];
+COMMIT      : [Cc][Oo][Mm][Mm][Ii][Tt];
+ROLLBACK    : [Rr][Oo][Ll][Ll][Bb][Aa][Cc][Kk];
+TRANSACTION : [Tt][Rr][Aa][Nn][Ss][Aa][Cc][Tt][Ii][Oo][Nn];
+
+WS : [ \t\r\n]+ -> skip;
\ No newline at end of file
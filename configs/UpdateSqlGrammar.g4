@@ -32,6 +32,8 @@ expression
 
 predicate
     : operand comparator operand
+    | operand BETWEEN operand AND operand
+    | operand IN LPAREN operand (COMMA operand)* RPAREN
     | LPAREN expression RPAREN
     ;
 
@@ -75,6 +77,8 @@ SET     : [Ss][Ee][Tt];
 WHERE   : [Ww][Hh][Ee][Rr][Ee];
 AND     : [Aa][Nn][Dd];
 OR      : [Oo][Rr];
+BETWEEN : [Bb][Ee][Tt][Ww][Ee][Ee][Nn];
+IN      : [Ii][Nn];
 
 INT32    : [Ii][Nn][Tt] '32';
 INT64    : [Ii][Nn][Tt] '64';
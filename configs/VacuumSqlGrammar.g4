@@ -0,0 +1,22 @@
+grammar VacuumSqlGrammar;
+
+query
+    : vacuumStatement EOF
+    ;
+
+vacuumStatement
+    : VACUUM TABLE? tableName
+    ;
+
+tableName
+    : IDENTIFIER
+    ;
+
+VACUUM : [Vv][Aa][Cc][Uu][Uu][Mm];
+TABLE  : [Tt][Aa][Bb][Ll][Ee];
+
+IDENTIFIER
+    : [a-zA-Z_][a-zA-Z0-9_]*
+    ;
+
+WS : [ \t\r\n]+-> skip;
\ No newline at end of file
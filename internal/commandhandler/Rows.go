@@ -0,0 +1,84 @@
+package commandhandler
+
+import (
+	"fmt"
+	"simple-database/internal/engine"
+	"simple-database/internal/engine/buffer"
+	"simple-database/internal/engine/table"
+	tableparser "simple-database/internal/engine/table/column/parser"
+	"simple-database/internal/parser"
+)
+
+// Rows are the rows of a select, pulled from its operators one at a time as they are read, see table.Operator. The
+// lock the select runs under is held until Close, which must be called once the rows are no longer needed
+type Rows struct {
+	db     *engine.Database
+	op     table.Operator
+	result *table.SelectResult
+	// before is what the buffer pool did before the select started, release unlocks the lock of the select
+	before  buffer.Stats
+	release func()
+	closed  bool
+}
+
+// query opens the operators of the select in sql. The caller holds the lock the select needs and sets release
+func (h *sqlCommandHandler) query(sql string) (*Rows, error) {
+	command, err := parser.ParseSelect(sql)
+	if err != nil {
+		return nil, err
+	}
+	// Pages read by selects running at the same time are counted too
+	before := h.db.BufferPoolStats()
+	op, result, err := h.db.Query(command)
+	if err != nil {
+		return nil, err
+	}
+	if err = op.Open(); err != nil {
+		_ = op.Close()
+		return nil, err
+	}
+	return &Rows{db: h.db, op: op, result: result, before: before}, nil
+}
+
+// Next returns the next row of the select, ok is false once there are none
+func (r *Rows) Next() (row tableparser.RawRecord, ok bool, err error) {
+	return r.op.Next()
+}
+
+// Close closes the operators of the select, releases its lock and returns how its rows were found. The rows of the
+// result are left empty
+func (r *Rows) Close() (*table.SelectResult, error) {
+	if r.closed {
+		return r.result, nil
+	}
+	r.closed = true
+	err := r.op.Close()
+	after := r.db.BufferPoolStats()
+	r.result.Extra = fmt.Sprintf("Buffer pool: %d hits, %d misses", after.Hits-r.before.Hits, after.Misses-r.before.Misses)
+	if r.release != nil {
+		r.release()
+	}
+	return r.result, err
+}
+
+// Collect reads every row left and closes the select, returning its result with the rows read
+func (r *Rows) Collect() (*table.SelectResult, error) {
+	rows := make([]tableparser.RawRecord, 0)
+	for {
+		row, ok, err := r.Next()
+		if err != nil {
+			_, _ = r.Close()
+			return nil, err
+		}
+		if !ok {
+			break
+		}
+		rows = append(rows, row)
+	}
+	result, err := r.Close()
+	if err != nil {
+		return nil, err
+	}
+	result.Rows = rows
+	return result, nil
+}
@@ -1,22 +1,50 @@
 package commandhandler
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"simple-database/internal/engine"
+	"simple-database/internal/engine/table"
 	"simple-database/internal/parser"
 	platformerror "simple-database/internal/platform/error"
 	"simple-database/internal/platform/helper"
 	"strings"
 	"sync"
+	"time"
 )
 
+// TransactionTimeout is how long a transaction may stay idle between two statements before it is rolled back
+const TransactionTimeout = 60 * time.Second
+
 type SqlCommandHandler interface {
+	// Execute runs sql. A select returns its *Rows, which hold the read lock of the handler until Rows.Close: the
+	// caller must close them, and soon, as statements changing the tables wait for it
 	Execute(sql string) (interface{}, error)
+	// ExecuteInTransaction runs sql inside the transaction returned by a previous BEGIN. A select returns its *Rows,
+	// the next statements of the transaction wait until Rows.Close
+	ExecuteInTransaction(sql string, transactionId string) (interface{}, error)
+}
+
+type TransactionResult struct {
+	TransactionId string
+}
+
+// transaction is a BEGIN that has not been committed or rolled back yet. It owns the write lock of the handler
+// until it ends, so statements outside of it wait
+type transaction struct {
+	id    string
+	timer *time.Timer
 }
 
 type sqlCommandHandler struct {
 	db   *engine.Database
 	lock *sync.RWMutex
+	// txLock guards transaction and serializes the statements sent to it
+	txLock      *sync.Mutex
+	transaction *transaction
+	// vacuumLock keeps two vacuums from writing the same copy
+	vacuumLock *sync.Mutex
 }
 
 func (h *sqlCommandHandler) Execute(sql string) (interface{}, error) {
@@ -24,66 +52,240 @@ func (h *sqlCommandHandler) Execute(sql string) (interface{}, error) {
 	helper.Log.Debugf("Executing command: %s", sql)
 
 	switch {
-	case strings.HasPrefix(normalized, "INSERT"):
+	case strings.HasPrefix(normalized, "BEGIN"):
+		command, err := parser.ParseTransaction(sql)
+		if err != nil {
+			return nil, err
+		}
+		return h.begin(command)
+	case strings.HasPrefix(normalized, "COMMIT"), strings.HasPrefix(normalized, "ROLLBACK"):
+		return nil, platformerror.NewStackTraceError(fmt.Sprintf("%s requires a transaction id", sql), platformerror.TransactionErrorCode)
+	case strings.HasPrefix(normalized, "SELECT"):
+		// The rows are read after returning, the read lock is held until they are closed
+		h.lock.RLock()
+		rows, err := h.query(sql)
+		if err != nil {
+			h.lock.RUnlock()
+			return nil, err
+		}
+		rows.release = h.lock.RUnlock
+		return rows, nil
+	case strings.HasPrefix(normalized, "VACUUM"):
+		command, err := parser.ParseVacuum(sql)
+		if err != nil {
+			return nil, err
+		}
+		return h.vacuum(command)
+	default:
 		h.lock.Lock()
 		defer h.lock.Unlock()
+		return h.execute(sql, normalized)
+	}
+}
+
+func (h *sqlCommandHandler) ExecuteInTransaction(sql string, transactionId string) (interface{}, error) {
+	normalized := strings.ToUpper(strings.TrimSpace(sql))
+	helper.Log.Debugf("Executing command in transaction %s: %s", transactionId, sql)
+
+	h.txLock.Lock()
+	// The rows of a select are read after returning, txLock is held until they are closed so the next statements of
+	// the transaction wait for them
+	var rows *Rows
+	defer func() {
+		if rows == nil {
+			h.txLock.Unlock()
+		}
+	}()
+
+	tx := h.transaction
+	if tx == nil || tx.id != transactionId {
+		return nil, platformerror.NewStackTraceError(fmt.Sprintf("Unknown transaction %s", transactionId), platformerror.TransactionErrorCode)
+	}
+	tx.timer.Reset(TransactionTimeout)
+
+	switch {
+	case strings.HasPrefix(normalized, "BEGIN"), strings.HasPrefix(normalized, "COMMIT"), strings.HasPrefix(normalized, "ROLLBACK"):
+		command, err := parser.ParseTransaction(sql)
+		if err != nil {
+			return nil, err
+		}
+		switch command.Statement {
+		case engine.TransactionCommit:
+			return nil, h.end(tx, h.db.Commit)
+		case engine.TransactionRollback:
+			return nil, h.end(tx, h.db.Rollback)
+		default:
+			return nil, platformerror.NewStackTraceError(fmt.Sprintf("Transaction %s already started", transactionId), platformerror.TransactionErrorCode)
+		}
+	case strings.HasPrefix(normalized, "CREATE TABLE"), strings.HasPrefix(normalized, "DROP TABLE"),
+		strings.HasPrefix(normalized, "CREATE INDEX"), strings.HasPrefix(normalized, "CREATE UNIQUE INDEX"),
+		strings.HasPrefix(normalized, "DROP INDEX"), strings.HasPrefix(normalized, "VACUUM"):
+		return nil, platformerror.NewStackTraceError(fmt.Sprintf("%s is not allowed inside a transaction", sql), platformerror.TransactionErrorCode)
+	default:
+		// The transaction already holds the write lock
+		result, err := h.execute(sql, normalized)
+		if r, ok := result.(*Rows); ok {
+			rows, r.release = r, h.txLock.Unlock
+		}
+		return result, err
+	}
+}
+
+// execute parses and runs a single statement. The caller holds the lock it needs
+func (h *sqlCommandHandler) execute(sql string, normalized string) (interface{}, error) {
+	switch {
+	case strings.HasPrefix(normalized, "INSERT"):
 		command, err := parser.ParseInsert(sql)
 		if err != nil {
 			return nil, err
 		}
 		return h.db.Tables[command.TableName].Insert(command)
 	case strings.HasPrefix(normalized, "UPDATE"):
-		h.lock.Lock()
-		defer h.lock.Unlock()
 		command, err := parser.ParseUpdate(sql)
 		if err != nil {
 			return nil, err
 		}
 		return h.db.Tables[command.TableName].Update(command)
 	case strings.HasPrefix(normalized, "DELETE"):
-		h.lock.Lock()
-		defer h.lock.Unlock()
 		command, err := parser.ParseDelete(sql)
 		if err != nil {
 			return nil, err
 		}
 		return h.db.Tables[command.TableName].Delete(command)
 	case strings.HasPrefix(normalized, "SELECT"):
-		h.lock.RLock()
-		defer h.lock.RUnlock()
-		command, err := parser.ParseSelect(sql)
+		rows, err := h.query(sql)
 		if err != nil {
 			return nil, err
 		}
-		return h.db.Tables[command.TableName].Select(command)
+		return rows, nil
 	case strings.HasPrefix(normalized, "DROP TABLE"):
-		h.lock.Lock()
-		defer h.lock.Unlock()
 		command, err := parser.ParseDropTable(sql)
 		if err != nil {
 			return nil, err
 		}
 		return nil, h.db.DropTable(command)
 	case strings.HasPrefix(normalized, "CREATE TABLE"):
-		h.lock.Lock()
-		defer h.lock.Unlock()
 		command, err := parser.ParseCreateTable(sql)
 		if err != nil {
 			return nil, err
 		}
 		return h.db.CreateTable(command)
+	case strings.HasPrefix(normalized, "CREATE INDEX"), strings.HasPrefix(normalized, "CREATE UNIQUE INDEX"):
+		command, err := parser.ParseCreateIndex(sql)
+		if err != nil {
+			return nil, err
+		}
+		return nil, h.db.CreateIndex(command)
+	case strings.HasPrefix(normalized, "DROP INDEX"):
+		command, err := parser.ParseDropIndex(sql)
+		if err != nil {
+			return nil, err
+		}
+		return nil, h.db.DropIndex(command)
 	default:
 		return nil, platformerror.NewStackTraceError(fmt.Sprintf("Unknown command %s", sql), platformerror.UnknownCommandErrorCode)
 	}
+}
+
+// vacuum copies the table while holding the read lock only, so selects keep running, and takes the write lock
+// just to swap the files
+func (h *sqlCommandHandler) vacuum(command engine.VacuumCommand) (interface{}, error) {
+	h.vacuumLock.Lock()
+	defer h.vacuumLock.Unlock()
+
+	h.lock.RLock()
+	v, err := h.db.PrepareVacuum(command)
+	h.lock.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	return h.db.FinishVacuum(v)
+}
+
+// begin waits for the write lock, starts a transaction and keeps the lock until the transaction ends
+func (h *sqlCommandHandler) begin(command engine.TransactionCommand) (interface{}, error) {
+	if command.Statement != engine.TransactionBegin {
+		return nil, platformerror.NewStackTraceError(fmt.Sprintf("Unexpected %s", command.Statement), platformerror.TransactionErrorCode)
+	}
+
+	h.lock.Lock()
+	if err := h.db.Begin(); err != nil {
+		h.lock.Unlock()
+		return nil, err
+	}
+
+	id, err := newTransactionId()
+	if err != nil {
+		_ = h.db.Rollback()
+		h.lock.Unlock()
+		return nil, err
+	}
+
+	tx := &transaction{id: id}
+	tx.timer = time.AfterFunc(TransactionTimeout, func() { h.expire(tx) })
+
+	h.txLock.Lock()
+	h.transaction = tx
+	h.txLock.Unlock()
+
+	return &TransactionResult{TransactionId: id}, nil
+}
+
+// end finishes tx with finish and releases the write lock. The caller holds txLock
+func (h *sqlCommandHandler) end(tx *transaction, finish func() error) error {
+	tx.timer.Stop()
+	h.transaction = nil
+	defer h.lock.Unlock()
+	return finish()
+}
+
+// expire rolls back a transaction whose client stopped sending statements
+func (h *sqlCommandHandler) expire(tx *transaction) {
+	h.txLock.Lock()
+	defer h.txLock.Unlock()
+
+	if h.transaction != tx {
+		return
+	}
+	helper.Log.Warnf("Transaction %s idle for %v, rolling back", tx.id, TransactionTimeout)
+	if err := h.end(tx, h.db.Rollback); err != nil {
+		helper.Log.Errorf("Error rolling back transaction %s: %s", tx.id, err.Error())
+	}
+}
+
+func newTransactionId() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", platformerror.NewStackTraceError(err.Error(), platformerror.TransactionErrorCode)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Config is how the handler opens its database
+type Config struct {
+	// BufferPoolSize is the number of bytes of pages the database caches
+	BufferPoolSize int64
+	// SortMemory is the number of bytes of rows a select sorts in memory before writing them to run files
+	SortMemory int64
+}
+
+var config = Config{BufferPoolSize: engine.DefaultBufferPoolSize, SortMemory: table.DefaultSortMemory}
 
+// Configure sets how the handler opens its database, it has no effect once GetSqlCommandHandler was called
+func Configure(c Config) {
+	config = c
 }
 
 func newSqlCommandHandler() (SqlCommandHandler, error) {
-	db, err := engine.NewDatabase("my_db")
+	db, err := engine.NewDatabaseWithBufferPool("my_db", config.BufferPoolSize)
 	if err != nil {
 		panic(err)
 	}
-	return &sqlCommandHandler{db: db, lock: &sync.RWMutex{}}, nil
+	db.SetSortMemory(config.SortMemory)
+	return &sqlCommandHandler{db: db, lock: &sync.RWMutex{}, txLock: &sync.Mutex{}, vacuumLock: &sync.Mutex{}}, nil
 }
 
 var (
@@ -0,0 +1,248 @@
+package buffer
+
+import (
+	"container/list"
+	"sync"
+)
+
+// File is a file whose pages a Pool caches. ReadPage fills data with page num as stored in the file and verifies
+// it, WritePage writes data as page num
+type File interface {
+	ReadPage(num int64, data []byte) error
+	WritePage(num int64, data []byte) error
+}
+
+// Stats counts what a Pool did since it was created
+type Stats struct {
+	Hits   uint64
+	Misses uint64
+	// Evictions is the number of pages dropped to stay within the budget
+	Evictions uint64
+	// WriteBacks is the number of dirty pages written to their file
+	WriteBacks uint64
+	// Pages is the number of pages held
+	Pages int
+	// Dirty is the number of pages changed since they were read or written back
+	Dirty int
+}
+
+type frameKey struct {
+	file File
+	num  int64
+}
+
+// Frame holds one page of a File. Its data may only be used while the frame is pinned
+type Frame struct {
+	key   frameKey
+	data  []byte
+	pins  int
+	dirty bool
+	// elem is the position of the frame in the list of unpinned frames, nil while it is pinned
+	elem *list.Element
+}
+
+// Data returns the content of the page
+func (f *Frame) Data() []byte {
+	return f.data
+}
+
+// Pool caches the pages of every table and index file of a database within a memory budget. Pinned pages stay
+// in memory, unpinned ones are evicted least recently used first. Changed pages are kept dirty until Flush writes
+// them back, or until they are evicted. A pool whose pages are all pinned grows past its budget until they are
+// unpinned
+type Pool struct {
+	mu       sync.Mutex
+	pageSize int
+	// capacity is the number of pages the budget holds
+	capacity int
+	frames   map[frameKey]*Frame
+	// unpinned holds the unpinned frames, least recently used first
+	unpinned *list.List
+	stats    Stats
+}
+
+// NewPool returns a pool of pages of pageSize bytes using at most budget bytes
+func NewPool(budget int64, pageSize int) *Pool {
+	return &Pool{
+		pageSize: pageSize,
+		capacity: max(1, int(budget/int64(pageSize))),
+		frames:   make(map[frameKey]*Frame),
+		unpinned: list.New(),
+	}
+}
+
+// Fetch returns page num of f pinned, reading it from f if the pool does not hold it. The caller must Unpin it
+func (p *Pool) Fetch(f File, num int64) (*Frame, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	key := frameKey{file: f, num: num}
+	if fr, ok := p.frames[key]; ok {
+		p.stats.Hits++
+		p.pin(fr)
+		return fr, nil
+	}
+
+	p.stats.Misses++
+	if err := p.makeRoom(); err != nil {
+		return nil, err
+	}
+	data := make([]byte, p.pageSize)
+	if err := f.ReadPage(num, data); err != nil {
+		return nil, err
+	}
+	fr := &Frame{key: key, data: data, pins: 1}
+	p.frames[key] = fr
+	return fr, nil
+}
+
+// Unpin releases a frame returned by Fetch. dirty marks its data as changed by the caller
+func (p *Pool) Unpin(fr *Frame, dirty bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if dirty {
+		fr.dirty = true
+	}
+	fr.pins--
+	if fr.pins == 0 && p.frames[fr.key] == fr {
+		fr.elem = p.unpinned.PushBack(fr)
+	}
+}
+
+// Write replaces page num of f with data. The page is written back to f by Flush
+func (p *Pool) Write(f File, num int64, data []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	key := frameKey{file: f, num: num}
+	fr, ok := p.frames[key]
+	if !ok {
+		if err := p.makeRoom(); err != nil {
+			return err
+		}
+		fr = &Frame{key: key, data: make([]byte, p.pageSize)}
+		p.frames[key] = fr
+	} else if fr.elem != nil {
+		p.unpinned.Remove(fr.elem)
+	}
+	copy(fr.data, data)
+	fr.dirty = true
+	if fr.pins == 0 {
+		fr.elem = p.unpinned.PushBack(fr)
+	}
+	return nil
+}
+
+// Flush writes every dirty page back to its file
+func (p *Pool) Flush() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, fr := range p.frames {
+		if err := p.writeBack(fr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FlushFile writes the dirty pages of f back to it
+func (p *Pool) FlushFile(f File) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for key, fr := range p.frames {
+		if key.file != f {
+			continue
+		}
+		if err := p.writeBack(fr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Discard drops every dirty page, losing its changes. Used when the operation that changed them is rolled back
+func (p *Pool) Discard() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, fr := range p.frames {
+		if fr.dirty {
+			p.remove(fr)
+		}
+	}
+}
+
+// Drop drops every page of f, dirty ones included. Used when f is closed or changed underneath the pool
+func (p *Pool) Drop(f File) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for key, fr := range p.frames {
+		if key.file == f {
+			p.remove(fr)
+		}
+	}
+}
+
+// Stats returns what the pool did so far
+func (p *Pool) Stats() Stats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	stats := p.stats
+	stats.Pages = len(p.frames)
+	for _, fr := range p.frames {
+		if fr.dirty {
+			stats.Dirty++
+		}
+	}
+	return stats
+}
+
+func (p *Pool) pin(fr *Frame) {
+	if fr.elem != nil {
+		p.unpinned.Remove(fr.elem)
+		fr.elem = nil
+	}
+	fr.pins++
+}
+
+// makeRoom evicts unpinned frames until one more fits in the budget, writing back dirty ones
+func (p *Pool) makeRoom() error {
+	for len(p.frames) >= p.capacity {
+		elem := p.unpinned.Front()
+		if elem == nil {
+			return nil
+		}
+		fr := elem.Value.(*Frame)
+		if err := p.writeBack(fr); err != nil {
+			return err
+		}
+		p.remove(fr)
+		p.stats.Evictions++
+	}
+	return nil
+}
+
+func (p *Pool) writeBack(fr *Frame) error {
+	if !fr.dirty {
+		return nil
+	}
+	if err := fr.key.file.WritePage(fr.key.num, fr.data); err != nil {
+		return err
+	}
+	fr.dirty = false
+	p.stats.WriteBacks++
+	return nil
+}
+
+func (p *Pool) remove(fr *Frame) {
+	if fr.elem != nil {
+		p.unpinned.Remove(fr.elem)
+		fr.elem = nil
+	}
+	delete(p.frames, fr.key)
+}
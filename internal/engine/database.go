@@ -4,26 +4,41 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"simple-database/internal/engine/buffer"
 	"simple-database/internal/engine/table"
 	"simple-database/internal/engine/table/column"
+	"simple-database/internal/engine/table/page"
+	"simple-database/internal/engine/wal"
 	platformerror "simple-database/internal/platform/error"
 	"simple-database/internal/platform/helper"
+	"sort"
 	"strings"
 )
 
 const (
 	BaseDir = "./data"
+	// DefaultBufferPoolSize is the number of bytes of pages a database caches
+	DefaultBufferPoolSize = 8 << 20
 )
 
 type Tables map[string]*table.Table
 
 type Database struct {
-	name   string
-	path   string
-	Tables Tables
+	name string
+	path string
+	log  *wal.Log
+	pool *buffer.Pool
+	// sortMemory is the sort memory of every table, see table.Table.SetSortMemory
+	sortMemory int64
+	Tables     Tables
 }
 
 func CreateDatabase(name string) (*Database, error) {
+	return CreateDatabaseWithBufferPool(name, DefaultBufferPoolSize)
+}
+
+// CreateDatabaseWithBufferPool creates a database caching at most bufferPoolSize bytes of pages
+func CreateDatabaseWithBufferPool(name string, bufferPoolSize int64) (*Database, error) {
 	if exists(name) {
 		return nil, platformerror.NewStackTraceError(fmt.Sprintf("Database %s already existed", name),
 			platformerror.DatabaseAlreadyExistsErrorCode)
@@ -31,18 +46,42 @@ func CreateDatabase(name string) (*Database, error) {
 	if err := os.MkdirAll(path(name), 0644); err != nil {
 		return nil, platformerror.NewStackTraceError(err.Error(), platformerror.OpenFileErrorCode)
 	}
+	log, err := wal.Open(path(name))
+	if err != nil {
+		return nil, err
+	}
 	return &Database{
-		name:   name,
-		path:   path(name),
-		Tables: make(Tables),
+		name:       name,
+		path:       path(name),
+		log:        log,
+		pool:       buffer.NewPool(bufferPoolSize, page.Size),
+		sortMemory: table.DefaultSortMemory,
+		Tables:     make(Tables),
 	}, nil
 }
 
 func NewDatabase(name string) (*Database, error) {
+	return NewDatabaseWithBufferPool(name, DefaultBufferPoolSize)
+}
+
+// NewDatabaseWithBufferPool opens or creates a database caching at most bufferPoolSize bytes of pages
+func NewDatabaseWithBufferPool(name string, bufferPoolSize int64) (*Database, error) {
 	if !exists(name) {
-		return CreateDatabase(name)
+		return CreateDatabaseWithBufferPool(name, bufferPoolSize)
+	}
+	log, err := wal.Open(path(name))
+	if err != nil {
+		return nil, err
+	}
+	// Recovery has to run before the tables are read, so they are loaded from consistent files
+	if err = table.RecoverVacuum(path(name)); err != nil {
+		return nil, err
 	}
-	db := &Database{name: name, path: path(name)}
+	if err = log.Recover(); err != nil {
+		return nil, err
+	}
+	db := &Database{name: name, path: path(name), log: log, pool: buffer.NewPool(bufferPoolSize, page.Size),
+		sortMemory: table.DefaultSortMemory}
 	tables, err := db.readTables()
 	if err != nil {
 		return nil, err
@@ -51,15 +90,48 @@ func NewDatabase(name string) (*Database, error) {
 	return db, nil
 }
 
+type TransactionStatement string
+
+const (
+	TransactionBegin    TransactionStatement = "BEGIN"
+	TransactionCommit   TransactionStatement = "COMMIT"
+	TransactionRollback TransactionStatement = "ROLLBACK"
+)
+
+type TransactionCommand struct {
+	Statement TransactionStatement
+}
+
 type DropTableCommand struct {
 	TableName string
 }
 
+type VacuumCommand struct {
+	TableName string
+}
+
 type CreateTableCommand struct {
 	TableName string
 	Columns   table.Columns
 }
 
+type CreateIndexCommand struct {
+	IndexName string
+	TableName string
+	// ColumnNames are the indexed columns in the order of the index
+	ColumnNames []string
+	// IncludeColumnNames are the columns whose values are stored in the index without being indexed
+	IncludeColumnNames []string
+	Unique             bool
+}
+
+// DropIndexCommand drops the index called IndexName. TableName may be left empty when a single table has an
+// index of that name
+type DropIndexCommand struct {
+	IndexName string
+	TableName string
+}
+
 func (db *Database) CreateTable(command CreateTableCommand) (*table.Table, error) {
 	dbPath := filepath.Join(path(db.name), command.TableName) + table.FileExtension
 	if _, err := os.Open(dbPath); err == nil {
@@ -75,11 +147,12 @@ func (db *Database) CreateTable(command CreateTableCommand) (*table.Table, error
 		return nil, err
 	}
 
-	t, err := table.NewTableWithColumns(f, command.Columns)
+	t, err := table.NewTableWithColumns(f, command.Columns, db.log, db.pool)
 
 	if err != nil {
 		return nil, err
 	}
+	t.SetSortMemory(db.sortMemory)
 
 	db.Tables[command.TableName] = t
 	return t, nil
@@ -90,6 +163,10 @@ func (db *Database) DropTable(command DropTableCommand) error {
 		return platformerror.NewStackTraceError(fmt.Sprintf("Database %s not existed", command.TableName),
 			platformerror.DatabaseNotExistsErrorCode)
 	}
+	// Pending log records must not outlive the files they refer to
+	if err := db.log.Checkpoint(); err != nil {
+		return err
+	}
 	if err := db.Tables[command.TableName].DropIndexes(); err != nil {
 		return err
 	}
@@ -101,6 +178,163 @@ func (db *Database) DropTable(command DropTableCommand) error {
 	return nil
 }
 
+// CreateIndex builds a new index on columns of an existing table from its records
+func (db *Database) CreateIndex(command CreateIndexCommand) error {
+	if db.log.InTransaction() {
+		return platformerror.NewStackTraceError("CREATE INDEX is not allowed inside a transaction", platformerror.TransactionErrorCode)
+	}
+	t, ok := db.Tables[command.TableName]
+	if !ok {
+		return platformerror.NewStackTraceError(fmt.Sprintf("Table %s not existed", command.TableName),
+			platformerror.TableNotExistsErrorCode)
+	}
+	return t.CreateIndex(table.IndexDefinition{Name: command.IndexName, Columns: command.ColumnNames,
+		Include: command.IncludeColumnNames, Unique: command.Unique})
+}
+
+// DropIndex removes an index created with CreateIndex, the table and its other indexes are kept
+func (db *Database) DropIndex(command DropIndexCommand) error {
+	if db.log.InTransaction() {
+		return platformerror.NewStackTraceError("DROP INDEX is not allowed inside a transaction", platformerror.TransactionErrorCode)
+	}
+	if command.TableName != "" {
+		t, ok := db.Tables[command.TableName]
+		if !ok {
+			return platformerror.NewStackTraceError(fmt.Sprintf("Table %s not existed", command.TableName),
+				platformerror.TableNotExistsErrorCode)
+		}
+		return t.DropIndex(command.IndexName)
+	}
+
+	owners := make([]string, 0)
+	for name, t := range db.Tables {
+		if t.HasIndex(command.IndexName) {
+			owners = append(owners, name)
+		}
+	}
+	switch len(owners) {
+	case 0:
+		return platformerror.NewStackTraceError(fmt.Sprintf("Index %s not existed", command.IndexName),
+			platformerror.IndexNotExistsErrorCode)
+	case 1:
+		return db.Tables[owners[0]].DropIndex(command.IndexName)
+	default:
+		sort.Strings(owners)
+		return platformerror.NewStackTraceError(fmt.Sprintf("Index %s exists in tables %s, name the table with DROP INDEX %s ON <table>",
+			command.IndexName, strings.Join(owners, ", "), command.IndexName), platformerror.IndexNotExistsErrorCode)
+	}
+}
+
+// Select runs a select on its table, or on the tables it joins through SelectJoin, and returns all of its rows at
+// once. A select naming its table with an alias is run as a join too. Query returns the rows one at a time instead
+func (db *Database) Select(command table.SelectCommand) (*table.SelectResult, error) {
+	t, ok := db.Tables[command.TableName]
+	if !ok {
+		return nil, platformerror.NewStackTraceError(fmt.Sprintf("Table %s not existed", command.TableName),
+			platformerror.TableNotExistsErrorCode)
+	}
+	if len(command.Joins) > 0 || command.TableAlias != "" {
+		return table.SelectJoin(db.Tables, command)
+	}
+	return t.Select(command)
+}
+
+// Query returns the operators of a select, as Select runs it, so its rows can be pulled one at a time, see
+// table.Operator. The result tells how the rows are found once the operators are closed
+func (db *Database) Query(command table.SelectCommand) (table.Operator, *table.SelectResult, error) {
+	t, ok := db.Tables[command.TableName]
+	if !ok {
+		return nil, nil, platformerror.NewStackTraceError(fmt.Sprintf("Table %s not existed", command.TableName),
+			platformerror.TableNotExistsErrorCode)
+	}
+	if len(command.Joins) > 0 || command.TableAlias != "" {
+		return table.QueryJoin(db.Tables, command)
+	}
+	return t.Query(command)
+}
+
+// Vacuum compacts a table in one go. PrepareVacuum and FinishVacuum do the same in two steps, so selects can
+// keep running while the table is copied
+func (db *Database) Vacuum(command VacuumCommand) (*table.VacuumResult, error) {
+	v, err := db.PrepareVacuum(command)
+	if err != nil {
+		return nil, err
+	}
+	return db.FinishVacuum(v)
+}
+
+// PrepareVacuum copies the live records of a table and rebuilds its indexes next to the table files
+func (db *Database) PrepareVacuum(command VacuumCommand) (*table.Vacuum, error) {
+	if db.log.InTransaction() {
+		return nil, platformerror.NewStackTraceError("VACUUM is not allowed inside a transaction", platformerror.TransactionErrorCode)
+	}
+	t, ok := db.Tables[command.TableName]
+	if !ok {
+		return nil, platformerror.NewStackTraceError(fmt.Sprintf("Table %s not existed", command.TableName),
+			platformerror.TableNotExistsErrorCode)
+	}
+	return t.PrepareVacuum()
+}
+
+// FinishVacuum replaces the table files with the copy made by PrepareVacuum
+func (db *Database) FinishVacuum(v *table.Vacuum) (*table.VacuumResult, error) {
+	if db.log.InTransaction() {
+		v.Abort()
+		return nil, platformerror.NewStackTraceError("VACUUM is not allowed inside a transaction", platformerror.TransactionErrorCode)
+	}
+	return v.Table().FinishVacuum(v)
+}
+
+// Begin starts a transaction that spans every following statement until Commit or Rollback. Statements run
+// inside it keep their own atomicity, so a failing statement is undone without ending the transaction
+func (db *Database) Begin() error {
+	if db.log.InTransaction() {
+		return platformerror.NewStackTraceError("A transaction is already in progress", platformerror.TransactionErrorCode)
+	}
+	db.log.Begin()
+	return nil
+}
+
+func (db *Database) Commit() error {
+	if !db.log.InTransaction() {
+		return platformerror.NewStackTraceError("No transaction in progress", platformerror.TransactionErrorCode)
+	}
+	return db.log.Commit()
+}
+
+// Rollback undoes every change made since Begin in all tables and their indexes
+func (db *Database) Rollback() error {
+	if !db.log.InTransaction() {
+		return platformerror.NewStackTraceError("No transaction in progress", platformerror.TransactionErrorCode)
+	}
+	if err := db.log.Rollback(); err != nil {
+		return err
+	}
+	for _, t := range db.Tables {
+		t.ResetCache()
+	}
+	return nil
+}
+
+// BufferPoolStats returns what the buffer pool of the database did so far
+func (db *Database) BufferPoolStats() buffer.Stats {
+	return db.pool.Stats()
+}
+
+// SetSortMemory sets the number of bytes of rows a select, or of entries an index build, sorts in memory before
+// writing them to run files, for every table of the database and those created later
+func (db *Database) SetSortMemory(bytes int64) {
+	db.sortMemory = bytes
+	for _, t := range db.Tables {
+		t.SetSortMemory(bytes)
+	}
+}
+
+// InTransaction reports whether a transaction started by Begin is running
+func (db *Database) InTransaction() bool {
+	return db.log.InTransaction()
+}
+
 func (db *Database) readTables() (Tables, error) {
 	tablePaths, err := os.ReadDir(path(db.name))
 	if err != nil {
@@ -114,7 +348,8 @@ func (db *Database) readTables() (Tables, error) {
 			return nil, platformerror.NewStackTraceError(err.Error(), platformerror.OpenFileErrorCode)
 		}
 
-		if strings.HasSuffix(v.Name(), "_idx.bin") || strings.HasSuffix(v.Name(), "_idx.bin.del") {
+		if strings.HasSuffix(v.Name(), "_idx.bin") || strings.HasSuffix(v.Name(), "_idx.bin.del") ||
+			!strings.HasSuffix(v.Name(), table.FileExtension) {
 			continue
 		}
 
@@ -123,10 +358,11 @@ func (db *Database) readTables() (Tables, error) {
 			return nil, platformerror.NewStackTraceError(err.Error(), platformerror.OpenFileErrorCode)
 		}
 
-		t, err := table.NewTable(f)
+		t, err := table.NewTable(f, db.log, db.pool)
 		if err != nil {
 			return nil, err
 		}
+		t.SetSortMemory(db.sortMemory)
 
 		tables = append(tables, t)
 	}
@@ -177,10 +413,17 @@ func validateColumnsConstraint(columns table.Columns) error {
 
 func (db *Database) Close() error {
 	var e error
+	// Flush the data files while they are still registered in the log
+	if err := db.log.Checkpoint(); err != nil {
+		e = err
+	}
 	for _, t := range db.Tables {
 		if err := t.Close(); err != nil {
 			e = err
 		}
 	}
+	if err := db.log.Close(); err != nil {
+		e = err
+	}
 	return e
 }
@@ -0,0 +1,619 @@
+package table
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	tableparser "simple-database/internal/engine/table/column/parser"
+	"simple-database/internal/platform/datatype"
+	platformerror "simple-database/internal/platform/error"
+	"simple-database/internal/platform/evaluator"
+	"slices"
+	"strconv"
+)
+
+const (
+	AggregateCount = "COUNT"
+	AggregateSum   = "SUM"
+	AggregateAvg   = "AVG"
+	AggregateMin   = "MIN"
+	AggregateMax   = "MAX"
+)
+
+const (
+	// GroupTypeHash is reported when the groups were kept in a hash table
+	GroupTypeHash = "Hash"
+	// GroupTypeExternal is reported when the groups took more than the memory of a select and were written to runs
+	// on disk, then merged
+	GroupTypeExternal = "External"
+	// GroupTypeStream is reported when the rows were read in the order of the group columns and each group was
+	// computed once its rows were read
+	GroupTypeStream = "Stream"
+)
+
+// Aggregate is a function computed over the rows of every group. Column is empty for COUNT(*), Cast is the type
+// the values are converted to first, 0 to keep them as stored
+type Aggregate struct {
+	Function string
+	Column   string
+	Cast     byte
+}
+
+// Name returns the aggregate as it is written in a query, it names its value in the rows of a grouped select
+func (a Aggregate) Name() string {
+	arg := a.Column
+	if arg == "" {
+		arg = "*"
+	}
+	if name, ok := datatype.TypeName(a.Cast); ok {
+		arg = name + "(" + arg + ")"
+	}
+	return a.Function + "(" + arg + ")"
+}
+
+// addNumbers adds two numbers, integers are added as int64 and floats as float64. A nil sum is 0
+func addNumbers(sum, value any) (any, error) {
+	switch v := value.(type) {
+	case int32:
+		value = int64(v)
+	case float32:
+		value = float64(v)
+	case int64, float64:
+	default:
+		return nil, platformerror.NewStackTraceError(fmt.Sprintf("Cannot add %T values", value), platformerror.InvalidDataTypeErrorCode)
+	}
+	switch s := sum.(type) {
+	case nil:
+		return value, nil
+	case int64:
+		if v, ok := value.(int64); ok {
+			return s + v, nil
+		}
+		return float64(s) + value.(float64), nil
+	default:
+		if v, ok := value.(int64); ok {
+			return s.(float64) + float64(v), nil
+		}
+		return s.(float64) + value.(float64), nil
+	}
+}
+
+// aggregateState is what is known of an aggregate after some rows of a group. States of the same group computed
+// from different rows can be merged
+type aggregateState struct {
+	count    int64
+	sum      any
+	min, max any
+}
+
+// add adds the value of a row
+func (s *aggregateState) add(function string, value any) error {
+	s.count++
+	switch function {
+	case AggregateSum, AggregateAvg:
+		sum, err := addNumbers(s.sum, value)
+		if err != nil {
+			return err
+		}
+		s.sum = sum
+	case AggregateMin:
+		if s.min == nil || datatype.Compare(value, s.min, datatype.OperatorLess) {
+			s.min = value
+		}
+	case AggregateMax:
+		if s.max == nil || datatype.Compare(value, s.max, datatype.OperatorGreater) {
+			s.max = value
+		}
+	}
+	return nil
+}
+
+// merge adds the rows of another state of the same aggregate
+func (s *aggregateState) merge(function string, o aggregateState) error {
+	s.count += o.count
+	if o.sum != nil {
+		sum, err := addNumbers(s.sum, o.sum)
+		if err != nil {
+			return err
+		}
+		s.sum = sum
+	}
+	if o.min != nil && (s.min == nil || datatype.Compare(o.min, s.min, datatype.OperatorLess)) {
+		s.min = o.min
+	}
+	if o.max != nil && (s.max == nil || datatype.Compare(o.max, s.max, datatype.OperatorGreater)) {
+		s.max = o.max
+	}
+	return nil
+}
+
+// result returns the value of the aggregate, nil when it has no rows to be computed from
+func (s *aggregateState) result(function string) any {
+	switch function {
+	case AggregateCount:
+		return s.count
+	case AggregateSum:
+		return s.sum
+	case AggregateAvg:
+		switch sum := s.sum.(type) {
+		case int64:
+			return float64(sum) / float64(s.count)
+		case float64:
+			return sum / float64(s.count)
+		}
+		return nil
+	case AggregateMin:
+		return s.min
+	default:
+		return s.max
+	}
+}
+
+// group is a group of rows: the values of the group columns and the state of every aggregate
+type group struct {
+	key    []byte
+	values tableparser.RecordValue
+	states []aggregateState
+}
+
+// groupSize estimates the bytes a group takes in memory
+func groupSize(g *group) int64 {
+	return int64(len(g.key)) + 64 + int64(len(g.values))*48 + int64(len(g.states))*96
+}
+
+// stateColumn names a field of the state of the aggregate i in a run. Column names start with a letter
+func stateColumn(i int, field string) string {
+	return strconv.Itoa(i) + "." + field
+}
+
+// partialRecord encodes the group in a record so it can be written to a run, see rowSorter
+func (g *group) partialRecord() tableparser.RecordValue {
+	record := make(tableparser.RecordValue, len(g.values)+4*len(g.states))
+	for col, val := range g.values {
+		record[col] = val
+	}
+	for i, s := range g.states {
+		record[stateColumn(i, "count")] = s.count
+		for field, val := range map[string]any{"sum": s.sum, "min": s.min, "max": s.max} {
+			if val != nil {
+				record[stateColumn(i, field)] = val
+			}
+		}
+	}
+	return record
+}
+
+// groupFromPartialRecord decodes a group written to a run by partialRecord
+func groupFromPartialRecord(key []byte, groupBy []string, aggregates []Aggregate, record tableparser.RecordValue) (*group, error) {
+	g := &group{key: key, values: make(tableparser.RecordValue, len(groupBy)), states: make([]aggregateState, len(aggregates))}
+	for _, col := range groupBy {
+		g.values[col] = record[col]
+	}
+	for i := range aggregates {
+		count, ok := record[stateColumn(i, "count")].(int64)
+		if !ok {
+			return nil, platformerror.NewStackTraceError(fmt.Sprintf("Expected the count of aggregate %d in a run, got %T",
+				i, record[stateColumn(i, "count")]), platformerror.InvalidDataTypeErrorCode)
+		}
+		g.states[i] = aggregateState{count: count, sum: record[stateColumn(i, "sum")],
+			min: record[stateColumn(i, "min")], max: record[stateColumn(i, "max")]}
+	}
+	return g, nil
+}
+
+// aggregator computes the aggregates of the groups of the rows it is given. A group is returned as a record of its
+// group columns and aggregates
+type aggregator interface {
+	// add adds a row, it returns the group the row completes, nil when it completes none
+	add(row tableparser.RecordValue) (tableparser.RecordValue, error)
+	// next returns the groups not returned yet one at a time once every row was added, ok is false once there are
+	// none
+	next() (values tableparser.RecordValue, ok bool, err error)
+	close()
+	groupType() string
+}
+
+// groupAggregates computes the aggregates of groups, it is shared by the aggregators
+type groupAggregates struct {
+	groupBy    []string
+	orderBy    []OrderBy
+	aggregates []Aggregate
+}
+
+func newGroupAggregates(groupBy []string, aggregates []Aggregate) groupAggregates {
+	orderBy := make([]OrderBy, 0, len(groupBy))
+	for _, col := range groupBy {
+		orderBy = append(orderBy, OrderBy{Column: col})
+	}
+	return groupAggregates{groupBy: groupBy, orderBy: orderBy, aggregates: aggregates}
+}
+
+// newGroup returns the empty group of the row
+func (a *groupAggregates) newGroup(key []byte, row tableparser.RecordValue) *group {
+	g := &group{key: key, values: make(tableparser.RecordValue, len(a.groupBy)), states: make([]aggregateState, len(a.aggregates))}
+	for _, col := range a.groupBy {
+		g.values[col] = row[col]
+	}
+	return g
+}
+
+// update adds the values of the row to the states of the group. An aggregate of a column skips the rows without a
+// value in it, as the rows of an outer join missing a match
+func (a *groupAggregates) update(g *group, row tableparser.RecordValue) error {
+	for i, aggregate := range a.aggregates {
+		var value any
+		if aggregate.Column != "" {
+			if row[aggregate.Column] == nil {
+				continue
+			}
+			var err error
+			if value, err = datatype.Cast(row[aggregate.Column], aggregate.Cast); err != nil {
+				return err
+			}
+		}
+		if err := g.states[i].add(aggregate.Function, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// result returns the values of the group columns and the aggregates of the group
+func (a *groupAggregates) result(g *group) tableparser.RecordValue {
+	values := make(tableparser.RecordValue, len(g.values)+len(a.aggregates))
+	for col, val := range g.values {
+		values[col] = val
+	}
+	for i, aggregate := range a.aggregates {
+		values[aggregate.Name()] = g.states[i].result(aggregate.Function)
+	}
+	return values
+}
+
+// hashAggregator keeps the groups in a hash table by the key of their group columns, see sortKey. Once they take
+// more than its memory their states are sorted and written to a run, and the table is emptied. The runs are merged
+// at the end, the states of a group found in several runs are merged then. Groups are returned in key order
+type hashAggregator struct {
+	groupAggregates
+	memory int64
+	dir    string
+	name   string
+	groups map[string]*group
+	size   int64
+	// runs holds the states written to disk, it is nil until the groups first exceed the memory
+	runs *rowSorter
+	// sorted holds the groups left to return when no run was written, current the group being merged from the runs
+	sorted   []*group
+	current  *group
+	finished bool
+}
+
+func newHashAggregator(groupBy []string, aggregates []Aggregate, memory int64, dir, name string) *hashAggregator {
+	return &hashAggregator{groupAggregates: newGroupAggregates(groupBy, aggregates), memory: memory, dir: dir,
+		name: name, groups: make(map[string]*group)}
+}
+
+func (h *hashAggregator) add(row tableparser.RecordValue) (tableparser.RecordValue, error) {
+	key, err := sortKey(h.orderBy, row)
+	if err != nil {
+		return nil, err
+	}
+	g, ok := h.groups[string(key)]
+	if !ok {
+		g = h.newGroup(key, row)
+		h.groups[string(key)] = g
+		h.size += groupSize(g)
+	}
+	if err = h.update(g, row); err != nil {
+		return nil, err
+	}
+	if h.size > h.memory {
+		return nil, h.spill()
+	}
+	return nil, nil
+}
+
+// sortedGroups returns the groups in key order
+func (h *hashAggregator) sortedGroups() []*group {
+	groups := make([]*group, 0, len(h.groups))
+	for _, g := range h.groups {
+		groups = append(groups, g)
+	}
+	slices.SortFunc(groups, func(a, b *group) int {
+		return bytes.Compare(a.key, b.key)
+	})
+	return groups
+}
+
+// moveToRuns adds the states of the groups to the runs and empties the table
+func (h *hashAggregator) moveToRuns() error {
+	if h.runs == nil {
+		h.runs = newRowSorter(h.orderBy, h.memory, h.dir, h.name)
+	}
+	for _, g := range h.sortedGroups() {
+		if err := h.runs.add(tableparser.RawRecord{Record: g.partialRecord()}); err != nil {
+			return err
+		}
+	}
+	h.groups, h.size = make(map[string]*group), 0
+	return nil
+}
+
+// spill writes the states of the groups to a run
+func (h *hashAggregator) spill() error {
+	if err := h.moveToRuns(); err != nil {
+		return err
+	}
+	return h.runs.spill()
+}
+
+// finish prepares the groups to be returned once every row was added
+func (h *hashAggregator) finish() error {
+	h.finished = true
+	if h.runs == nil {
+		h.sorted = h.sortedGroups()
+		if len(h.sorted) == 0 && len(h.groupBy) == 0 {
+			// Aggregates over no rows without GROUP BY still make one group
+			h.sorted = []*group{h.newGroup(nil, nil)}
+		}
+		return nil
+	}
+	if err := h.moveToRuns(); err != nil {
+		return err
+	}
+	return h.runs.finish()
+}
+
+func (h *hashAggregator) next() (tableparser.RecordValue, bool, error) {
+	if !h.finished {
+		if err := h.finish(); err != nil {
+			return nil, false, err
+		}
+	}
+	if h.runs == nil {
+		if len(h.sorted) == 0 {
+			return nil, false, nil
+		}
+		g := h.sorted[0]
+		h.sorted = h.sorted[1:]
+		return h.result(g), true, nil
+	}
+
+	// The runs are merged in key order, so the states of a group come one after the other
+	for {
+		row, ok, err := h.runs.next()
+		if err != nil {
+			return nil, false, err
+		}
+		if !ok {
+			if h.current == nil {
+				return nil, false, nil
+			}
+			g := h.current
+			h.current = nil
+			return h.result(g), true, nil
+		}
+		key, err := sortKey(h.orderBy, row.Record)
+		if err != nil {
+			return nil, false, err
+		}
+		g, err := groupFromPartialRecord(key, h.groupBy, h.aggregates, row.Record)
+		if err != nil {
+			return nil, false, err
+		}
+		if h.current != nil && bytes.Equal(h.current.key, key) {
+			for i, aggregate := range h.aggregates {
+				if err = h.current.states[i].merge(aggregate.Function, g.states[i]); err != nil {
+					return nil, false, err
+				}
+			}
+			continue
+		}
+		previous := h.current
+		h.current = g
+		if previous != nil {
+			return h.result(previous), true, nil
+		}
+	}
+}
+
+func (h *hashAggregator) close() {
+	if h.runs != nil {
+		h.runs.close()
+	}
+}
+
+func (h *hashAggregator) groupType() string {
+	if h.runs != nil {
+		return GroupTypeExternal
+	}
+	return GroupTypeHash
+}
+
+// streamAggregator computes the groups of rows read in the order of their group columns: a group is complete once
+// a row of another group is read, so only one group is kept in memory
+type streamAggregator struct {
+	groupAggregates
+	current *group
+	// rows tells whether a row was added, aggregates over no rows without GROUP BY still make one group
+	rows bool
+	// finished is set once the last group was returned
+	finished bool
+}
+
+func newStreamAggregator(groupBy []string, aggregates []Aggregate) *streamAggregator {
+	return &streamAggregator{groupAggregates: newGroupAggregates(groupBy, aggregates)}
+}
+
+func (s *streamAggregator) add(row tableparser.RecordValue) (tableparser.RecordValue, error) {
+	s.rows = true
+	key, err := sortKey(s.orderBy, row)
+	if err != nil {
+		return nil, err
+	}
+	var complete tableparser.RecordValue
+	if s.current == nil || !bytes.Equal(s.current.key, key) {
+		if s.current != nil {
+			complete = s.result(s.current)
+		}
+		s.current = s.newGroup(key, row)
+	}
+	return complete, s.update(s.current, row)
+}
+
+func (s *streamAggregator) next() (tableparser.RecordValue, bool, error) {
+	if s.finished {
+		return nil, false, nil
+	}
+	s.finished = true
+	if s.current == nil {
+		if s.rows || len(s.groupBy) > 0 {
+			return nil, false, nil
+		}
+		s.current = s.newGroup(nil, nil)
+	}
+	values := s.result(s.current)
+	s.current = nil
+	return values, true, nil
+}
+
+func (s *streamAggregator) close() {}
+
+func (s *streamAggregator) groupType() string {
+	return GroupTypeStream
+}
+
+// ordered reports whether MIN and MAX can compare the values of a column of type typ
+func ordered(typ byte) bool {
+	switch typ {
+	case datatype.TypeInt32, datatype.TypeInt64, datatype.TypeFloat32, datatype.TypeFloat64, datatype.TypeString, datatype.TypeByte:
+		return true
+	default:
+		return false
+	}
+}
+
+// validateGroups checks the columns of a grouped select of rows holding the columns of types, by name, and returns
+// the names of the values of its groups. The selected columns must be group columns, HAVING may also name the
+// aggregates
+func validateGroups(command SelectCommand, types map[string]byte) ([]string, error) {
+	unknown := func(clause, col string) error {
+		return platformerror.NewStackTraceError(fmt.Sprintf("unknown column in %s: %s", clause, col), platformerror.ColumnViolationErrorCode)
+	}
+	for _, col := range command.GroupBy {
+		if _, ok := types[col]; !ok {
+			return nil, unknown("group by", col)
+		}
+	}
+	for _, col := range command.SelectColumns {
+		if !slices.Contains(command.GroupBy, col) {
+			return nil, platformerror.NewStackTraceError(fmt.Sprintf("column %s must be in group by to be selected with aggregates", col),
+				platformerror.ColumnViolationErrorCode)
+		}
+	}
+	names := slices.Clone(command.GroupBy)
+	for _, a := range command.Aggregates {
+		switch a.Function {
+		case AggregateCount, AggregateSum, AggregateAvg, AggregateMin, AggregateMax:
+		default:
+			return nil, platformerror.NewStackTraceError(fmt.Sprintf("unknown aggregate: %s", a.Function), platformerror.ColumnViolationErrorCode)
+		}
+		if a.Column == "" && a.Function != AggregateCount {
+			return nil, platformerror.NewStackTraceError(fmt.Sprintf("%s needs a column", a.Function), platformerror.ColumnViolationErrorCode)
+		}
+		typ, ok := types[a.Column]
+		if a.Column != "" && !ok {
+			return nil, unknown("aggregate", a.Column)
+		}
+		if (a.Function == AggregateMin || a.Function == AggregateMax) && a.Cast == 0 && !ordered(typ) {
+			return nil, platformerror.NewStackTraceError(fmt.Sprintf("%s cannot compare the values of column %s", a.Function, a.Column),
+				platformerror.ColumnViolationErrorCode)
+		}
+		names = append(names, a.Name())
+	}
+	if command.Having != nil {
+		for _, key := range command.Having.Keys() {
+			if !slices.Contains(names, key) {
+				return nil, unknown("having", key)
+			}
+		}
+	}
+	return names, nil
+}
+
+// having reports whether the group passes the HAVING clause of command. A group whose aggregate has no value, such
+// as the SUM of no rows, does not pass a clause reading it
+func having(command SelectCommand, values tableparser.RecordValue) bool {
+	if command.Having == nil {
+		return true
+	}
+	for _, key := range command.Having.Keys() {
+		if val, ok := values[key]; ok && val == nil {
+			return false
+		}
+	}
+	e := evaluator.SimpleEvaluator{}
+	return e.Eval(*command.Having, values)
+}
+
+// queryGroups returns the operators of a grouped select. The rows matching the where clause are grouped as they are
+// read in the order of their group columns when an index gives it, and in a hash table otherwise. The groups passing
+// HAVING are sorted by ORDER BY, unless the index gives that order too, projected and cut at the limit
+func (t *Table) queryGroups(command SelectCommand, selectResult *SelectResult) (Operator, error) {
+	names, err := validateGroups(command, t.columnTypes())
+	if err != nil {
+		return nil, err
+	}
+	rows, err := newSelectRows(command, names)
+	if err != nil {
+		return nil, err
+	}
+
+	// An index covers the select when it holds the group columns and the columns of the aggregates
+	read := slices.Clone(command.GroupBy)
+	for _, a := range command.Aggregates {
+		if a.Column != "" {
+			read = append(read, a.Column)
+		}
+	}
+	path := t.planAccess(command.Expression, read)
+
+	// Without group columns every row is in the one group, they can be read in any order
+	streamed := path
+	stream := len(command.GroupBy) == 0
+	if !stream {
+		streamed = t.orderedPath(path, t.readColumns(command.Expression, read), command.Limit != UnlimitedSize && len(command.OrderBy) == 0,
+			func(plan *indexPlan) bool {
+				return t.groupedBy(plan, command.GroupBy)
+			})
+		stream = streamed != nil
+	}
+
+	var agg aggregator
+	if stream {
+		path = streamed
+		agg = newStreamAggregator(command.GroupBy, command.Aggregates)
+	} else {
+		agg = newHashAggregator(command.GroupBy, command.Aggregates, t.sortMemory, filepath.Dir(t.file.Name()), t.Name)
+	}
+	selectResult.AccessType = path.accessType()
+	op := newHaving(newAggregateOperator(newFilter(t.scan(path, selectResult), command.Expression), agg, selectResult), command)
+	if len(command.OrderBy) > 0 {
+		if !rows.extend && stream && streamed != nil && streamed.scan != nil && t.orderedBy(streamed.scan, command.OrderBy) {
+			selectResult.SortType = SortTypeIndex
+		} else {
+			op = rows.sort(op, t, selectResult)
+		}
+	}
+	return rows.output(op), nil
+}
+
+// newHaving returns the operator returning the groups of child passing the HAVING clause of command, see having
+func newHaving(child Operator, command SelectCommand) Operator {
+	if command.Having == nil {
+		return child
+	}
+	return &Filter{child: child, match: func(values tableparser.RecordValue) bool {
+		return having(command, values)
+	}}
+}
@@ -3,12 +3,15 @@ package btree
 import (
 	"bytes"
 	"fmt"
+	"simple-database/internal/engine/buffer"
+	"simple-database/internal/engine/wal"
 	platformerror "simple-database/internal/platform/error"
-	"sort"
 
 	"github.com/hashicorp/go-msgpack/codec"
 )
 
+// BTree is a B+tree stored in the pages of a file. Keys and their values live in the leaves, which are linked in
+// key order, internal nodes only hold the keys separating their children. The root is always at page 0
 type BTree struct {
 	Pager  *Pager // The pager for the btree
 	Degree int    // The order of the tree
@@ -16,14 +19,24 @@ type BTree struct {
 
 type Key struct {
 	K []byte // The key
-	V []byte // The values
+	V []byte // The values, empty in internal nodes
+	// Overflow is the first page of the chain holding K and V once they were moved out of the node, see packNode
+	Overflow int64 `codec:",omitempty"`
 }
 
 type Node struct {
-	Page     int64   // The page number of the node
-	Keys     []*Key  // The keys in the node
+	Page int64 // The page number of the node
+	// Keys holds the keys and values of a leaf. In an internal node, Keys[i] separates Children[i], whose keys are
+	// less than it, from Children[i+1], whose keys are greater than or equal to it
+	Keys     []*Key
 	Children []int64 // The children of the node
 	Leaf     bool    // If the node is a leaf node
+	// Next and Prev are the pages of the leaves holding the following and preceding keys, 0 when there are none.
+	// A root leaf is the only leaf, so page 0 is never linked to
+	Next int64 `codec:",omitempty"`
+	Prev int64 `codec:",omitempty"`
+	// Version is the FormatVersion the tree was written with, only set on the root
+	Version int `codec:",omitempty"`
 }
 
 func (k *Key) String() string {
@@ -31,14 +44,33 @@ func (k *Key) String() string {
 }
 
 func (n *Node) String() string {
-	return fmt.Sprintf("page: %d, keys: %v, children: %v, leaf: %v", n.Page, n.Keys, n.Children, n.Leaf)
+	return fmt.Sprintf("page: %d, keys: %v, children: %v, leaf: %v, next: %d, prev: %d", n.Page, n.Keys, n.Children, n.Leaf, n.Next, n.Prev)
 }
 
 const DefaultDegree = 20
 
+// FormatVersion is the layout of the trees written by this package. Trees of an older version must be rebuilt
+// before they are used:
+//   - 0: values in every node, leaves not linked
+//   - 1: leaves linked, index keys encoded big-endian, which does not sort negative numbers and floats
+//   - 2: index keys sort as their values, no header page and no free-list
+//   - 3: pages follow a header page holding the free-list, see Pager
+const FormatVersion = 3
+
 // Open opens a new or existing BTree
 func Open(name string) (*BTree, error) {
-	pager, err := OpenPager(name)
+	return OpenWithLog(name, nil)
+}
+
+// OpenWithLog opens a new or existing BTree whose page writes are recorded in the write-ahead log
+func OpenWithLog(name string, log *wal.Log) (*BTree, error) {
+	return OpenWithPool(name, log, nil)
+}
+
+// OpenWithPool opens a new or existing BTree whose pages are cached in pool. Page writes reach the file once the
+// pool is flushed
+func OpenWithPool(name string, log *wal.Log, pool *buffer.Pool) (*BTree, error) {
+	pager, err := OpenPager(name, log, pool)
 	if err != nil {
 		return nil, err
 	}
@@ -51,8 +83,39 @@ func (b *BTree) Close() error {
 	return b.Pager.Close()
 }
 
+// Outdated reports whether the tree was written with an older FormatVersion or its file has no header page. An
+// empty file is not outdated
+func (b *BTree) Outdated() (bool, error) {
+	data, err := b.Pager.GetPage(0)
+	if err != nil {
+		if err.Error() == "EOF" {
+			return false, nil
+		}
+		return false, err
+	}
+	root, err := decodeNode(data)
+	if err != nil {
+		return false, err
+	}
+	return root.Version < FormatVersion || b.Pager.offset == 0, nil
+}
+
 // encodeNode encodes a node into a byte slice
 func encodeNode(n *Node) ([]byte, error) {
+	encoded, err := marshalNode(n)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(encoded) > PageCapacity {
+		return nil, platformerror.NewStackTraceError(fmt.Sprintf("Node size %d exceed %d", len(encoded), PageCapacity),
+			platformerror.BTreeWriteErrorCode)
+	}
+
+	return encoded, nil
+}
+
+func marshalNode(n *Node) ([]byte, error) {
 	// Create a new msgpack handle
 	handle := new(codec.MsgpackHandle)
 
@@ -62,12 +125,6 @@ func encodeNode(n *Node) ([]byte, error) {
 	if err != nil {
 		return nil, platformerror.NewStackTraceError(err.Error(), platformerror.BinaryWriteErrorCode)
 	}
-
-	if len(encoded) > PageSize {
-		return nil, platformerror.NewStackTraceError(fmt.Sprintf("Node size %d exceed %d", len(encoded), PageSize),
-			platformerror.BTreeWriteErrorCode)
-	}
-
 	return encoded, nil
 }
 
@@ -81,18 +138,12 @@ func (b *BTree) newNode(leaf bool) (*Node, error) {
 		Children: make([]int64, 0),
 	}
 
-	// we encode the new node
-	encodedNode, err := encodeNode(newNode)
+	newNode.Page, err = b.Pager.Allocate()
 	if err != nil {
 		return nil, err
 	}
 
-	newNode.Page, err = b.Pager.NextPageId()
-	if err != nil {
-		return nil, err
-	}
-
-	encodedNode, err = encodeNode(newNode)
+	encodedNode, err := encodeNode(newNode)
 	if err != nil {
 		return nil, err
 	}
@@ -137,18 +188,6 @@ func (b *BTree) getRoot() (*Node, error) {
 				Keys:     make([]*Key, 0),
 			}
 
-			// encode the root node
-			encodedRoot, err := encodeNode(rootNode)
-			if err != nil {
-				return nil, err
-			}
-
-			// write the root to the file
-			err = b.Pager.WriteTo(0, encodedRoot)
-			if err != nil {
-				return nil, err
-			}
-
 			if err := b.writeToDisk(rootNode); err != nil {
 				return nil, err
 			}
@@ -160,7 +199,7 @@ func (b *BTree) getRoot() (*Node, error) {
 	}
 
 	// decode the root
-	rootNode, err := decodeNode(root)
+	rootNode, err := b.unpackNode(root)
 	if err != nil {
 		return nil, err
 	}
@@ -173,11 +212,15 @@ func (b *BTree) Get(keyVal []byte) (Key, bool, error) {
 		return Key{}, false, platformerror.NewStackTraceError("keyVal cannot be nil", platformerror.BTreeReadErrorCode)
 	}
 
-	root, err := b.getRoot()
+	leaf, err := b.findLeaf(keyVal)
 	if err != nil {
 		return Key{}, false, err
 	}
-	return b.get(keyVal, root)
+	i, found := leaf.search(keyVal)
+	if !found {
+		return Key{}, false, nil
+	}
+	return *leaf.Keys[i], true, nil
 }
 
 func (n *Node) search(key []byte) (int, bool) {
@@ -198,23 +241,27 @@ func (n *Node) search(key []byte) (int, bool) {
 	return low, false
 }
 
-func (b *BTree) get(keyVal []byte, n *Node) (Key, bool, error) {
-	for cur := n; cur != nil; {
-		i, found := cur.search(keyVal)
-		if found {
-			return *cur.Keys[i], true, nil
-		}
-		if !cur.Leaf {
-			nextNode, err := b.readFromDisk(cur.Children[i])
-			if err != nil {
-				return Key{}, false, err
-			}
-			cur = nextNode
-		} else {
-			return Key{}, false, nil
+// childIndex returns the position of the child of an internal node whose keys may hold key
+func (n *Node) childIndex(key []byte) int {
+	i, found := n.search(key)
+	if found {
+		i++
+	}
+	return i
+}
+
+// findLeaf returns the leaf whose range holds key
+func (b *BTree) findLeaf(key []byte) (*Node, error) {
+	n, err := b.getRoot()
+	if err != nil {
+		return nil, err
+	}
+	for !n.Leaf {
+		if n, err = b.readFromDisk(n.Children[n.childIndex(key)]); err != nil {
+			return nil, err
 		}
 	}
-	return Key{}, false, nil
+	return n, nil
 }
 
 // From
@@ -236,17 +283,36 @@ func (b *BTree) splitChildAt(x *Node, i int32) error {
 	if err != nil {
 		return err
 	}
-	z.Keys = append(z.Keys, y.Keys[b.Degree:]...)
 
-	if !y.Leaf {
+	var separator *Key
+	if y.Leaf {
+		// The keys stay in the leaves, z is linked after y and its first key is copied up as the separator
+		z.Keys = append(z.Keys, y.Keys[b.Degree:]...)
+		y.Keys = y.Keys[:b.Degree]
+		separator = &Key{K: z.Keys[0].K}
+
+		z.Next, z.Prev, y.Next = y.Next, y.Page, z.Page
+		if z.Next != 0 {
+			next, err := b.readFromDisk(z.Next)
+			if err != nil {
+				return err
+			}
+			next.Prev = z.Page
+			if err := b.writeToDisk(next); err != nil {
+				return err
+			}
+		}
+	} else {
+		// The middle separator moves up
+		separator = y.Keys[b.Degree-1]
+		z.Keys = append(z.Keys, y.Keys[b.Degree:]...)
 		z.Children = append(z.Children, y.Children[b.Degree:]...)
+		y.Keys = y.Keys[:b.Degree-1]
 		y.Children = y.Children[:b.Degree]
 	}
 
 	x.Children = addElementAt(x.Children, int(i+1), z.Page)
-	x.Keys = addElementAt(x.Keys, int(i), y.Keys[b.Degree-1])
-
-	y.Keys = y.Keys[:b.Degree-1]
+	x.Keys = addElementAt(x.Keys, int(i), separator)
 
 	// encode y
 	if err := b.writeToDisk(y); err != nil {
@@ -333,7 +399,7 @@ func (b *BTree) Insert(keyData []byte, valueData []byte) error {
 			return err
 		}
 
-		// 2. Move data from Page 0 to the new page
+		// 2. Move data from Page 0 to the new page. A root leaf is not linked, so neither is its copy
 		oldRootCopy.Keys = root.Keys
 		oldRootCopy.Children = root.Children
 		if err := b.writeToDisk(oldRootCopy); err != nil {
@@ -375,7 +441,7 @@ func (b *BTree) insertNonFull(curNode *Node, keyData []byte, valueData []byte) e
 		return nil
 	}
 
-	i, _ := curNode.search(keyData)
+	i := curNode.childIndex(keyData)
 
 	nextNode, err := b.readFromDisk(curNode.Children[i])
 	if err != nil {
@@ -387,7 +453,7 @@ func (b *BTree) insertNonFull(curNode *Node, keyData []byte, valueData []byte) e
 			return err
 		}
 		// determine which of the two children is now the correct one to descend to
-		if bytes.Compare(keyData, curNode.Keys[i].K) > 0 {
+		if bytes.Compare(keyData, curNode.Keys[i].K) >= 0 {
 			i++
 		}
 
@@ -427,13 +493,17 @@ func (b *BTree) Remove(keyData []byte) error {
 			return err
 		}
 
-		// Copy child's CONTENT into root (page 0)
+		// Copy child's CONTENT into root (page 0). A leaf child is the only leaf, so it has no links to keep
 		root.Keys = child.Keys
 		root.Children = child.Children
 		root.Leaf = child.Leaf
+		root.Next, root.Prev = 0, 0
 
-		// Persist updated root
-		return b.writeToDisk(root)
+		// Persist updated root, the page of the child is no longer used
+		if err = b.writeToDisk(root); err != nil {
+			return err
+		}
+		return b.Pager.Free(child.Page)
 	}
 
 	return nil
@@ -445,256 +515,166 @@ func removeAt[T any](s []T, i int) []T {
 	return append(s[:i], s[i+1:]...)
 }
 
+// remove removes keyData from the subtree of curNode. Each child is given at least Degree keys before the
+// descent, so that removing a key from a leaf never leaves it with too few
 func (b *BTree) remove(curNode *Node, keyData []byte) error {
-	i, found := curNode.search(keyData)
-
-	if found {
-		// Case: node is a left
-		if curNode.Leaf {
-			curNode.Keys = removeAt(curNode.Keys, i)
-			if err := b.writeToDisk(curNode); err != nil {
-				return err
-			}
-		} else { // Case: node is an internal node
-			leftChild, err := b.readFromDisk(curNode.Children[i])
-			if err != nil {
-				return err
-			}
-			// Case: left child has at least t keys
-			if len(leftChild.Keys) >= b.Degree {
-				predecessorNode, err := b.getPredecessor(leftChild)
-				if err != nil {
-					return err
-				}
-				predecessorKey := predecessorNode.Keys[len(predecessorNode.Keys)-1]
-				curNode.Keys[i] = predecessorKey
-				if err := b.writeToDisk(curNode); err != nil {
-					return err
-				}
-
-				return b.remove(leftChild, predecessorKey.K)
-			}
-
-			rightChild, err := b.readFromDisk(curNode.Children[i+1])
-			if err != nil {
-				return err
-			}
-			// Case: right child has at least t keys
-			if len(rightChild.Keys) >= b.Degree {
-				successorNode, err := b.getSuccessor(rightChild)
-				if err != nil {
-					return err
-				}
-				successorKey := successorNode.Keys[0]
-				curNode.Keys[i] = successorKey
-				if err := b.writeToDisk(curNode); err != nil {
-					return err
-				}
-
-				return b.remove(rightChild, successorKey.K)
-			}
-
-			// Case: both children have t-1 keys
-			err = b.mergeChild(curNode, i)
-			if err != nil {
-				return err
-			}
-			mergedChild, err := b.readFromDisk(curNode.Children[i])
-			if err != nil {
-				return err
-			}
-			return b.remove(mergedChild, keyData)
-		}
-	} else {
-		if curNode.Leaf {
+	if curNode.Leaf {
+		i, found := curNode.search(keyData)
+		if !found {
 			return nil
 		}
-		child, err := b.readFromDisk(curNode.Children[i])
-		if err != nil {
+		removed := curNode.Keys[i]
+		curNode.Keys = removeAt(curNode.Keys, i)
+		if err := b.writeToDisk(curNode); err != nil {
 			return err
 		}
+		return b.freeKeys(removed)
+	}
 
-		// if a child has only t-1 keys, we need to ensure it has at least t keys
-		if len(child.Keys) == b.Degree-1 {
-			var leftSibling *Node = nil
-			if i > 0 {
-				leftSibling, err = b.readFromDisk(curNode.Children[i-1])
-				if err != nil {
-					return err
-				}
-			}
-
-			var rightSibling *Node = nil
-			if i < len(curNode.Children)-1 {
-				rightSibling, err = b.readFromDisk(curNode.Children[i+1])
-				if err != nil {
-					return err
-				}
-			}
-
-			// Case: borrow from left sibling
-			if leftSibling != nil && len(leftSibling.Keys) >= b.Degree {
-				// Move parent's separator key down to child (front)
-				child.Keys = addElementAt(child.Keys, 0, curNode.Keys[i-1])
-
-				// If internal node, move a rightmost child pointer
-				if !leftSibling.Leaf {
-					child.Children = addElementAt(child.Children, 0, leftSibling.Children[len(leftSibling.Children)-1])
-					leftSibling.Children = leftSibling.Children[:len(leftSibling.Children)-1]
-				}
-
-				// Move left sibling's rightmost key up to parent
-				curNode.Keys[i-1] = leftSibling.Keys[len(leftSibling.Keys)-1]
-
-				// Remove key from left sibling
-				leftSibling.Keys = leftSibling.Keys[:len(leftSibling.Keys)-1]
-				if err := b.writeToDisk(curNode); err != nil {
-					return err
-				}
-				if err := b.writeToDisk(leftSibling); err != nil {
-					return err
-				}
-				if err := b.writeToDisk(child); err != nil {
-					return err
-				}
-			} else if rightSibling != nil && len(rightSibling.Keys) >= b.Degree { // Case: borrow from the right sibling
-				// Move parent's separator key down to the child (append at the end)
-				child.Keys = append(child.Keys, curNode.Keys[i])
-
-				// Replace parent's separator with right sibling's first key
-				curNode.Keys[i] = rightSibling.Keys[0]
-
-				// Remove the borrowed key from the right sibling
-				rightSibling.Keys = rightSibling.Keys[1:]
-
-				// If internal node, move the first child pointer from the right sibling
-				if !rightSibling.Leaf {
-					child.Children = append(child.Children, rightSibling.Children[0])
-					rightSibling.Children = rightSibling.Children[1:]
-				}
-				if err := b.writeToDisk(curNode); err != nil {
-					return err
-				}
-				if err := b.writeToDisk(rightSibling); err != nil {
-					return err
-				}
-				if err := b.writeToDisk(child); err != nil {
-					return err
-				}
-			} else if leftSibling != nil { // Case: merge left siblings
-				err = b.mergeChild(curNode, i-1)
-				// Get the updated merged node
-				child, err = b.readFromDisk(curNode.Children[i-1])
-				if err != nil {
-					return err
-				}
-			} else if rightSibling != nil { // Case: merge right sibling
-				err = b.mergeChild(curNode, i)
-
-				child, err = b.readFromDisk(curNode.Children[i])
-				if err != nil {
-					return err
-				}
-			}
+	i := curNode.childIndex(keyData)
+	child, err := b.readFromDisk(curNode.Children[i])
+	if err != nil {
+		return err
+	}
+	if len(child.Keys) < b.Degree {
+		if child, err = b.fillChild(curNode, i, child); err != nil {
+			return err
 		}
-		return b.remove(child, keyData)
 	}
-
-	return nil
+	return b.remove(child, keyData)
 }
 
-func (b *BTree) mergeChild(x *Node, i int) error {
-	y, err := b.readFromDisk(x.Children[i]) // left child
-	if err != nil {
-		return err
+// fillChild gives child, the child i of x, one more key by borrowing from a sibling or by merging it with one,
+// and returns the node keys of child are in afterward
+func (b *BTree) fillChild(x *Node, i int, child *Node) (*Node, error) {
+	var leftSibling *Node = nil
+	var err error
+	if i > 0 {
+		leftSibling, err = b.readFromDisk(x.Children[i-1])
+		if err != nil {
+			return nil, err
+		}
 	}
-	z, err := b.readFromDisk(x.Children[i+1]) // right child
-	if err != nil {
-		return err
+
+	var rightSibling *Node = nil
+	if i < len(x.Children)-1 {
+		rightSibling, err = b.readFromDisk(x.Children[i+1])
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	// 1. Move the separator key from parent into y
-	y.Keys = append(y.Keys, x.Keys[i])
+	switch {
+	case leftSibling != nil && len(leftSibling.Keys) >= b.Degree: // Case: borrow from left sibling
+		last := len(leftSibling.Keys) - 1
+		replaced := make([]*Key, 0, 1)
+		if child.Leaf {
+			// Move the last key of the left sibling, it becomes the separator
+			child.Keys = addElementAt(child.Keys, 0, leftSibling.Keys[last])
+			replaced = append(replaced, x.Keys[i-1])
+			x.Keys[i-1] = &Key{K: child.Keys[0].K}
+		} else {
+			// Rotate through the parent: its separator comes down, the last key of the sibling goes up
+			child.Keys = addElementAt(child.Keys, 0, x.Keys[i-1])
+			child.Children = addElementAt(child.Children, 0, leftSibling.Children[len(leftSibling.Children)-1])
+			leftSibling.Children = leftSibling.Children[:len(leftSibling.Children)-1]
+			x.Keys[i-1] = leftSibling.Keys[last]
+		}
+		leftSibling.Keys = leftSibling.Keys[:last]
+		if err = b.writeNodes(x, leftSibling, child); err != nil {
+			return nil, err
+		}
+		return child, b.freeKeys(replaced...)
+	case rightSibling != nil && len(rightSibling.Keys) >= b.Degree: // Case: borrow from the right sibling
+		replaced := make([]*Key, 0, 1)
+		if child.Leaf {
+			// Move the first key of the right sibling, its new first key becomes the separator
+			child.Keys = append(child.Keys, rightSibling.Keys[0])
+			rightSibling.Keys = rightSibling.Keys[1:]
+			replaced = append(replaced, x.Keys[i])
+			x.Keys[i] = &Key{K: rightSibling.Keys[0].K}
+		} else {
+			child.Keys = append(child.Keys, x.Keys[i])
+			child.Children = append(child.Children, rightSibling.Children[0])
+			x.Keys[i] = rightSibling.Keys[0]
+			rightSibling.Keys = rightSibling.Keys[1:]
+			rightSibling.Children = rightSibling.Children[1:]
+		}
+		if err = b.writeNodes(x, rightSibling, child); err != nil {
+			return nil, err
+		}
+		return child, b.freeKeys(replaced...)
+	case leftSibling != nil: // Case: merge into the left sibling
+		return leftSibling, b.mergeChild(x, i-1, leftSibling, child)
+	case rightSibling != nil: // Case: merge the right sibling
+		return child, b.mergeChild(x, i, child, rightSibling)
+	}
+	return child, nil
+}
 
-	// 2. Append all keys from z into y
-	y.Keys = append(y.Keys, z.Keys...)
+func (b *BTree) writeNodes(nodes ...*Node) error {
+	for _, n := range nodes {
+		if err := b.writeToDisk(n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-	// 3. Append children from z if internal node
-	if !y.Leaf {
+// mergeChild moves the keys of z, the child i+1 of x, into y, the child i, and removes z from x. The page of z
+// is freed
+func (b *BTree) mergeChild(x *Node, i int, y, z *Node) error {
+	dropped := make([]*Key, 0, 1)
+	if y.Leaf {
+		dropped = append(dropped, x.Keys[i])
+		// Leaves hold every key, the separator is dropped and z is unlinked
+		y.Keys = append(y.Keys, z.Keys...)
+		y.Next = z.Next
+		if z.Next != 0 {
+			next, err := b.readFromDisk(z.Next)
+			if err != nil {
+				return err
+			}
+			next.Prev = y.Page
+			if err = b.writeToDisk(next); err != nil {
+				return err
+			}
+		}
+	} else {
+		// The separator comes down between the keys of y and z
+		y.Keys = append(y.Keys, x.Keys[i])
+		y.Keys = append(y.Keys, z.Keys...)
 		y.Children = append(y.Children, z.Children...)
 	}
 
-	// 4. Remove key i from parent x
 	x.Keys = removeAt(x.Keys, i)
-
-	// 5. Remove child z (i+1) from parent x
 	x.Children = removeAt(x.Children, i+1)
 
-	if err = b.writeToDisk(y); err != nil {
+	if err := b.writeNodes(y, x); err != nil {
 		return err
 	}
-	if err = b.writeToDisk(x); err != nil {
+	if err := b.freeKeys(dropped...); err != nil {
 		return err
 	}
-
-	return nil
-}
-
-func (b *BTree) getPredecessor(n *Node) (*Node, error) {
-	currentNode := n
-	for !currentNode.Leaf {
-		nextNode, err := b.readFromDisk(currentNode.Children[len(currentNode.Children)-1])
-		if err != nil {
-			return nil, err
-		}
-		currentNode = nextNode
-	}
-	return currentNode, nil
-}
-
-func (b *BTree) getSuccessor(n *Node) (*Node, error) {
-	currentNode := n
-	for !currentNode.Leaf {
-		nextNode, err := b.readFromDisk(currentNode.Children[0])
-		if err != nil {
-			return nil, err
-		}
-		currentNode = nextNode
-	}
-	return currentNode, nil
+	return b.Pager.Free(z.Page)
 }
 
+// Size returns the number of keys of the tree
 func (b *BTree) Size() (int64, error) {
-	root, err := b.getRoot()
+	c := b.NewCursor()
+	var count int64
+	ok, err := c.First()
+	for ; ok && err == nil; ok, err = c.Next() {
+		count++
+	}
 	if err != nil {
 		return 0, err
 	}
-	stack := []*Node{root}
-	count := 0
-
-	for len(stack) > 0 {
-		// pop
-		n := stack[len(stack)-1]
-		stack = stack[:len(stack)-1]
-
-		count += len(n.Keys)
-
-		// push children
-		if !n.Leaf {
-			for _, childID := range n.Children {
-				child, err := b.readFromDisk(childID)
-				if err != nil {
-					return 0, err
-				}
-				stack = append(stack, child)
-			}
-		}
-	}
-	return int64(count), nil
+	return count, nil
 }
 
 func (b *BTree) writeToDisk(n *Node) error {
-	encodedCurNode, err := encodeNode(n)
+	encodedCurNode, err := b.packNode(n)
 	if err != nil {
 		return err
 	}
@@ -710,298 +690,75 @@ func (b *BTree) readFromDisk(pageId int64) (*Node, error) {
 	if err != nil {
 		return nil, err
 	}
-	return decodeNode(data)
+	return b.unpackNode(data)
 }
 
-func (b *BTree) LessThan(keyData []byte) ([]Key, error) {
-	root, err := b.getRoot()
-	if err != nil {
-		return nil, err
+// scan returns the keys in order from the first one greater than or equal to from, or from the first key when
+// from is nil, until while is false
+func (b *BTree) scan(from []byte, while func(k []byte) bool) ([]Key, error) {
+	c := b.NewCursor()
+	var ok bool
+	var err error
+	if from == nil {
+		ok, err = c.First()
+	} else {
+		ok, err = c.Seek(from)
 	}
-	return b.lessThan(keyData, root)
-}
-
-func (b *BTree) lessThan(keyData []byte, n *Node) ([]Key, error) {
-	// Currently, in the next recursive work, a search is not needed as from previous pos, the b-tree guarantee that key[pos] < keyData
-	pos, _ := n.search(keyData)
 
 	keys := make([]Key, 0)
-
-	for i := 0; i < pos; i++ {
-		if !n.Leaf {
-			k, err := b.readFromDisk(n.Children[i])
-			if err != nil {
-				return nil, err
-			}
-			childKeys, err := b.lessThan(keyData, k)
-			if err != nil {
-				return nil, err
-			}
-			keys = append(keys, childKeys...)
-		}
-		keys = append(keys, *n.Keys[i])
-	}
-
-	if !n.Leaf {
-		k, err := b.readFromDisk(n.Children[pos])
-		if err != nil {
-			return nil, err
-		}
-		childKeys, err := b.lessThan(keyData, k)
-		if err != nil {
-			return nil, err
+	for ; ok && err == nil; ok, err = c.Next() {
+		k := c.Key()
+		if !while(k.K) {
+			break
 		}
-		keys = append(keys, childKeys...)
+		keys = append(keys, k)
 	}
-
-	return keys, nil
-}
-
-func (b *BTree) LessThanOrEqual(keyData []byte) ([]Key, error) {
-	root, err := b.getRoot()
 	if err != nil {
 		return nil, err
 	}
-	return b.lessThanOrEqual(keyData, root)
-}
-
-func (b *BTree) lessThanOrEqual(keyData []byte, n *Node) ([]Key, error) {
-	// Currently, in the next recursive work, a search is not needed as from previous pos, the b-tree guarantee that key[pos] < keyData
-	pos, found := n.search(keyData)
-	if found {
-		pos++
-	}
-
-	keys := make([]Key, 0)
-
-	for i := 0; i < pos; i++ {
-		if !n.Leaf {
-			k, err := b.readFromDisk(n.Children[i])
-			if err != nil {
-				return nil, err
-			}
-			childKeys, err := b.lessThanOrEqual(keyData, k)
-			if err != nil {
-				return nil, err
-			}
-			keys = append(keys, childKeys...)
-		}
-		keys = append(keys, *n.Keys[i])
-	}
-
-	if !n.Leaf {
-		k, err := b.readFromDisk(n.Children[pos])
-		if err != nil {
-			return nil, err
-		}
-		childKeys, err := b.lessThanOrEqual(keyData, k)
-		if err != nil {
-			return nil, err
-		}
-		keys = append(keys, childKeys...)
-	}
-
 	return keys, nil
 }
 
-func (b *BTree) GreaterThan(keyData []byte) ([]Key, error) {
-	root, err := b.getRoot()
-	if err != nil {
-		return nil, err
-	}
-
-	return b.greaterThan(keyData, root)
+func (b *BTree) LessThan(keyData []byte) ([]Key, error) {
+	return b.scan(nil, func(k []byte) bool {
+		return bytes.Compare(k, keyData) < 0
+	})
 }
 
-func (b *BTree) greaterThan(keyData []byte, n *Node) ([]Key, error) {
-	// Currently, in the next recursive work, a search is not needed as from previous pos, the b-tree guarantee that key[pos] ? keyData
-	pos, found := n.search(keyData)
-	if found {
-		pos++
-	}
-
-	keys := make([]Key, 0)
-
-	for i := pos; i < len(n.Keys); i++ {
-		if !n.Leaf {
-			k, err := b.readFromDisk(n.Children[i])
-			if err != nil {
-				return nil, err
-			}
-			childKeys, err := b.greaterThan(keyData, k)
-			if err != nil {
-				return nil, err
-			}
-			keys = append(keys, childKeys...)
-		}
-		keys = append(keys, *n.Keys[i])
-	}
-
-	if !n.Leaf {
-		k, err := b.readFromDisk(n.Children[len(n.Children)-1])
-		if err != nil {
-			return nil, err
-		}
-		childKeys, err := b.greaterThan(keyData, k)
-		if err != nil {
-			return nil, err
-		}
-		keys = append(keys, childKeys...)
-	}
-
-	return keys, nil
+func (b *BTree) LessThanOrEqual(keyData []byte) ([]Key, error) {
+	return b.scan(nil, func(k []byte) bool {
+		return bytes.Compare(k, keyData) <= 0
+	})
 }
 
-func (b *BTree) GreaterThanOrEqual(keyData []byte) ([]Key, error) {
-	root, err := b.getRoot()
+func (b *BTree) GreaterThan(keyData []byte) ([]Key, error) {
+	keys, err := b.GreaterThanOrEqual(keyData)
 	if err != nil {
 		return nil, err
 	}
-
-	return b.greaterThanOrEqual(keyData, root)
-}
-
-func (b *BTree) greaterThanOrEqual(keyData []byte, n *Node) ([]Key, error) {
-	// Currently, in the next recursive work, a search is not needed as from previous pos, the b-tree guarantee that key[pos] > keyData
-	pos, _ := n.search(keyData)
-
-	keys := make([]Key, 0)
-
-	for i := pos; i < len(n.Keys); i++ {
-		if !n.Leaf {
-			k, err := b.readFromDisk(n.Children[i])
-			if err != nil {
-				return nil, err
-			}
-			childKeys, err := b.greaterThanOrEqual(keyData, k)
-			if err != nil {
-				return nil, err
-			}
-			keys = append(keys, childKeys...)
-		}
-		keys = append(keys, *n.Keys[i])
+	if len(keys) > 0 && bytes.Equal(keys[0].K, keyData) {
+		keys = keys[1:]
 	}
-
-	if !n.Leaf {
-		k, err := b.readFromDisk(n.Children[len(n.Children)-1])
-		if err != nil {
-			return nil, err
-		}
-		childKeys, err := b.greaterThanOrEqual(keyData, k)
-		if err != nil {
-			return nil, err
-		}
-		keys = append(keys, childKeys...)
-	}
-
 	return keys, nil
 }
 
-// PrefixSearch finds all entries with keys starting with the given prefix
-func (b *BTree) prefixSearch(prefix []byte, n *Node) ([]Key, error) {
-	var results []Key
-
-	// 1. Find the starting index using binary search
-	idx := sort.Search(len(n.Keys), func(i int) bool {
-		return bytes.Compare(n.Keys[i].K, prefix) >= 0
+func (b *BTree) GreaterThanOrEqual(keyData []byte) ([]Key, error) {
+	return b.scan(keyData, func(k []byte) bool {
+		return true
 	})
-
-	for i := idx; i <= len(n.Keys); i++ {
-		// 2. Recursively search the child that comes before this entry
-		if !n.Leaf && i < len(n.Children) {
-			child, err := b.readFromDisk(n.Children[i])
-			if err != nil {
-				return nil, err
-			}
-			childResults, err := b.prefixSearch(prefix, child)
-			if err != nil {
-				return nil, err
-			}
-			results = append(results, childResults...)
-		}
-
-		// 3. Check if the current entry matches the prefix
-		if i < len(n.Keys) {
-			if bytes.HasPrefix(n.Keys[i].K, prefix) {
-				results = append(results, *n.Keys[i])
-			} else if bytes.Compare(n.Keys[i].K, prefix) > 0 {
-				// Since keys are sorted, if this key is > prefix and doesn't
-				// start with it, no later keys in this node will match.
-				return results, nil
-			}
-		}
-	}
-
-	return results, nil
 }
 
+// GetPrefix returns the keys starting with keyData
 func (b *BTree) GetPrefix(keyData []byte) ([]Key, error) {
-	root, err := b.getRoot()
-	if err != nil {
-		return nil, err
-	}
-
-	return b.prefixSearch(keyData, root)
-}
-
-func (n *Node) searchFirstNByte(key []byte, compareRange int) (int, bool) {
-	low, high := 0, len(n.Keys)
-	var mid int
-	for low < high {
-		mid = (low + high) / 2
-		cmp := bytes.Compare(key[:compareRange], n.Keys[mid].K[:compareRange])
-		switch {
-		case cmp > 0:
-			low = mid + 1
-		case cmp < 0:
-			high = mid
-		default:
-			return mid, true
-		}
-	}
-	return low, false
+	return b.scan(keyData, func(k []byte) bool {
+		return bytes.HasPrefix(k, keyData)
+	})
 }
 
+// LessThanFirstNByte returns the keys whose first n bytes are less than the first n bytes of keyData
 func (b *BTree) LessThanFirstNByte(keyData []byte, n int) ([]Key, error) {
-	root, err := b.getRoot()
-	if err != nil {
-		return nil, err
-	}
-	return b.lessThanFirstNByte(keyData, n, root)
-}
-
-func (b *BTree) lessThanFirstNByte(keyData []byte, compareRange int, n *Node) ([]Key, error) {
-	// Currently, in the next recursive work, a search is not needed as from previous pos, the b-tree guarantee that key[pos] < keyData
-	pos, _ := n.searchFirstNByte(keyData, compareRange)
-
-	keys := make([]Key, 0)
-
-	for i := 0; i < pos; i++ {
-		if !n.Leaf {
-			k, err := b.readFromDisk(n.Children[i])
-			if err != nil {
-				return nil, err
-			}
-			childKeys, err := b.lessThanFirstNByte(keyData, compareRange, k)
-			if err != nil {
-				return nil, err
-			}
-			keys = append(keys, childKeys...)
-		}
-		keys = append(keys, *n.Keys[i])
-	}
-
-	if !n.Leaf {
-		k, err := b.readFromDisk(n.Children[pos])
-		if err != nil {
-			return nil, err
-		}
-		childKeys, err := b.lessThanFirstNByte(keyData, compareRange, k)
-		if err != nil {
-			return nil, err
-		}
-		keys = append(keys, childKeys...)
-	}
-
-	return keys, nil
+	bound := keyData[:min(n, len(keyData))]
+	return b.scan(nil, func(k []byte) bool {
+		return bytes.Compare(k[:min(n, len(k))], bound) < 0
+	})
 }
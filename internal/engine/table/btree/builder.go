@@ -0,0 +1,219 @@
+package btree
+
+import (
+	"bytes"
+	"math"
+	platformerror "simple-database/internal/platform/error"
+)
+
+// DefaultFillFactor is the share of the largest node size filled by a Builder, leaving room for later inserts
+const DefaultFillFactor = 0.9
+
+// Builder writes a tree bottom-up from keys added in increasing order. Leaves are filled one after the other and
+// every finished node adds its smallest key to its parent level, so each page is written once instead of
+// descending the tree for every key as Insert does
+type Builder struct {
+	tree *BTree
+	// leafKeys and internalChildren are the sizes of the nodes written before the last one of their level
+	leafKeys         int
+	internalChildren int
+	levels           []*level
+	last             []byte
+}
+
+// level holds the last two nodes of a level of a Builder, they are written once a third one is started so that
+// the last one can be balanced with its sibling when the build is finished
+type level struct {
+	prev, cur *pendingNode
+	// written is set once a node of the level was written, the level is then below the root
+	written bool
+}
+
+// pendingNode is a node not written yet with the smallest key of its subtree, its separator in the parent
+type pendingNode struct {
+	node *Node
+	low  []byte
+}
+
+// NewBuilder returns a builder of a tree in the file name, which must be empty. Nodes are filled to fill, a share
+// of their largest size, but never below the smallest size of the nodes of a tree
+func NewBuilder(name string, fill float64) (*Builder, error) {
+	tree, err := Open(name)
+	if err != nil {
+		return nil, err
+	}
+	if tree.Pager.Count() != 0 {
+		_ = tree.Close()
+		return nil, platformerror.NewStackTraceError("A tree can only be built in an empty file", platformerror.BTreeWriteErrorCode)
+	}
+	// The root is written last, at page 0
+	if _, err = tree.Pager.Allocate(); err != nil {
+		_ = tree.Close()
+		return nil, err
+	}
+
+	d := tree.Degree
+	return &Builder{
+		tree:             tree,
+		leafKeys:         min(max(int(math.Round(fill*float64(2*d-1))), d-1, 1), 2*d-1),
+		internalChildren: min(max(int(math.Round(fill*float64(2*d))), d, 2), 2*d),
+	}, nil
+}
+
+// Add adds a key and its value after the keys added before, which must all be less than it
+func (b *Builder) Add(key []byte, value []byte) error {
+	if key == nil {
+		return platformerror.NewStackTraceError("keyData cannot be nil", platformerror.BTreeWriteErrorCode)
+	}
+	if b.last != nil && bytes.Compare(key, b.last) <= 0 {
+		return platformerror.NewStackTraceError("Keys must be added in increasing order", platformerror.BTreeWriteErrorCode)
+	}
+	b.last = key
+
+	if len(b.levels) == 0 {
+		b.levels = append(b.levels, &level{})
+	}
+	lv := b.levels[0]
+	if lv.cur != nil && len(lv.cur.node.Keys) >= b.leafKeys {
+		if err := b.shift(0); err != nil {
+			return err
+		}
+	}
+	if lv.cur == nil {
+		lv.cur = &pendingNode{node: &Node{Leaf: true, Keys: make([]*Key, 0), Children: make([]int64, 0)}, low: key}
+	}
+	lv.cur.node.Keys = append(lv.cur.node.Keys, &Key{K: key, V: value})
+	return nil
+}
+
+// addChild adds a node written at page to level l, low is the smallest key below it
+func (b *Builder) addChild(l int, low []byte, page int64) error {
+	if len(b.levels) == l {
+		b.levels = append(b.levels, &level{})
+	}
+	lv := b.levels[l]
+	if lv.cur != nil && len(lv.cur.node.Children) >= b.internalChildren {
+		if err := b.shift(l); err != nil {
+			return err
+		}
+	}
+	if lv.cur == nil {
+		lv.cur = &pendingNode{node: &Node{Keys: make([]*Key, 0), Children: []int64{page}}, low: low}
+		return nil
+	}
+	lv.cur.node.Keys = append(lv.cur.node.Keys, &Key{K: low})
+	lv.cur.node.Children = append(lv.cur.node.Children, page)
+	return nil
+}
+
+// shift writes the node before the full last node of level l, which then waits for the next one
+func (b *Builder) shift(l int) error {
+	lv := b.levels[l]
+	if lv.prev != nil {
+		if err := b.write(l, lv.prev, lv.cur); err != nil {
+			return err
+		}
+	}
+	lv.prev, lv.cur = lv.cur, nil
+	return nil
+}
+
+// write writes p, a node of level l followed by next when it is not nil, and adds it to the parent level.
+// Leaves are linked to the leaf after them, whose page is allocated before it is written
+func (b *Builder) write(l int, p, next *pendingNode) error {
+	var err error
+	if p.node.Page == 0 {
+		if p.node.Page, err = b.tree.Pager.Allocate(); err != nil {
+			return err
+		}
+	}
+	if p.node.Leaf && next != nil {
+		if next.node.Page == 0 {
+			if next.node.Page, err = b.tree.Pager.Allocate(); err != nil {
+				return err
+			}
+		}
+		p.node.Next = next.node.Page
+		next.node.Prev = p.node.Page
+	}
+	if err = b.tree.writeToDisk(p.node); err != nil {
+		return err
+	}
+	b.levels[l].written = true
+	return b.addChild(l+1, p.low, p.node.Page)
+}
+
+// balance gives the last node of a level at least the smallest size of a node, moving keys from the node before
+// it or merging both when they fit in one node
+func (b *Builder) balance(lv *level) {
+	prev, cur := lv.prev.node, lv.cur.node
+	d := b.tree.Degree
+	if cur.Leaf {
+		if len(cur.Keys) >= d-1 {
+			return
+		}
+		keys := append(prev.Keys, cur.Keys...)
+		if len(keys) <= 2*d-1 {
+			prev.Keys, lv.cur = keys, nil
+			return
+		}
+		half := len(keys) / 2
+		prev.Keys, cur.Keys = keys[:half:half], keys[half:]
+		lv.cur.low = cur.Keys[0].K
+		return
+	}
+
+	if len(cur.Children) >= d {
+		return
+	}
+	// The smallest key of cur separates the children of both nodes
+	keys := append(append(prev.Keys, &Key{K: lv.cur.low}), cur.Keys...)
+	children := append(prev.Children, cur.Children...)
+	if len(children) <= 2*d {
+		prev.Keys, prev.Children, lv.cur = keys, children, nil
+		return
+	}
+	half := len(children) / 2
+	prev.Keys, prev.Children = keys[:half-1:half-1], children[:half:half]
+	lv.cur.low = keys[half-1].K
+	cur.Keys, cur.Children = keys[half:], children[half:]
+}
+
+// Close writes the nodes left on each level, the last one being the root, and closes the tree
+func (b *Builder) Close() error {
+	if err := b.finish(); err != nil {
+		_ = b.tree.Close()
+		return err
+	}
+	return b.tree.Close()
+}
+
+func (b *Builder) finish() error {
+	if len(b.levels) == 0 {
+		// No keys, the root is an empty leaf
+		return b.tree.writeToDisk(&Node{Leaf: true, Keys: make([]*Key, 0), Children: make([]int64, 0)})
+	}
+	for l := 0; l < len(b.levels); l++ {
+		lv := b.levels[l]
+		if !lv.written && lv.prev == nil {
+			// The only node of the level is the root
+			lv.cur.node.Page = 0
+			return b.tree.writeToDisk(lv.cur.node)
+		}
+		b.balance(lv)
+		if !lv.written && lv.cur == nil {
+			// Both nodes of the level were merged into the root
+			lv.prev.node.Page = 0
+			return b.tree.writeToDisk(lv.prev.node)
+		}
+		if err := b.write(l, lv.prev, lv.cur); err != nil {
+			return err
+		}
+		if lv.cur != nil {
+			if err := b.write(l, lv.cur, nil); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+package btree
+
+import (
+	"bytes"
+	"fmt"
+	platformerror "simple-database/internal/platform/error"
+)
+
+// Check walks every node of the tree and returns a description of each broken invariant: keys out of order or
+// outside the range of their subtree, nodes with too few or too many keys, internal nodes without one child more
+// than keys, leaves at different depths or not linked in key order, a root of an older format and nodes that
+// cannot be read. Pages that are both used and free, or neither, are reported as well. Subtrees below an
+// unreadable node are skipped
+func (b *BTree) Check() []string {
+	c := &checker{tree: b, visited: make(map[int64]bool), leafDepth: -1}
+	c.check(0, 0, nil, nil)
+	if !c.skipped {
+		c.checkLinks()
+		c.checkPages()
+	}
+	return c.problems
+}
+
+type checker struct {
+	tree      *BTree
+	visited   map[int64]bool
+	leafDepth int
+	// leaves holds the leaves in the order they were reached, which is key order
+	leaves []*Node
+	// skipped is set when a subtree was not walked, its leaves are then missing from leaves
+	skipped  bool
+	problems []string
+}
+
+func (c *checker) report(format string, args ...any) {
+	c.problems = append(c.problems, fmt.Sprintf(format, args...))
+}
+
+// check verifies the node stored at page and its subtree, whose keys must be greater than or equal to lower and
+// less than upper when they are set
+func (c *checker) check(page int64, depth int, lower, upper []byte) {
+	if c.visited[page] {
+		c.report("Page %d is reachable from more than one parent", page)
+		c.skipped = true
+		return
+	}
+	c.visited[page] = true
+
+	var n *Node
+	var err error
+	if page == 0 {
+		n, err = c.tree.getRoot()
+	} else {
+		n, err = c.tree.readFromDisk(page)
+	}
+	if err != nil {
+		c.report("Page %d cannot be read: %s", page, platformerror.Message(err))
+		c.skipped = true
+		return
+	}
+	if n.Page != page {
+		c.report("Page %d holds the node of page %d", page, n.Page)
+	}
+	if page == 0 && n.Version != FormatVersion {
+		c.report("Root page has format version %d, %d expected", n.Version, FormatVersion)
+	}
+
+	maxKeys := 2*c.tree.Degree - 1
+	if len(n.Keys) > maxKeys {
+		c.report("Page %d has %d keys, at most %d allowed", page, len(n.Keys), maxKeys)
+	}
+	if page != 0 && len(n.Keys) < c.tree.Degree-1 {
+		c.report("Page %d has %d keys, at least %d required", page, len(n.Keys), c.tree.Degree-1)
+	}
+	if page == 0 && !n.Leaf && len(n.Keys) == 0 {
+		c.report("Root page has children but no keys")
+	}
+
+	for _, k := range n.Keys {
+		if k.Overflow != 0 {
+			c.checkOverflow(page, k.Overflow)
+		}
+	}
+	for i, k := range n.Keys {
+		if i > 0 && bytes.Compare(n.Keys[i-1].K, k.K) >= 0 {
+			c.report("Page %d: keys %d and %d are out of order", page, i-1, i)
+		}
+		if lower != nil && bytes.Compare(k.K, lower) < 0 || upper != nil && bytes.Compare(k.K, upper) >= 0 {
+			c.report("Page %d: key %d is outside the range of its parent", page, i)
+		}
+	}
+
+	if n.Leaf {
+		if len(n.Children) != 0 {
+			c.report("Leaf page %d has %d children", page, len(n.Children))
+		}
+		if c.leafDepth == -1 {
+			c.leafDepth = depth
+		} else if c.leafDepth != depth {
+			c.report("Leaf page %d is at depth %d, other leaves are at depth %d", page, depth, c.leafDepth)
+		}
+		c.leaves = append(c.leaves, n)
+		return
+	}
+
+	if len(n.Children) != len(n.Keys)+1 {
+		c.report("Page %d has %d keys and %d children", page, len(n.Keys), len(n.Children))
+		c.skipped = true
+		return
+	}
+	for i, child := range n.Children {
+		childLower, childUpper := lower, upper
+		if i > 0 {
+			childLower = n.Keys[i-1].K
+		}
+		if i < len(n.Keys) {
+			childUpper = n.Keys[i].K
+		}
+		c.check(child, depth+1, childLower, childUpper)
+	}
+}
+
+// checkOverflow marks the pages of the overflow chain starting at first, held by the node at page, as used
+func (c *checker) checkOverflow(page, first int64) {
+	pages, err := c.tree.overflowPages(first)
+	if err != nil {
+		c.report("Page %d: overflow chain at page %d cannot be read: %s", page, first, platformerror.Message(err))
+		return
+	}
+	for _, num := range pages {
+		if c.visited[num] {
+			c.report("Page %d: overflow page %d is used more than once", page, num)
+		}
+		c.visited[num] = true
+	}
+}
+
+// checkLinks verifies that every leaf links to the leaves before and after it in key order
+func (c *checker) checkLinks() {
+	for i, leaf := range c.leaves {
+		var prev, next int64
+		if i > 0 {
+			prev = c.leaves[i-1].Page
+		}
+		if i < len(c.leaves)-1 {
+			next = c.leaves[i+1].Page
+		}
+		if leaf.Prev != prev {
+			c.report("Leaf page %d links back to page %d, %d expected", leaf.Page, leaf.Prev, prev)
+		}
+		if leaf.Next != next {
+			c.report("Leaf page %d links to page %d, %d expected", leaf.Page, leaf.Next, next)
+		}
+	}
+}
+
+// checkPages verifies that no page of the free-list is used by the tree and that every other page is, a page
+// neither used nor free is lost until the tree is rebuilt
+func (c *checker) checkPages() {
+	if c.tree.Pager.offset == 0 {
+		// The file has no free-list, its unused pages cannot be told apart
+		return
+	}
+	free, err := c.tree.Pager.FreePages()
+	if err != nil {
+		c.report("The free-list cannot be read: %s", platformerror.Message(err))
+		return
+	}
+	for _, page := range free {
+		if c.visited[page] {
+			c.report("Page %d is in the free-list but used by the tree", page)
+		}
+	}
+	if lost := c.tree.Pager.Count() - int64(len(c.visited)) - int64(len(free)); lost > 0 {
+		c.report("%d pages are neither used by the tree nor in the free-list", lost)
+	}
+}
+
+// Keys returns every key of the tree in order
+func (b *BTree) Keys() ([]Key, error) {
+	return b.scan(nil, func(k []byte) bool {
+		return true
+	})
+}
@@ -0,0 +1,117 @@
+package btree
+
+// Cursor reads the keys of a BTree in order, moving from leaf to leaf through their links so that a range is read
+// one leaf at a time. A cursor holds a copy of its current leaf and must not be used once the tree changed
+type Cursor struct {
+	tree *BTree
+	leaf *Node
+	// pos is the position of the current key in leaf, -1 before the first key and len(leaf.Keys) after the last one
+	pos int
+}
+
+// NewCursor returns a cursor that is not positioned yet, First, Last or Seek must be called before reading keys
+func (b *BTree) NewCursor() *Cursor {
+	return &Cursor{tree: b}
+}
+
+// First moves to the smallest key and reports whether the tree has one
+func (c *Cursor) First() (bool, error) {
+	n, err := c.tree.getRoot()
+	if err != nil {
+		return false, err
+	}
+	for !n.Leaf {
+		if n, err = c.tree.readFromDisk(n.Children[0]); err != nil {
+			return false, err
+		}
+	}
+	c.leaf, c.pos = n, 0
+	return c.forward()
+}
+
+// Last moves to the largest key and reports whether the tree has one
+func (c *Cursor) Last() (bool, error) {
+	n, err := c.tree.getRoot()
+	if err != nil {
+		return false, err
+	}
+	for !n.Leaf {
+		if n, err = c.tree.readFromDisk(n.Children[len(n.Children)-1]); err != nil {
+			return false, err
+		}
+	}
+	c.leaf, c.pos = n, len(n.Keys)-1
+	return c.backward()
+}
+
+// Seek moves to the first key greater than or equal to key and reports whether there is one. When there is none,
+// Prev moves to the largest key
+func (c *Cursor) Seek(key []byte) (bool, error) {
+	leaf, err := c.tree.findLeaf(key)
+	if err != nil {
+		return false, err
+	}
+	c.leaf = leaf
+	c.pos, _ = leaf.search(key)
+	return c.forward()
+}
+
+// Next moves to the following key and reports whether there is one
+func (c *Cursor) Next() (bool, error) {
+	if c.leaf == nil || c.pos >= len(c.leaf.Keys) {
+		return false, nil
+	}
+	c.pos++
+	return c.forward()
+}
+
+// Prev moves to the preceding key and reports whether there is one
+func (c *Cursor) Prev() (bool, error) {
+	if c.leaf == nil || c.pos < 0 {
+		return false, nil
+	}
+	c.pos--
+	return c.backward()
+}
+
+// Valid reports whether the cursor is on a key
+func (c *Cursor) Valid() bool {
+	return c.leaf != nil && c.pos >= 0 && c.pos < len(c.leaf.Keys)
+}
+
+// Key returns the key the cursor is on. It must only be called while the cursor is Valid
+func (c *Cursor) Key() Key {
+	return *c.leaf.Keys[c.pos]
+}
+
+// forward moves to the first key of the following leaves when pos is past the end of the current one
+func (c *Cursor) forward() (bool, error) {
+	for c.pos >= len(c.leaf.Keys) {
+		if c.leaf.Next == 0 {
+			c.pos = len(c.leaf.Keys)
+			return false, nil
+		}
+		next, err := c.tree.readFromDisk(c.leaf.Next)
+		if err != nil {
+			return false, err
+		}
+		c.leaf, c.pos = next, 0
+	}
+	return true, nil
+}
+
+// backward moves to the last key of the preceding leaves when pos is before the start of the current one
+func (c *Cursor) backward() (bool, error) {
+	for c.pos < 0 {
+		if c.leaf.Prev == 0 {
+			c.pos = -1
+			return false, nil
+		}
+		prev, err := c.tree.readFromDisk(c.leaf.Prev)
+		if err != nil {
+			return false, err
+		}
+		c.leaf, c.pos = prev, len(prev.Keys)-1
+	}
+	return true, nil
+}
@@ -0,0 +1,161 @@
+package btree
+
+import (
+	"encoding/binary"
+	"fmt"
+	platformerror "simple-database/internal/platform/error"
+)
+
+// overflowHeaderSize is the size of the header of an overflow page: the next page of the chain, 0 when it is the
+// last one, and the length of the data held by the page
+const overflowHeaderSize = 8 + 4
+
+// packNode encodes n so that it fits in a page. Keys already moved to overflow pages stay there, then the
+// largest keys are moved until the node fits. Keys never change once inserted, so a chain is written only once.
+// The root is stamped with the FormatVersion
+func (b *BTree) packNode(n *Node) ([]byte, error) {
+	stored := &Node{Page: n.Page, Children: n.Children, Leaf: n.Leaf, Next: n.Next, Prev: n.Prev, Keys: make([]*Key, len(n.Keys))}
+	if n.Page == 0 {
+		stored.Version = FormatVersion
+	}
+	for i, k := range n.Keys {
+		stored.Keys[i] = k
+		if k.Overflow != 0 {
+			stored.Keys[i] = &Key{Overflow: k.Overflow}
+		}
+	}
+
+	for {
+		encoded, err := marshalNode(stored)
+		if err != nil {
+			return nil, err
+		}
+		if len(encoded) <= PageCapacity {
+			return encoded, nil
+		}
+
+		largest := -1
+		for i, k := range stored.Keys {
+			if k.Overflow == 0 && (largest == -1 || len(k.K)+len(k.V) > len(stored.Keys[largest].K)+len(stored.Keys[largest].V)) {
+				largest = i
+			}
+		}
+		if largest == -1 {
+			return nil, platformerror.NewStackTraceError(fmt.Sprintf("Node size %d exceed %d", len(encoded), PageCapacity),
+				platformerror.BTreeWriteErrorCode)
+		}
+		key := n.Keys[largest]
+		if key.Overflow, err = b.writeOverflow(key); err != nil {
+			return nil, err
+		}
+		stored.Keys[largest] = &Key{Overflow: key.Overflow}
+	}
+}
+
+// unpackNode decodes a node and reads back the keys stored in overflow pages
+func (b *BTree) unpackNode(data []byte) (*Node, error) {
+	n, err := decodeNode(data)
+	if err != nil {
+		return nil, err
+	}
+	for _, k := range n.Keys {
+		if k.Overflow != 0 {
+			if err = b.readOverflow(k); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return n, nil
+}
+
+// writeOverflow writes the length of K, K and V to newly allocated pages and returns the first one
+func (b *BTree) writeOverflow(k *Key) (int64, error) {
+	data := make([]byte, 4, 4+len(k.K)+len(k.V))
+	binary.LittleEndian.PutUint32(data, uint32(len(k.K)))
+	data = append(append(data, k.K...), k.V...)
+
+	capacity := PageCapacity - overflowHeaderSize
+	pages := make([]int64, (len(data)+capacity-1)/capacity)
+	for i := range pages {
+		var err error
+		if pages[i], err = b.Pager.Allocate(); err != nil {
+			return 0, err
+		}
+	}
+	for i, num := range pages {
+		start, end := i*capacity, min((i+1)*capacity, len(data))
+		var next int64
+		if i < len(pages)-1 {
+			next = pages[i+1]
+		}
+		page := make([]byte, overflowHeaderSize, overflowHeaderSize+end-start)
+		binary.LittleEndian.PutUint64(page, uint64(next))
+		binary.LittleEndian.PutUint32(page[8:], uint32(end-start))
+		if err := b.Pager.WriteTo(num, append(page, data[start:end]...)); err != nil {
+			return 0, err
+		}
+	}
+	return pages[0], nil
+}
+
+// overflowPages returns the pages of the chain starting at first
+func (b *BTree) overflowPages(first int64) ([]int64, error) {
+	pages := make([]int64, 0)
+	for num := first; num != 0; {
+		if len(pages) > int(b.Pager.Count()) {
+			return nil, platformerror.NewStackTraceError(fmt.Sprintf("Overflow chain at page %d loops", first), platformerror.BTreeReadErrorCode)
+		}
+		page, err := b.Pager.GetPage(num)
+		if err != nil {
+			return nil, err
+		}
+		pages = append(pages, num)
+		num = int64(binary.LittleEndian.Uint64(page))
+	}
+	return pages, nil
+}
+
+// freeKeys frees the overflow chains of keys removed from the tree
+func (b *BTree) freeKeys(keys ...*Key) error {
+	for _, k := range keys {
+		if k.Overflow == 0 {
+			continue
+		}
+		pages, err := b.overflowPages(k.Overflow)
+		if err != nil {
+			return err
+		}
+		for _, num := range pages {
+			if err = b.Pager.Free(num); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// readOverflow sets K and V from the chain starting at k.Overflow
+func (b *BTree) readOverflow(k *Key) error {
+	data := make([]byte, 0)
+	for num := k.Overflow; num != 0; {
+		page, err := b.Pager.GetPage(num)
+		if err != nil {
+			return err
+		}
+		next := int64(binary.LittleEndian.Uint64(page))
+		length := int(binary.LittleEndian.Uint32(page[8:]))
+		if length > PageCapacity-overflowHeaderSize {
+			return platformerror.NewStackTraceError(fmt.Sprintf("Overflow page %d: length %d exceeds %d", num, length, PageCapacity-overflowHeaderSize),
+				platformerror.BTreeReadErrorCode)
+		}
+		data = append(data, page[overflowHeaderSize:overflowHeaderSize+length]...)
+		num = next
+	}
+
+	if len(data) < 4 || int(binary.LittleEndian.Uint32(data)) > len(data)-4 {
+		return platformerror.NewStackTraceError(fmt.Sprintf("Invalid overflow chain at page %d", k.Overflow), platformerror.BTreeReadErrorCode)
+	}
+	keyLen := 4 + int(binary.LittleEndian.Uint32(data))
+	k.K, k.V = data[4:keyLen], data[keyLen:]
+	return nil
+}
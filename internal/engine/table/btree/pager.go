@@ -1,62 +1,238 @@
 package btree
 
 import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
 	"os"
+	"path/filepath"
+	"simple-database/internal/engine/buffer"
+	"simple-database/internal/engine/wal"
+	platformerror "simple-database/internal/platform/error"
 )
 
 const PageSize = 4096 // Page size
 
-// Pager manages pages in a file
+// ChecksumSize is the size of the CRC32C stored in the last bytes of every page
+const ChecksumSize = 4
+
+// PageCapacity is the number of bytes of a page left for its content
+const PageCapacity = PageSize - ChecksumSize
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// headerPage is the number of the page holding the header of a pager, stored before page 0 in the file. The
+// header starts with headerMagic, followed by the first page of the free-list and the number of free pages
+const headerPage = -1
+
+var headerMagic = []byte("SDBT")
+
+// Pager manages pages in a file. Pages no longer used are freed into a list whose first page is kept in the
+// header, every free page holding the next one, and are given out again before the file grows. Files written
+// before the header existed have no free-list, their pages start at the beginning of the file
 type Pager struct {
-	file  *os.File // file to store pages
-	count int64    // count the number of writing pages
+	file  *wal.File // file to store pages
+	count int64     // count the number of writing pages
+	// pool caches the pages when it is not nil, writes then reach the file once the pool is flushed
+	pool *buffer.Pool
+	disk *pagerFile
+	// offset is the number of pages before page 0 in the file, 1 when it has a header and 0 otherwise
+	offset int64
+	// freeHead is the first page of the free-list, 0 when it is empty as page 0 is never freed
+	freeHead  int64
+	freeCount int64
+}
+
+// Stats counts the pages of a Pager, the header page excluded
+type Stats struct {
+	Total int64
+	// Used is the number of pages holding nodes or overflow chains
+	Used int64
+	// Free is the number of pages in the free-list
+	Free int64
+}
+
+// pagerFile reads and writes the pages of a Pager for its buffer pool
+type pagerFile struct {
+	p *Pager
 }
 
-// OpenPager opens a file for page management
-func OpenPager(filename string) (*Pager, error) {
-	file, err := os.OpenFile(filename, os.O_RDWR|os.O_CREATE, 0777)
+func (f *pagerFile) ReadPage(num int64, data []byte) error {
+	return f.p.readPage(num, data)
+}
+
+func (f *pagerFile) WritePage(num int64, data []byte) error {
+	return f.p.writePage(num, data)
+}
+
+// OpenPager opens a file for page management. Page writes are recorded in log when it is not nil, pages are cached
+// in pool when it is not nil
+func OpenPager(filename string, log *wal.Log, pool *buffer.Pool) (*Pager, error) {
+	f, err := os.OpenFile(filename, os.O_RDWR|os.O_CREATE, 0777)
 	if err != nil {
 		return nil, err
 	}
 
-	stat, err := file.Stat()
+	file, err := wal.NewFile(f, log)
 	if err != nil {
 		return nil, err
 	}
 
-	count := stat.Size() / (PageSize)
-
-	p := &Pager{file: file, count: count}
+	p := &Pager{file: file, pool: pool}
+	p.disk = &pagerFile{p: p}
+	if err = p.load(); err != nil {
+		_ = file.Close()
+		return nil, err
+	}
 
 	return p, nil
 }
 
-// WriteTo writes data to a specific page
+// load counts the pages of the file and reads its header. A header is written to an empty file
+func (p *Pager) load() error {
+	stat, err := p.file.Stat()
+	if err != nil {
+		return err
+	}
+	p.freeHead, p.freeCount = 0, 0
+
+	if stat.Size() == 0 {
+		p.offset, p.count = 1, 0
+		return p.writeHeader()
+	}
+
+	magic := make([]byte, len(headerMagic))
+	if _, err = p.file.ReadAt(magic, 0); err != nil {
+		return err
+	}
+	if !bytes.Equal(magic, headerMagic) {
+		p.offset, p.count = 0, stat.Size()/PageSize
+		return nil
+	}
+
+	p.offset, p.count = 1, stat.Size()/PageSize-1
+	header, err := p.GetPage(headerPage)
+	if err != nil {
+		return err
+	}
+	p.freeHead = int64(binary.LittleEndian.Uint64(header[len(headerMagic):]))
+	p.freeCount = int64(binary.LittleEndian.Uint64(header[len(headerMagic)+8:]))
+	return nil
+}
+
+// writeHeader writes the header page with the current free-list
+func (p *Pager) writeHeader() error {
+	header := make([]byte, len(headerMagic)+16)
+	copy(header, headerMagic)
+	binary.LittleEndian.PutUint64(header[len(headerMagic):], uint64(p.freeHead))
+	binary.LittleEndian.PutUint64(header[len(headerMagic)+8:], uint64(p.freeCount))
+	return p.WriteTo(headerPage, header)
+}
+
+// WriteTo writes data to a specific page, followed by the checksum of the page
 func (p *Pager) WriteTo(pageID int64, data []byte) error {
+	if len(data) > PageCapacity {
+		return platformerror.NewStackTraceError(fmt.Sprintf("Page size %d exceed %d", len(data), PageCapacity),
+			platformerror.BTreeWriteErrorCode)
+	}
 	// if data is less than PAGE_SIZE, we need to pad it with null bytes
-	if len(data) < PageSize {
-		data = append(data, make([]byte, PageSize-len(data))...)
+	page := make([]byte, PageSize)
+	copy(page, data)
+	binary.LittleEndian.PutUint32(page[PageCapacity:], crc32.Checksum(page[:PageCapacity], castagnoli))
+	p.count = max(p.count, pageID+1)
+
+	if p.pool != nil {
+		return p.pool.Write(p.disk, pageID, page)
 	}
+	return p.writePage(pageID, page)
+}
 
-	// write the data to the file
-	_, err := p.file.WriteAt(data, PageSize*pageID)
+// writePage writes a page with its checksum to the file
+func (p *Pager) writePage(pageID int64, data []byte) error {
+	_, err := p.file.WriteAt(data, PageSize*(pageID+p.offset))
 	if err != nil {
 		return err
 	}
-
 	return nil
 }
 
-func (p *Pager) NextPageId() (int64, error) {
-	fileInfo, err := p.file.Stat()
+// Allocate returns a page to write a new node or overflow page to: the first page of the free-list, or the page
+// after the last one. Pages allocated after it come after it, written or not
+func (p *Pager) Allocate() (int64, error) {
+	if p.freeHead == 0 {
+		p.count++
+		return p.count - 1, nil
+	}
+
+	pageID := p.freeHead
+	data, err := p.GetPage(pageID)
 	if err != nil {
-		return -1, err
+		return 0, err
+	}
+	next := int64(binary.LittleEndian.Uint64(data))
+	if next < 0 || next >= p.count {
+		return 0, platformerror.NewStackTraceError(fmt.Sprintf("Free page %d of index %s links to page %d, beyond the %d pages of the file",
+			pageID, filepath.Base(p.file.Name()), next, p.count), platformerror.BTreeReadErrorCode)
+	}
+	p.freeHead = next
+	p.freeCount--
+	return pageID, p.writeHeader()
+}
+
+// Free adds a page no longer used to the free-list. Files without a header have no free-list, their pages stay
+// unused
+func (p *Pager) Free(pageID int64) error {
+	if p.offset == 0 {
+		return nil
+	}
+	if pageID <= 0 || pageID >= p.count {
+		return platformerror.NewStackTraceError(fmt.Sprintf("Page %d of index %s cannot be freed", pageID, filepath.Base(p.file.Name())),
+			platformerror.BTreeWriteErrorCode)
+	}
+
+	data := make([]byte, 8)
+	binary.LittleEndian.PutUint64(data, uint64(p.freeHead))
+	if err := p.WriteTo(pageID, data); err != nil {
+		return err
+	}
+	p.freeHead = pageID
+	p.freeCount++
+	return p.writeHeader()
+}
+
+// FreePages returns the pages of the free-list, in the order they will be allocated
+func (p *Pager) FreePages() ([]int64, error) {
+	pages := make([]int64, 0, p.freeCount)
+	for pageID := p.freeHead; pageID != 0; {
+		if int64(len(pages)) == p.freeCount {
+			return nil, platformerror.NewStackTraceError(fmt.Sprintf("The free-list of index %s holds more than %d pages",
+				filepath.Base(p.file.Name()), p.freeCount), platformerror.BTreeReadErrorCode)
+		}
+		pages = append(pages, pageID)
+		data, err := p.GetPage(pageID)
+		if err != nil {
+			return nil, err
+		}
+		pageID = int64(binary.LittleEndian.Uint64(data))
 	}
-	return fileInfo.Size() / PageSize, nil
+	return pages, nil
+}
+
+// Stats returns the number of pages of the file, those in use and those in the free-list
+func (p *Pager) Stats() Stats {
+	return Stats{Total: p.count, Used: p.count - p.freeCount, Free: p.freeCount}
 }
 
 // Close closes the file
 func (p *Pager) Close() error {
+	if p.pool != nil {
+		if err := p.pool.FlushFile(p.disk); err != nil {
+			return err
+		}
+		p.pool.Drop(p.disk)
+	}
+
 	// sync one last time
 	if err := p.file.Sync(); err != nil {
 		return err
@@ -65,22 +241,51 @@ func (p *Pager) Close() error {
 	return p.file.Close()
 }
 
-// GetPage gets a page and returns the data
-// Will gather all the pages that are linked together
+// Reset drops the cached pages, counts the pages of the file and reads its header again, used after the file was
+// changed underneath the pager
+func (p *Pager) Reset() error {
+	if p.pool != nil {
+		p.pool.Drop(p.disk)
+	}
+	return p.load()
+}
+
+// GetPage gets a page and returns the data after verifying its checksum
 func (p *Pager) GetPage(pageID int64) ([]byte, error) {
-	// get the page
 	data := make([]byte, PageSize)
+	if p.pool == nil {
+		if err := p.readPage(pageID, data); err != nil {
+			return nil, err
+		}
+		return data[:PageCapacity], nil
+	}
 
-	_, err := p.file.ReadAt(data, pageID*PageSize)
-
+	frame, err := p.pool.Fetch(p.disk, pageID)
 	if err != nil {
 		return nil, err
 	}
+	copy(data, frame.Data())
+	p.pool.Unpin(frame, false)
+	return data[:PageCapacity], nil
+}
+
+// readPage reads a page from the file and verifies its checksum
+func (p *Pager) readPage(pageID int64, data []byte) error {
+	_, err := p.file.ReadAt(data, (pageID+p.offset)*PageSize)
+
+	if err != nil {
+		return err
+	}
 
-	return data, nil
+	stored := binary.LittleEndian.Uint32(data[PageCapacity:])
+	if actual := crc32.Checksum(data[:PageCapacity], castagnoli); actual != stored {
+		return platformerror.NewStackTraceError(fmt.Sprintf("Page %d of index %s is damaged: checksum %08x, expected %08x",
+			pageID, filepath.Base(p.file.Name()), actual, stored), platformerror.ChecksumMismatchErrorCode)
+	}
+	return nil
 }
 
-// Count returns the number of pages
+// Count returns the number of pages, the header page excluded
 func (p *Pager) Count() int64 {
 	return p.count
 }
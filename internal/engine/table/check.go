@@ -0,0 +1,269 @@
+package table
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	tableparser "simple-database/internal/engine/table/column/parser"
+	"simple-database/internal/engine/table/index"
+	"simple-database/internal/engine/table/page"
+	"simple-database/internal/platform/datatype"
+	platformerror "simple-database/internal/platform/error"
+	"simple-database/internal/platform/helper"
+	"slices"
+	"sort"
+)
+
+// CheckResult is what Check found in a table file and its indexes
+type CheckResult struct {
+	TableName     string
+	Rows          int
+	Pages         int
+	OverflowPages int
+	// IndexEntries holds the number of entries of every index by index name
+	IndexEntries map[string]int
+	// Problems are the problems of the table file
+	Problems []string
+	// IndexProblems holds the problems of every index by index name, RebuildIndexes fixes them
+	IndexProblems map[string][]string
+}
+
+func (r *CheckResult) report(format string, args ...any) {
+	r.Problems = append(r.Problems, fmt.Sprintf(format, args...))
+}
+
+func (r *CheckResult) reportIndex(name string, format string, args ...any) {
+	r.IndexProblems[name] = append(r.IndexProblems[name], fmt.Sprintf(format, args...))
+}
+
+// ProblemCount returns the number of problems of the table file and its indexes
+func (r *CheckResult) ProblemCount() int {
+	n := len(r.Problems)
+	for _, problems := range r.IndexProblems {
+		n += len(problems)
+	}
+	return n
+}
+
+// recordLocation is where a record is stored in the table file
+type recordLocation struct {
+	page int64
+	slot uint16
+}
+
+// Check reads every page of the table and every entry of its indexes and reports what is wrong with them:
+// damaged pages, records that are not well-formed TLVs, B-tree invariants that do not hold and index entries
+// that do not match the live records one for one. The files are only read
+func (t *Table) Check() (*CheckResult, error) {
+	result := &CheckResult{TableName: t.Name, IndexEntries: make(map[string]int), IndexProblems: make(map[string][]string)}
+
+	stat, err := t.file.File.Stat()
+	if err != nil {
+		return nil, platformerror.NewStackTraceError(err.Error(), platformerror.OpenFileErrorCode)
+	}
+	if t.header.FirstPage%PageSize != 0 {
+		result.report("First page offset %d is not a multiple of %d", t.header.FirstPage, PageSize)
+		return result, nil
+	}
+	if t.header.LastPage != 0 && t.header.LastPage+PageSize > stat.Size() {
+		result.report("File of %d bytes ends before the last page at offset %d", stat.Size(), t.header.LastPage)
+	}
+
+	records := make(map[recordLocation]tableparser.RecordValue)
+	// damaged holds the pages whose records could not be read, index entries pointing at them are not checked
+	damaged := make(map[int64]bool)
+	if t.header.LastPage != 0 {
+		for num := t.header.FirstPage / PageSize; num <= t.header.LastPage/PageSize; num++ {
+			if !t.checkPage(num, records, result) {
+				damaged[num] = true
+			}
+		}
+	}
+	result.Rows = len(records)
+	if uint64(result.Rows) != t.header.RowCount {
+		result.report("Header counts %d rows, the pages hold %d", t.header.RowCount, result.Rows)
+	}
+
+	for _, name := range t.indexNames() {
+		t.checkIndex(name, records, damaged, result)
+	}
+	return result, nil
+}
+
+// checkPage verifies page num and adds the values of its live records to records. It returns false when the
+// records of the page could not be read
+func (t *Table) checkPage(num int64, records map[recordLocation]tableparser.RecordValue, result *CheckResult) bool {
+	data, err := t.readPageData(num)
+	if err != nil {
+		result.report("Page %d cannot be read: %s", num, platformerror.Message(err))
+		return false
+	}
+	if page.IsOverflow(data) {
+		result.OverflowPages++
+		if _, _, err = page.DecodeOverflow(num, data); err != nil {
+			result.report("Overflow page %d is invalid: %s", num, platformerror.Message(err))
+		}
+		return true
+	}
+	result.Pages++
+	p, err := page.Load(num, data)
+	if err != nil {
+		result.report("Page %d is invalid: %s", num, platformerror.Message(err))
+		return false
+	}
+	for slot := uint16(0); slot < p.SlotCount(); slot++ {
+		record, ok := p.Record(slot)
+		if !ok {
+			continue
+		}
+		if problem := t.checkRecord(record); problem != "" {
+			result.report("Record at page %d slot %d is invalid: %s", num, slot, problem)
+			continue
+		}
+		rawRecord, err := t.parseRecord(p, slot)
+		if err != nil {
+			result.report("Record at page %d slot %d cannot be read: %s", num, slot, platformerror.Message(err))
+			continue
+		}
+		records[recordLocation{page: num, slot: slot}] = rawRecord.Record
+	}
+	return true
+}
+
+// checkRecord returns what is wrong with record: it must be a record TLV whose length matches the TLVs of its
+// columns, one per column. It returns an empty string for a well-formed record
+func (t *Table) checkRecord(record []byte) string {
+	if len(record) < datatype.LenMeta || record[0] != datatype.TypeRecord {
+		return "not a record TLV"
+	}
+	length := binary.LittleEndian.Uint32(record[datatype.LenByte:])
+	if int(length) != len(record)-datatype.LenMeta {
+		return fmt.Sprintf("length %d does not match the %d bytes of its columns", length, len(record)-datatype.LenMeta)
+	}
+	values := 0
+	for pos := datatype.LenMeta; pos < len(record); values++ {
+		if pos+datatype.LenMeta > len(record) {
+			return fmt.Sprintf("column %d is cut off at byte %d", values, pos)
+		}
+		pos += datatype.LenMeta + int(binary.LittleEndian.Uint32(record[pos+datatype.LenByte:]))
+		if pos > len(record) {
+			return fmt.Sprintf("column %d ends after the record", values)
+		}
+	}
+	if values != len(t.ColumnNames) {
+		return fmt.Sprintf("%d columns, expected %d", values, len(t.ColumnNames))
+	}
+	return ""
+}
+
+// checkIndex verifies the B-tree of the index called name and that it holds exactly one entry, stored under the
+// right key, for every live record. Entries pointing at damaged pages are skipped
+func (t *Table) checkIndex(name string, records map[recordLocation]tableparser.RecordValue, damaged map[int64]bool, result *CheckResult) {
+	idx := t.indexes[name]
+	for _, problem := range idx.Check() {
+		result.reportIndex(name, "%s", problem)
+	}
+	entries, err := idx.Entries()
+	if err != nil {
+		result.reportIndex(name, "Entries cannot be read: %s", platformerror.Message(err))
+		return
+	}
+	result.IndexEntries[name] = len(entries)
+
+	definition := t.indexDefinitions[name]
+	primaryKeyColumnName := t.getPrimaryKeyColumnName()
+	counts := make(map[recordLocation]int)
+	for _, entry := range entries {
+		loc := recordLocation{page: entry.Item.Page, slot: entry.Item.Slot}
+		if damaged[loc.page] {
+			continue
+		}
+		value, ok := records[loc]
+		if !ok {
+			result.reportIndex(name, "Entry points at page %d slot %d, which holds no record", loc.page, loc.slot)
+			continue
+		}
+		counts[loc]++
+		key, err := index.NewCompositeItemKey(definition.values(value), value[primaryKeyColumnName]).MarshalBinary()
+		if err != nil {
+			result.reportIndex(name, "Key of the record at page %d slot %d cannot be encoded: %s", loc.page, loc.slot, platformerror.Message(err))
+			continue
+		}
+		if !bytes.Equal(key, entry.Key) {
+			result.reportIndex(name, "Entry for page %d slot %d does not match the record", loc.page, loc.slot)
+			continue
+		}
+		if !slices.Equal(entry.Item.Values(), append(definition.values(value), definition.includedValues(value)...)) {
+			result.reportIndex(name, "Entry for page %d slot %d holds values that do not match the record", loc.page, loc.slot)
+		}
+	}
+
+	locations := make([]recordLocation, 0, len(records))
+	for loc := range records {
+		locations = append(locations, loc)
+	}
+	sort.Slice(locations, func(i, j int) bool {
+		return locations[i].page < locations[j].page || locations[i].page == locations[j].page && locations[i].slot < locations[j].slot
+	})
+	for _, loc := range locations {
+		switch n := counts[loc]; {
+		case n == 0:
+			result.reportIndex(name, "Record at page %d slot %d has no entry", loc.page, loc.slot)
+		case n > 1:
+			result.reportIndex(name, "Record at page %d slot %d has %d entries", loc.page, loc.slot, n)
+		}
+	}
+}
+
+// RebuildIndexes replaces every index of the table with one built from the records of the table file. Each
+// index is built next to its file and moved over it once complete
+func (t *Table) RebuildIndexes() error {
+	if err := t.log.Checkpoint(); err != nil {
+		return err
+	}
+	primaryKeyColumnName := t.getPrimaryKeyColumnName()
+	for name, idx := range t.indexes {
+		idxPath := t.indexFileName(name)
+		definition := t.indexDefinitions[name]
+		if err := os.Remove(idxPath + VacuumExtension); err != nil && !os.IsNotExist(err) {
+			return platformerror.NewStackTraceError(err.Error(), platformerror.DeleteFileErrorCode)
+		}
+		rebuilt := index.NewBuilder(idxPath+VacuumExtension, definition.Unique, t.sortMemory)
+		err := t.forEachPage(func(p *page.Page) (bool, error) {
+			for slot := uint16(0); slot < p.SlotCount(); slot++ {
+				if _, ok := p.Record(slot); !ok {
+					continue
+				}
+				rawRecord, err := t.parseRecord(p, slot)
+				if err != nil {
+					return false, err
+				}
+				item := definition.item(rawRecord.Record, rawRecord.Record[primaryKeyColumnName], p.Num, slot)
+				if err = rebuilt.Add(item); err != nil {
+					return false, err
+				}
+			}
+			return true, nil
+		})
+		if err == nil {
+			err = rebuilt.Build()
+		}
+		if err != nil {
+			rebuilt.Close()
+			_ = os.Remove(idxPath + VacuumExtension)
+			return err
+		}
+
+		if err = idx.Close(); err != nil {
+			return platformerror.NewStackTraceError(err.Error(), platformerror.CloseErrorCode)
+		}
+		if err = os.Rename(idxPath+VacuumExtension, idxPath); err != nil {
+			return platformerror.NewStackTraceError(err.Error(), platformerror.OpenFileErrorCode)
+		}
+		t.indexes[name] = index.NewIndex(idxPath, definition.Unique, t.log, t.pool)
+		helper.Log.Infof("Rebuilt index %s of table %s", name, t.Name)
+	}
+	t.version++
+	return nil
+}
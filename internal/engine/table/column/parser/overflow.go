@@ -0,0 +1,75 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	stdio "io"
+	"simple-database/internal/platform/datatype"
+	platformerror "simple-database/internal/platform/error"
+	"simple-database/internal/platform/io"
+)
+
+// OverflowValueSize is the size of an OverflowValue including its type and length bytes
+const OverflowValueSize = datatype.LenMeta + datatype.LenInt64 + datatype.LenInt32
+
+// OverflowValue is stored in a record in place of a STRING value moved to a chain of overflow pages
+type OverflowValue struct {
+	// Page is the number of the first page of the chain
+	Page int64
+	// Length is the length of the value
+	Length uint32
+}
+
+// OverflowReader reads the values stored in overflow pages
+type OverflowReader interface {
+	ReadOverflow(v OverflowValue) ([]byte, error)
+}
+
+func (v *OverflowValue) MarshalBinary() ([]byte, error) {
+	buf := bytes.Buffer{}
+	buf.WriteByte(datatype.TypeOverflowString)
+	if err := binary.Write(&buf, binary.LittleEndian, uint32(OverflowValueSize-datatype.LenMeta)); err != nil {
+		return nil, platformerror.NewStackTraceError(err.Error(), platformerror.BinaryWriteErrorCode)
+	}
+	if err := binary.Write(&buf, binary.LittleEndian, v); err != nil {
+		return nil, platformerror.NewStackTraceError(err.Error(), platformerror.BinaryWriteErrorCode)
+	}
+	return buf.Bytes(), nil
+}
+
+func (v *OverflowValue) UnmarshalBinary(data []byte) error {
+	if len(data) != OverflowValueSize || data[0] != datatype.TypeOverflowString {
+		return platformerror.NewStackTraceError(fmt.Sprintf("Invalid overflow value %v", data), platformerror.InvalidDataTypeErrorCode)
+	}
+	if err := binary.Read(bytes.NewReader(data[datatype.LenMeta:]), binary.LittleEndian, v); err != nil {
+		return platformerror.NewStackTraceError(err.Error(), platformerror.BinaryReadErrorCode)
+	}
+	return nil
+}
+
+// OverflowValues returns the overflow values of a record as stored in a page, without reading their pages
+func OverflowValues(record []byte) ([]OverflowValue, error) {
+	values := make([]OverflowValue, 0)
+	if len(record) < datatype.LenMeta {
+		return nil, platformerror.NewStackTraceError(fmt.Sprintf("Record of %d bytes too short", len(record)), platformerror.IncompleteReadErrorCode)
+	}
+	reader := io.NewReader(bytes.NewReader(record[datatype.LenMeta:]))
+	for {
+		data, err := reader.ReadTLV()
+		if err != nil {
+			if err == stdio.EOF {
+				return values, nil
+			}
+			return nil, err
+		}
+		if data[0] != datatype.TypeOverflowString {
+			continue
+		}
+		v := OverflowValue{}
+		if err = v.UnmarshalBinary(data); err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+}
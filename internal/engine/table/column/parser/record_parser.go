@@ -1,6 +1,7 @@
 package parser
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	stdio "io"
@@ -11,10 +12,11 @@ import (
 )
 
 type RecordParser struct {
-	file    stdio.ReadSeeker
-	columns []string
-	Value   *RawRecord
-	reader  *io.Reader
+	file     stdio.ReadSeeker
+	columns  []string
+	Value    *RawRecord
+	reader   *io.Reader
+	overflow OverflowReader
 }
 
 // RawRecord represents one record read from the table file
@@ -24,6 +26,10 @@ type RawRecord struct {
 	CachePageKey string
 	// Offset is the offset of the record in the file
 	Offset uint32
+	// Page is the number of the page holding the record
+	Page int64
+	// Slot is the slot of the record in its page
+	Slot uint16
 	// Size is the size of the record in bytes. This only includes the actual fields
 	Size uint32
 	// FullSize is a sum of [RawRecord.Size] and [types.LenMeta] that includes metadata associated with records such as the type and length bytes
@@ -41,6 +47,15 @@ func NewRecordParser(f stdio.ReadSeeker, columns []string) *RecordParser {
 	}
 }
 
+// NewRecordParserWithOverflow returns a parser reading the values moved to overflow pages through overflow
+func NewRecordParserWithOverflow(f stdio.ReadSeeker, columns []string, overflow OverflowReader) *RecordParser {
+	return &RecordParser{
+		file:     f,
+		columns:  columns,
+		overflow: overflow,
+	}
+}
+
 func NewRawRecord(size uint32, record map[string]interface{}) *RawRecord {
 	return &RawRecord{
 		Size:     size,
@@ -106,8 +121,7 @@ func (r *RecordParser) Parse() (int32, error) {
 	}
 
 	for i := 0; i < len(r.columns); i++ {
-		tlvParser := parser.NewTLVParser(read)
-		value, err := tlvParser.Parse()
+		value, err := r.parseValue(read)
 		if errors.Is(err, stdio.EOF) {
 			endPos, err := r.file.Seek(0, stdio.SeekCurrent)
 			if err != nil {
@@ -129,3 +143,27 @@ func (r *RecordParser) Parse() (int32, error) {
 
 	return int32(endPos - startPos), nil
 }
+
+// parseValue parses the next value of the record, reading it from its overflow pages if it was moved there
+func (r *RecordParser) parseValue(read *io.Reader) (any, error) {
+	data, err := read.ReadTLV()
+	if err != nil {
+		return nil, err
+	}
+	if data[0] != datatype.TypeOverflowString {
+		return parser.NewTLVParser(io.NewReader(bytes.NewReader(data))).Parse()
+	}
+
+	if r.overflow == nil {
+		return nil, platformerror.NewStackTraceError("Record has an overflow value but no overflow reader", platformerror.InvalidDataTypeErrorCode)
+	}
+	v := OverflowValue{}
+	if err = v.UnmarshalBinary(data); err != nil {
+		return nil, err
+	}
+	b, err := r.overflow.ReadOverflow(v)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
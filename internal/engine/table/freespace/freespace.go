@@ -0,0 +1,60 @@
+package freespace
+
+import "sort"
+
+// Map tracks how many bytes each page of a table file can still take, so inserts go to a page with room left
+// instead of always growing the file. It only lives in memory and is rebuilt from the pages when the table is
+// opened
+type Map struct {
+	// pages are the numbers of the known pages in ascending order
+	pages []int64
+	free  map[int64]int
+}
+
+func New() *Map {
+	return &Map{
+		pages: make([]int64, 0),
+		free:  make(map[int64]int),
+	}
+}
+
+// Set records that page can take free more bytes
+func (m *Map) Set(page int64, free int) {
+	if _, ok := m.free[page]; !ok {
+		i := sort.Search(len(m.pages), func(i int) bool { return m.pages[i] >= page })
+		m.pages = append(m.pages, 0)
+		copy(m.pages[i+1:], m.pages[i:])
+		m.pages[i] = page
+	}
+	m.free[page] = free
+}
+
+// Find returns the first page that can take size bytes
+func (m *Map) Find(size int) (int64, bool) {
+	for _, page := range m.pages {
+		if m.free[page] >= size {
+			return page, true
+		}
+	}
+	return 0, false
+}
+
+// Remove forgets page, used when it stops holding records
+func (m *Map) Remove(page int64) {
+	if _, ok := m.free[page]; !ok {
+		return
+	}
+	delete(m.free, page)
+	i := sort.Search(len(m.pages), func(i int) bool { return m.pages[i] >= page })
+	m.pages = append(m.pages[:i], m.pages[i+1:]...)
+}
+
+// Free returns the number of bytes page can still take
+func (m *Map) Free(page int64) int {
+	return m.free[page]
+}
+
+// Pages returns the numbers of the known pages in ascending order
+func (m *Map) Pages() []int64 {
+	return m.pages
+}
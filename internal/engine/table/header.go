@@ -0,0 +1,98 @@
+package table
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"simple-database/internal/platform/datatype"
+	platformerror "simple-database/internal/platform/error"
+)
+
+// HeaderMagic are the first bytes of the header value of every table file
+var HeaderMagic = [4]byte{'S', 'D', 'B', 'T'}
+
+// HeaderVersion is the version of the file format written by this package. Files of version 0 predate the
+// header and start with the column definitions, files of version 1 store records in pages of any length and files
+// of version 2 have no page checksums. They are rewritten when the table is opened
+const HeaderVersion uint32 = 3
+
+// slottedPagesVersion is the first version storing records in slotted pages of PageSize bytes
+const slottedPagesVersion uint32 = 2
+
+// checksumVersion is the first version storing a checksum in every page, for the table and the index files
+const checksumVersion uint32 = 3
+
+// HeaderSize is the size of the header including its type and length bytes
+const HeaderSize = datatype.LenMeta + 44
+
+// Header is stored at the start of a table file, in front of the column definitions. It is rewritten in place
+// whenever one of its fields changes
+type Header struct {
+	Magic    [4]byte
+	Version  uint32
+	PageSize uint32
+	// FirstPage is the offset of the first page, the first multiple of PageSize after the column definitions
+	FirstPage int64
+	// LastPage is the offset of the last page, new pages are appended after it. It is 0 while the table has no page
+	LastPage int64
+	RowCount uint64
+	// NextRowId is the number handed to the next inserted row. It only grows, so numbers are never reused
+	NextRowId uint64
+}
+
+func NewHeader(firstPage int64) *Header {
+	return &Header{
+		Magic:     HeaderMagic,
+		Version:   HeaderVersion,
+		PageSize:  PageSize,
+		FirstPage: firstPage,
+	}
+}
+
+// columnsPos returns the offset of the column definitions
+func (h *Header) columnsPos() int64 {
+	if h.Version == 0 {
+		return 0
+	}
+	return HeaderSize
+}
+
+func (h *Header) MarshalBinary() ([]byte, error) {
+	buf := bytes.Buffer{}
+	buf.WriteByte(datatype.TypeTableHeader)
+	if err := binary.Write(&buf, binary.LittleEndian, uint32(HeaderSize-datatype.LenMeta)); err != nil {
+		return nil, platformerror.NewStackTraceError(err.Error(), platformerror.BinaryWriteErrorCode)
+	}
+	if err := binary.Write(&buf, binary.LittleEndian, h); err != nil {
+		return nil, platformerror.NewStackTraceError(err.Error(), platformerror.BinaryWriteErrorCode)
+	}
+	return buf.Bytes(), nil
+}
+
+func (h *Header) UnmarshalBinary(data []byte) error {
+	if len(data) < HeaderSize {
+		return platformerror.NewStackTraceError(fmt.Sprintf("Expected %d header bytes, got %d", HeaderSize, len(data)),
+			platformerror.IncompleteReadErrorCode)
+	}
+	if data[0] != datatype.TypeTableHeader {
+		return platformerror.NewStackTraceError(fmt.Sprintf("Expected %v, got %v", datatype.TypeTableHeader, data[0]),
+			platformerror.InvalidDataTypeErrorCode)
+	}
+	if err := binary.Read(bytes.NewReader(data[datatype.LenMeta:HeaderSize]), binary.LittleEndian, h); err != nil {
+		return platformerror.NewStackTraceError(err.Error(), platformerror.BinaryReadErrorCode)
+	}
+
+	if h.Magic != HeaderMagic {
+		return platformerror.NewStackTraceError(fmt.Sprintf("Not a table file, magic %q", h.Magic[:]),
+			platformerror.InvalidHeaderErrorCode)
+	}
+	if h.Version > HeaderVersion {
+		return platformerror.NewStackTraceError(fmt.Sprintf("Unsupported table format version %d, expected at most %d",
+			h.Version, HeaderVersion), platformerror.InvalidHeaderErrorCode)
+	}
+	if h.PageSize != PageSize {
+		return platformerror.NewStackTraceError(fmt.Sprintf("Table page size is %d, expected %d", h.PageSize, PageSize),
+			platformerror.InvalidHeaderErrorCode)
+	}
+	return nil
+}
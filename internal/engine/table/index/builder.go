@@ -0,0 +1,255 @@
+package index
+
+import (
+	"bufio"
+	"bytes"
+	"container/heap"
+	"encoding/binary"
+	"fmt"
+	stdio "io"
+	"os"
+	"path/filepath"
+	btree "simple-database/internal/engine/table/btree"
+	platformerror "simple-database/internal/platform/error"
+	"slices"
+)
+
+// builderRunPattern is the name of the temporary files holding sorted runs of entries, next to the index file
+const builderRunPattern = "%s_build_*.tmp"
+
+// Builder collects the items of a new index in any order and writes the index in one pass once they are sorted,
+// see btree.Builder. Entries are buffered until they take the memory budget, then sorted and written to a run file.
+// Build merges the runs and the entries left in memory
+type Builder struct {
+	file    string
+	unique  bool
+	memory  int64
+	entries []builderEntry
+	// size is the number of bytes the buffered entries take
+	size int64
+	runs []*os.File
+}
+
+// builderEntry is an item encoded as it is stored in the tree. The encoding of its indexed values starts its key
+// and takes values bytes, count is the number of indexed values
+type builderEntry struct {
+	key, value    []byte
+	values, count int
+}
+
+// NewBuilder returns a builder of the index in the file f, which must be empty. Up to memory bytes of entries are
+// sorted in memory, past that they are sorted in runs written next to f
+func NewBuilder(f string, unique bool, memory int64) *Builder {
+	return &Builder{file: f, unique: unique, memory: memory, entries: make([]builderEntry, 0)}
+}
+
+// Add adds an item to the index
+func (b *Builder) Add(item *Item) error {
+	value, err := item.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	values, err := item.itemKey.MarshalValueBinary()
+	if err != nil {
+		return err
+	}
+	key, err := item.itemKey.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	b.entries = append(b.entries, builderEntry{key: key, value: value, values: len(values), count: len(item.itemKey.vals)})
+	b.size += int64(len(key)+len(value)) + 64
+	if b.size > b.memory {
+		return b.spill()
+	}
+	return nil
+}
+
+// Build sorts the items and writes the index, the runs are removed. The items of a unique index must not share
+// their values
+func (b *Builder) Build() error {
+	defer b.Close()
+
+	b.sortEntries()
+	sources := make(builderSources, 0, len(b.runs)+1)
+	for _, f := range b.runs {
+		src := &builderSource{reader: bufio.NewReader(f)}
+		if err := src.next(); err != nil {
+			return err
+		}
+		if src.current != nil {
+			sources = append(sources, src)
+		}
+	}
+	if len(b.entries) > 0 {
+		src := &builderSource{entries: b.entries}
+		if err := src.next(); err != nil {
+			return err
+		}
+		sources = append(sources, src)
+	}
+	heap.Init(&sources)
+
+	builder, err := btree.NewBuilder(b.file, btree.DefaultFillFactor)
+	if err != nil {
+		return err
+	}
+	var previous *builderEntry
+	for len(sources) > 0 {
+		src := sources[0]
+		entry := src.current
+		// No encoding of values starts with another, so items of equal values are next to each other
+		if b.unique && previous != nil && bytes.Equal(previous.key[:previous.values], entry.key[:entry.values]) {
+			_ = builder.Close()
+			return uniqueViolation(entry)
+		}
+		if err = builder.Add(entry.key, entry.value); err != nil {
+			_ = builder.Close()
+			return platformerror.NewStackTraceError(err.Error(), platformerror.BTreeWriteErrorCode)
+		}
+		previous = entry
+
+		if err = src.next(); err != nil {
+			_ = builder.Close()
+			return err
+		}
+		if src.current == nil {
+			heap.Pop(&sources)
+		} else {
+			heap.Fix(&sources, 0)
+		}
+	}
+	return builder.Close()
+}
+
+// Close removes the runs, it is called by Build and must be called when the index is not built
+func (b *Builder) Close() {
+	for _, f := range b.runs {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+	}
+	b.runs, b.entries, b.size = nil, nil, 0
+}
+
+// uniqueViolation returns the error of an entry whose values are already in a unique index
+func uniqueViolation(entry *builderEntry) error {
+	var item Item
+	if err := item.UnmarshalBinary(entry.value); err != nil {
+		return err
+	}
+	vals := item.Values()[:entry.count]
+	var val any = vals
+	if len(vals) == 1 {
+		val = vals[0]
+	}
+	return platformerror.NewStackTraceError(fmt.Sprintf("Unique key validate with value: %v", val), platformerror.UniqueKeyViolationErrorCode)
+}
+
+// sortEntries sorts the buffered entries by key
+func (b *Builder) sortEntries() {
+	slices.SortFunc(b.entries, func(x, y builderEntry) int {
+		return bytes.Compare(x.key, y.key)
+	})
+}
+
+// spill writes the buffered entries to a new run file
+func (b *Builder) spill() error {
+	b.sortEntries()
+	f, err := os.CreateTemp(filepath.Dir(b.file), fmt.Sprintf(builderRunPattern, filepath.Base(b.file)))
+	if err != nil {
+		return platformerror.NewStackTraceError(err.Error(), platformerror.OpenFileErrorCode)
+	}
+	b.runs = append(b.runs, f)
+
+	w := bufio.NewWriter(f)
+	for _, entry := range b.entries {
+		if _, err = w.Write(marshalBuilderEntry(entry)); err != nil {
+			return platformerror.NewStackTraceError(err.Error(), platformerror.BinaryWriteErrorCode)
+		}
+	}
+	if err = w.Flush(); err != nil {
+		return platformerror.NewStackTraceError(err.Error(), platformerror.BinaryWriteErrorCode)
+	}
+	if _, err = f.Seek(0, stdio.SeekStart); err != nil {
+		return platformerror.NewStackTraceError(err.Error(), platformerror.FileSeekErrorCode)
+	}
+	b.entries, b.size = b.entries[:0], 0
+	return nil
+}
+
+// builderEntryHeader is the number of bytes before the key of an entry in a run, see marshalBuilderEntry
+const builderEntryHeader = 16
+
+// marshalBuilderEntry encodes an entry of a run: the lengths of its key and value, the length of its values and
+// their count, followed by the key and the value
+func marshalBuilderEntry(entry builderEntry) []byte {
+	buf := make([]byte, 0, builderEntryHeader+len(entry.key)+len(entry.value))
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(len(entry.key)))
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(len(entry.value)))
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(entry.values))
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(entry.count))
+	buf = append(buf, entry.key...)
+	return append(buf, entry.value...)
+}
+
+// unmarshalBuilderEntry reads the next entry of a run, stdio.EOF once there are none
+func unmarshalBuilderEntry(r *bufio.Reader) (*builderEntry, error) {
+	header := make([]byte, builderEntryHeader)
+	if _, err := stdio.ReadFull(r, header); err != nil {
+		if err == stdio.EOF {
+			return nil, err
+		}
+		return nil, platformerror.NewStackTraceError(err.Error(), platformerror.IncompleteReadErrorCode)
+	}
+	keyLen := binary.LittleEndian.Uint32(header)
+	buf := make([]byte, keyLen+binary.LittleEndian.Uint32(header[4:]))
+	if _, err := stdio.ReadFull(r, buf); err != nil {
+		return nil, platformerror.NewStackTraceError(err.Error(), platformerror.IncompleteReadErrorCode)
+	}
+	return &builderEntry{key: buf[:keyLen], value: buf[keyLen:],
+		values: int(binary.LittleEndian.Uint32(header[8:])), count: int(binary.LittleEndian.Uint32(header[12:]))}, nil
+}
+
+// builderSource is a run file, or the entries left in memory, being merged
+type builderSource struct {
+	reader  *bufio.Reader
+	entries []builderEntry
+	current *builderEntry
+}
+
+// next moves to the next entry of the source, current is nil once there are none
+func (s *builderSource) next() error {
+	if s.reader == nil {
+		s.current = nil
+		if len(s.entries) > 0 {
+			s.current, s.entries = &s.entries[0], s.entries[1:]
+		}
+		return nil
+	}
+	entry, err := unmarshalBuilderEntry(s.reader)
+	if err == stdio.EOF {
+		s.current = nil
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	s.current = entry
+	return nil
+}
+
+// builderSources are merged by key, keys hold the id of their record so no two are equal
+type builderSources []*builderSource
+
+func (s builderSources) Len() int { return len(s) }
+func (s builderSources) Less(i, j int) bool {
+	return bytes.Compare(s[i].current.key, s[j].current.key) < 0
+}
+func (s builderSources) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s *builderSources) Push(x any)   { *s = append(*s, x.(*builderSource)) }
+func (s *builderSources) Pop() any {
+	old := *s
+	src := old[len(old)-1]
+	*s = old[:len(old)-1]
+	return src
+}
@@ -4,12 +4,16 @@ import (
 	"bytes"
 	"encoding/binary"
 	"fmt"
-	"math"
+	stdio "io"
+	"reflect"
+	"simple-database/internal/engine/buffer"
 	btree "simple-database/internal/engine/table/btree"
+	"simple-database/internal/engine/wal"
 	"simple-database/internal/platform/datatype"
 	platformerror "simple-database/internal/platform/error"
 	"simple-database/internal/platform/io"
 	platformparser "simple-database/internal/platform/parser"
+	"slices"
 )
 
 type Index struct {
@@ -17,59 +21,96 @@ type Index struct {
 	unique bool
 }
 
+// Item points at a record of the table file by the number of its page and its slot in the page. It may also hold
+// the values of columns that are not indexed, so a select reading only them does not read the record
 type Item struct {
-	itemKey ItemKey
-	PagePos int64
+	itemKey  ItemKey
+	included []any
+	Page     int64
+	Slot     uint16
 }
 
+// ItemKey is the key of an item: the values of the indexed columns, in the order of the index, and the id of the
+// record
 type ItemKey struct {
-	id  any
-	val any
+	id   any
+	vals []any
 }
 
 func NewItemKey(val, idVal any) *ItemKey {
-	return &ItemKey{val: val, id: idVal}
+	return &ItemKey{vals: []any{val}, id: idVal}
 }
 
-func (k *ItemKey) MarshalBinary() ([]byte, error) {
-	var buf bytes.Buffer
+// NewCompositeItemKey returns the key of an item of an index over several columns
+func NewCompositeItemKey(vals []any, idVal any) *ItemKey {
+	return &ItemKey{vals: vals, id: idVal}
+}
 
-	marshaler := platformparser.NewValueMarshaler[any](k.val)
-	valBuf, err := marshaler.MarshalBinaryWithBigEndian()
+// MarshalBinary encodes the key of the item in the tree as the tuple of its values and the id of its record. Each
+// is encoded to sort as it does and so that no encoding starts with another, see
+// platformparser.ValueMarshaler.MarshalComparableBinary. Keys of equal values are ordered by id and the keys of
+// the items whose leading columns hold some values are exactly the keys starting with their encoding
+func (k *ItemKey) MarshalBinary() ([]byte, error) {
+	buf, err := k.MarshalValueBinary()
 	if err != nil {
 		return nil, err
 	}
-	buf.Write(valBuf)
 
-	marshaler = platformparser.NewValueMarshaler[any](k.id)
-	idBuf, err := marshaler.MarshalBinaryWithBigEndian()
+	marshaler := platformparser.NewValueMarshaler[any](k.id)
+	idBuf, err := marshaler.MarshalComparableBinary()
 	if err != nil {
 		return nil, err
 	}
-	buf.Write(idBuf)
 
-	return buf.Bytes(), nil
+	return append(buf, idBuf...), nil
 }
 
+// MarshalValueBinary encodes the values of the key alone, which start the keys of every item of those values
 func (k *ItemKey) MarshalValueBinary() ([]byte, error) {
 	var buf bytes.Buffer
 
-	marshaler := platformparser.NewValueMarshaler[any](k.val)
-	valBuf, err := marshaler.MarshalBinaryWithBigEndian()
-	if err != nil {
-		return nil, err
+	for _, val := range k.vals {
+		marshaler := platformparser.NewValueMarshaler[any](val)
+		valBuf, err := marshaler.MarshalComparableBinary()
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(valBuf)
 	}
-	buf.Write(valBuf)
 
 	return buf.Bytes(), nil
 }
 
-func NewItem(val, idVal any, pagePos int64) *Item {
-	return &Item{itemKey: ItemKey{val: val, id: idVal}, PagePos: pagePos}
+func NewItem(val, idVal any, page int64, slot uint16) *Item {
+	return NewCompositeItem([]any{val}, idVal, page, slot)
 }
 
-func NewIndex(f string, unique bool) *Index {
-	t, err := btree.Open(f)
+// NewCompositeItem returns an item of an index over several columns, vals holds their values in the order of the
+// index
+func NewCompositeItem(vals []any, idVal any, page int64, slot uint16) *Item {
+	return NewCoveringItem(vals, nil, idVal, page, slot)
+}
+
+// NewCoveringItem returns an item also holding the values of the included columns, which are stored with the item
+// but are not part of its key
+func NewCoveringItem(vals []any, included []any, idVal any, page int64, slot uint16) *Item {
+	return &Item{itemKey: ItemKey{vals: vals, id: idVal}, included: included, Page: page, Slot: slot}
+}
+
+// Id returns the id of the record of the item
+func (i *Item) Id() any {
+	return i.itemKey.id
+}
+
+// Values returns the values stored in the item: those of the indexed columns followed by those of the included
+// columns, in the order of the index
+func (i *Item) Values() []any {
+	return append(slices.Clone(i.itemKey.vals), i.included...)
+}
+
+// NewIndex opens the index stored in file f. Its pages are cached in pool when it is not nil
+func NewIndex(f string, unique bool, log *wal.Log, pool *buffer.Pool) *Index {
+	t, err := btree.OpenWithPool(f, log, pool)
 	if err != nil {
 		panic(err)
 	}
@@ -99,64 +140,66 @@ func (i *Item) MarshalBinary() ([]byte, error) {
 	}
 	buf.Write(idValBuf)
 
-	valTLV := platformparser.NewTLVMarshaler(i.itemKey.val)
-	valBuf, err := valTLV.MarshalBinary()
+	for _, val := range i.Values() {
+		valTLV := platformparser.NewTLVMarshaler(val)
+		valBuf, err := valTLV.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(valBuf)
+	}
+
+	pageTLV := platformparser.NewTLVMarshaler(i.Page)
+	pageBuf, err := pageTLV.MarshalBinary()
 	if err != nil {
 		return nil, err
 	}
-	buf.Write(valBuf)
+	buf.Write(pageBuf)
 
-	pagePosTLV := platformparser.NewTLVMarshaler(i.PagePos)
-	pagePosBuf, err := pagePosTLV.MarshalBinary()
+	slotTLV := platformparser.NewTLVMarshaler(int32(i.Slot))
+	slotBuf, err := slotTLV.MarshalBinary()
 	if err != nil {
 		return nil, err
 	}
-	buf.Write(pagePosBuf)
+	buf.Write(slotBuf)
 	return buf.Bytes(), nil
 }
 
+// UnmarshalBinary decodes an item: the TLVs of the id, of every value, of the page and of the slot follow its
+// type and length. The item does not tell the indexed values from the included ones, they are all read as indexed
+// values and Values returns them in the same order
 func (i *Item) UnmarshalBinary(buf []byte) error {
-	byteUnmarshaler := platformparser.NewValueUnmarshaler[byte]()
-	int32Unmarshaler := platformparser.NewValueUnmarshaler[uint32]()
-	int64Unmarshaler := platformparser.NewValueUnmarshaler[int64]()
-
-	n := 0
-
-	// type
-	if err := byteUnmarshaler.UnmarshalBinary(buf); err != nil {
-		return err
-	}
-	n += datatype.LenByte
-
-	// len
-	if err := int32Unmarshaler.UnmarshalBinary(buf[n:]); err != nil {
-		return err
+	if len(buf) < datatype.LenMeta || buf[0] != datatype.TypeIndexItem {
+		return platformerror.NewStackTraceError(fmt.Sprintf("Expected an index item of type %v", datatype.TypeIndexItem),
+			platformerror.InvalidDataTypeErrorCode)
 	}
-	n += datatype.LenInt32
 
-	tlvParser := platformparser.NewTLVParser(io.NewReader(bytes.NewReader(buf[n:])))
-	idBuf, err := tlvParser.Parse()
-	if err != nil {
-		return err
+	tlvParser := platformparser.NewTLVParser(io.NewReader(bytes.NewReader(buf[datatype.LenMeta:])))
+	values := make([]any, 0, 4)
+	for {
+		value, err := tlvParser.Parse()
+		if err != nil {
+			if err == stdio.EOF {
+				break
+			}
+			return err
+		}
+		values = append(values, value)
 	}
-	i.itemKey.id = idBuf
-	n += int(tlvParser.BytesRead())
-
-	tlvParser = platformparser.NewTLVParser(io.NewReader(bytes.NewReader(buf[n:])))
-	valBuf, err := tlvParser.Parse()
-	if err != nil {
-		return err
+	if len(values) < 4 {
+		return platformerror.NewStackTraceError(fmt.Sprintf("Expected at least 4 values in an index item, got %d", len(values)),
+			platformerror.IncompleteReadErrorCode)
 	}
-	i.itemKey.id = valBuf
-	n += int(tlvParser.BytesRead())
 
-	pagePosTLV := platformparser.NewTLVUnmarshaler(int64Unmarshaler)
-	if err := pagePosTLV.UnmarshalBinary(buf[n:]); err != nil {
-		return err
+	page, ok := values[len(values)-2].(int64)
+	slot, slotOk := values[len(values)-1].(int32)
+	if !ok || !slotOk {
+		return platformerror.NewStackTraceError(fmt.Sprintf("Expected the page and slot of an index item, got %T and %T",
+			values[len(values)-2], values[len(values)-1]), platformerror.InvalidDataTypeErrorCode)
 	}
-	i.PagePos = pagePosTLV.Value
-	n += int(pagePosTLV.BytesRead)
-
+	i.itemKey = ItemKey{id: values[0], vals: values[1 : len(values)-2]}
+	i.Page = page
+	i.Slot = uint16(slot)
 	return nil
 }
 
@@ -167,13 +210,21 @@ func (i *Index) Add(item *Item) error {
 	}
 
 	if i.unique {
-		items, err := i.Get(item.itemKey.val, datatype.OperatorEqual)
+		found := false
+		err := i.ScanPrefix(item.itemKey.vals, nil, "", func(Item) (bool, error) {
+			found = true
+			return false, nil
+		})
 		if err != nil {
 			return err
 		}
 
-		if items != nil {
-			return platformerror.NewStackTraceError(fmt.Sprintf("Unique key validate with value: %v", item.itemKey.val), platformerror.UniqueKeyViolationErrorCode)
+		if found {
+			var val any = item.itemKey.vals
+			if len(item.itemKey.vals) == 1 {
+				val = item.itemKey.vals[0]
+			}
+			return platformerror.NewStackTraceError(fmt.Sprintf("Unique key validate with value: %v", val), platformerror.UniqueKeyViolationErrorCode)
 		}
 	}
 
@@ -193,6 +244,16 @@ func (i *Index) Close() error {
 	return i.tree.Close()
 }
 
+// Outdated reports whether the index file was written in an older format and must be rebuilt
+func (i *Index) Outdated() (bool, error) {
+	return i.tree.Outdated()
+}
+
+// ResetCache drops the cached pages of the index, used after its file was changed underneath it
+func (i *Index) ResetCache() error {
+	return i.tree.Pager.Reset()
+}
+
 func (i *Index) Remove(key *ItemKey) error {
 	idBuf, err := key.MarshalBinary()
 	if err != nil {
@@ -206,146 +267,330 @@ func (i *Index) Remove(key *ItemKey) error {
 	return nil
 }
 
-func appendSlice(source []byte, b byte) []byte {
-	size := int(math.Ceil(btree.PageSize/btree.DefaultDegree) * 1.5)
+// Get returns the items matching op and val, nil when there are none. See Scan
+func (i *Index) Get(val any, op datatype.Operator) ([]Item, error) {
+	items := make([]Item, 0)
+	err := i.Scan(val, op, func(item Item) (bool, error) {
+		items = append(items, item)
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		return nil, nil
+	}
+	return items, nil
+}
 
-	dest := make([]byte, size)
-	copy(dest, source)
+// keyRange is the range of keys of the tree matching a value and an operator: keys from from, included, up to
+// to, excluded. A nil bound is open
+type keyRange struct {
+	from []byte
+	to   []byte
+	// prefix is set when the keys must start with it
+	prefix []byte
+}
 
-	for i := len(source); i < size; i++ {
-		dest[i] = b
+// above reports whether k, and so every key after it, is past the range
+func (r *keyRange) above(k []byte) bool {
+	if r.prefix != nil && !bytes.HasPrefix(k, r.prefix) {
+		return true
 	}
-	return dest
+	return r.to != nil && bytes.Compare(k, r.to) >= 0
 }
 
-//goland:noinspection DuplicatedCode
-func (i *Index) Get(val any, op datatype.Operator) ([]Item, error) {
-	// in case of unique index, id is same as val
-	var itemKey = NewItemKey(val, val)
+// interval returns r as the keys from its start up to its end, the keys starting with its prefix ending before
+// prefixEnd
+func (r *keyRange) interval() *keyRange {
+	to := r.to
+	if r.prefix != nil {
+		if end := prefixEnd(r.prefix); end != nil && (to == nil || bytes.Compare(end, to) < 0) {
+			to = end
+		}
+	}
+	return &keyRange{from: r.from, to: to}
+}
 
-	itemKeyBuf, err := itemKey.MarshalBinary()
-	if err != nil {
-		return nil, err
+// prefixEnd returns the smallest key greater than every key starting with prefix, nil when there is none
+func prefixEnd(prefix []byte) []byte {
+	end := bytes.Clone(prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
 	}
+	return nil
+}
+
+// Bound is one end of a range of values
+type Bound struct {
+	Value     any
+	Inclusive bool
+}
 
-	keys := make([]btree.Key, 0)
+// Range selects the items whose leading values equal Equal and whose next value lies between Lower and Upper. A
+// nil bound is open, a range without bounds selects every item of the leading values
+type Range struct {
+	Equal []any
+	Lower *Bound
+	Upper *Bound
+}
 
+// NewRanges returns the ranges of the items whose leading values equal equal and whose next value matches op and
+// val, in key order. Without op every item of the leading values matches. != is the values below val and those
+// above it
+func NewRanges(equal []any, val any, op datatype.Operator) ([]Range, error) {
+	r := Range{Equal: equal}
 	switch op {
+	case "":
 	case datatype.OperatorEqual:
-		if i.unique {
-			key, found, err := i.tree.Get(itemKeyBuf)
-			if err != nil {
-				return nil, platformerror.NewStackTraceError(err.Error(), platformerror.BTreeReadErrorCode)
-			}
-			if !found {
+		r.Lower, r.Upper = &Bound{Value: val, Inclusive: true}, &Bound{Value: val, Inclusive: true}
+	case datatype.OperatorGreater, datatype.OperatorGreaterOrEqual:
+		r.Lower = &Bound{Value: val, Inclusive: op == datatype.OperatorGreaterOrEqual}
+	case datatype.OperatorLess, datatype.OperatorLessOrEqual:
+		r.Upper = &Bound{Value: val, Inclusive: op == datatype.OperatorLessOrEqual}
+	case datatype.OperatorNotEqual:
+		return []Range{{Equal: equal, Upper: &Bound{Value: val}}, {Equal: equal, Lower: &Bound{Value: val}}}, nil
+	default:
+		return nil, platformerror.NewStackTraceError(fmt.Sprintf("Unknown Operator : %v", op), platformerror.UnknownOperatorErrorCode)
+	}
+	return []Range{r}, nil
+}
+
+// Compare compares two values of the same type as the keys of an index order them. ok is false when they are not
+// of the same type
+func Compare(a, b any) (n int, ok bool, err error) {
+	if reflect.TypeOf(a) != reflect.TypeOf(b) {
+		return 0, false, nil
+	}
+	aBuf, err := platformparser.NewValueMarshaler[any](a).MarshalComparableBinary()
+	if err != nil {
+		return 0, false, err
+	}
+	bBuf, err := platformparser.NewValueMarshaler[any](b).MarshalComparableBinary()
+	if err != nil {
+		return 0, false, err
+	}
+	return bytes.Compare(aBuf, bBuf), true, nil
+}
+
+// keyRange returns the range of keys holding the items of r, nil when no key can match. The keys of the items of
+// some values are exactly the keys starting with their encoding, see ItemKey.MarshalBinary
+func (i *Index) keyRange(r Range) (*keyRange, error) {
+	prefix, err := NewCompositeItemKey(r.Equal, nil).MarshalValueBinary()
+	if err != nil {
+		return nil, err
+	}
+	kr := &keyRange{from: prefix, prefix: prefix}
+	bound := func(b *Bound) ([]byte, error) {
+		buf, err := NewCompositeItemKey([]any{b.Value}, nil).MarshalValueBinary()
+		if err != nil {
+			return nil, err
+		}
+		return append(bytes.Clone(prefix), buf...), nil
+	}
+
+	if r.Lower != nil {
+		from, err := bound(r.Lower)
+		if err != nil {
+			return nil, err
+		}
+		if !r.Lower.Inclusive {
+			// The keys of the value itself are skipped
+			if from = prefixEnd(from); from == nil {
 				return nil, nil
 			}
-			keys = append(keys, key)
-		} else {
-			keyBuf, err := itemKey.MarshalValueBinary()
-			if err != nil {
-				return nil, err
-			}
+		}
+		kr.from = from
+	}
+	if r.Upper != nil {
+		to, err := bound(r.Upper)
+		if err != nil {
+			return nil, err
+		}
+		if r.Upper.Inclusive {
+			// The keys of the value itself are read, with no end past them every key of the prefix is
+			to = prefixEnd(to)
+		}
+		kr.to = to
+		if to != nil && bytes.Compare(kr.from, to) >= 0 {
+			return nil, nil
+		}
+	}
+	return kr, nil
+}
 
-			// TODO: edge case where prefix search can return wrong results if val + part of id as byte same as another val
-			keys, err = i.tree.GetPrefix(keyBuf)
-			if err != nil {
-				return nil, platformerror.NewStackTraceError(err.Error(), platformerror.BTreeReadErrorCode)
-			}
+// Scan calls fn with each item matching op and val in key order, reading the index one leaf at a time, until fn
+// returns false. The value of an index over several columns is the value of its first column
+func (i *Index) Scan(val any, op datatype.Operator, fn func(item Item) (bool, error)) error {
+	return i.ScanPrefix(nil, val, op, fn)
+}
+
+// ScanPrefix is Scan for an index over several columns: the items passed to fn have their leading columns equal to
+// equal and their next column matching op and val. With an empty op every item of the leading columns is passed
+func (i *Index) ScanPrefix(equal []any, val any, op datatype.Operator, fn func(item Item) (bool, error)) error {
+	ranges, err := NewRanges(equal, val, op)
+	if err != nil {
+		return err
+	}
+	return i.ScanRanges(ranges, fn)
+}
+
+// ScanRange is Scan for the items of r
+func (i *Index) ScanRange(r Range, fn func(item Item) (bool, error)) error {
+	return i.ScanRanges([]Range{r}, fn)
+}
+
+// ScanRanges is Scan for the items of every range of ranges, in key order
+func (i *Index) ScanRanges(ranges []Range, fn func(item Item) (bool, error)) error {
+	c := i.NewRangeCursor(ranges)
+	for {
+		item, ok, err := c.Next()
+		if err != nil || !ok {
+			return err
 		}
-	case datatype.OperatorGreater:
-		if i.unique {
-			keys, err = i.tree.GreaterThan(itemKeyBuf)
-			if err != nil {
-				return nil, platformerror.NewStackTraceError(err.Error(), platformerror.BTreeReadErrorCode)
-			}
-		} else {
-			keyBuf, err := itemKey.MarshalValueBinary()
-			if err != nil {
-				return nil, err
-			}
-			keys, err = i.tree.GreaterThan(keyBuf)
-			if err != nil {
-				return nil, platformerror.NewStackTraceError(err.Error(), platformerror.BTreeReadErrorCode)
-			}
+		more, err := fn(item)
+		if err != nil || !more {
+			return err
 		}
-	case datatype.OperatorLess:
-		if i.unique {
-			keys, err = i.tree.LessThan(itemKeyBuf)
-			if err != nil {
-				return nil, platformerror.NewStackTraceError(err.Error(), platformerror.BTreeReadErrorCode)
-			}
-		} else {
-			keyBuf, err := itemKey.MarshalValueBinary()
-			if err != nil {
-				return nil, err
-			}
-			keys, err = i.tree.LessThan(keyBuf)
-			if err != nil {
-				return nil, platformerror.NewStackTraceError(err.Error(), platformerror.BTreeReadErrorCode)
-			}
+	}
+}
+
+// RangeCursor returns the items of ranges one at a time, as ScanRanges passes them, so a reader pulls items only
+// as it needs them. It must not be used once the index changed
+type RangeCursor struct {
+	index  *Index
+	ranges []Range
+	// keyRanges are the keys of ranges left to read, made by the first Next. An item is stored under a single key,
+	// so as they do not overlap each item is returned once
+	keyRanges []*keyRange
+	// cursor reads the keys of kr, the range being read. It is nil before a range is read
+	cursor *btree.Cursor
+	kr     *keyRange
+}
+
+// NewRangeCursor returns a cursor over the items of ranges, in key order
+func (i *Index) NewRangeCursor(ranges []Range) *RangeCursor {
+	return &RangeCursor{index: i, ranges: ranges}
+}
+
+// keyRanges returns the keys of ranges in key order, ranges sharing keys merged into one
+func (i *Index) keyRanges(ranges []Range) ([]*keyRange, error) {
+	krs := make([]*keyRange, 0, len(ranges))
+	for _, r := range ranges {
+		kr, err := i.keyRange(r)
+		if err != nil {
+			return nil, err
 		}
-	case datatype.OperatorGreaterOrEqual:
-		if i.unique {
-			keys, err = i.tree.GreaterThanOrEqual(itemKeyBuf)
-			if err != nil {
-				return nil, platformerror.NewStackTraceError(err.Error(), platformerror.BTreeReadErrorCode)
-			}
-		} else {
-			keyBuf, err := itemKey.MarshalValueBinary()
-			if err != nil {
-				return nil, err
-			}
-			keys, err = i.tree.GreaterThanOrEqual(appendSlice(keyBuf, byte(0x00)))
-			if err != nil {
-				return nil, platformerror.NewStackTraceError(err.Error(), platformerror.BTreeReadErrorCode)
-			}
+		if kr != nil {
+			krs = append(krs, kr.interval())
+		}
+	}
+	slices.SortFunc(krs, func(a, b *keyRange) int {
+		return bytes.Compare(a.from, b.from)
+	})
+
+	merged := make([]*keyRange, 0, len(krs))
+	for _, kr := range krs {
+		last := len(merged) - 1
+		if last < 0 || merged[last].to != nil && bytes.Compare(kr.from, merged[last].to) > 0 {
+			merged = append(merged, kr)
+			continue
 		}
+		if merged[last].to != nil && (kr.to == nil || bytes.Compare(kr.to, merged[last].to) > 0) {
+			merged[last].to = kr.to
+		}
+	}
+	return merged, nil
+}
 
-	case datatype.OperatorLessOrEqual:
-		if i.unique {
-			keys, err = i.tree.LessThanOrEqual(itemKeyBuf)
-			if err != nil {
-				return nil, platformerror.NewStackTraceError(err.Error(), platformerror.BTreeReadErrorCode)
+// Next returns the next item, ok is false once every range was read
+func (c *RangeCursor) Next() (item Item, ok bool, err error) {
+	if c.ranges != nil {
+		if c.keyRanges, err = c.index.keyRanges(c.ranges); err != nil {
+			return Item{}, false, err
+		}
+		c.ranges = nil
+	}
+	for {
+		if c.cursor == nil {
+			if len(c.keyRanges) == 0 {
+				return Item{}, false, nil
 			}
-		} else {
-			keyBuf, err := itemKey.MarshalValueBinary()
-			if err != nil {
-				return nil, err
+			if ok, err = c.seek(); err != nil {
+				return Item{}, false, err
 			}
-			keys, err = i.tree.LessThanOrEqual(appendSlice(keyBuf, byte(0xff)))
-			if err != nil {
-				return nil, platformerror.NewStackTraceError(err.Error(), platformerror.BTreeReadErrorCode)
+			if !ok {
+				continue
 			}
+		} else if ok, err = c.cursor.Next(); err != nil {
+			return Item{}, false, platformerror.NewStackTraceError(err.Error(), platformerror.BTreeReadErrorCode)
 		}
-	case datatype.OperatorNotEqual:
-		return nil, platformerror.NewStackTraceError(fmt.Sprintf("Not yet implemented : %v", op), platformerror.UnknownOperatorErrorCode)
-	default:
-		return nil, platformerror.NewStackTraceError(fmt.Sprintf("Unknown Operator : %v", op), platformerror.UnknownOperatorErrorCode)
-	}
 
-	items := make(map[int64]Item)
+		if !ok || c.kr.above(c.cursor.Key().K) {
+			// The range was read, the next one is
+			c.cursor = nil
+			continue
+		}
+		item = Item{}
+		if err = item.UnmarshalBinary(c.cursor.Key().V); err != nil {
+			return Item{}, false, err
+		}
+		return item, true, nil
+	}
+}
 
-	if keys == nil {
-		return nil, nil
+// seek moves to the first key of the next range, it returns false when the range holds no key
+func (c *RangeCursor) seek() (bool, error) {
+	kr := c.keyRanges[0]
+	c.keyRanges = c.keyRanges[1:]
+
+	cursor := c.index.tree.NewCursor()
+	var ok bool
+	var err error
+	if kr.from != nil {
+		ok, err = cursor.Seek(kr.from)
+	} else {
+		ok, err = cursor.First()
+	}
+	if err != nil {
+		return false, platformerror.NewStackTraceError(err.Error(), platformerror.BTreeReadErrorCode)
+	}
+	if !ok || kr.above(cursor.Key().K) {
+		return false, nil
 	}
+	c.cursor, c.kr = cursor, kr
+	return true, nil
+}
+
+// Entry is a key of the index with the item stored under it
+type Entry struct {
+	Key  []byte
+	Item Item
+}
 
+// Entries returns every entry of the index in key order
+func (i *Index) Entries() ([]Entry, error) {
+	keys, err := i.tree.Keys()
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]Entry, 0, len(keys))
 	for _, k := range keys {
 		item := Item{}
-		err := item.UnmarshalBinary(k.V)
-		if err != nil {
+		if err = item.UnmarshalBinary(k.V); err != nil {
 			return nil, err
 		}
-		if _, exists := items[item.PagePos]; !exists {
-			items[item.PagePos] = item
-		}
-	}
-	uniqueItems := make([]Item, 0)
-	for _, v := range items {
-		uniqueItems = append(uniqueItems, v)
+		entries = append(entries, Entry{Key: k.K, Item: item})
 	}
+	return entries, nil
+}
 
-	return uniqueItems, nil
+// Check returns the broken invariants of the B-tree of the index, see btree.BTree.Check
+func (i *Index) Check() []string {
+	return i.tree.Check()
 }
 
 func (i *Index) LogTree() error {
@@ -0,0 +1,355 @@
+package table
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	stdio "io"
+	"os"
+	"simple-database/internal/engine/table/column"
+	tableparser "simple-database/internal/engine/table/column/parser"
+	"simple-database/internal/engine/table/index"
+	"simple-database/internal/engine/table/page"
+	"simple-database/internal/platform/datatype"
+	platformerror "simple-database/internal/platform/error"
+	"simple-database/internal/platform/helper"
+	"simple-database/internal/platform/parser"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// IndexDefinition describes an index of a table. Indexes created with CREATE INDEX are stored in the table file
+// right after the column definitions, indexes declared by the flags of a column are named after it and not stored
+type IndexDefinition struct {
+	Name string
+	// Columns are the indexed columns, entries are ordered by the first one, then by the next one and so on
+	Columns []string
+	// Include are the columns whose values are stored with the entries without being indexed
+	Include []string
+	Unique  bool
+	// inline is set for the index declared by the flags of its column
+	inline bool
+}
+
+// MarshalBinary encodes the definition as a TLV holding the TLVs of its name, its uniqueness and its columns. The
+// included columns follow the number of them, which is left out when there are none
+func (d *IndexDefinition) MarshalBinary() ([]byte, error) {
+	body := bytes.Buffer{}
+	marshalers := []interface{ MarshalBinary() ([]byte, error) }{
+		parser.NewTLVMarshaler(d.Name),
+		parser.NewTLVMarshaler(d.Unique),
+	}
+	for _, col := range d.Columns {
+		marshalers = append(marshalers, parser.NewTLVMarshaler(col))
+	}
+	if len(d.Include) > 0 {
+		marshalers = append(marshalers, parser.NewTLVMarshaler(int32(len(d.Include))))
+		for _, col := range d.Include {
+			marshalers = append(marshalers, parser.NewTLVMarshaler(col))
+		}
+	}
+	for _, m := range marshalers {
+		b, err := m.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		body.Write(b)
+	}
+
+	buf := bytes.Buffer{}
+	buf.WriteByte(datatype.TypeIndexDefinition)
+	if err := binary.Write(&buf, binary.LittleEndian, uint32(body.Len())); err != nil {
+		return nil, platformerror.NewStackTraceError(err.Error(), platformerror.BinaryWriteErrorCode)
+	}
+	buf.Write(body.Bytes())
+	return buf.Bytes(), nil
+}
+
+func (d *IndexDefinition) UnmarshalBinary(data []byte) error {
+	if len(data) < datatype.LenMeta || data[0] != datatype.TypeIndexDefinition {
+		return platformerror.NewStackTraceError(fmt.Sprintf("Expected an index definition of type %v", datatype.TypeIndexDefinition),
+			platformerror.InvalidDataTypeErrorCode)
+	}
+	n := uint32(datatype.LenMeta)
+
+	name := parser.NewTLVUnmarshaler(parser.NewValueUnmarshaler[string]())
+	if err := name.UnmarshalBinary(data[n:]); err != nil {
+		return err
+	}
+	n += name.BytesRead
+
+	unique := parser.NewTLVUnmarshaler(parser.NewValueUnmarshaler[bool]())
+	if err := unique.UnmarshalBinary(data[n:]); err != nil {
+		return err
+	}
+	n += unique.BytesRead
+
+	readColumn := func() (string, error) {
+		col := parser.NewTLVUnmarshaler(parser.NewValueUnmarshaler[string]())
+		if err := col.UnmarshalBinary(data[n:]); err != nil {
+			return "", err
+		}
+		n += col.BytesRead
+		return col.Value, nil
+	}
+	columns := make([]string, 0, 1)
+	for n < uint32(len(data)) && data[n] == datatype.TypeString {
+		col, err := readColumn()
+		if err != nil {
+			return err
+		}
+		columns = append(columns, col)
+	}
+	include := make([]string, 0)
+	if n < uint32(len(data)) {
+		count := parser.NewTLVUnmarshaler(parser.NewValueUnmarshaler[int32]())
+		if err := count.UnmarshalBinary(data[n:]); err != nil {
+			return err
+		}
+		n += count.BytesRead
+		for range count.Value {
+			col, err := readColumn()
+			if err != nil {
+				return err
+			}
+			include = append(include, col)
+		}
+	}
+
+	d.Name = name.Value
+	d.Unique = unique.Value
+	d.Columns = columns
+	d.Include = include
+	return nil
+}
+
+// values returns the values of the indexed columns of record in the order of the index
+func (d *IndexDefinition) values(record tableparser.RecordValue) []any {
+	values := make([]any, len(d.Columns))
+	for i, col := range d.Columns {
+		values[i] = record[col]
+	}
+	return values
+}
+
+// includedValues returns the values of the included columns of record in the order of the index
+func (d *IndexDefinition) includedValues(record tableparser.RecordValue) []any {
+	values := make([]any, len(d.Include))
+	for i, col := range d.Include {
+		values[i] = record[col]
+	}
+	return values
+}
+
+// item returns the entry of the index for record stored in slot of page num
+func (d *IndexDefinition) item(record tableparser.RecordValue, id any, num int64, slot uint16) *index.Item {
+	return index.NewCoveringItem(d.values(record), d.includedValues(record), id, num, slot)
+}
+
+// stored returns the columns whose values the entries of the index hold: the indexed ones, then the included ones
+func (d *IndexDefinition) stored() []string {
+	return append(slices.Clone(d.Columns), d.Include...)
+}
+
+// inlineIndexDefinitions returns the definitions of the indexes declared by the flags of the columns
+func inlineIndexDefinitions(columns Columns) map[string]*IndexDefinition {
+	definitions := make(map[string]*IndexDefinition)
+	for _, col := range columns {
+		if col.Is(column.UsingIndex) {
+			name := helper.ToString(col.Name[:])
+			definitions[name] = &IndexDefinition{Name: name, Columns: []string{name}, Unique: col.Is(column.UsingUniqueIndex), inline: true}
+		}
+	}
+	return definitions
+}
+
+// readIndexDefinitions reads the definitions stored after the column definitions, up to the first page
+func (t *Table) readIndexDefinitions() error {
+	pos, err := t.endOfColumnDefinitions(t.header.columnsPos(), t.header.FirstPage)
+	if err != nil {
+		return err
+	}
+	t.indexDefinitionsPos = pos
+
+	meta := make([]byte, datatype.LenMeta)
+	for pos+datatype.LenMeta <= t.header.FirstPage {
+		if _, err = t.file.File.ReadAt(meta, pos); err != nil {
+			if err == stdio.EOF {
+				break
+			}
+			return platformerror.NewStackTraceError(err.Error(), platformerror.BinaryReadErrorCode)
+		}
+		if meta[0] != datatype.TypeIndexDefinition {
+			break
+		}
+		buf := make([]byte, datatype.LenMeta+int64(binary.LittleEndian.Uint32(meta[datatype.LenByte:])))
+		if _, err = t.file.File.ReadAt(buf, pos); err != nil {
+			return platformerror.NewStackTraceError(err.Error(), platformerror.BinaryReadErrorCode)
+		}
+		definition := &IndexDefinition{}
+		if err = definition.UnmarshalBinary(buf); err != nil {
+			return err
+		}
+		t.indexDefinitions[definition.Name] = definition
+		pos += int64(len(buf))
+	}
+	return nil
+}
+
+// marshalIndexDefinitions returns the stored definitions ordered by name, followed by a 0 byte ending them
+func (t *Table) marshalIndexDefinitions() ([]byte, error) {
+	buf := bytes.Buffer{}
+	for _, name := range t.indexNames() {
+		definition := t.indexDefinitions[name]
+		if definition.inline {
+			continue
+		}
+		b, err := definition.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(b)
+	}
+	buf.WriteByte(0)
+	return buf.Bytes(), nil
+}
+
+// writeIndexDefinitions rewrites the stored definitions in place. They must fit before the first page
+func (t *Table) writeIndexDefinitions() error {
+	b, err := t.marshalIndexDefinitions()
+	if err != nil {
+		return err
+	}
+	if t.indexDefinitionsPos+int64(len(b)) > t.header.FirstPage {
+		return platformerror.NewStackTraceError(fmt.Sprintf("The index definitions of table %s take %d bytes, only %d are left before its first page",
+			t.Name, len(b), t.header.FirstPage-t.indexDefinitionsPos), platformerror.RecordTooLargeErrorCode)
+	}
+	if _, err = t.file.WriteAt(b, t.indexDefinitionsPos); err != nil {
+		return platformerror.NewStackTraceError(err.Error(), platformerror.BinaryWriteErrorCode)
+	}
+	return nil
+}
+
+// indexNames returns the names of the indexes of the table in order
+func (t *Table) indexNames() []string {
+	names := make([]string, 0, len(t.indexDefinitions))
+	for name := range t.indexDefinitions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// HasIndex reports whether the table has an index called name
+func (t *Table) HasIndex(name string) bool {
+	_, ok := t.indexDefinitions[name]
+	return ok
+}
+
+// CreateIndex builds the index described by definition from the records of the table file and stores its
+// definition. The index is built next to its file and moved over it once complete, a unique index fails on the
+// first value found twice
+func (t *Table) CreateIndex(definition IndexDefinition) error {
+	if _, ok := t.indexDefinitions[definition.Name]; ok {
+		return platformerror.NewStackTraceError(fmt.Sprintf("Index %s of table %s already existed", definition.Name, t.Name),
+			platformerror.IndexAlreadyExistsErrorCode)
+	}
+	if len(definition.Columns) == 0 {
+		return platformerror.NewStackTraceError(fmt.Sprintf("Index %s has no column", definition.Name),
+			platformerror.ColumnViolationErrorCode)
+	}
+	seen := make(map[string]bool)
+	for _, col := range definition.stored() {
+		if _, ok := t.columns[col]; !ok {
+			return platformerror.NewStackTraceError(fmt.Sprintf("Unknown column: %s", col),
+				platformerror.ColumnViolationErrorCode)
+		}
+		if seen[col] {
+			return platformerror.NewStackTraceError(fmt.Sprintf("Column %s is stored twice by index %s", col, definition.Name),
+				platformerror.ColumnViolationErrorCode)
+		}
+		seen[col] = true
+	}
+	definition.inline = false
+
+	idxPath := t.indexFileName(definition.Name)
+	if err := os.Remove(idxPath + VacuumExtension); err != nil && !os.IsNotExist(err) {
+		return platformerror.NewStackTraceError(err.Error(), platformerror.DeleteFileErrorCode)
+	}
+	built := index.NewBuilder(idxPath+VacuumExtension, definition.Unique, t.sortMemory)
+	defer built.Close()
+	primaryKeyColumnName := t.getPrimaryKeyColumnName()
+	err := t.forEachPage(func(p *page.Page) (bool, error) {
+		for slot := uint16(0); slot < p.SlotCount(); slot++ {
+			if _, ok := p.Record(slot); !ok {
+				continue
+			}
+			rawRecord, err := t.parseRecord(p, slot)
+			if err != nil {
+				return false, err
+			}
+			item := definition.item(rawRecord.Record, rawRecord.Record[primaryKeyColumnName], p.Num, slot)
+			if err = built.Add(item); err != nil {
+				return false, err
+			}
+		}
+		return true, nil
+	})
+	if err == nil {
+		err = built.Build()
+	}
+	if err == nil {
+		if err = os.Rename(idxPath+VacuumExtension, idxPath); err != nil {
+			err = platformerror.NewStackTraceError(err.Error(), platformerror.OpenFileErrorCode)
+		}
+	}
+	if err != nil {
+		_ = os.Remove(idxPath + VacuumExtension)
+		return err
+	}
+
+	// The index file is only used once its definition is stored
+	t.indexDefinitions[definition.Name] = &definition
+	t.log.Begin()
+	if err = t.endOperation(t.writeIndexDefinitions()); err != nil {
+		delete(t.indexDefinitions, definition.Name)
+		_ = os.Remove(idxPath)
+		return err
+	}
+	t.indexes[definition.Name] = index.NewIndex(idxPath, definition.Unique, t.log, t.pool)
+	helper.Log.Infof("Created index %s on %s of table %s", definition.Name, strings.Join(definition.Columns, ", "), t.Name)
+	return nil
+}
+
+// DropIndex removes the index called name and its definition. Indexes declared by the flags of a column cannot
+// be dropped
+func (t *Table) DropIndex(name string) error {
+	definition, ok := t.indexDefinitions[name]
+	if !ok {
+		return platformerror.NewStackTraceError(fmt.Sprintf("Index %s of table %s not existed", name, t.Name),
+			platformerror.IndexNotExistsErrorCode)
+	}
+	if definition.inline {
+		return platformerror.NewStackTraceError(fmt.Sprintf("Index %s is declared by column %s of table %s and cannot be dropped",
+			name, definition.Columns[0], t.Name), platformerror.ColumnViolationErrorCode)
+	}
+
+	delete(t.indexDefinitions, name)
+	t.log.Begin()
+	if err := t.endOperation(t.writeIndexDefinitions()); err != nil {
+		t.indexDefinitions[name] = definition
+		return err
+	}
+
+	idx := t.indexes[name]
+	delete(t.indexes, name)
+	if err := idx.Close(); err != nil {
+		return platformerror.NewStackTraceError(err.Error(), platformerror.CloseErrorCode)
+	}
+	if err := os.Remove(t.indexFileName(name)); err != nil {
+		return platformerror.NewStackTraceError(err.Error(), platformerror.DeleteFileErrorCode)
+	}
+	helper.Log.Infof("Dropped index %s of table %s", name, t.Name)
+	return nil
+}
@@ -0,0 +1,760 @@
+package table
+
+import (
+	"fmt"
+	"path/filepath"
+	tableparser "simple-database/internal/engine/table/column/parser"
+	"simple-database/internal/platform/datatype"
+	platformerror "simple-database/internal/platform/error"
+	"simple-database/internal/platform/evaluator"
+	"slices"
+	"strings"
+)
+
+const (
+	JoinTypeInner = "INNER"
+	JoinTypeLeft  = "LEFT"
+	JoinTypeRight = "RIGHT"
+)
+
+const (
+	// JoinStrategyNestedLoop is reported when the joined table was read again for every row joined to it
+	JoinStrategyNestedLoop = "NestedLoop"
+	// JoinStrategyIndexNestedLoop is reported when the rows of the joined table matching each row joined to it were
+	// looked up in one of its indexes
+	JoinStrategyIndexNestedLoop = "IndexNestedLoop"
+	// JoinStrategyHash is reported when the rows of the joined table were read once and kept in a hash table by the
+	// values compared with = by ON
+	JoinStrategyHash = "Hash"
+)
+
+// Join joins the rows of TableName, named Alias in the columns of the select when set, to the rows of the tables
+// before it. INNER keeps the pairs of rows matching On. LEFT also keeps the rows before it matching no row of
+// TableName, RIGHT the rows of TableName matching none of them, the columns of the missing side being nil
+type Join struct {
+	Type      string
+	TableName string
+	Alias     string
+	On        *evaluator.Expression
+}
+
+// joinedTable is a table of a select joining tables
+type joinedTable struct {
+	table *Table
+	// name qualifies the columns of the table in the joined rows, name.column
+	name string
+	// nullable is set when an outer join gives rows without a row of the table
+	nullable bool
+	// read holds the columns of the table the select reads
+	read []string
+	// filter holds the conditions on the table alone its rows must match, see pushDown
+	filter []*evaluator.Expression
+}
+
+// filterExpression returns the conditions of filter joined by AND, nil without any
+func (j *joinedTable) filterExpression() *evaluator.Expression {
+	var expression *evaluator.Expression
+	for _, e := range j.filter {
+		if expression == nil {
+			expression = e
+			continue
+		}
+		expression = &evaluator.Expression{Left: expression, Op: datatype.OperatorAnd, Right: e}
+	}
+	return expression
+}
+
+// open returns the operator reading the rows of the table matching its filter found by path, all of them when it
+// is nil. The rows inspected are added to selectResult
+func (j *joinedTable) open(path *accessPath, selectResult *SelectResult) Operator {
+	return newFilter(j.table.scan(path, selectResult), j.filterExpression())
+}
+
+// planAccess returns how the rows of the table matching its filter are found
+func (j *joinedTable) planAccess() *accessPath {
+	return j.table.planAccess(j.filterExpression(), j.read)
+}
+
+// joinColumns resolves the columns named by a select joining tables. A column is named name.column after its table,
+// or column alone when no other table has a column of that name. The joined rows hold the values of both names
+type joinColumns struct {
+	tables []*joinedTable
+	// owners holds the tables having each column name
+	owners map[string][]int
+}
+
+func newJoinColumns(tables []*joinedTable) *joinColumns {
+	c := &joinColumns{tables: tables, owners: make(map[string][]int)}
+	for i, j := range tables {
+		for _, col := range j.table.ColumnNames {
+			c.owners[col] = append(c.owners[col], i)
+		}
+	}
+	return c
+}
+
+// resolve returns the table and the column name refers to, ok is false when it names no column. A column name
+// more than one table has is an error
+func (c *joinColumns) resolve(name string) (table int, col string, ok bool, err error) {
+	if qualifier, col, found := strings.Cut(name, "."); found {
+		for i, j := range c.tables {
+			if j.name == qualifier && slices.Contains(j.table.ColumnNames, col) {
+				return i, col, true, nil
+			}
+		}
+		return 0, "", false, nil
+	}
+	owners := c.owners[name]
+	switch len(owners) {
+	case 0:
+		return 0, "", false, nil
+	case 1:
+		return owners[0], name, true, nil
+	}
+	return 0, "", false, platformerror.NewStackTraceError(fmt.Sprintf("ambiguous column: %s, name it after its table", name),
+		platformerror.ColumnViolationErrorCode)
+}
+
+// names returns every name a joined row holds a value under
+func (c *joinColumns) names() []string {
+	names := make([]string, 0)
+	for _, j := range c.tables {
+		for _, col := range j.table.ColumnNames {
+			names = append(names, j.name+"."+col)
+			if len(c.owners[col]) == 1 {
+				names = append(names, col)
+			}
+		}
+	}
+	return names
+}
+
+// types returns the data type of the value under every name of names
+func (c *joinColumns) types() map[string]byte {
+	types := make(map[string]byte)
+	for _, j := range c.tables {
+		for name, typ := range j.table.columnTypes() {
+			types[j.name+"."+name] = typ
+			if len(c.owners[name]) == 1 {
+				types[name] = typ
+			}
+		}
+	}
+	return types
+}
+
+// qualifiedNames returns the name of every column after its table, in the order of the tables
+func (c *joinColumns) qualifiedNames() []string {
+	names := make([]string, 0)
+	for _, j := range c.tables {
+		for _, col := range j.table.ColumnNames {
+			names = append(names, j.name+"."+col)
+		}
+	}
+	return names
+}
+
+// set sets the value of column col of table i in the joined row
+func (c *joinColumns) set(row tableparser.RecordValue, i int, col string, val any) {
+	row[c.tables[i].name+"."+col] = val
+	if len(c.owners[col]) == 1 {
+		row[col] = val
+	}
+}
+
+// add adds the values of record, a row of table i, to the joined row
+func (c *joinColumns) add(row tableparser.RecordValue, i int, record tableparser.RecordValue) {
+	for col, val := range record {
+		c.set(row, i, col, val)
+	}
+}
+
+// addNulls adds the columns of the tables from the first to the last without values to the joined row
+func (c *joinColumns) addNulls(row tableparser.RecordValue, first, last int) {
+	for i := first; i <= last; i++ {
+		for _, col := range c.tables[i].table.ColumnNames {
+			c.set(row, i, col, nil)
+		}
+	}
+}
+
+// tablesOf returns the tables whose columns v reads
+func (c *joinColumns) tablesOf(v any) ([]int, error) {
+	tables := make([]int, 0)
+	for _, name := range evaluator.Columns(v) {
+		i, _, ok, err := c.resolve(name)
+		if err != nil {
+			return nil, err
+		}
+		if ok && !slices.Contains(tables, i) {
+			tables = append(tables, i)
+		}
+	}
+	return tables, nil
+}
+
+// unqualify returns a copy of v naming the columns of table i as the table does, so it can be evaluated on its rows
+func (c *joinColumns) unqualify(v any, i int) any {
+	switch x := v.(type) {
+	case string:
+		if table, col, ok, _ := c.resolve(x); ok && table == i {
+			return col
+		}
+		return x
+	case evaluator.Expression:
+		return evaluator.Expression{Left: c.unqualify(x.Left, i), Op: x.Op, Right: c.unqualify(x.Right, i)}
+	case *evaluator.Expression:
+		if x == nil {
+			return x
+		}
+		e := c.unqualify(*x, i).(evaluator.Expression)
+		return &e
+	case []any:
+		values := make([]any, 0, len(x))
+		for _, value := range x {
+			values = append(values, c.unqualify(value, i))
+		}
+		return values
+	case evaluator.Function:
+		return evaluator.Function{Name: x.Name, Args: c.unqualify(x.Args, i).([]any)}
+	}
+	return v
+}
+
+// JoinOperator joins the rows of a table, the inner table, to the rows of its child, the outer rows holding the
+// rows of the tables before it. It is not named Join as that is the join of a select
+type JoinOperator struct {
+	outer   Operator
+	join    Join
+	inner   int
+	columns *joinColumns
+	// strategy is one of the JoinStrategy constants
+	strategy string
+	// outerKeys and innerKeys are the names in the outer rows and the columns of the inner table compared with = by
+	// On, in pairs
+	outerKeys []string
+	innerKeys []string
+	// definition is the index of the inner table an index nested loop looks the rows up in, covering is set when
+	// its entries hold every column read from the table
+	definition *IndexDefinition
+	covering   bool
+	// path finds the rows of the inner table read by a nested loop
+	path *accessPath
+	// rows and hashed hold the rows of the inner table of a hash join and their positions by key
+	rows   []tableparser.RawRecord
+	hashed map[string][]int
+	// matched holds the rows of the inner table of a RIGHT join matching an outer row
+	matched map[recordLocation]bool
+	// current is the outer row being joined, candidates returns the rows of the inner table it may match and found
+	// is set once one did
+	current    *tableparser.RawRecord
+	candidates Operator
+	found      bool
+	// unmatched returns the rows of the inner table of a RIGHT join once every outer row was read
+	unmatched    Operator
+	outerRead    bool
+	selectResult *SelectResult
+	evaluator    evaluator.SimpleEvaluator
+}
+
+// joinKey returns the key of the values of names in row, false when one of them has no value as it matches nothing
+func joinKey(names []string, row tableparser.RecordValue) ([]byte, bool, error) {
+	orderBy := make([]OrderBy, 0, len(names))
+	for _, name := range names {
+		if row[name] == nil {
+			return nil, false, nil
+		}
+		orderBy = append(orderBy, OrderBy{Column: name})
+	}
+	key, err := sortKey(orderBy, row)
+	return key, err == nil, err
+}
+
+// plan chooses the strategy of the join. An index of the inner table narrowed by the columns compared with = to
+// the outer rows is looked up for every outer row, the rows of the inner table are hashed on these columns without
+// one, and read again for every outer row when On compares no column of the inner table with = to an outer one
+func (j *JoinOperator) plan() error {
+	inner := j.columns.tables[j.inner]
+	for _, e := range conjuncts(j.join.On) {
+		if e.Op != datatype.OperatorEqual {
+			continue
+		}
+		left, leftOk := e.Left.(string)
+		right, rightOk := e.Right.(string)
+		if !leftOk || !rightOk {
+			continue
+		}
+		leftTable, leftCol, leftOk, err := j.columns.resolve(left)
+		if err != nil {
+			return err
+		}
+		rightTable, rightCol, rightOk, err := j.columns.resolve(right)
+		if err != nil {
+			return err
+		}
+		switch {
+		case !leftOk || !rightOk:
+		case leftTable == j.inner && rightTable < j.inner:
+			j.outerKeys, j.innerKeys = append(j.outerKeys, right), append(j.innerKeys, leftCol)
+		case rightTable == j.inner && leftTable < j.inner:
+			j.outerKeys, j.innerKeys = append(j.outerKeys, left), append(j.innerKeys, rightCol)
+		}
+	}
+
+	if len(j.innerKeys) == 0 {
+		j.strategy, j.path = JoinStrategyNestedLoop, inner.planAccess()
+		return nil
+	}
+	// The values are those of the outer rows, any value plans the same index
+	predicates := make([]predicate, 0, len(j.innerKeys))
+	for _, col := range j.innerKeys {
+		predicates = append(predicates, predicate{column: col, op: datatype.OperatorEqual, value: int64(0)})
+	}
+	read := inner.table.readColumns(inner.filterExpression(), inner.read)
+	if plans := inner.table.indexPlans(valueRanges(predicates), read); len(plans) > 0 {
+		j.strategy, j.definition, j.covering = JoinStrategyIndexNestedLoop, inner.table.indexDefinitions[plans[0].name], plans[0].covering
+		return nil
+	}
+	j.strategy = JoinStrategyHash
+	return nil
+}
+
+// Open reads the rows of the inner table of a hash join into its hash table
+func (j *JoinOperator) Open() error {
+	j.current, j.candidates, j.unmatched, j.outerRead = nil, nil, nil, false
+	if j.join.Type == JoinTypeRight {
+		j.matched = make(map[recordLocation]bool)
+	}
+	if err := j.outer.Open(); err != nil {
+		return err
+	}
+	if j.strategy != JoinStrategyHash {
+		return nil
+	}
+
+	inner := j.columns.tables[j.inner]
+	rows := inner.open(inner.planAccess(), j.selectResult)
+	defer rows.Close()
+	if err := rows.Open(); err != nil {
+		return err
+	}
+	j.rows, j.hashed = make([]tableparser.RawRecord, 0), make(map[string][]int)
+	for {
+		row, ok, err := rows.Next()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return nil
+		}
+		key, ok, err := joinKey(j.innerKeys, row.Record)
+		if err != nil {
+			return err
+		}
+		if ok {
+			j.hashed[string(key)] = append(j.hashed[string(key)], len(j.rows))
+		}
+		j.rows = append(j.rows, row)
+	}
+}
+
+// Next returns the next joined row. The rows of the inner table matching each outer row are returned as soon as
+// they are found, LEFT returns an outer row matching none once they were all read and RIGHT the rows of the inner
+// table matching no outer row once the outer rows were all read
+func (j *JoinOperator) Next() (tableparser.RawRecord, bool, error) {
+	for {
+		if j.current == nil {
+			if j.outerRead {
+				return j.nextUnmatched()
+			}
+			outer, ok, err := j.outer.Next()
+			if err != nil {
+				return outer, false, err
+			}
+			if !ok {
+				j.outerRead = true
+				if err = j.openUnmatched(); err != nil {
+					return outer, false, err
+				}
+				continue
+			}
+			j.current, j.found = &outer, false
+			if err = j.openCandidates(outer); err != nil {
+				return outer, false, err
+			}
+		}
+
+		if j.candidates != nil {
+			row, ok, err := j.candidates.Next()
+			if err != nil {
+				return row, false, err
+			}
+			if ok {
+				if joined, match := j.match(row); match {
+					return joined, true, nil
+				}
+				continue
+			}
+			err = j.candidates.Close()
+			if j.candidates = nil; err != nil {
+				return row, false, err
+			}
+		}
+
+		outer := j.current
+		j.current = nil
+		if !j.found && j.join.Type == JoinTypeLeft {
+			joined := make(tableparser.RecordValue, len(outer.Record)+2*len(j.columns.tables[j.inner].table.ColumnNames))
+			for name, val := range outer.Record {
+				joined[name] = val
+			}
+			j.columns.addNulls(joined, j.inner, j.inner)
+			return tableparser.RawRecord{Record: joined}, true, nil
+		}
+	}
+}
+
+// openCandidates opens the operator returning the rows of the inner table the outer row may match, none when a
+// value compared with = has no value
+func (j *JoinOperator) openCandidates(outer tableparser.RawRecord) error {
+	inner := j.columns.tables[j.inner]
+	switch j.strategy {
+	case JoinStrategyHash:
+		key, ok, err := joinKey(j.outerKeys, outer.Record)
+		if err != nil || !ok {
+			return err
+		}
+		rows := make([]tableparser.RawRecord, 0, len(j.hashed[string(key)]))
+		for _, i := range j.hashed[string(key)] {
+			rows = append(rows, j.rows[i])
+		}
+		j.candidates = &rowList{rows: rows}
+	case JoinStrategyIndexNestedLoop:
+		predicates := make([]predicate, 0, len(j.innerKeys))
+		for i, col := range j.innerKeys {
+			if outer.Record[j.outerKeys[i]] == nil {
+				return nil
+			}
+			predicates = append(predicates, predicate{column: col, op: datatype.OperatorEqual, value: outer.Record[j.outerKeys[i]]})
+		}
+		// The filter of the inner table may narrow the index further
+		for _, e := range inner.filter {
+			if p, ok := inner.table.predicate(e); ok {
+				predicates = append(predicates, p)
+			}
+		}
+		plan := planIndex(j.definition, valueRanges(predicates))
+		plan.covering = j.covering
+		j.candidates = inner.open(&accessPath{scan: plan}, j.selectResult)
+	default:
+		j.candidates = inner.open(j.path, j.selectResult)
+	}
+	return j.candidates.Open()
+}
+
+// match returns the outer row joined to a row of the inner table, false when they do not match On
+func (j *JoinOperator) match(row tableparser.RawRecord) (tableparser.RawRecord, bool) {
+	joined := make(tableparser.RecordValue, len(j.current.Record)+2*len(row.Record))
+	for name, val := range j.current.Record {
+		joined[name] = val
+	}
+	j.columns.add(joined, j.inner, row.Record)
+	if !j.evaluator.Eval(*j.join.On, joined) {
+		return tableparser.RawRecord{}, false
+	}
+	j.found = true
+	if j.matched != nil {
+		j.matched[recordLocation{page: row.Page, slot: row.Slot}] = true
+	}
+	return tableparser.RawRecord{Record: joined}, true
+}
+
+// openUnmatched opens the operator returning the rows of the inner table of a RIGHT join, a hash join keeps them
+func (j *JoinOperator) openUnmatched() error {
+	if j.join.Type != JoinTypeRight {
+		return nil
+	}
+	if j.strategy == JoinStrategyHash {
+		j.unmatched = &rowList{rows: j.rows}
+	} else {
+		inner := j.columns.tables[j.inner]
+		j.unmatched = inner.open(inner.planAccess(), j.selectResult)
+	}
+	return j.unmatched.Open()
+}
+
+// nextUnmatched returns the next row of the inner table of a RIGHT join matching no outer row
+func (j *JoinOperator) nextUnmatched() (tableparser.RawRecord, bool, error) {
+	if j.unmatched == nil {
+		return tableparser.RawRecord{}, false, nil
+	}
+	for {
+		row, ok, err := j.unmatched.Next()
+		if err != nil || !ok {
+			return row, false, err
+		}
+		if j.matched[recordLocation{page: row.Page, slot: row.Slot}] {
+			continue
+		}
+		joined := make(tableparser.RecordValue)
+		j.columns.addNulls(joined, 0, j.inner-1)
+		j.columns.add(joined, j.inner, row.Record)
+		return tableparser.RawRecord{Record: joined}, true, nil
+	}
+}
+
+func (j *JoinOperator) Close() error {
+	for _, op := range []Operator{j.candidates, j.unmatched} {
+		if op != nil {
+			_ = op.Close()
+		}
+	}
+	j.current, j.candidates, j.unmatched = nil, nil, nil
+	j.rows, j.hashed, j.matched = nil, nil, nil
+	return j.outer.Close()
+}
+
+// joinedTables returns the tables of a select joining tables, those an outer join gives rows without marked
+// nullable
+func joinedTables(tables map[string]*Table, command SelectCommand) ([]*joinedTable, error) {
+	joined := make([]*joinedTable, 0, len(command.Joins)+1)
+	add := func(tableName, alias string) error {
+		t, ok := tables[tableName]
+		if !ok {
+			return platformerror.NewStackTraceError(fmt.Sprintf("Table %s not existed", tableName), platformerror.TableNotExistsErrorCode)
+		}
+		name := tableName
+		if alias != "" {
+			name = alias
+		}
+		for _, j := range joined {
+			if j.name == name {
+				return platformerror.NewStackTraceError(fmt.Sprintf("Table %s is joined twice, give it an alias", name),
+					platformerror.ColumnViolationErrorCode)
+			}
+		}
+		joined = append(joined, &joinedTable{table: t, name: name, read: make([]string, 0)})
+		return nil
+	}
+	if err := add(command.TableName, command.TableAlias); err != nil {
+		return nil, err
+	}
+	for i, join := range command.Joins {
+		if err := add(join.TableName, join.Alias); err != nil {
+			return nil, err
+		}
+		switch join.Type {
+		case JoinTypeInner:
+		case JoinTypeLeft:
+			joined[i+1].nullable = true
+		case JoinTypeRight:
+			for _, j := range joined[:i+1] {
+				j.nullable = true
+			}
+		default:
+			return nil, platformerror.NewStackTraceError(fmt.Sprintf("unknown join type: %s", join.Type), platformerror.UnknownOperatorErrorCode)
+		}
+		if join.On == nil {
+			return nil, platformerror.NewStackTraceError(fmt.Sprintf("%s JOIN %s needs ON", join.Type, join.TableName),
+				platformerror.UnknownOperatorErrorCode)
+		}
+	}
+	return joined, nil
+}
+
+// pushDown adds the conditions of the select on a single table to the filter of the table, so its rows are
+// narrowed as they are read, through its indexes when they help. A condition of WHERE is pushed down to a table
+// no outer join gives rows without, one of ON to the table joined by an INNER or LEFT join. Every condition is
+// still evaluated on the joined rows
+func pushDown(columns *joinColumns, command SelectCommand) error {
+	push := func(e *evaluator.Expression, allowed func(table int) bool) error {
+		tables, err := columns.tablesOf(e)
+		if err != nil {
+			return err
+		}
+		if len(tables) == 1 && allowed(tables[0]) {
+			i := tables[0]
+			columns.tables[i].filter = append(columns.tables[i].filter, columns.unqualify(e, i).(*evaluator.Expression))
+		}
+		return nil
+	}
+	for _, e := range conjuncts(command.Expression) {
+		if err := push(e, func(table int) bool { return !columns.tables[table].nullable }); err != nil {
+			return err
+		}
+	}
+	for i, join := range command.Joins {
+		if join.Type == JoinTypeRight {
+			continue
+		}
+		for _, e := range conjuncts(join.On) {
+			if err := push(e, func(table int) bool { return table == i+1 }); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// validateJoin checks the columns named by a select joining tables and records those read from each table
+func validateJoin(columns *joinColumns, command SelectCommand) error {
+	unknown := func(clause, col string) error {
+		return platformerror.NewStackTraceError(fmt.Sprintf("unknown column in %s: %s", clause, col), platformerror.ColumnViolationErrorCode)
+	}
+	read := func(name string) (bool, error) {
+		i, col, ok, err := columns.resolve(name)
+		if ok && !slices.Contains(columns.tables[i].read, col) {
+			columns.tables[i].read = append(columns.tables[i].read, col)
+		}
+		return ok, err
+	}
+	// Names of no column are values in the expressions, unless they are named after a table
+	readAll := func(clause string, v any) error {
+		for _, name := range evaluator.Columns(v) {
+			if ok, err := read(name); err != nil {
+				return err
+			} else if qualifier, _, found := strings.Cut(name, "."); !ok && found &&
+				slices.ContainsFunc(columns.tables, func(j *joinedTable) bool { return j.name == qualifier }) {
+				return unknown(clause, name)
+			}
+		}
+		return nil
+	}
+
+	for _, col := range command.SelectColumns {
+		if ok, err := read(col); err != nil {
+			return err
+		} else if !ok {
+			return unknown("select", col)
+		}
+	}
+	for _, col := range command.GroupBy {
+		if ok, err := read(col); err != nil {
+			return err
+		} else if !ok {
+			return unknown("group by", col)
+		}
+	}
+	for _, a := range command.Aggregates {
+		if a.Column == "" {
+			continue
+		}
+		if ok, err := read(a.Column); err != nil {
+			return err
+		} else if !ok {
+			return unknown("aggregate", a.Column)
+		}
+	}
+	for _, o := range command.OrderBy {
+		if slices.ContainsFunc(command.Projections, func(p Projection) bool { return p.Name == o.Column }) {
+			continue
+		}
+		if _, err := read(o.Column); err != nil {
+			return err
+		}
+	}
+	for _, p := range command.Projections {
+		if err := readAll("select", p.Expression); err != nil {
+			return err
+		}
+	}
+	if err := readAll("where statement", command.Expression); err != nil {
+		return err
+	}
+	for _, key := range command.Expression.Keys() {
+		if _, _, ok, _ := columns.resolve(key); !ok {
+			return unknown("where statement", key)
+		}
+	}
+	for i, join := range command.Joins {
+		if err := readAll("on", join.On); err != nil {
+			return err
+		}
+		// ON compares the columns of the tables joined so far
+		for _, key := range join.On.Keys() {
+			if table, _, ok, _ := columns.resolve(key); !ok || table > i+1 {
+				return unknown("on", key)
+			}
+		}
+	}
+	return nil
+}
+
+// SelectJoin runs a select joining the table of command to the tables of its joins, all found in tables, and
+// returns all of its rows at once, held in SelectResult.Rows. QueryJoin returns them one at a time instead
+func SelectJoin(tables map[string]*Table, command SelectCommand) (*SelectResult, error) {
+	first, ok := tables[command.TableName]
+	if !ok {
+		return nil, platformerror.NewStackTraceError(fmt.Sprintf("Table %s not existed", command.TableName), platformerror.TableNotExistsErrorCode)
+	}
+	before := first.pool.Stats()
+	op, selectResult, err := QueryJoin(tables, command)
+	if err != nil {
+		return nil, err
+	}
+	if err = collect(op, selectResult); err != nil {
+		return nil, err
+	}
+	after := first.pool.Stats()
+	selectResult.Extra = fmt.Sprintf("Buffer pool: %d hits, %d misses", after.Hits-before.Hits, after.Misses-before.Misses)
+	return selectResult, nil
+}
+
+// QueryJoin returns the operators of a select joining tables. The rows of the first table are read and joined to
+// the rows of each joined table in turn, see JoinOperator. The joined rows matching WHERE are then grouped, sorted
+// and projected as the rows of a single table. The access type is that of the first table
+func QueryJoin(tables map[string]*Table, command SelectCommand) (Operator, *SelectResult, error) {
+	joined, err := joinedTables(tables, command)
+	if err != nil {
+		return nil, nil, err
+	}
+	first := joined[0].table
+	columns := newJoinColumns(joined)
+	if slices.Contains(command.SelectColumns, "*") && command.Projections == nil && !command.grouped() {
+		command.SelectColumns = columns.qualifiedNames()
+	}
+	if err = validateJoin(columns, command); err != nil {
+		return nil, nil, err
+	}
+	if err = pushDown(columns, command); err != nil {
+		return nil, nil, err
+	}
+
+	names := columns.names()
+	if command.grouped() {
+		if names, err = validateGroups(command, columns.types()); err != nil {
+			return nil, nil, err
+		}
+	}
+	rows, err := newSelectRows(command, names)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	selectResult := newSelectResult()
+	path := joined[0].planAccess()
+	selectResult.AccessType = path.accessType()
+	var op Operator = newProject(joined[0].open(path, selectResult), func(row tableparser.RawRecord) (tableparser.RawRecord, error) {
+		record := make(tableparser.RecordValue, 2*len(row.Record))
+		columns.add(record, 0, row.Record)
+		return tableparser.RawRecord{Record: record}, nil
+	})
+	for i, join := range command.Joins {
+		j := &JoinOperator{outer: op, join: join, inner: i + 1, columns: columns, selectResult: selectResult}
+		if err = j.plan(); err != nil {
+			return nil, nil, err
+		}
+		selectResult.JoinStrategies = append(selectResult.JoinStrategies, j.strategy)
+		op = j
+	}
+	op = newFilter(op, command.Expression)
+
+	if command.grouped() {
+		agg := newHashAggregator(command.GroupBy, command.Aggregates, first.sortMemory, filepath.Dir(first.file.Name()), first.Name)
+		op = newHaving(newAggregateOperator(op, agg, selectResult), command)
+	}
+	if len(command.OrderBy) > 0 {
+		op = rows.sort(op, first, selectResult)
+	}
+	return rows.output(op), selectResult, nil
+}
@@ -0,0 +1,409 @@
+package table
+
+import (
+	"path/filepath"
+	tableparser "simple-database/internal/engine/table/column/parser"
+	"simple-database/internal/engine/table/index"
+	"simple-database/internal/engine/table/page"
+	"simple-database/internal/platform/evaluator"
+	"simple-database/internal/platform/helper"
+)
+
+// Operator is a step of the execution of a select. The operators of a select make a tree: each one pulls the rows
+// it needs from its children, so rows are read only as the root is asked for them and are kept in memory only by the
+// operators that must see every row first, as Sort and AggregateOperator. Open must be called before Next, Close
+// once the rows are no longer needed, even when Open or Next failed
+type Operator interface {
+	Open() error
+	// Next returns the next row, ok is false once there are none
+	Next() (row tableparser.RawRecord, ok bool, err error)
+	Close() error
+}
+
+// SeqScan returns every record of a table in the order of the table file, reading a page only once the records of
+// the previous one were returned
+type SeqScan struct {
+	table        *Table
+	selectResult *SelectResult
+	// num is the number of the next page to read, p the page whose records are returned and slot the next of them
+	num  int64
+	p    *page.Page
+	slot uint16
+}
+
+func newSeqScan(t *Table, selectResult *SelectResult) *SeqScan {
+	return &SeqScan{table: t, selectResult: selectResult}
+}
+
+func (s *SeqScan) Open() error {
+	s.num, s.p, s.slot = s.table.header.FirstPage/PageSize, nil, 0
+	return nil
+}
+
+func (s *SeqScan) Next() (tableparser.RawRecord, bool, error) {
+	for {
+		if s.p == nil {
+			if s.table.header.LastPage == 0 || s.num > s.table.header.LastPage/PageSize {
+				return tableparser.RawRecord{}, false, nil
+			}
+			data, err := s.table.readPageData(s.num)
+			if err != nil {
+				return tableparser.RawRecord{}, false, err
+			}
+			s.num++
+			// Overflow pages hold no record
+			if page.IsOverflow(data) {
+				continue
+			}
+			if s.p, err = page.Load(s.num-1, data); err != nil {
+				return tableparser.RawRecord{}, false, err
+			}
+			s.slot = 0
+		}
+		for ; s.slot < s.p.SlotCount(); s.slot++ {
+			if _, ok := s.p.Record(s.slot); !ok {
+				continue
+			}
+			rawRecord, err := s.table.parseRecord(s.p, s.slot)
+			if err != nil {
+				return tableparser.RawRecord{}, false, err
+			}
+			s.slot++
+			s.selectResult.RowsInspected++
+			return *rawRecord, true, nil
+		}
+		s.p = nil
+	}
+}
+
+func (s *SeqScan) Close() error {
+	s.p = nil
+	return nil
+}
+
+// IndexScan returns the records found by an access path through the indexes of a table. The items of a single
+// index are read as records are returned, so a select stops reading the index at its limit. The records found by
+// several indexes are collected first and returned in the order of the table file
+type IndexScan struct {
+	table        *Table
+	path         *accessPath
+	selectResult *SelectResult
+	// cursor reads the items of the index of path.scan, locations holds the records left to return otherwise
+	cursor    *index.RangeCursor
+	locations []recordLocation
+	// stored names the values of the entries of a covering index, primaryKey the column of their id
+	stored     []string
+	primaryKey string
+}
+
+func newIndexScan(t *Table, path *accessPath, selectResult *SelectResult) *IndexScan {
+	return &IndexScan{table: t, path: path, selectResult: selectResult}
+}
+
+func (s *IndexScan) Open() error {
+	if plan := s.path.scan; plan != nil {
+		s.cursor = s.table.indexes[plan.name].NewRangeCursor(plan.ranges)
+		if plan.covering {
+			s.stored, s.primaryKey = s.table.indexDefinitions[plan.name].stored(), s.table.getPrimaryKeyColumnName()
+		}
+		return nil
+	}
+	var err error
+	s.locations, err = s.table.locations(s.path)
+	return err
+}
+
+func (s *IndexScan) Next() (tableparser.RawRecord, bool, error) {
+	for {
+		var loc recordLocation
+		if s.cursor != nil {
+			item, ok, err := s.cursor.Next()
+			if err != nil || !ok {
+				return tableparser.RawRecord{}, false, err
+			}
+			if s.path.scan.covering {
+				// The record is made of the values stored in the index, its page is not read
+				rawRecord := tableparser.RawRecord{Page: item.Page, Slot: item.Slot, CachePageKey: s.table.pageKey(item.Page),
+					Record: tableparser.RecordValue{s.primaryKey: item.Id()}}
+				for i, val := range item.Values() {
+					rawRecord.Record[s.stored[i]] = val
+				}
+				s.selectResult.RowsInspected++
+				return rawRecord, true, nil
+			}
+			loc = recordLocation{page: item.Page, slot: item.Slot}
+		} else {
+			if len(s.locations) == 0 {
+				return tableparser.RawRecord{}, false, nil
+			}
+			loc, s.locations = s.locations[0], s.locations[1:]
+		}
+
+		rawRecord, ok, err := s.table.recordAt(loc)
+		if err != nil {
+			return tableparser.RawRecord{}, false, err
+		}
+		if ok {
+			s.selectResult.RowsInspected++
+			return *rawRecord, true, nil
+		}
+	}
+}
+
+func (s *IndexScan) Close() error {
+	s.cursor, s.locations = nil, nil
+	return nil
+}
+
+// Filter returns the rows of its child matching a condition
+type Filter struct {
+	child Operator
+	match func(row tableparser.RecordValue) bool
+}
+
+// newFilter returns the operator returning the rows of child matching expression, child itself when it is nil
+func newFilter(child Operator, expression *evaluator.Expression) Operator {
+	if expression == nil {
+		return child
+	}
+	e := evaluator.SimpleEvaluator{}
+	return &Filter{child: child, match: func(row tableparser.RecordValue) bool {
+		return e.Eval(*expression, row)
+	}}
+}
+
+func (f *Filter) Open() error {
+	return f.child.Open()
+}
+
+func (f *Filter) Next() (tableparser.RawRecord, bool, error) {
+	for {
+		row, ok, err := f.child.Next()
+		if err != nil || !ok || f.match(row.Record) {
+			return row, ok, err
+		}
+	}
+}
+
+func (f *Filter) Close() error {
+	return f.child.Close()
+}
+
+// Project returns every row of its child shaped by fn, as projector.project does
+type Project struct {
+	child Operator
+	fn    func(row tableparser.RawRecord) (tableparser.RawRecord, error)
+}
+
+func newProject(child Operator, fn func(row tableparser.RawRecord) (tableparser.RawRecord, error)) *Project {
+	return &Project{child: child, fn: fn}
+}
+
+func (p *Project) Open() error {
+	return p.child.Open()
+}
+
+func (p *Project) Next() (tableparser.RawRecord, bool, error) {
+	row, ok, err := p.child.Next()
+	if err != nil || !ok {
+		return row, ok, err
+	}
+	if row, err = p.fn(row); err != nil {
+		return row, false, err
+	}
+	return row, true, nil
+}
+
+func (p *Project) Close() error {
+	return p.child.Close()
+}
+
+// Limit returns the rows of its child up to a number of rows, its child is not asked for more. As the rows of a
+// select were always added before the limit was checked, a limit of 0 returns one row
+type Limit struct {
+	child Operator
+	limit uint32
+	n     uint32
+}
+
+func newLimit(child Operator, limit uint32) *Limit {
+	return &Limit{child: child, limit: limit}
+}
+
+func (l *Limit) Open() error {
+	l.n = 0
+	return l.child.Open()
+}
+
+func (l *Limit) Next() (tableparser.RawRecord, bool, error) {
+	if l.n > 0 && l.n >= l.limit {
+		return tableparser.RawRecord{}, false, nil
+	}
+	row, ok, err := l.child.Next()
+	if ok {
+		l.n++
+	}
+	return row, ok, err
+}
+
+func (l *Limit) Close() error {
+	return l.child.Close()
+}
+
+// Sort returns the rows of its child sorted by ORDER BY. Every row of the child is read on Open, the rows are
+// sorted within the sort memory of the table, see rowSorter
+type Sort struct {
+	child        Operator
+	sorter       *rowSorter
+	selectResult *SelectResult
+}
+
+// newSort returns the operator sorting the rows of child by orderBy, its runs are written next to the file of t
+func (t *Table) newSort(child Operator, orderBy []OrderBy, selectResult *SelectResult) *Sort {
+	return &Sort{child: child, selectResult: selectResult,
+		sorter: newRowSorter(orderBy, t.sortMemory, filepath.Dir(t.file.Name()), t.Name)}
+}
+
+func (s *Sort) Open() error {
+	if err := s.child.Open(); err != nil {
+		return err
+	}
+	for {
+		row, ok, err := s.child.Next()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			break
+		}
+		if err = s.sorter.add(row); err != nil {
+			return err
+		}
+	}
+	s.selectResult.SortType = s.sorter.sortType()
+	return s.sorter.finish()
+}
+
+func (s *Sort) Next() (tableparser.RawRecord, bool, error) {
+	return s.sorter.next()
+}
+
+func (s *Sort) Close() error {
+	s.sorter.close()
+	return s.child.Close()
+}
+
+// AggregateOperator returns a row per group of the rows of its child, holding the values of the group columns and
+// of the aggregates, see aggregator. It is not named Aggregate as that is the aggregate of a select
+type AggregateOperator struct {
+	child        Operator
+	aggregator   aggregator
+	selectResult *SelectResult
+	// read is set once every row of the child was added
+	read bool
+}
+
+func newAggregateOperator(child Operator, agg aggregator, selectResult *SelectResult) *AggregateOperator {
+	return &AggregateOperator{child: child, aggregator: agg, selectResult: selectResult}
+}
+
+func (a *AggregateOperator) Open() error {
+	a.read = false
+	return a.child.Open()
+}
+
+func (a *AggregateOperator) Next() (tableparser.RawRecord, bool, error) {
+	for !a.read {
+		row, ok, err := a.child.Next()
+		if err != nil {
+			return tableparser.RawRecord{}, false, err
+		}
+		if !ok {
+			a.read = true
+			break
+		}
+		values, err := a.aggregator.add(row.Record)
+		if err != nil {
+			return tableparser.RawRecord{}, false, err
+		}
+		if values != nil {
+			return tableparser.RawRecord{Record: values}, true, nil
+		}
+	}
+	values, ok, err := a.aggregator.next()
+	return tableparser.RawRecord{Record: values}, ok, err
+}
+
+// Close reports how the groups were computed, a hash aggregator knows it once it was given every row
+func (a *AggregateOperator) Close() error {
+	a.selectResult.GroupType = a.aggregator.groupType()
+	a.aggregator.close()
+	return a.child.Close()
+}
+
+// rowList returns rows already read, as the rows of the inner table of a hash join
+type rowList struct {
+	rows []tableparser.RawRecord
+	pos  int
+}
+
+func (r *rowList) Open() error {
+	r.pos = 0
+	return nil
+}
+
+func (r *rowList) Next() (tableparser.RawRecord, bool, error) {
+	if r.pos == len(r.rows) {
+		return tableparser.RawRecord{}, false, nil
+	}
+	r.pos++
+	return r.rows[r.pos-1], true, nil
+}
+
+func (r *rowList) Close() error {
+	return nil
+}
+
+// collect opens op, adds every row it returns to the rows of selectResult and closes it
+func collect(op Operator, selectResult *SelectResult) (err error) {
+	defer func() {
+		if closeErr := op.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+	if err = op.Open(); err != nil {
+		return err
+	}
+	for {
+		row, ok, err := op.Next()
+		if err != nil || !ok {
+			return err
+		}
+		selectResult.Rows = append(selectResult.Rows, row)
+	}
+}
+
+// recordAt returns the record at loc, found through an index. ok is false when the slot is empty
+func (t *Table) recordAt(loc recordLocation) (*tableparser.RawRecord, bool, error) {
+	p, err := t.readPage(loc.page)
+	if err != nil {
+		return nil, false, err
+	}
+	if _, ok := p.Record(loc.slot); !ok {
+		helper.Log.Warnf("An index of table %s points at empty slot %d of page %d", t.Name, loc.slot, loc.page)
+		return nil, false, nil
+	}
+	rawRecord, err := t.parseRecord(p, loc.slot)
+	if err != nil {
+		return nil, false, err
+	}
+	return rawRecord, true, nil
+}
+
+// scan returns the operator reading the records found by path, the whole table when it is nil
+func (t *Table) scan(path *accessPath, selectResult *SelectResult) Operator {
+	if path == nil {
+		return newSeqScan(t, selectResult)
+	}
+	return newIndexScan(t, path, selectResult)
+}
@@ -0,0 +1,94 @@
+package table
+
+import (
+	"fmt"
+	tableparser "simple-database/internal/engine/table/column/parser"
+	"simple-database/internal/engine/table/page"
+	platformerror "simple-database/internal/platform/error"
+)
+
+// overflowWriter stores the values moved out of a record, see Table.marshalRecord
+type overflowWriter interface {
+	writeOverflow(data []byte) (int64, error)
+}
+
+// writeOverflow stores data in a chain of overflow pages and returns the number of its first page. Empty pages
+// are used before new ones are appended, the caller writes the header as header.LastPage may change
+func (t *Table) writeOverflow(data []byte) (int64, error) {
+	nums := make([]int64, max(1, (len(data)+page.OverflowCapacity-1)/page.OverflowCapacity))
+	for i := range nums {
+		num, err := t.allocatePage()
+		if err != nil {
+			return 0, err
+		}
+		nums[i] = num
+	}
+
+	for i, num := range nums {
+		var next int64
+		if i+1 < len(nums) {
+			next = nums[i+1]
+		}
+		chunk := data[i*page.OverflowCapacity : min((i+1)*page.OverflowCapacity, len(data))]
+		if err := t.pool.Write(t.heap, num, page.EncodeOverflow(next, chunk)); err != nil {
+			return 0, err
+		}
+	}
+	return nums[0], nil
+}
+
+// allocatePage returns a page without records to turn into an overflow page, appending one if there is none
+func (t *Table) allocatePage() (int64, error) {
+	freeSpace, err := t.freeSpaceMap()
+	if err != nil {
+		return 0, err
+	}
+	if num, ok := freeSpace.Find(page.MaxRecordSize); ok {
+		freeSpace.Remove(num)
+		return num, nil
+	}
+	return t.appendPage(), nil
+}
+
+// ReadOverflow reads the value stored in the chain of overflow pages starting at v.Page
+func (t *Table) ReadOverflow(v tableparser.OverflowValue) ([]byte, error) {
+	data := make([]byte, 0, v.Length)
+	for num := v.Page; uint32(len(data)) < v.Length; {
+		if num == 0 {
+			return nil, platformerror.NewStackTraceError(fmt.Sprintf("Overflow chain at page %d of table %s holds %d bytes, expected %d",
+				v.Page, t.Name, len(data), v.Length), platformerror.InvalidPageErrorCode)
+		}
+		b, err := t.readPageData(num)
+		if err != nil {
+			return nil, err
+		}
+		next, chunk, err := page.DecodeOverflow(num, b)
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, chunk...)
+		num = next
+	}
+	return data[:v.Length], nil
+}
+
+// freeOverflow turns the pages of the chains of values into empty pages new records can use
+func (t *Table) freeOverflow(values []tableparser.OverflowValue) error {
+	for _, v := range values {
+		for num := v.Page; num != 0; {
+			b, err := t.readPageData(num)
+			if err != nil {
+				return err
+			}
+			next, _, err := page.DecodeOverflow(num, b)
+			if err != nil {
+				return err
+			}
+			if err = t.writePage(page.New(num)); err != nil {
+				return err
+			}
+			num = next
+		}
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package page
+
+import (
+	"encoding/binary"
+	"fmt"
+	"simple-database/internal/platform/datatype"
+	platformerror "simple-database/internal/platform/error"
+)
+
+// OverflowHeaderSize is the size of the header of an overflow page: the type byte, the number of the next page of
+// the chain and the length of the data
+const OverflowHeaderSize = datatype.LenByte + datatype.LenInt64 + 2
+
+// OverflowCapacity is the number of bytes of a value one overflow page holds
+const OverflowCapacity = Size - OverflowHeaderSize - ChecksumSize
+
+// InlineRecordSize is the size above which the largest STRING values of a record are moved to overflow pages, so
+// a page holds at least four records
+const InlineRecordSize = MaxRecordSize / 4
+
+// EncodeOverflow returns an overflow page holding data. next is the number of the following page of the chain,
+// 0 ends the chain as page 0 never holds records
+func EncodeOverflow(next int64, data []byte) []byte {
+	b := make([]byte, Size)
+	b[0] = datatype.TypeOverflowPage
+	binary.LittleEndian.PutUint64(b[datatype.LenByte:], uint64(next))
+	binary.LittleEndian.PutUint16(b[datatype.LenByte+datatype.LenInt64:], uint16(len(data)))
+	copy(b[OverflowHeaderSize:], data)
+	Seal(b)
+	return b
+}
+
+// DecodeOverflow returns the number of the next page of the chain and the data held by overflow page num
+func DecodeOverflow(num int64, b []byte) (int64, []byte, error) {
+	if len(b) != Size {
+		return 0, nil, platformerror.NewStackTraceError(fmt.Sprintf("Overflow page %d: expected %d bytes, got %d", num, Size, len(b)),
+			platformerror.IncompleteReadErrorCode)
+	}
+	if !IsOverflow(b) {
+		return 0, nil, platformerror.NewStackTraceError(fmt.Sprintf("Page %d: expected %d, got %d", num, datatype.TypeOverflowPage, b[0]),
+			platformerror.InvalidPageErrorCode)
+	}
+	next := int64(binary.LittleEndian.Uint64(b[datatype.LenByte:]))
+	length := int(binary.LittleEndian.Uint16(b[datatype.LenByte+datatype.LenInt64:]))
+	// Pages written before checksums existed could use the last bytes too
+	if OverflowHeaderSize+length > Size {
+		return 0, nil, platformerror.NewStackTraceError(fmt.Sprintf("Overflow page %d: length %d exceeds %d", num, length, Size-OverflowHeaderSize),
+			platformerror.InvalidPageErrorCode)
+	}
+	return next, b[OverflowHeaderSize : OverflowHeaderSize+length], nil
+}
+
+// IsOverflow reports whether the page content b is an overflow page
+func IsOverflow(b []byte) bool {
+	return len(b) > 0 && b[0] == datatype.TypeOverflowPage
+}
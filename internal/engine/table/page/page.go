@@ -0,0 +1,252 @@
+package page
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"simple-database/internal/platform/datatype"
+	platformerror "simple-database/internal/platform/error"
+)
+
+// Size is the size of every page of a table file. Pages start at multiples of Size
+const Size = 4096
+
+// HeaderSize is the size of the page header: the type byte, the number of slots and the start of the records
+const HeaderSize = datatype.LenByte + 2 + 2
+
+// SlotSize is the size of one entry of the slot directory: the offset and the length of a record
+const SlotSize = 2 + 2
+
+// ChecksumSize is the size of the CRC32C stored in the last bytes of every page
+const ChecksumSize = 4
+
+// MaxRecordSize is the size of the largest record an empty page can take
+const MaxRecordSize = Size - HeaderSize - SlotSize - ChecksumSize
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// Page is a slotted page. The slot directory grows forward from the header while records are added backward
+// from the checksum at the end of the page, so records can be moved inside the page without changing their slot.
+// An empty slot has offset 0 and is reused by the next insert
+type Page struct {
+	// Num is the number of the page in the file
+	Num  int64
+	data []byte
+}
+
+// New returns an empty page
+func New(num int64) *Page {
+	p := &Page{Num: num, data: make([]byte, Size)}
+	p.data[0] = datatype.TypePage
+	p.setRecordsStart(Size - ChecksumSize)
+	return p
+}
+
+// Load wraps the content of page num read from a file
+func Load(num int64, data []byte) (*Page, error) {
+	if len(data) != Size {
+		return nil, platformerror.NewStackTraceError(fmt.Sprintf("Page %d: expected %d bytes, got %d", num, Size, len(data)),
+			platformerror.IncompleteReadErrorCode)
+	}
+	if data[0] != datatype.TypePage {
+		return nil, platformerror.NewStackTraceError(fmt.Sprintf("Page %d: expected %d, got %d", num, datatype.TypePage, data[0]),
+			platformerror.InvalidPageErrorCode)
+	}
+	p := &Page{Num: num, data: data}
+	if p.directoryEnd() > int(p.recordsStart()) || p.recordsStart() > Size {
+		return nil, platformerror.NewStackTraceError(fmt.Sprintf("Page %d: slot directory overlaps records", num),
+			platformerror.InvalidPageErrorCode)
+	}
+	return p, nil
+}
+
+// Bytes returns the content of the page to be written to the file, with its checksum updated
+func (p *Page) Bytes() []byte {
+	Seal(p.data)
+	return p.data
+}
+
+// Offset returns the position of the page in the file
+func (p *Page) Offset() int64 {
+	return p.Num * Size
+}
+
+func (p *Page) SlotCount() uint16 {
+	return binary.LittleEndian.Uint16(p.data[1:])
+}
+
+func (p *Page) setSlotCount(n uint16) {
+	binary.LittleEndian.PutUint16(p.data[1:], n)
+}
+
+func (p *Page) recordsStart() uint16 {
+	return binary.LittleEndian.Uint16(p.data[3:])
+}
+
+func (p *Page) setRecordsStart(pos int) {
+	binary.LittleEndian.PutUint16(p.data[3:], uint16(pos))
+}
+
+func (p *Page) directoryEnd() int {
+	return HeaderSize + int(p.SlotCount())*SlotSize
+}
+
+// slot returns the offset and the length of the record in slot
+func (p *Page) slot(slot uint16) (uint16, uint16) {
+	pos := HeaderSize + int(slot)*SlotSize
+	return binary.LittleEndian.Uint16(p.data[pos:]), binary.LittleEndian.Uint16(p.data[pos+2:])
+}
+
+func (p *Page) setSlot(slot uint16, offset, length uint16) {
+	pos := HeaderSize + int(slot)*SlotSize
+	binary.LittleEndian.PutUint16(p.data[pos:], offset)
+	binary.LittleEndian.PutUint16(p.data[pos+2:], length)
+}
+
+// Record returns the record in slot, or false if the slot is empty
+func (p *Page) Record(slot uint16) ([]byte, bool) {
+	if slot >= p.SlotCount() {
+		return nil, false
+	}
+	offset, length := p.slot(slot)
+	if offset == 0 {
+		return nil, false
+	}
+	return p.data[offset : offset+length], true
+}
+
+// RecordOffset returns the position of the record in slot inside the page
+func (p *Page) RecordOffset(slot uint16) uint16 {
+	offset, _ := p.slot(slot)
+	return offset
+}
+
+// FreeSpace returns the size of the largest record Insert can still add
+func (p *Page) FreeSpace() int {
+	free := Size - ChecksumSize - p.directoryEnd() - p.usedBytes()
+	if _, ok := p.emptySlot(); !ok {
+		free -= SlotSize
+	}
+	return max(free, 0)
+}
+
+func (p *Page) usedBytes() int {
+	used := 0
+	for i := uint16(0); i < p.SlotCount(); i++ {
+		if offset, length := p.slot(i); offset != 0 {
+			used += int(length)
+		}
+	}
+	return used
+}
+
+func (p *Page) emptySlot() (uint16, bool) {
+	for i := uint16(0); i < p.SlotCount(); i++ {
+		if offset, _ := p.slot(i); offset == 0 {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// Insert adds record to the page and returns its slot, or false if the page has no room for it
+func (p *Page) Insert(record []byte) (uint16, bool) {
+	if len(record) == 0 || len(record) > p.FreeSpace() {
+		return 0, false
+	}
+	slot, ok := p.emptySlot()
+	if !ok {
+		slot = p.SlotCount()
+		p.setSlotCount(slot + 1)
+	}
+	// Zero the slot so compact does not move a record that is not there yet
+	p.setSlot(slot, 0, 0)
+	p.place(slot, record)
+	return slot, true
+}
+
+// Update replaces the record in slot, moving it inside the page if it grew. It returns false, leaving the page
+// unchanged, if the slot is empty or the page has no room for the new record
+func (p *Page) Update(slot uint16, record []byte) bool {
+	old, ok := p.Record(slot)
+	if !ok || len(record) == 0 {
+		return false
+	}
+	if len(record) <= len(old) {
+		offset, _ := p.slot(slot)
+		copy(p.data[offset:], record)
+		p.setSlot(slot, offset, uint16(len(record)))
+		return true
+	}
+
+	free := Size - ChecksumSize - p.directoryEnd() - p.usedBytes() + len(old)
+	if len(record) > free {
+		return false
+	}
+	p.setSlot(slot, 0, 0)
+	p.place(slot, record)
+	return true
+}
+
+// place writes record in front of the other records, compacting them first if the gap is too small
+func (p *Page) place(slot uint16, record []byte) {
+	if int(p.recordsStart())-p.directoryEnd() < len(record) {
+		p.compact()
+	}
+	start := int(p.recordsStart()) - len(record)
+	copy(p.data[start:], record)
+	p.setRecordsStart(start)
+	p.setSlot(slot, uint16(start), uint16(len(record)))
+}
+
+// Delete empties slot. Trailing empty slots are dropped from the directory
+func (p *Page) Delete(slot uint16) bool {
+	if _, ok := p.Record(slot); !ok {
+		return false
+	}
+	p.setSlot(slot, 0, 0)
+	n := p.SlotCount()
+	for n > 0 {
+		if offset, _ := p.slot(n - 1); offset != 0 {
+			break
+		}
+		n--
+	}
+	p.setSlotCount(n)
+	if n == 0 {
+		p.setRecordsStart(Size - ChecksumSize)
+	}
+	return true
+}
+
+// compact moves the records to the end of the page so the free space between them becomes one gap
+func (p *Page) compact() {
+	records := make(map[uint16][]byte)
+	for i := uint16(0); i < p.SlotCount(); i++ {
+		if record, ok := p.Record(i); ok {
+			records[i] = append([]byte(nil), record...)
+		}
+	}
+	pos := Size - ChecksumSize
+	for i := uint16(0); i < p.SlotCount(); i++ {
+		record, ok := records[i]
+		if !ok {
+			continue
+		}
+		pos -= len(record)
+		copy(p.data[pos:], record)
+		p.setSlot(i, uint16(pos), uint16(len(record)))
+	}
+	clear(p.data[p.directoryEnd():pos])
+	p.setRecordsStart(pos)
+}
+
+// Seal stores the checksum of the page content b in its last bytes
+func Seal(b []byte) {
+	binary.LittleEndian.PutUint32(b[Size-ChecksumSize:], crc32.Checksum(b[:Size-ChecksumSize], castagnoli))
+}
+
+// Checksums returns the checksum stored in the page content b and the one computed from it
+func Checksums(b []byte) (uint32, uint32) {
+	return binary.LittleEndian.Uint32(b[Size-ChecksumSize:]), crc32.Checksum(b[:Size-ChecksumSize], castagnoli)
+}
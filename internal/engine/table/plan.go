@@ -0,0 +1,500 @@
+package table
+
+import (
+	"simple-database/internal/engine/table/index"
+	"simple-database/internal/platform/datatype"
+	"simple-database/internal/platform/evaluator"
+	"slices"
+	"sort"
+)
+
+// predicate is a comparison of a column with a value that every selected record must satisfy
+type predicate struct {
+	column string
+	op     datatype.Operator
+	value  any
+}
+
+// valueRange is the values of a column allowed by the predicates joined by AND: the value of the first =, the
+// values of the first IN, the tightest lower and upper bounds or the value excluded by the first !=, from the most
+// narrowing to the least
+type valueRange struct {
+	equal       any
+	hasEqual    bool
+	in          []any
+	lower       *index.Bound
+	upper       *index.Bound
+	notEqual    any
+	hasNotEqual bool
+}
+
+// indexPlan is the part of an index a select reads: the items of its ranges, in key order
+type indexPlan struct {
+	name   string
+	ranges []index.Range
+	// columns is the number of columns of the index the plan narrows the items with
+	columns int
+	// fixed is the number of leading columns of the index matched with =, the items are in the order of the others
+	fixed int
+	// selective is set when the plan reads a bounded part of the index: =, IN or a range closed on both sides
+	selective bool
+	// point is set for a unique index whose columns are all matched with =, it finds a single record
+	point bool
+	// covering is set when the items hold every column the select reads, so its records are not read
+	covering bool
+}
+
+// accessPath is how a select finds its records: through the items of one index, or through the records found
+// by several paths, those found by every path when intersect is set and those found by any of them otherwise
+type accessPath struct {
+	scan      *indexPlan
+	intersect bool
+	paths     []*accessPath
+}
+
+// accessType returns the access type of a select reading the records found by p, the whole table when it is nil
+func (p *accessPath) accessType() string {
+	switch {
+	case p == nil:
+		return AccessTypeAll
+	case p.scan != nil && p.scan.covering:
+		return AccessTypeIndexOnly
+	case p.scan != nil:
+		return AccessTypeIndex
+	case p.intersect:
+		return AccessTypeIndexIntersection
+	}
+	return AccessTypeIndexUnion
+}
+
+// subExpression returns side when it is an expression, nil otherwise
+func subExpression(side any) *evaluator.Expression {
+	switch v := side.(type) {
+	case evaluator.Expression:
+		return &v
+	case *evaluator.Expression:
+		return v
+	}
+	return nil
+}
+
+// conjuncts returns the expressions joined by the AND operators at the top of expression
+func conjuncts(expression *evaluator.Expression) []*evaluator.Expression {
+	if expression == nil {
+		return nil
+	}
+	if expression.Op != datatype.OperatorAnd {
+		return []*evaluator.Expression{expression}
+	}
+	return append(conjuncts(subExpression(expression.Left)), conjuncts(subExpression(expression.Right))...)
+}
+
+// predicate returns the comparison of a column with a value e is, false when e is anything else
+func (t *Table) predicate(e *evaluator.Expression) (predicate, bool) {
+	col, ok := e.Left.(string)
+	if !ok {
+		return predicate{}, false
+	}
+	if _, ok = t.columns[col]; !ok {
+		return predicate{}, false
+	}
+	switch v := e.Right.(type) {
+	case evaluator.Expression, *evaluator.Expression:
+		return predicate{}, false
+	case string:
+		// A string naming a column compares two columns
+		if _, ok = t.columns[v]; ok {
+			return predicate{}, false
+		}
+	}
+	switch e.Op {
+	case datatype.OperatorEqual, datatype.OperatorNotEqual, datatype.OperatorLess, datatype.OperatorLessOrEqual,
+		datatype.OperatorGreater, datatype.OperatorGreaterOrEqual:
+		return predicate{column: col, op: e.Op, value: e.Right}, true
+	case datatype.OperatorBetween, datatype.OperatorIn:
+		// The right side holds the bounds or the values, they must all be values
+		values, ok := e.Right.([]any)
+		if !ok || len(values) == 0 || e.Op == datatype.OperatorBetween && len(values) != 2 {
+			return predicate{}, false
+		}
+		for _, v := range values {
+			switch v := v.(type) {
+			case nil, evaluator.Expression, *evaluator.Expression:
+				return predicate{}, false
+			case string:
+				if _, ok = t.columns[v]; ok {
+					return predicate{}, false
+				}
+			}
+		}
+		return predicate{column: col, op: e.Op, value: values}, true
+	}
+	return predicate{}, false
+}
+
+// tighter returns the bound of a and b letting fewer values through, lower tells which end of a range they are.
+// Bounds whose values cannot be compared keep a
+func tighter(a, b *index.Bound, lower bool) *index.Bound {
+	if a == nil {
+		return b
+	}
+	n, ok, err := index.Compare(a.Value, b.Value)
+	if err != nil || !ok {
+		return a
+	}
+	if n == 0 {
+		if !b.Inclusive {
+			return b
+		}
+		return a
+	}
+	if n < 0 == lower {
+		return b
+	}
+	return a
+}
+
+// sortedValues returns the distinct values of values in the order of the keys of an index, so they are read one
+// after the other. Values that cannot be compared are kept in their order
+func sortedValues(values []any) []any {
+	sorted := make([]any, 0, len(values))
+	for _, v := range values {
+		i, found := len(sorted), false
+		for j, s := range sorted {
+			n, ok, err := index.Compare(v, s)
+			if err != nil || !ok {
+				continue
+			}
+			if n == 0 {
+				found = true
+				break
+			}
+			if n < 0 {
+				i = j
+				break
+			}
+		}
+		if !found {
+			sorted = slices.Insert(sorted, i, v)
+		}
+	}
+	return sorted
+}
+
+// valueRanges merges the predicates by column
+func valueRanges(predicates []predicate) map[string]*valueRange {
+	ranges := make(map[string]*valueRange)
+	for _, p := range predicates {
+		r, ok := ranges[p.column]
+		if !ok {
+			r = &valueRange{}
+			ranges[p.column] = r
+		}
+		switch p.op {
+		case datatype.OperatorEqual:
+			if !r.hasEqual {
+				r.equal, r.hasEqual = p.value, true
+			}
+		case datatype.OperatorIn:
+			if r.in == nil {
+				r.in = sortedValues(p.value.([]any))
+			}
+		case datatype.OperatorBetween:
+			bounds := p.value.([]any)
+			r.lower = tighter(r.lower, &index.Bound{Value: bounds[0], Inclusive: true}, true)
+			r.upper = tighter(r.upper, &index.Bound{Value: bounds[1], Inclusive: true}, false)
+		case datatype.OperatorGreater, datatype.OperatorGreaterOrEqual:
+			r.lower = tighter(r.lower, &index.Bound{Value: p.value, Inclusive: p.op == datatype.OperatorGreaterOrEqual}, true)
+		case datatype.OperatorLess, datatype.OperatorLessOrEqual:
+			r.upper = tighter(r.upper, &index.Bound{Value: p.value, Inclusive: p.op == datatype.OperatorLessOrEqual}, false)
+		case datatype.OperatorNotEqual:
+			if !r.hasNotEqual {
+				r.notEqual, r.hasNotEqual = p.value, true
+			}
+		}
+	}
+	return ranges
+}
+
+// expressionColumns returns the columns expression reads, on either side of its comparisons
+func (t *Table) expressionColumns(expression *evaluator.Expression) []string {
+	columns := make([]string, 0)
+	var collect func(side any)
+	collect = func(side any) {
+		switch v := side.(type) {
+		case evaluator.Expression:
+			collect(v.Left)
+			collect(v.Right)
+		case *evaluator.Expression:
+			if v != nil {
+				collect(v.Left)
+				collect(v.Right)
+			}
+		case []any:
+			for _, value := range v {
+				collect(value)
+			}
+		case string:
+			if _, ok := t.columns[v]; ok {
+				columns = append(columns, v)
+			}
+		}
+	}
+	collect(expression)
+	return columns
+}
+
+// covers reports whether the entries of the index described by definition hold every column in columns. The id
+// of the record is stored with every entry. "*" is only answered from the records
+func (t *Table) covers(definition *IndexDefinition, columns []string) bool {
+	stored := append(definition.stored(), t.getPrimaryKeyColumnName())
+	for _, col := range columns {
+		if !slices.Contains(stored, col) {
+			return false
+		}
+	}
+	return true
+}
+
+// readColumns returns the columns a select reads: the selected columns and those expression reads. They must be
+// stored in an index for it to cover the select, it is nil when the select reads every column
+func (t *Table) readColumns(expression *evaluator.Expression, selectColumns []string) []string {
+	if len(selectColumns) == 0 || slices.Contains(selectColumns, "*") {
+		return nil
+	}
+	return append(slices.Clone(selectColumns), t.expressionColumns(expression)...)
+}
+
+// planIndex returns the plan reading the index described by definition narrowed by ranges, nil when they do not
+// narrow its first column. An index is used from its first column on: its leading columns compared with = and the
+// next one read at the values of an IN, between bounds or around the value excluded by !=
+func planIndex(definition *IndexDefinition, ranges map[string]*valueRange) *indexPlan {
+	plan := &indexPlan{name: definition.Name, selective: true}
+	equal := make([]any, 0)
+	for _, col := range definition.Columns {
+		vr, ok := ranges[col]
+		if !ok {
+			break
+		}
+		if vr.hasEqual {
+			// = narrows the most and lets the next column be used
+			equal = append(equal, vr.equal)
+			plan.columns++
+			continue
+		}
+
+		plan.columns++
+		switch {
+		case vr.in != nil:
+			for _, v := range vr.in {
+				plan.ranges = append(plan.ranges, index.Range{Equal: append(slices.Clone(equal), v)})
+			}
+		case vr.lower != nil || vr.upper != nil:
+			plan.ranges = []index.Range{{Equal: equal, Lower: vr.lower, Upper: vr.upper}}
+			plan.selective = vr.lower != nil && vr.upper != nil
+		default:
+			excluded := &index.Bound{Value: vr.notEqual}
+			plan.ranges = []index.Range{{Equal: equal, Upper: excluded}, {Equal: equal, Lower: excluded}}
+			plan.selective = false
+		}
+		break
+	}
+	if plan.columns == 0 {
+		return nil
+	}
+	plan.fixed = len(equal)
+	if plan.ranges == nil {
+		plan.ranges = []index.Range{{Equal: equal}}
+		plan.point = definition.Unique && len(equal) == len(definition.Columns)
+	}
+	return plan
+}
+
+// indexPlans returns a plan for every index the ranges narrow, the best first. The index using the most columns
+// wins, then a unique index fully matched with =, then an index covering the columns in read, then the first by
+// name. read is nil when the select cannot be answered from an index alone
+func (t *Table) indexPlans(ranges map[string]*valueRange, read []string) []*indexPlan {
+	plans := make([]*indexPlan, 0)
+	for _, name := range t.indexNames() {
+		definition := t.indexDefinitions[name]
+		plan := planIndex(definition, ranges)
+		if plan == nil {
+			continue
+		}
+		plan.covering = read != nil && t.covers(definition, read)
+		plans = append(plans, plan)
+	}
+
+	sort.SliceStable(plans, func(i, j int) bool {
+		a, b := plans[i], plans[j]
+		if a.columns != b.columns {
+			return a.columns > b.columns
+		}
+		if a.point != b.point {
+			return a.point
+		}
+		return a.covering && !b.covering
+	})
+	return plans
+}
+
+// planAccess returns how the records matching expression are found through the indexes, nil when the whole table
+// must be read. Under AND the best index is read, intersected with the other indexes reading a bounded part of
+// their items on other columns, unless it finds a single record or covers the select. Under OR every side must be
+// found through the indexes and their records are merged. selectColumns are the columns the select returns
+func (t *Table) planAccess(expression *evaluator.Expression, selectColumns []string) *accessPath {
+	if expression == nil {
+		return nil
+	}
+	if expression.Op == datatype.OperatorOr {
+		left := t.planAccess(subExpression(expression.Left), nil)
+		right := t.planAccess(subExpression(expression.Right), nil)
+		if left == nil || right == nil {
+			return nil
+		}
+		paths := make([]*accessPath, 0, 2)
+		for _, path := range []*accessPath{left, right} {
+			if path.scan == nil && !path.intersect {
+				paths = append(paths, path.paths...)
+			} else {
+				paths = append(paths, path)
+			}
+		}
+		return &accessPath{paths: paths}
+	}
+
+	predicates := make([]predicate, 0)
+	ors := make([]*evaluator.Expression, 0)
+	for _, e := range conjuncts(expression) {
+		if p, ok := t.predicate(e); ok {
+			predicates = append(predicates, p)
+		} else if e.Op == datatype.OperatorOr {
+			ors = append(ors, e)
+		}
+	}
+
+	plans := t.indexPlans(valueRanges(predicates), t.readColumns(expression, selectColumns))
+	if len(plans) == 0 {
+		// The records matching an OR below the AND hold the matching records
+		for _, e := range ors {
+			if path := t.planAccess(e, nil); path != nil {
+				return path
+			}
+		}
+		return nil
+	}
+
+	best := plans[0]
+	if best.point || best.covering {
+		return &accessPath{scan: best}
+	}
+	used := slices.Clone(t.indexDefinitions[best.name].Columns[:best.columns])
+	paths := []*accessPath{{scan: best}}
+	for _, plan := range plans[1:] {
+		columns := t.indexDefinitions[plan.name].Columns[:plan.columns]
+		if !plan.selective || slices.ContainsFunc(columns, func(col string) bool { return slices.Contains(used, col) }) {
+			continue
+		}
+		used = append(used, columns...)
+		paths = append(paths, &accessPath{scan: plan})
+	}
+	if len(paths) == 1 {
+		return paths[0]
+	}
+	return &accessPath{intersect: true, paths: paths}
+}
+
+// orderedBy reports whether the items read by plan come in the order of orderBy. The ranges of a plan are read in
+// key order, so its items are ordered on the columns of the index after those matched with =, then on the primary
+// key. Columns matched with = hold a single value and may appear anywhere in orderBy
+func (t *Table) orderedBy(plan *indexPlan, orderBy []OrderBy) bool {
+	columns := t.indexDefinitions[plan.name].Columns
+	order := append(slices.Clone(columns[plan.fixed:]), t.getPrimaryKeyColumnName())
+	i := 0
+	for _, o := range orderBy {
+		if slices.Contains(columns[:plan.fixed], o.Column) {
+			continue
+		}
+		if o.Descending || i == len(order) || order[i] != o.Column {
+			return false
+		}
+		i++
+	}
+	return true
+}
+
+// groupedBy reports whether the items read by plan come grouped on the columns in groupBy: the columns of the index
+// after those matched with =, then the primary key, start with the columns of groupBy in any order
+func (t *Table) groupedBy(plan *indexPlan, groupBy []string) bool {
+	columns := t.indexDefinitions[plan.name].Columns
+	order := append(slices.Clone(columns[plan.fixed:]), t.getPrimaryKeyColumnName())
+	grouped := make([]string, 0, len(groupBy))
+	for _, col := range groupBy {
+		if !slices.Contains(columns[:plan.fixed], col) && !slices.Contains(grouped, col) {
+			grouped = append(grouped, col)
+		}
+	}
+	if len(grouped) > len(order) {
+		return false
+	}
+	for _, col := range order[:len(grouped)] {
+		if !slices.Contains(grouped, col) {
+			return false
+		}
+	}
+	return true
+}
+
+// orderedPath returns the path reading the records in an order given by ordered, nil when there is none. A single
+// index read by path is used when ordered accepts it. Without a path, a whole index accepted by ordered replaces
+// reading the table when it covers the columns in read or when early is set, as the select then stops reading
+// before the end. Reading every record through an index would cost more than sorting them
+func (t *Table) orderedPath(path *accessPath, read []string, early bool, ordered func(plan *indexPlan) bool) *accessPath {
+	if path != nil {
+		if path.scan != nil && ordered(path.scan) {
+			return path
+		}
+		return nil
+	}
+	for _, name := range t.indexNames() {
+		plan := &indexPlan{name: name, ranges: []index.Range{{}}, covering: read != nil && t.covers(t.indexDefinitions[name], read)}
+		if (plan.covering || early) && ordered(plan) {
+			return &accessPath{scan: plan}
+		}
+	}
+	return nil
+}
+
+// locations returns the locations of the records found by path in the order of the table file
+func (t *Table) locations(path *accessPath) ([]recordLocation, error) {
+	found := make(map[recordLocation]int)
+	if path.scan != nil {
+		err := t.indexes[path.scan.name].ScanRanges(path.scan.ranges, func(item index.Item) (bool, error) {
+			found[recordLocation{page: item.Page, slot: item.Slot}]++
+			return true, nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, p := range path.paths {
+		locations, err := t.locations(p)
+		if err != nil {
+			return nil, err
+		}
+		for _, loc := range locations {
+			found[loc]++
+		}
+	}
+
+	locations := make([]recordLocation, 0, len(found))
+	for loc, n := range found {
+		if !path.intersect || n == len(path.paths) {
+			locations = append(locations, loc)
+		}
+	}
+	sort.Slice(locations, func(i, j int) bool {
+		return locations[i].page < locations[j].page || locations[i].page == locations[j].page && locations[i].slot < locations[j].slot
+	})
+	return locations, nil
+}
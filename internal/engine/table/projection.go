@@ -0,0 +1,195 @@
+package table
+
+import (
+	"fmt"
+	tableparser "simple-database/internal/engine/table/column/parser"
+	platformerror "simple-database/internal/platform/error"
+	"simple-database/internal/platform/evaluator"
+	"slices"
+)
+
+// Projection is a value returned by a select under Name. Expression is the name of a column, or of an aggregate
+// in a grouped select, a value, or an evaluator.Expression or evaluator.Function computed from those
+type Projection struct {
+	Name       string
+	Expression any
+}
+
+// projector shapes the rows of a select into the values of its projections
+type projector struct {
+	projections []Projection
+	evaluator   evaluator.SimpleEvaluator
+}
+
+// newProjector returns the projector of command, nil when its rows are returned whole. Without projections a
+// select returns its SelectColumns, and a grouped select its aggregates too
+func newProjector(command SelectCommand) *projector {
+	projections := command.Projections
+	if projections == nil {
+		for _, col := range command.SelectColumns {
+			if col == "*" {
+				return nil
+			}
+			projections = append(projections, Projection{Name: col, Expression: col})
+		}
+		if command.grouped() {
+			for _, a := range command.Aggregates {
+				projections = append(projections, Projection{Name: a.Name(), Expression: a.Name()})
+			}
+		}
+	}
+	if len(projections) == 0 {
+		return nil
+	}
+	return &projector{projections: projections}
+}
+
+// names returns the names of the projections
+func (p *projector) names() []string {
+	names := make([]string, 0, len(p.projections))
+	for _, projection := range p.projections {
+		names = append(names, projection.Name)
+	}
+	return names
+}
+
+// check returns an error when a projection calls an unknown function
+func (p *projector) check() error {
+	var check func(v any) error
+	check = func(v any) error {
+		switch x := v.(type) {
+		case evaluator.Function:
+			if err := x.Check(); err != nil {
+				return err
+			}
+			for _, arg := range x.Args {
+				if err := check(arg); err != nil {
+					return err
+				}
+			}
+		case evaluator.Expression:
+			return check(&x)
+		case *evaluator.Expression:
+			if err := check(x.Left); err != nil {
+				return err
+			}
+			return check(x.Right)
+		}
+		return nil
+	}
+	for _, projection := range p.projections {
+		if err := check(projection.Expression); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// computes reports whether a projection called name holds anything else than the column of that name, so rows
+// sorted on name must be extended first
+func (p *projector) computes(name string) bool {
+	for _, projection := range p.projections {
+		if projection.Name == name {
+			return projection.Expression != name
+		}
+	}
+	return false
+}
+
+// project returns the row holding only the values of the projections
+func (p *projector) project(row tableparser.RawRecord) (tableparser.RawRecord, error) {
+	values := make(tableparser.RecordValue, len(p.projections))
+	for _, projection := range p.projections {
+		val, err := p.evaluator.Value(projection.Expression, row.Record)
+		if err != nil {
+			return row, err
+		}
+		values[projection.Name] = val
+	}
+	row.Record = values
+	return row, nil
+}
+
+// extend returns the row with the values of the projections added to its columns, so it can be sorted on them
+// and projected later
+func (p *projector) extend(row tableparser.RawRecord) (tableparser.RawRecord, error) {
+	values := make(tableparser.RecordValue, len(row.Record)+len(p.projections))
+	for col, val := range row.Record {
+		values[col] = val
+	}
+	for _, projection := range p.projections {
+		val, err := p.evaluator.Value(projection.Expression, row.Record)
+		if err != nil {
+			return row, err
+		}
+		values[projection.Name] = val
+	}
+	row.Record = values
+	return row, nil
+}
+
+// pick returns the row holding only the values of the projections, already added to it by extend
+func (p *projector) pick(row tableparser.RawRecord) (tableparser.RawRecord, error) {
+	values := make(tableparser.RecordValue, len(p.projections))
+	for _, projection := range p.projections {
+		values[projection.Name] = row.Record[projection.Name]
+	}
+	row.Record = values
+	return row, nil
+}
+
+// selectRows shapes the rows of a select into what it returns
+type selectRows struct {
+	command   SelectCommand
+	projector *projector
+	// extend is set when ORDER BY names a computed projection. The rows are sorted with the values of the
+	// projections added, see projector.extend, and these values are kept
+	extend bool
+}
+
+// newSelectRows returns the rows of command. columns holds the names ORDER BY may use besides those of the
+// projections
+func newSelectRows(command SelectCommand, columns []string) (*selectRows, error) {
+	r := &selectRows{command: command, projector: newProjector(command)}
+	names := slices.Clone(columns)
+	if r.projector != nil {
+		if err := r.projector.check(); err != nil {
+			return nil, err
+		}
+		names = append(names, r.projector.names()...)
+		for _, o := range command.OrderBy {
+			r.extend = r.extend || r.projector.computes(o.Column)
+		}
+	}
+	for _, o := range command.OrderBy {
+		if !slices.Contains(names, o.Column) {
+			return nil, platformerror.NewStackTraceError(fmt.Sprintf("unknown column in order by: %s", o.Column),
+				platformerror.ColumnViolationErrorCode)
+		}
+	}
+	return r, nil
+}
+
+// sort returns the operator sorting the rows of child by ORDER BY, with the values of the projections added when
+// it names them. The runs of the sort are written next to the file of t
+func (r *selectRows) sort(child Operator, t *Table, selectResult *SelectResult) Operator {
+	if r.extend {
+		child = newProject(child, r.projector.extend)
+	}
+	return t.newSort(child, r.command.OrderBy, selectResult)
+}
+
+// output returns the operator returning the rows of child projected, up to the limit of the select
+func (r *selectRows) output(child Operator) Operator {
+	if r.projector != nil {
+		project := r.projector.project
+		if r.extend {
+			project = r.projector.pick
+		}
+		child = newProject(child, project)
+	}
+	if r.command.Limit != UnlimitedSize {
+		child = newLimit(child, r.command.Limit)
+	}
+	return child
+}
@@ -0,0 +1,355 @@
+package table
+
+import (
+	"bufio"
+	"bytes"
+	"container/heap"
+	"encoding/binary"
+	"fmt"
+	stdio "io"
+	"os"
+	tableparser "simple-database/internal/engine/table/column/parser"
+	platformerror "simple-database/internal/platform/error"
+	"simple-database/internal/platform/io"
+	platformparser "simple-database/internal/platform/parser"
+	"slices"
+	"strings"
+)
+
+// DefaultSortMemory is the number of bytes of rows a select sorts in memory before it writes them to a run file
+const DefaultSortMemory = 4 << 20
+
+const (
+	// SortTypeIndex is reported when the rows were read in the order of an index and not sorted
+	SortTypeIndex = "Index"
+	// SortTypeMemory is reported when the rows were sorted in memory
+	SortTypeMemory = "Memory"
+	// SortTypeExternal is reported when the rows were sorted in runs written to disk and merged
+	SortTypeExternal = "External"
+)
+
+// sortRunPattern is the name of the temporary files holding sorted runs, next to the table file
+const sortRunPattern = "%s_sort_*.tmp"
+
+// OrderBy is a column the rows of a select are sorted on
+type OrderBy struct {
+	Column     string
+	Descending bool
+}
+
+// sortedRow is a row with its sort key: the comparable encodings of its order columns, each complemented when
+// the column is sorted in descending order. No encoding starts with another, so comparing the keys compares the
+// columns one after the other
+type sortedRow struct {
+	key []byte
+	row tableparser.RawRecord
+}
+
+// rowSorter sorts the rows of a select within a memory budget. Rows are buffered until they take the budget, then
+// sorted and written to a run file. Once every row was added the runs and the rows left in memory are merged
+type rowSorter struct {
+	orderBy []OrderBy
+	memory  int64
+	dir     string
+	name    string
+	rows    []sortedRow
+	// size is an estimate of the bytes the buffered rows take
+	size int64
+	runs []*os.File
+	// sources are the runs and the rows in memory being merged once the rows are sorted, see finish
+	sources sortSources
+}
+
+func newRowSorter(orderBy []OrderBy, memory int64, dir, name string) *rowSorter {
+	return &rowSorter{orderBy: orderBy, memory: memory, dir: dir, name: name, rows: make([]sortedRow, 0)}
+}
+
+// sortKey returns the key of record, see sortedRow
+func sortKey(orderBy []OrderBy, record tableparser.RecordValue) ([]byte, error) {
+	key := make([]byte, 0)
+	for _, o := range orderBy {
+		// A column without a value sorts before every value, a marker byte keeps the key of a column the prefix of
+		// no other
+		b := []byte{0}
+		if val := record[o.Column]; val != nil {
+			encoded, err := platformparser.NewValueMarshaler[any](val).MarshalComparableBinary()
+			if err != nil {
+				return nil, err
+			}
+			b = append([]byte{1}, encoded...)
+		}
+		if o.Descending {
+			for i := range b {
+				b[i] = ^b[i]
+			}
+		}
+		key = append(key, b...)
+	}
+	return key, nil
+}
+
+// rowSize estimates the bytes a row takes in memory
+func rowSize(row sortedRow) int64 {
+	size := int64(len(row.key)) + 64
+	for col, val := range row.row.Record {
+		size += int64(len(col)) + 32
+		if s, ok := val.(string); ok {
+			size += int64(len(s))
+		}
+	}
+	return size
+}
+
+// add adds a row, the buffered rows are written to a run once they exceed the budget
+func (s *rowSorter) add(row tableparser.RawRecord) error {
+	key, err := sortKey(s.orderBy, row.Record)
+	if err != nil {
+		return err
+	}
+	r := sortedRow{key: key, row: row}
+	s.rows = append(s.rows, r)
+	s.size += rowSize(r)
+	if s.size > s.memory {
+		return s.spill()
+	}
+	return nil
+}
+
+// sortRows sorts the buffered rows, rows of equal keys stay in the order they were added
+func (s *rowSorter) sortRows() {
+	slices.SortStableFunc(s.rows, func(a, b sortedRow) int {
+		return bytes.Compare(a.key, b.key)
+	})
+}
+
+// spill writes the buffered rows to a new run file
+func (s *rowSorter) spill() error {
+	s.sortRows()
+	f, err := os.CreateTemp(s.dir, fmt.Sprintf(sortRunPattern, s.name))
+	if err != nil {
+		return platformerror.NewStackTraceError(err.Error(), platformerror.OpenFileErrorCode)
+	}
+	s.runs = append(s.runs, f)
+
+	w := bufio.NewWriter(f)
+	for _, r := range s.rows {
+		b, err := marshalSortedRow(r)
+		if err != nil {
+			return err
+		}
+		if _, err = w.Write(b); err != nil {
+			return platformerror.NewStackTraceError(err.Error(), platformerror.BinaryWriteErrorCode)
+		}
+	}
+	if err = w.Flush(); err != nil {
+		return platformerror.NewStackTraceError(err.Error(), platformerror.BinaryWriteErrorCode)
+	}
+	if _, err = f.Seek(0, stdio.SeekStart); err != nil {
+		return platformerror.NewStackTraceError(err.Error(), platformerror.FileSeekErrorCode)
+	}
+	s.rows, s.size = s.rows[:0], 0
+	return nil
+}
+
+// sortType returns how the rows were sorted
+func (s *rowSorter) sortType() string {
+	if len(s.runs) > 0 {
+		return SortTypeExternal
+	}
+	return SortTypeMemory
+}
+
+// finish sorts the rows once every row was added, next then returns them in order. The runs and the rows left in
+// memory are merged, rows of equal keys come from the earlier source first as the rows in memory were added last
+func (s *rowSorter) finish() error {
+	s.sortRows()
+	s.sources = make(sortSources, 0, len(s.runs)+1)
+	for i, f := range s.runs {
+		src := &sortSource{order: i, reader: bufio.NewReader(f)}
+		if err := src.next(); err != nil {
+			return err
+		}
+		if src.current != nil {
+			s.sources = append(s.sources, src)
+		}
+	}
+	if len(s.rows) > 0 {
+		src := &sortSource{order: len(s.runs), rows: s.rows}
+		if err := src.next(); err != nil {
+			return err
+		}
+		s.sources = append(s.sources, src)
+	}
+	heap.Init(&s.sources)
+	return nil
+}
+
+// next returns the next row in order, ok is false once every row was returned
+func (s *rowSorter) next() (row tableparser.RawRecord, ok bool, err error) {
+	if len(s.sources) == 0 {
+		return row, false, nil
+	}
+	src := s.sources[0]
+	row = src.current.row
+	if err = src.next(); err != nil {
+		return row, false, err
+	}
+	if src.current == nil {
+		heap.Pop(&s.sources)
+	} else {
+		heap.Fix(&s.sources, 0)
+	}
+	return row, true, nil
+}
+
+// close removes the run files
+func (s *rowSorter) close() {
+	for _, f := range s.runs {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+	}
+	s.runs, s.sources = nil, nil
+}
+
+// sortSource is a run file, or the rows left in memory, being merged
+type sortSource struct {
+	order   int
+	reader  *bufio.Reader
+	rows    []sortedRow
+	current *sortedRow
+}
+
+// next moves to the next row of the source, current is nil once there are none
+func (s *sortSource) next() error {
+	if s.reader == nil {
+		s.current = nil
+		if len(s.rows) > 0 {
+			s.current, s.rows = &s.rows[0], s.rows[1:]
+		}
+		return nil
+	}
+	row, err := unmarshalSortedRow(s.reader)
+	if err == stdio.EOF {
+		s.current = nil
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	s.current = row
+	return nil
+}
+
+type sortSources []*sortSource
+
+func (s sortSources) Len() int { return len(s) }
+func (s sortSources) Less(i, j int) bool {
+	if n := bytes.Compare(s[i].current.key, s[j].current.key); n != 0 {
+		return n < 0
+	}
+	return s[i].order < s[j].order
+}
+func (s sortSources) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s *sortSources) Push(x any)   { *s = append(*s, x.(*sortSource)) }
+func (s *sortSources) Pop() any {
+	old := *s
+	src := old[len(old)-1]
+	*s = old[:len(old)-1]
+	return src
+}
+
+// sortedRowHeader is the number of values encoded before the columns of a row in a run, see marshalSortedRow
+const sortedRowHeader = 6
+
+// nullColumn starts the name of a column without a value in a run, no value follows it. Column names start with a
+// letter
+const nullColumn = "\x00"
+
+// marshalSortedRow encodes a row of a run: its length, the length of its key, the key, then the location, cache
+// key and sizes of the record followed by the name and value of each column as TLV
+func marshalSortedRow(r sortedRow) ([]byte, error) {
+	row := r.row
+	values := []any{row.Page, int32(row.Slot), row.CachePageKey, int64(row.Offset), int64(row.Size), int64(row.FullSize)}
+	for col, val := range row.Record {
+		if val == nil {
+			values = append(values, nullColumn+col)
+			continue
+		}
+		values = append(values, col, val)
+	}
+	payload := binary.LittleEndian.AppendUint32(nil, uint32(len(r.key)))
+	payload = append(payload, r.key...)
+	for _, val := range values {
+		b, err := platformparser.NewTLVMarshaler(val).MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		payload = append(payload, b...)
+	}
+	return append(binary.LittleEndian.AppendUint32(nil, uint32(len(payload))), payload...), nil
+}
+
+// unmarshalSortedRow reads the next row of a run, stdio.EOF once there are none
+func unmarshalSortedRow(r *bufio.Reader) (*sortedRow, error) {
+	length := make([]byte, 4)
+	if _, err := stdio.ReadFull(r, length); err != nil {
+		if err == stdio.EOF {
+			return nil, err
+		}
+		return nil, platformerror.NewStackTraceError(err.Error(), platformerror.IncompleteReadErrorCode)
+	}
+	payload := make([]byte, binary.LittleEndian.Uint32(length))
+	if _, err := stdio.ReadFull(r, payload); err != nil {
+		return nil, platformerror.NewStackTraceError(err.Error(), platformerror.IncompleteReadErrorCode)
+	}
+	keyLen := binary.LittleEndian.Uint32(payload)
+	row := &sortedRow{key: payload[4 : 4+keyLen], row: tableparser.RawRecord{Record: make(tableparser.RecordValue)}}
+
+	tlvParser := platformparser.NewTLVParser(io.NewReader(bytes.NewReader(payload[4+keyLen:])))
+	values := make([]any, 0)
+	for {
+		value, err := tlvParser.Parse()
+		if err == stdio.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	if len(values) < sortedRowHeader {
+		return nil, platformerror.NewStackTraceError(fmt.Sprintf("Expected %d values before the columns of a sorted row, got %d values",
+			sortedRowHeader, len(values)), platformerror.IncompleteReadErrorCode)
+	}
+	page, pageOk := values[0].(int64)
+	slot, slotOk := values[1].(int32)
+	cachePageKey, keyOk := values[2].(string)
+	offset, offsetOk := values[3].(int64)
+	size, sizeOk := values[4].(int64)
+	fullSize, fullSizeOk := values[5].(int64)
+	if !pageOk || !slotOk || !keyOk || !offsetOk || !sizeOk || !fullSizeOk {
+		return nil, platformerror.NewStackTraceError(fmt.Sprintf("Unexpected types %T %T %T %T %T %T in the header of a sorted row",
+			values[0], values[1], values[2], values[3], values[4], values[5]), platformerror.InvalidDataTypeErrorCode)
+	}
+	row.row.Page, row.row.Slot, row.row.CachePageKey = page, uint16(slot), cachePageKey
+	row.row.Offset, row.row.Size, row.row.FullSize = uint32(offset), uint32(size), uint32(fullSize)
+	for i := sortedRowHeader; i < len(values); {
+		col, ok := values[i].(string)
+		if !ok {
+			return nil, platformerror.NewStackTraceError(fmt.Sprintf("Expected a column name in a sorted row, got %T", values[i]),
+				platformerror.InvalidDataTypeErrorCode)
+		}
+		if strings.HasPrefix(col, nullColumn) {
+			row.row.Record[col[len(nullColumn):]] = nil
+			i++
+			continue
+		}
+		if i+1 == len(values) {
+			return nil, platformerror.NewStackTraceError(fmt.Sprintf("Expected the value of column %s in a sorted row", col),
+				platformerror.IncompleteReadErrorCode)
+		}
+		row.row.Record[col] = values[i+1]
+		i += 2
+	}
+	return row, nil
+}
@@ -8,12 +8,14 @@ import (
 	"math"
 	"os"
 	"path/filepath"
+	"simple-database/internal/engine/buffer"
 	"simple-database/internal/engine/table/column"
 	io2 "simple-database/internal/engine/table/column/io"
 	tableparser "simple-database/internal/engine/table/column/parser"
+	"simple-database/internal/engine/table/freespace"
 	"simple-database/internal/engine/table/index"
-	indexparser "simple-database/internal/engine/table/index/parser"
-	"simple-database/internal/platform"
+	"simple-database/internal/engine/table/page"
+	"simple-database/internal/engine/wal"
 	"simple-database/internal/platform/datatype"
 	platformerror "simple-database/internal/platform/error"
 	"simple-database/internal/platform/evaluator"
@@ -29,35 +31,54 @@ type Columns map[string]*column.Column
 const FileExtension = ".bin"
 const UnlimitedSize = math.MaxUint32
 
-var lastPagePos int64 = -1
-var pageRegionPos int64 = -1
-
-const PageSize = 4096
+const PageSize = page.Size
 
 type Table struct {
 	Name            string
-	file            *os.File
+	file            *wal.File
+	log             *wal.Log
 	ColumnNames     []string
 	columns         Columns
 	reader          *platformio.Reader
 	columnDefReader *io2.ColumnDefinitionReader
-	recordParser    *tableparser.RecordParser
-	indexes         map[string]*index.Index
-	lru             *platform.LRU[string, index.Page]
+	// indexes holds the indexes of the table by name, indexDefinitions describes them
+	indexes          map[string]*index.Index
+	indexDefinitions map[string]*IndexDefinition
+	// indexDefinitionsPos is the offset of the stored index definitions, right after the column definitions
+	indexDefinitionsPos int64
+	// pool caches the pages of the table and index files, it is shared by every table of the database
+	pool *buffer.Pool
+	heap *heapFile
+	// freeSpace holds the free bytes of every page. It is built on first use, see freeSpaceMap
+	freeSpace *freespace.Map
+	header    *Header
+	// version changes whenever the table files may have been written to
+	version uint64
+	// sortMemory is the number of bytes of rows a select sorts in memory, see rowSorter
+	sortMemory int64
 }
 
 type SelectResult struct {
 	Rows          []tableparser.RawRecord
 	AccessType    string
 	RowsInspected int
-	Extra         string
+	// SortType tells how the rows of a select with ORDER BY were sorted, it is empty without ORDER BY
+	SortType string
+	// GroupType tells how the groups of a select with GROUP BY or aggregates were computed
+	GroupType string
+	// JoinStrategies tells how each join of the select was computed, in the order of the joins
+	JoinStrategies []string
+	Extra          string
 }
 
 func (sr *SelectResult) String() string {
 	return fmt.Sprintf(
-		"SelectResult\n\tAccess Type: %s\n\tRows Inspected: %d\n\tExtra: %s\n\tTotal Rows: %d",
+		"SelectResult\n\tAccess Type: %s\n\tRows Inspected: %d\n\tSort Type: %s\n\tGroup Type: %s\n\tJoin Strategies: %s\n\tExtra: %s\n\tTotal Rows: %d",
 		sr.AccessType,
 		sr.RowsInspected,
+		sr.SortType,
+		sr.GroupType,
+		strings.Join(sr.JoinStrategies, ", "),
 		sr.Extra,
 		len(sr.Rows),
 	)
@@ -73,6 +94,26 @@ type SelectCommand struct {
 	Expression    *evaluator.Expression
 	Limit         uint32
 	TableName     string
+	// OrderBy holds the columns the rows are sorted on, the rows are in no particular order without them
+	OrderBy []OrderBy
+	// GroupBy holds the columns the rows are grouped on and Aggregates what is computed for every group. A select
+	// with either returns a row per group
+	GroupBy    []string
+	Aggregates []Aggregate
+	// Having filters the groups, its operands name the group columns and the aggregates, see Aggregate.Name
+	Having *evaluator.Expression
+	// Projections are the values returned for every row. Without them a select returns its SelectColumns, which
+	// are then the columns the projections read
+	Projections []Projection
+	// TableAlias names the table in the columns of a select joining tables, see Join
+	TableAlias string
+	// Joins are the tables joined to the table of the select, in order
+	Joins []Join
+}
+
+// grouped reports whether the select returns groups of rows
+func (c *SelectCommand) grouped() bool {
+	return len(c.GroupBy) > 0 || len(c.Aggregates) > 0
 }
 
 type UpdateCommand struct {
@@ -110,17 +151,25 @@ type DeleteResult struct {
 }
 
 type DeletableRecord struct {
-	id     any
-	offset int64
+	id   any
+	page int64
+	slot uint16
 }
 
 const AccessTypeAll = "All"
 const AccessTypeIndex = "Index"
 
+// AccessTypeIndexOnly is the access type of a select answered from the values stored in an index alone
+const AccessTypeIndexOnly = "IndexOnly"
+
+// AccessTypeIndexIntersection is the access type of a select reading the records found by every one of several
+// indexes, AccessTypeIndexUnion of one reading those found by any of them
+const AccessTypeIndexIntersection = "IndexIntersection"
+const AccessTypeIndexUnion = "IndexUnion"
+
 func newSelectResult() *SelectResult {
 	return &SelectResult{
 		AccessType: AccessTypeAll,
-		Extra:      "Not using page cache",
 		Rows:       make([]tableparser.RawRecord, 0),
 	}
 }
@@ -139,7 +188,7 @@ func (t *Table) writeColumnDefinitions() error {
 	return nil
 }
 
-func newTable(f *os.File) (*Table, error) {
+func newTable(f *os.File, log *wal.Log, pool *buffer.Pool) (*Table, error) {
 	if f == nil {
 		return nil, platformerror.NewStackTraceError(fmt.Sprintf("Null file pointer"), platformerror.OpenFileErrorCode)
 	}
@@ -148,55 +197,109 @@ func newTable(f *os.File) (*Table, error) {
 		return nil, err
 	}
 
-	r := platformio.NewReader(f)
+	file, err := wal.NewFile(f, log)
+	if err != nil {
+		return nil, err
+	}
+
+	r := platformio.NewReader(file)
 	columnDefReader := io2.NewColumnDefinitionReader(r)
 
 	return &Table{
-		file:            f,
-		Name:            tableName,
-		columns:         make(Columns),
-		reader:          r,
-		columnDefReader: columnDefReader,
-		lru:             platform.NewLRU[string, index.Page](100),
+		file:             file,
+		log:              log,
+		Name:             tableName,
+		columns:          make(Columns),
+		indexDefinitions: make(map[string]*IndexDefinition),
+		reader:           r,
+		columnDefReader:  columnDefReader,
+		pool:             pool,
+		heap:             &heapFile{file: file, name: tableName},
+		sortMemory:       DefaultSortMemory,
 	}, nil
 }
 
+// initIndexes opens the indexes declared by the flags of the columns and the ones of the stored definitions
 func (t *Table) initIndexes() {
-	indexes := make(map[string]*index.Index)
-
-	dbPath := GetPath(t.file)
-	tableName, _ := GetTableName(t.file)
+	for name, definition := range inlineIndexDefinitions(t.columns) {
+		t.indexDefinitions[name] = definition
+	}
 
-	for _, col := range t.columns {
-		if col.Is(column.UsingIndex) {
-			idxName := dbPath + "_" + tableName + "_" + helper.ToString(col.Name[:]) + "_idx.bin"
-			idx := index.NewIndex(idxName, col.Is(column.UsingUniqueIndex))
-			indexes[helper.ToString(col.Name[:])] = idx
-		}
+	indexes := make(map[string]*index.Index)
+	for name, definition := range t.indexDefinitions {
+		indexes[name] = index.NewIndex(t.indexFileName(name), definition.Unique, t.log, t.pool)
 	}
 	t.indexes = indexes
 }
 
-func NewTable(f *os.File) (*Table, error) {
-	t, err := newTable(f)
+// indexFileName returns the path of the file of the index called name. Indexes declared by the flags of a column
+// are named after it
+func (t *Table) indexFileName(name string) string {
+	tableName, _ := GetTableName(t.file.File)
+	return GetPath(t.file.File) + "_" + tableName + "_" + name + "_idx.bin"
+}
+
+// NewTable opens the table stored in f. Its pages and the pages of its indexes are cached in pool
+func NewTable(f *os.File, log *wal.Log, pool *buffer.Pool) (*Table, error) {
+	t, err := newTable(f, log, pool)
 
 	if err != nil {
 		return nil, err
 	}
 
+	if err = t.readHeader(); err != nil {
+		return nil, err
+	}
+
 	err = t.readColumnDefinitions()
 	if err != nil {
 		return nil, err
 	}
+	if err = t.readIndexDefinitions(); err != nil {
+		return nil, err
+	}
 
-	t.recordParser = tableparser.NewRecordParser(f, t.ColumnNames)
 	t.initIndexes()
 
+	if t.header.Version < HeaderVersion {
+		return t.upgrade()
+	}
+	if err = t.upgradeIndexes(); err != nil {
+		return nil, err
+	}
 	return t, nil
 }
 
-func NewTableWithColumns(file *os.File, columns Columns) (*Table, error) {
-	table, err := newTable(file)
+// upgradeIndexes rebuilds the indexes when one of them was written in an older format
+func (t *Table) upgradeIndexes() error {
+	for name, idx := range t.indexes {
+		outdated, err := idx.Outdated()
+		if err != nil {
+			return err
+		}
+		if outdated {
+			helper.Log.Infof("Index %s of table %s has an older format, rebuilding the indexes", name, t.Name)
+			return t.RebuildIndexes()
+		}
+	}
+	return nil
+}
+
+// upgrade rewrites a table file of an older format version, the indexes are rebuilt as the records move
+func (t *Table) upgrade() (*Table, error) {
+	helper.Log.Infof("Upgrading table %s from format version %d to %d", t.Name, t.header.Version, HeaderVersion)
+	v, err := t.PrepareVacuum()
+	if err != nil {
+		return nil, err
+	}
+	if _, err = t.FinishVacuum(v); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func NewTableWithColumns(file *os.File, columns Columns, log *wal.Log, pool *buffer.Pool) (*Table, error) {
+	table, err := newTable(file, log, pool)
 	if err != nil {
 		return nil, err
 	}
@@ -210,16 +313,109 @@ func NewTableWithColumns(file *os.File, columns Columns) (*Table, error) {
 	table.columns = columns
 
 	table.initIndexes()
+	if _, err = table.file.Seek(HeaderSize, stdio.SeekStart); err != nil {
+		return nil, platformerror.NewStackTraceError(err.Error(), platformerror.FileSeekErrorCode)
+	}
 	err = table.writeColumnDefinitions()
 	if err != nil {
 		return nil, err
 	}
 
+	columnsEnd, err := table.file.Seek(0, stdio.SeekCurrent)
+	if err != nil {
+		return nil, platformerror.NewStackTraceError(err.Error(), platformerror.FileSeekErrorCode)
+	}
+	table.indexDefinitionsPos = columnsEnd
+	table.header = NewHeader(firstPageAfter(columnsEnd))
+	table.heap.checksums = true
+	if err = table.writeHeader(); err != nil {
+		return nil, err
+	}
+
 	return table, nil
 }
 
+// readHeader reads the header at the start of the file. A file without one gets a version 0 header describing it
+func (t *Table) readHeader() error {
+	buf := make([]byte, HeaderSize)
+	n, err := t.file.File.ReadAt(buf, 0)
+	if err != nil && err != stdio.EOF {
+		return platformerror.NewStackTraceError(err.Error(), platformerror.BinaryReadErrorCode)
+	}
+	if n > 0 && buf[0] == datatype.TypeColumnDefinition {
+		stat, err := t.file.File.Stat()
+		if err != nil {
+			return platformerror.NewStackTraceError(err.Error(), platformerror.OpenFileErrorCode)
+		}
+		header := &Header{Magic: HeaderMagic, PageSize: PageSize}
+		if header.FirstPage, err = t.endOfColumnDefinitions(0, stat.Size()); err != nil {
+			return err
+		}
+		t.header = header
+		return nil
+	}
+
+	header := &Header{}
+	if err = header.UnmarshalBinary(buf[:n]); err != nil {
+		return err
+	}
+	t.header = header
+	t.heap.checksums = header.Version >= checksumVersion
+	return nil
+}
+
+func (t *Table) writeHeader() error {
+	b, err := t.header.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	n, err := t.file.WriteAt(b, 0)
+	if err != nil {
+		return platformerror.NewStackTraceError(err.Error(), platformerror.BinaryWriteErrorCode)
+	}
+	if n != len(b) {
+		return platformerror.NewStackTraceError(fmt.Sprintf("Expected %d, got %d", len(b), n), platformerror.BinaryWriteErrorCode)
+	}
+	return nil
+}
+
+// SetSortMemory sets the number of bytes of rows a select, or of entries an index build, sorts in memory before
+// writing them to run files
+func (t *Table) SetSortMemory(bytes int64) {
+	t.sortMemory = bytes
+}
+
+// RowCount returns the number of rows stored in the table
+func (t *Table) RowCount() uint64 {
+	return t.header.RowCount
+}
+
+// firstPageAfter returns the offset of the first page boundary at or after pos
+func firstPageAfter(pos int64) int64 {
+	return (pos + PageSize - 1) / PageSize * PageSize
+}
+
+// endOfColumnDefinitions returns the offset right after the column definitions starting at pos. The file may end
+// before size, as the first page of a table without rows is never written
+func (t *Table) endOfColumnDefinitions(pos int64, size int64) (int64, error) {
+	meta := make([]byte, datatype.LenMeta)
+	for pos < size {
+		if _, err := t.file.File.ReadAt(meta, pos); err != nil {
+			if err == stdio.EOF {
+				break
+			}
+			return 0, platformerror.NewStackTraceError(err.Error(), platformerror.BinaryReadErrorCode)
+		}
+		if meta[0] != datatype.TypeColumnDefinition {
+			break
+		}
+		pos += datatype.LenMeta + int64(binary.LittleEndian.Uint32(meta[datatype.LenByte:]))
+	}
+	return pos, nil
+}
+
 func (t *Table) readColumnDefinitions() error {
-	if _, err := t.file.Seek(0, stdio.SeekStart); err != nil {
+	if _, err := t.file.Seek(t.header.columnsPos(), stdio.SeekStart); err != nil {
 		return platformerror.NewStackTraceError(err.Error(), platformerror.FileSeekErrorCode)
 	}
 
@@ -243,27 +439,82 @@ func (t *Table) readColumnDefinitions() error {
 	return nil
 }
 
-func (t *Table) Insert(command InsertCommand) (int, error) {
-	if _, err := t.file.Seek(0, stdio.SeekEnd); err != nil {
-		return 0, platformerror.NewStackTraceError(err.Error(), platformerror.FileSeekErrorCode)
-	}
+func (t *Table) Insert(command InsertCommand) (n int, e error) {
+	t.log.Begin()
+	defer func() { e = t.endOperation(e) }()
 
 	if err := t.validateColumns(command.Record); err != nil {
 		return 0, err
 	}
 
+	buf, err := t.marshalRecord(command.Record, t)
+	if err != nil {
+		return 0, err
+	}
+
+	p, slot, err := t.insertRecord(buf)
+	if err != nil {
+		return 0, err
+	}
+
+	primaryKeyColumnName := t.getPrimaryKeyColumnName()
+	for name, idx := range t.indexes {
+		item := t.indexDefinitions[name].item(command.Record, command.Record[primaryKeyColumnName], p.Num, slot)
+		if err = idx.Add(item); err != nil {
+			return 0, err
+		}
+	}
+
+	t.header.RowCount++
+	t.header.NextRowId++
+	if err = t.writeHeader(); err != nil {
+		return 0, err
+	}
+
+	return 1, nil
+}
+
+// marshalRecord returns the record as it is stored in a page: a TLV holding the TLV of every column. While the
+// record is larger than page.InlineRecordSize, its largest STRING values are moved to overflow pages through w
+func (t *Table) marshalRecord(record tableparser.RecordValue, w overflowWriter) ([]byte, error) {
+	values := make([][]byte, len(t.ColumnNames))
 	var sizeOfRecord uint32 = 0
-	for _, col := range t.ColumnNames {
-		val, ok := command.Record[col]
+	for i, col := range t.ColumnNames {
+		val, ok := record[col]
 		if !ok {
-			return 0, platformerror.NewStackTraceError(fmt.Sprintf("Table.Insert: missing column: %s", col), platformerror.MissingColumnErrorCode)
+			return nil, platformerror.NewStackTraceError(fmt.Sprintf("Table.Insert: missing column: %s", col), platformerror.MissingColumnErrorCode)
 		}
 		tlvMarshaler := parser.NewTLVMarshaler(val)
-		length, err := tlvMarshaler.TLVLength()
+		b, err := tlvMarshaler.MarshalBinary()
 		if err != nil {
-			return 0, err
+			return nil, err
 		}
-		sizeOfRecord += length
+		values[i] = b
+		sizeOfRecord += uint32(len(b))
+	}
+
+	for sizeOfRecord+datatype.LenMeta > page.InlineRecordSize {
+		largest := -1
+		for i, b := range values {
+			if b[0] == datatype.TypeString && len(b) > tableparser.OverflowValueSize && (largest == -1 || len(b) > len(values[largest])) {
+				largest = i
+			}
+		}
+		if largest == -1 {
+			break
+		}
+		value := values[largest][datatype.LenMeta:]
+		num, err := w.writeOverflow(value)
+		if err != nil {
+			return nil, err
+		}
+		overflowValue := tableparser.OverflowValue{Page: num, Length: uint32(len(value))}
+		b, err := overflowValue.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		sizeOfRecord -= uint32(len(values[largest]) - len(b))
+		values[largest] = b
 	}
 
 	buf := bytes.Buffer{}
@@ -271,43 +522,21 @@ func (t *Table) Insert(command InsertCommand) (int, error) {
 	byteMarshaler := parser.NewValueMarshaler(datatype.TypeRecord)
 	typeBuf, err := byteMarshaler.MarshalBinary()
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 	buf.Write(typeBuf)
 
 	intMarshaler := parser.NewValueMarshaler(sizeOfRecord)
 	lenBuf, err := intMarshaler.MarshalBinary()
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 	buf.Write(lenBuf)
 
-	for _, col := range t.ColumnNames {
-		v := command.Record[col]
-		tlvMarshaler := parser.NewTLVMarshaler(v)
-		b, err := tlvMarshaler.MarshalBinary()
-		if err != nil {
-			return 0, err
-		}
+	for _, b := range values {
 		buf.Write(b)
 	}
-
-	// TODO: consider also return the row offset for faster access
-	page, err := t.insertIntoPage(buf)
-	if err != nil {
-		return 0, err
-	}
-
-	primaryKeyColumnName := t.getPrimaryKeyColumnName()
-	for k, v := range t.indexes {
-		if err = v.Add(index.NewItem(command.Record[k], command.Record[primaryKeyColumnName], page.StartPos)); err != nil {
-			return 0, err
-		}
-	}
-
-	t.invalidateCache(t.pageKey(page.StartPos))
-
-	return 1, nil
+	return buf.Bytes(), nil
 }
 
 func (t *Table) validateColumns(record tableparser.RecordValue) error {
@@ -335,6 +564,15 @@ func (t *Table) getUniqueColumns() Columns {
 	return uniqueColumns
 }
 
+// columnTypes returns the data type of every column by name
+func (t *Table) columnTypes() map[string]byte {
+	types := make(map[string]byte, len(t.columns))
+	for name, col := range t.columns {
+		types[name] = col.DataType
+	}
+	return types
+}
+
 func (t *Table) getPrimaryKeyColumnName() string {
 	var primaryKeyColumnName string
 	for _, col := range t.columns {
@@ -346,229 +584,92 @@ func (t *Table) getPrimaryKeyColumnName() string {
 	return primaryKeyColumnName
 }
 
-func (t *Table) getColumnsUsingIndex(filteredColumnNames []string) (string, bool) {
-	for _, v := range filteredColumnNames {
-		_, ok := t.indexes[v]
-		if ok {
-			return v, true
-		}
+// Select runs a select on the table and returns all of its rows at once, held in SelectResult.Rows. Query returns
+// them one at a time instead
+func (t *Table) Select(command SelectCommand) (*SelectResult, error) {
+	// Pages read by selects running at the same time are counted too
+	before := t.pool.Stats()
+	op, selectResult, err := t.Query(command)
+	if err != nil {
+		return nil, err
 	}
-
-	return "", false
+	if err = collect(op, selectResult); err != nil {
+		return nil, err
+	}
+	after := t.pool.Stats()
+	selectResult.Extra = fmt.Sprintf("Buffer pool: %d hits, %d misses", after.Hits-before.Hits, after.Misses-before.Misses)
+	return selectResult, nil
 }
 
-func (t *Table) Select(command SelectCommand) (*SelectResult, error) {
+// Query returns the operators of a select on the table: the records found by its access path are filtered by the
+// where clause, sorted when no index gives their order, projected and cut at the limit. Rows are read as they are
+// pulled from the returned operator, the result tells how they are found and is complete once it is closed. Its
+// rows stay empty
+func (t *Table) Query(command SelectCommand) (Operator, *SelectResult, error) {
+	if len(command.Joins) > 0 {
+		return nil, nil, platformerror.NewStackTraceError("A select joining tables is run by SelectJoin", platformerror.UnknownCommandErrorCode)
+	}
 	filteredColumnNames := command.Expression.Keys()
 	if err := t.validateColumnNames(filteredColumnNames); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	selectResult := newSelectResult()
-
-	columnsUsingIndex, ok := t.getColumnsUsingIndex(filteredColumnNames)
-	var indexKeys []index.Item
-
-	if ok {
-		selectResult.AccessType = AccessTypeIndex
-
-		colVal, op := command.Expression.ValueAndOperator(columnsUsingIndex)
-
-		keys, err := t.indexes[columnsUsingIndex].Get(colVal, op)
+	if command.grouped() {
+		op, err := t.queryGroups(command, selectResult)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
-		if keys == nil {
-			return selectResult, nil
-		}
-
-		indexKeys = keys
+		return op, selectResult, nil
 	}
-
-	defer func() {
-		t.recordParser = tableparser.NewRecordParser(t.file, t.ColumnNames)
-	}()
-
-	if selectResult.AccessType == AccessTypeIndex {
-		for _, key := range indexKeys {
-			pageKey := t.pageKey(key.PagePos)
-
-			_, err := t.file.Seek(key.PagePos, stdio.SeekStart)
-			if err != nil {
-				return nil, platformerror.NewStackTraceError(err.Error(), platformerror.FileSeekErrorCode)
-			}
-
-			if !t.lru.Contains(pageKey) {
-				selectResult.Extra = "Not using page cache"
-				pr := indexparser.NewPageReader(t.reader)
-				pageContent := make([]byte, PageSize+datatype.LenMeta)
-				n, err := pr.Read(pageContent)
-				if err != nil {
-					return nil, err
-				}
-				pageContent = pageContent[:n]
-				reader := bytes.NewReader(pageContent)
-				t.recordParser = tableparser.NewRecordParser(reader, t.ColumnNames)
-				t.lru.Put(pageKey, *index.NewPageWithContent(key.PagePos, pageContent))
-			} else {
-				page := t.lru.Get(pageKey)
-				selectResult.Extra = "Using page cache"
-				t.recordParser = tableparser.NewRecordParser(bytes.NewReader(page.Content), t.ColumnNames)
-			}
-
-			var readBytes uint32 = 0
-
-			for {
-				n, err := t.recordParser.Parse()
-				if err != nil {
-					if err == stdio.EOF {
-						break
-					}
-					return nil, err
-				}
-				readBytes += uint32(n)
-
-				rawRecord := t.recordParser.Value
-				selectResult.RowsInspected++
-
-				if !t.evaluateWhereClause(command, rawRecord.Record) {
-					continue
-				}
-				if err != nil {
-					return nil, platformerror.NewStackTraceError(err.Error(), platformerror.FileSeekErrorCode)
-				}
-
-				rawRecord.Offset = uint32(key.PagePos) + readBytes - rawRecord.FullSize
-				rawRecord.CachePageKey = pageKey
-				rawRecord.CachePageKey = pageKey
-
-				selectResult.Rows = append(selectResult.Rows, *rawRecord)
-				if uint32(len(selectResult.Rows)) >= command.Limit {
-					return selectResult, nil
-				}
-			}
-		}
-	} else {
-		if err := t.moveToFirstPageRegion(); err != nil {
-			return nil, err
-		}
-
-		for {
-			_, err := t.recordParser.Parse()
-			if err != nil {
-				if err == stdio.EOF {
-					return selectResult, nil
-				}
-				return nil, err
-			}
-
-			rawRecord := t.recordParser.Value
-			selectResult.RowsInspected++
-
-			if !t.evaluateWhereClause(command, rawRecord.Record) {
-				continue
-			}
-			curPos, _ := t.file.Seek(0, stdio.SeekCurrent)
-			rawRecord.Offset = uint32(curPos - int64(rawRecord.FullSize))
-
-			selectResult.Rows = append(selectResult.Rows, *rawRecord)
-
-			if uint32(len(selectResult.Rows)) >= command.Limit {
-				return selectResult, nil
-			}
+	for _, col := range command.SelectColumns {
+		if col != "*" && !slices.Contains(t.ColumnNames, col) {
+			return nil, nil, platformerror.NewStackTraceError(fmt.Sprintf("unknown column in select: %s", col),
+				platformerror.ColumnViolationErrorCode)
 		}
 	}
-
-	return selectResult, nil
-}
-
-func (t *Table) moveToFirstPageRegion() error {
-	if pageRegionPos == -1 {
-		if _, err := t.file.Seek(0, stdio.SeekStart); err != nil {
-			return platformerror.NewStackTraceError(err.Error(), platformerror.FileSeekErrorCode)
-		}
-		if err := t.seekUntil(datatype.TypePage); err != nil {
-			return err
-		}
-		pageRegionPos, _ = t.file.Seek(0, stdio.SeekCurrent)
-	} else {
-		if _, err := t.file.Seek(pageRegionPos, stdio.SeekStart); err != nil {
-			return platformerror.NewStackTraceError(err.Error(), platformerror.FileSeekErrorCode)
-		}
+	rows, err := newSelectRows(command, t.ColumnNames)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	return nil
-}
-
-func (t *Table) seekUntil(targetType byte) error {
-	for {
-		dataType, err := t.reader.ReadByte()
-		if err != nil {
-			if err == stdio.EOF {
-				return err
-			}
-			return err
-		}
-		if dataType == targetType {
-			if _, err = t.file.Seek(-1*datatype.LenByte, stdio.SeekCurrent); err != nil {
-				return platformerror.NewStackTraceError(err.Error(), platformerror.FileSeekErrorCode)
-			}
-			return nil
-		}
-
-		if targetType == datatype.TypeRecord && dataType == datatype.TypePage {
-			// Ignore page's len
-			if _, err := t.reader.ReadUint32(); err != nil {
-				return err
+	// The columns the rows are sorted on are read too, an index covers the select only when it holds them
+	selectColumns := command.SelectColumns
+	if len(command.OrderBy) > 0 && len(selectColumns) > 0 && !slices.Contains(selectColumns, "*") {
+		selectColumns = slices.Clone(selectColumns)
+		for _, o := range command.OrderBy {
+			if slices.Contains(t.ColumnNames, o.Column) {
+				selectColumns = append(selectColumns, o.Column)
 			}
-			// The first type flag inside a page should be a record
-			dataType, err = t.skipDeletedRecords()
-
-			if err != nil {
-				return err
-			}
-			if dataType != targetType {
-				return platformerror.NewStackTraceError(fmt.Sprintf("First byte inside a page should be %d but %d found",
-					datatype.TypeRecord, dataType), platformerror.InvalidDataTypeErrorCode)
-			}
-			if _, err = t.file.Seek(-1, stdio.SeekCurrent); err != nil {
-				return platformerror.NewStackTraceError(err.Error(), platformerror.FileSeekErrorCode)
-			}
-			return nil
 		}
-
-		length, err := t.reader.ReadUint32()
-		if err != nil {
-			return err
+	}
+	path := t.planAccess(command.Expression, selectColumns)
+
+	sorted := false
+	if len(command.OrderBy) > 0 {
+		// Rows sorted on computed values are never in the order of an index
+		var ordered *accessPath
+		if !rows.extend {
+			read := t.readColumns(command.Expression, selectColumns)
+			ordered = t.orderedPath(path, read, command.Limit != UnlimitedSize, func(plan *indexPlan) bool {
+				return t.orderedBy(plan, command.OrderBy)
+			})
 		}
-
-		if _, err = t.file.Seek(int64(length), stdio.SeekCurrent); err != nil {
-			return platformerror.NewStackTraceError(err.Error(), platformerror.FileSeekErrorCode)
+		if ordered != nil {
+			// The records come in the order of the index, so the select still stops reading at the limit
+			selectResult.SortType = SortTypeIndex
+			path = ordered
+		} else {
+			sorted = true
 		}
 	}
-}
 
-func (t *Table) skipDeletedRecords() (dataType byte, err error) {
-	for {
-		dataType, err := t.reader.ReadByte()
-		if err != nil {
-			if err == stdio.EOF {
-				return 0, err
-			}
-			return 0, err
-		}
-		if dataType == datatype.TypeDeletedRecord {
-			l, err := t.reader.ReadUint32()
-			if err != nil {
-				return 0, err
-			}
-			if _, err = t.file.Seek(int64(l), stdio.SeekCurrent); err != nil {
-				return 0, platformerror.NewStackTraceError(err.Error(), platformerror.FileSeekErrorCode)
-			}
-		}
-		if dataType == datatype.TypeRecord {
-			return dataType, nil
-		}
+	selectResult.AccessType = path.accessType()
+	op := newFilter(t.scan(path, selectResult), command.Expression)
+	if sorted {
+		op = rows.sort(op, t, selectResult)
 	}
+	return rows.output(op), selectResult, nil
 }
 
 func (t *Table) validateColumnNames(columnNames []string) error {
@@ -585,15 +686,6 @@ func (t *Table) validateColumnNames(columnNames []string) error {
 	return nil
 }
 
-func (t *Table) evaluateWhereClause(command SelectCommand, record tableparser.RecordValue) bool {
-	if command.Expression == nil {
-		return true
-	}
-
-	e := evaluator.SimpleEvaluator{}
-	return e.Eval(*command.Expression, record)
-}
-
 func (t *Table) ensureColumnLength(record tableparser.RecordValue) error {
 	if len(record) != len(t.columns) {
 		return platformerror.NewStackTraceError(fmt.Sprintf("Expected column length: %d, got %d", len(record), len(t.columns)),
@@ -602,23 +694,50 @@ func (t *Table) ensureColumnLength(record tableparser.RecordValue) error {
 	return nil
 }
 
+// markRecordsAsDeleted empties the slots of the records, every page is written once. The overflow pages of the
+// records are freed
 func (t *Table) markRecordsAsDeleted(deletableRecords []*DeletableRecord) (n int, e error) {
+	pages := make(map[int64]*page.Page)
+	nums := make([]int64, 0)
+	overflowValues := make([]tableparser.OverflowValue, 0)
 	for _, rec := range deletableRecords {
-		if _, err := t.file.Seek(rec.offset, stdio.SeekStart); err != nil {
-			return 0, platformerror.NewStackTraceError(err.Error(), platformerror.FileSeekErrorCode)
+		p, ok := pages[rec.page]
+		if !ok {
+			var err error
+			if p, err = t.readPage(rec.page); err != nil {
+				return 0, err
+			}
+			pages[rec.page] = p
+			nums = append(nums, rec.page)
 		}
-		err := binary.Write(t.file, binary.LittleEndian, datatype.TypeDeletedRecord)
+		record, ok := p.Record(rec.slot)
+		if !ok {
+			return 0, platformerror.NewStackTraceError(fmt.Sprintf("Slot %d of page %d is already empty", rec.slot, rec.page),
+				platformerror.InvalidPageErrorCode)
+		}
+		values, err := tableparser.OverflowValues(record)
 		if err != nil {
-			return 0, platformerror.NewStackTraceError(err.Error(), platformerror.BinaryWriteErrorCode)
+			return 0, err
 		}
+		overflowValues = append(overflowValues, values...)
+		p.Delete(rec.slot)
+	}
+	for _, num := range nums {
+		if err := t.writePage(pages[num]); err != nil {
+			return 0, err
+		}
+	}
+	if err := t.freeOverflow(overflowValues); err != nil {
+		return 0, err
 	}
 	return len(deletableRecords), nil
 }
 
-func newDeletableRecord(id any, offset int64) *DeletableRecord {
+func newDeletableRecord(id any, page int64, slot uint16) *DeletableRecord {
 	return &DeletableRecord{
-		id:     id,
-		offset: offset,
+		id:   id,
+		page: page,
+		slot: slot,
 	}
 }
 
@@ -626,10 +745,10 @@ func newDeleteResult() *DeleteResult {
 	return &DeleteResult{}
 }
 
-func (t *Table) Delete(command DeleteCommand) (*DeleteResult, error) {
-	if err := t.moveToFirstPageRegion(); err != nil {
-		return nil, err
-	}
+func (t *Table) Delete(command DeleteCommand) (result *DeleteResult, e error) {
+	t.log.Begin()
+	defer func() { e = t.endOperation(e) }()
+
 	if err := t.validateColumnNames(command.Expression.Keys()); err != nil {
 		return nil, err
 	}
@@ -646,7 +765,7 @@ func (t *Table) Delete(command DeleteCommand) (*DeleteResult, error) {
 	for _, row := range selectResult.Rows {
 		id, _ := row.Record[primaryKeyColumnName]
 
-		deletableRecord := newDeletableRecord(id, int64(row.Offset))
+		deletableRecord := newDeletableRecord(id, row.Page, row.Slot)
 		deletableRecords = append(deletableRecords, deletableRecord)
 
 		deleteResult.DeletedRecords = append(deleteResult.DeletedRecords, &row)
@@ -654,56 +773,118 @@ func (t *Table) Delete(command DeleteCommand) (*DeleteResult, error) {
 		deleteResult.AffectedCachePageKeys = append(deleteResult.AffectedCachePageKeys, row.CachePageKey)
 	}
 
-	if _, err := t.markRecordsAsDeleted(deletableRecords); err != nil {
+	n, err := t.markRecordsAsDeleted(deletableRecords)
+	if err != nil {
+		return nil, err
+	}
+	t.header.RowCount -= uint64(n)
+	if err = t.writeHeader(); err != nil {
 		return nil, err
 	}
 
 	for _, rec := range deleteResult.DeletedRecords {
-		for k, v := range rec.Record {
-			idx, ok := t.indexes[k]
-			if !ok {
-				continue
-			}
-
-			if err := idx.Remove(index.NewItemKey(v, rec.Record[primaryKeyColumnName])); err != nil {
+		for name, idx := range t.indexes {
+			values := t.indexDefinitions[name].values(rec.Record)
+			if err := idx.Remove(index.NewCompositeItemKey(values, rec.Record[primaryKeyColumnName])); err != nil {
 				return nil, err
 			}
 		}
 	}
 
-	for _, p := range deleteResult.AffectedCachePageKeys {
-		t.invalidateCache(p)
-	}
-
 	return deleteResult, nil
 }
 
-func (t *Table) Update(command UpdateCommand) (int, error) {
+// Update rewrites every matching record in its slot. A record that no longer fits in its page is moved to
+// another one, only then all of its index entries change
+func (t *Table) Update(command UpdateCommand) (n int, e error) {
+	t.log.Begin()
+	defer func() { e = t.endOperation(e) }()
+
 	if err := t.validateColumns(command.Record); err != nil {
 		return 0, err
 	}
 
-	deleteResult, err := t.Delete(command.toDeleteCommand())
+	deleteCommand := command.toDeleteCommand()
+	selectResult, err := t.Select(deleteCommand.toSelectCommand())
 	if err != nil {
 		return 0, err
 	}
 
-	rawRecords := deleteResult.DeletedRecords
+	primaryKeyColumnName := t.getPrimaryKeyColumnName()
+	changed := func(row tableparser.RawRecord, col string) bool {
+		val, ok := command.Record[col]
+		return ok && val != row.Record[col]
+	}
 
-	for _, rawRecord := range rawRecords {
+	for _, row := range selectResult.Rows {
 		updatedRecord := make(map[string]interface{})
-		for k, v := range rawRecord.Record {
+		for k, v := range row.Record {
 			if updatedVal, ok := command.Record[k]; ok {
 				updatedRecord[k] = updatedVal
 			} else {
 				updatedRecord[k] = v
 			}
 		}
-		if _, err = t.Insert(InsertCommand{Record: updatedRecord, TableName: t.Name}); err != nil {
+
+		// The overflow pages of the old record are freed first, so the new values can take them
+		p, err := t.readPage(row.Page)
+		if err != nil {
+			return 0, err
+		}
+		oldRecord, _ := p.Record(row.Slot)
+		overflowValues, err := tableparser.OverflowValues(oldRecord)
+		if err != nil {
+			return 0, err
+		}
+		if err = t.freeOverflow(overflowValues); err != nil {
+			return 0, err
+		}
+		buf, err := t.marshalRecord(updatedRecord, t)
+		if err != nil {
 			return 0, err
 		}
+		if len(overflowValues) > 0 {
+			if p, err = t.readPage(row.Page); err != nil {
+				return 0, err
+			}
+		}
+
+		num, slot, moved := row.Page, row.Slot, false
+		if !p.Update(row.Slot, buf) {
+			p.Delete(row.Slot)
+			if err = t.writePage(p); err != nil {
+				return 0, err
+			}
+			if p, slot, err = t.insertRecord(buf); err != nil {
+				return 0, err
+			}
+			num, moved = p.Num, true
+		} else if err = t.writePage(p); err != nil {
+			return 0, err
+		}
+
+		for name, idx := range t.indexes {
+			definition := t.indexDefinitions[name]
+			if !moved && !slices.ContainsFunc(definition.stored(), func(col string) bool { return changed(row, col) }) &&
+				!changed(row, primaryKeyColumnName) {
+				continue
+			}
+			oldKey := index.NewCompositeItemKey(definition.values(row.Record), row.Record[primaryKeyColumnName])
+			if err = idx.Remove(oldKey); err != nil {
+				return 0, err
+			}
+			item := definition.item(updatedRecord, updatedRecord[primaryKeyColumnName], num, slot)
+			if err = idx.Add(item); err != nil {
+				return 0, err
+			}
+		}
 	}
-	return len(rawRecords), nil
+
+	// A moved record may have added a page
+	if err = t.writeHeader(); err != nil {
+		return 0, err
+	}
+	return len(selectResult.Rows), nil
 }
 
 func GetTableName(f *os.File) (string, error) {
@@ -723,251 +904,194 @@ func GetPath(f *os.File) string {
 	return filepath.Dir(f.Name()) + string(filepath.Separator)
 }
 
-func (t *Table) seekToNextPage(lenToFit uint32) (*index.Page, error) {
-	_, err := t.file.Seek(0, stdio.SeekStart)
-	if err != nil {
-		return nil, platformerror.NewStackTraceError(err.Error(), platformerror.FileSeekErrorCode)
-	}
-
-	if lastPagePos == -1 {
-		err = t.seekUntil(datatype.TypePage)
-		if err != nil {
-			if err == stdio.EOF {
-				page, err := t.insertEmptyPage()
-				if err != nil {
-					return nil, err
-				}
-				lastPagePos = page.StartPos
-				return page, nil
-			}
-
-			return nil, err
-		}
-
-		lastPagePos, err = t.file.Seek(0, stdio.SeekCurrent)
-	} else {
-		_, err = t.file.Seek(lastPagePos, stdio.SeekStart)
-		if err != nil {
-			return nil, err
-		}
-	}
-
-	// Skipping the type definition byte
-	if _, err = t.reader.ReadByte(); err != nil {
-		return nil, err
-	}
-
-	curPageLen, err := t.reader.ReadUint32()
+// readPage reads page num from the table file
+func (t *Table) readPage(num int64) (*page.Page, error) {
+	data, err := t.readPageData(num)
 	if err != nil {
 		return nil, err
 	}
+	return page.Load(num, data)
+}
 
-	if curPageLen+lenToFit <= PageSize {
-		pagePos, err := t.file.Seek(-1*datatype.LenMeta, stdio.SeekCurrent)
-		if err != nil {
-			return nil, platformerror.NewStackTraceError(err.Error(), platformerror.FileSeekErrorCode)
-		}
-
-		_, err = t.file.Seek(int64(curPageLen)+datatype.LenMeta, stdio.SeekCurrent)
-		lastPagePos = pagePos
-		return index.NewPage(pagePos), err
-	}
-
-	_, err = t.file.Seek(int64(curPageLen), stdio.SeekCurrent)
-	if err != nil {
-		return nil, platformerror.NewStackTraceError(err.Error(), platformerror.FileSeekErrorCode)
-	}
-	page, err := t.insertEmptyPage()
+// readPageData returns a copy of the content of page num, whatever its type, through the buffer pool
+func (t *Table) readPageData(num int64) ([]byte, error) {
+	frame, err := t.pool.Fetch(t.heap, num)
 	if err != nil {
 		return nil, err
 	}
-
-	helper.Log.Debugf("Page full, inserting new one at offset %d", page.StartPos)
-
-	lastPagePos = page.StartPos
-	return page, err
+	data := make([]byte, PageSize)
+	copy(data, frame.Data())
+	t.pool.Unpin(frame, false)
+	return data, nil
 }
 
-func (t *Table) insertEmptyPage() (*index.Page, error) {
-	buf := bytes.Buffer{}
-
-	// type
-	if err := binary.Write(&buf, binary.LittleEndian, datatype.TypePage); err != nil {
-		return nil, platformerror.NewStackTraceError(err.Error(), platformerror.BinaryWriteErrorCode)
-	}
-	// length
-	if err := binary.Write(&buf, binary.LittleEndian, uint32(0)); err != nil {
-		return nil, platformerror.NewStackTraceError(err.Error(), platformerror.BinaryWriteErrorCode)
-	}
-	n, err := t.file.Write(buf.Bytes())
-	if err != nil {
-		return nil, platformerror.NewStackTraceError(err.Error(), platformerror.BinaryWriteErrorCode)
-	}
-	if n != buf.Len() {
-		return nil, platformerror.NewStackTraceError(fmt.Sprintf("Expected %d, got %d", n, buf.Len()), platformerror.BinaryWriteErrorCode)
-	}
-
-	curPos, err := t.file.Seek(0, stdio.SeekCurrent)
-	if err != nil {
-		return nil, platformerror.NewStackTraceError(err.Error(), platformerror.FileSeekErrorCode)
-	}
-	// startPos should point at the very first byte, that is types.TypePage and 5 bytes before the current pos
-	startPos := curPos - (datatype.LenMeta)
-	if startPos <= 0 {
-		return nil, platformerror.NewStackTraceError(fmt.Sprintf("Unable to insert new page: start should be positive: %d", startPos),
-			platformerror.PagePosViolationErrorCode)
-	}
-	return index.NewPage(startPos), nil
+// heapFile reads and writes the pages of a table file for the buffer pool
+type heapFile struct {
+	file *wal.File
+	name string
+	// checksums is set when the pages of the file carry a checksum to verify
+	checksums bool
 }
 
-// insertIntoPage finds the first page that can fit buf and writes it into the page
-func (t *Table) insertIntoPage(buf bytes.Buffer) (*index.Page, error) {
-	page, err := t.seekToNextPage(uint32(buf.Len()))
-	if err != nil {
-		return nil, err
+func (h *heapFile) ReadPage(num int64, data []byte) error {
+	n, err := h.file.File.ReadAt(data, num*PageSize)
+	if err != nil && err != stdio.EOF {
+		return platformerror.NewStackTraceError(err.Error(), platformerror.BinaryReadErrorCode)
 	}
-	n, err := t.file.Write(buf.Bytes())
-	if err != nil {
-		return nil, platformerror.NewStackTraceError(err.Error(), platformerror.BinaryWriteErrorCode)
-	}
-	if n != buf.Len() {
-		return nil, platformerror.NewStackTraceError(fmt.Sprintf("Expected %d, got %d", n, buf.Len()), platformerror.BinaryWriteErrorCode)
+	if n != PageSize {
+		return platformerror.NewStackTraceError(fmt.Sprintf("Page %d of table %s: expected %d bytes, got %d", num, h.name, PageSize, n),
+			platformerror.IncompleteReadErrorCode)
 	}
-	// seek back to the beginning of the page
-	if _, err = t.file.Seek(page.StartPos, stdio.SeekStart); err != nil {
-		return nil, platformerror.NewStackTraceError(err.Error(), platformerror.FileSeekErrorCode)
+	if h.checksums {
+		if stored, actual := page.Checksums(data); stored != actual {
+			return platformerror.NewStackTraceError(fmt.Sprintf("Page %d of table %s is damaged: checksum %08x, expected %08x",
+				num, h.name, actual, stored), platformerror.ChecksumMismatchErrorCode)
+		}
 	}
-	return page, t.updatePageSize(page.StartPos, int32(buf.Len()))
+	return nil
 }
 
-// updatePageSize increases or decreases the size of a page by offset
-// if the new size is 0, the page is removed
-func (t *Table) updatePageSize(page int64, offset int32) (e error) {
-	origPos, err := t.file.Seek(0, stdio.SeekCurrent)
+func (h *heapFile) WritePage(num int64, data []byte) error {
+	n, err := h.file.WriteAt(data, num*PageSize)
 	if err != nil {
-		return platformerror.NewStackTraceError(err.Error(), platformerror.FileSeekErrorCode)
+		return platformerror.NewStackTraceError(err.Error(), platformerror.BinaryWriteErrorCode)
 	}
-	defer func() {
-		_, err := t.file.Seek(origPos, stdio.SeekStart)
-		e = err
-	}()
-
-	if _, err = t.file.Seek(page, stdio.SeekStart); err != nil {
-		return platformerror.NewStackTraceError(err.Error(), platformerror.FileSeekErrorCode)
+	if n != PageSize {
+		return platformerror.NewStackTraceError(fmt.Sprintf("Expected %d, got %d", PageSize, n), platformerror.BinaryWriteErrorCode)
 	}
+	return nil
+}
 
-	dataType, err := t.reader.ReadByte()
-	if err != nil {
-		return err
-	}
-	if dataType != datatype.TypePage {
-		return platformerror.NewStackTraceError(
-			fmt.Sprintf("Table.updatePageSize: file pointer is at wrong position: expected: %d, actual: %d", datatype.TypePage, dataType),
-			platformerror.InvalidDataTypeErrorCode)
-	}
-	length, err := t.reader.ReadUint32()
-	if err != nil {
+// writePage writes p to the buffer pool, it reaches the table file when the operation ends. The free-space map is
+// updated
+func (t *Table) writePage(p *page.Page) error {
+	if err := t.pool.Write(t.heap, p.Num, p.Bytes()); err != nil {
 		return err
 	}
-	_, err = t.file.Seek(-1*datatype.LenInt32, stdio.SeekCurrent)
-	if err != nil {
-		return err
-	}
-
-	var newLength uint32
-	if offset >= 0 {
-		newLength = length + uint32(offset)
-	} else {
-		newLength = length - uint32(-offset)
-	}
-
-	marshaler := parser.NewValueMarshaler[uint32](newLength)
-	b, err := marshaler.MarshalBinary()
-	if err != nil {
-		return err
+	if t.freeSpace != nil {
+		t.freeSpace.Set(p.Num, p.FreeSpace())
 	}
+	return nil
+}
 
-	n, err := t.file.Write(b)
-	if n != len(b) {
-		return platformerror.NewStackTraceError(fmt.Sprintf("Expected %v, got %v", len(b), n), platformerror.BinaryWriteErrorCode)
+// forEachPage calls fn with every page of the table holding records in order until fn returns false. Overflow
+// pages are skipped
+func (t *Table) forEachPage(fn func(p *page.Page) (bool, error)) error {
+	if t.header.LastPage == 0 {
+		return nil
 	}
-
-	// This will not likely to happen as delete is only marking records as deleted thus the page will not be empty
-	if newLength == 0 {
-		if err = t.removeEmptyPage(page); err != nil {
+	for num := t.header.FirstPage / PageSize; num <= t.header.LastPage/PageSize; num++ {
+		data, err := t.readPageData(num)
+		if err != nil {
+			return err
+		}
+		if page.IsOverflow(data) {
+			continue
+		}
+		p, err := page.Load(num, data)
+		if err != nil {
+			return err
+		}
+		more, err := fn(p)
+		if err != nil {
 			return err
 		}
+		if !more {
+			return nil
+		}
 	}
 	return nil
 }
 
-func (t *Table) removeEmptyPage(page int64) (e error) {
-	origPos, err := t.file.Seek(0, stdio.SeekCurrent)
-	if err != nil {
-		return platformerror.NewStackTraceError(err.Error(), platformerror.FileSeekErrorCode)
+// parseRecord parses the record in slot of p
+func (t *Table) parseRecord(p *page.Page, slot uint16) (*tableparser.RawRecord, error) {
+	record, ok := p.Record(slot)
+	if !ok {
+		return nil, platformerror.NewStackTraceError(fmt.Sprintf("Slot %d of page %d is empty", slot, p.Num),
+			platformerror.InvalidPageErrorCode)
 	}
-	defer func() {
-		_, err := t.file.Seek(origPos, stdio.SeekStart)
-		e = err
-	}()
-
-	if _, err = t.file.Seek(page, stdio.SeekStart); err != nil {
-		return platformerror.NewStackTraceError(err.Error(), platformerror.FileSeekErrorCode)
-	}
-	dataType, err := t.reader.ReadByte()
-	if err != nil {
-		return err
-	}
-	if dataType != datatype.TypePage {
-		return platformerror.NewStackTraceError(fmt.Sprintf("Expected %v, got %v", t, datatype.TypePage), platformerror.InvalidDataTypeErrorCode)
-	}
-	length, err := t.reader.ReadUint32()
-	if err != nil {
-		return err
+	recordParser := tableparser.NewRecordParserWithOverflow(bytes.NewReader(record), t.ColumnNames, t)
+	if _, err := recordParser.Parse(); err != nil && err != stdio.EOF {
+		return nil, err
 	}
-	if length != 0 {
-		return platformerror.NewStackTraceError(fmt.Sprintf("New page not empty"), platformerror.InvalidPageErrorCode)
+
+	rawRecord := recordParser.Value
+	rawRecord.Page = p.Num
+	rawRecord.Slot = slot
+	rawRecord.Offset = uint32(p.Offset()) + uint32(p.RecordOffset(slot))
+	rawRecord.CachePageKey = t.pageKey(p.Num)
+	return rawRecord, nil
+}
+
+// insertRecord adds record to the first page with enough free space, or to a new page appended to the file.
+// The caller writes the header, as header.LastPage may change
+func (t *Table) insertRecord(record []byte) (*page.Page, uint16, error) {
+	if len(record) > page.MaxRecordSize {
+		return nil, 0, platformerror.NewStackTraceError(fmt.Sprintf("Record of %d bytes does not fit in a page of table %s, at most %d bytes",
+			len(record), t.Name, page.MaxRecordSize), platformerror.RecordTooLargeErrorCode)
 	}
-	stat, err := t.file.Stat()
+	freeSpace, err := t.freeSpaceMap()
 	if err != nil {
-		return platformerror.NewStackTraceError(err.Error(), platformerror.OpenFileErrorCode)
+		return nil, 0, err
 	}
 
-	beforeReader := stdio.NewSectionReader(t.file, 0, page)
-	afterReader := stdio.NewSectionReader(t.file, page+datatype.LenMeta, stat.Size())
-	beforeBuf := make([]byte, page)
-
-	if _, err = beforeReader.Read(beforeBuf); err != nil {
-		return err
+	var p *page.Page
+	if num, ok := freeSpace.Find(len(record)); ok {
+		if p, err = t.readPage(num); err != nil {
+			return nil, 0, err
+		}
+	} else {
+		num = t.appendPage()
+		helper.Log.Debugf("No page of table %s can take %d bytes, appending page %d", t.Name, len(record), num)
+		p = page.New(num)
 	}
 
-	afterBuf := make([]byte, stat.Size()-(page+datatype.LenMeta))
-	if _, err = afterReader.Read(afterBuf); err != nil {
-		return err
+	slot, ok := p.Insert(record)
+	if !ok {
+		return nil, 0, platformerror.NewStackTraceError(fmt.Sprintf("Page %d has no room for %d bytes", p.Num, len(record)),
+			platformerror.InvalidPageErrorCode)
 	}
+	if err = t.writePage(p); err != nil {
+		return nil, 0, err
+	}
+	return p, slot, nil
+}
 
-	if _, err = t.file.Seek(0, stdio.SeekStart); err != nil {
-		return platformerror.NewStackTraceError(err.Error(), platformerror.FileSeekErrorCode)
+// appendPage returns the number of a new page after the last one. The caller writes the page and the header
+func (t *Table) appendPage() int64 {
+	num := t.header.FirstPage / PageSize
+	if t.header.LastPage != 0 {
+		num = t.header.LastPage/PageSize + 1
 	}
+	t.header.LastPage = num * PageSize
+	return num
+}
 
-	bw, err := t.file.Write(beforeBuf)
-	if err != nil {
-		return platformerror.NewStackTraceError(err.Error(), platformerror.BinaryWriteErrorCode)
+// freeSpaceMap returns the free-space map of the table, reading every page on first use
+func (t *Table) freeSpaceMap() (*freespace.Map, error) {
+	if t.freeSpace != nil {
+		return t.freeSpace, nil
 	}
 
-	aw, err := t.file.Write(afterBuf)
+	freeSpace := freespace.New()
+	err := t.forEachPage(func(p *page.Page) (bool, error) {
+		freeSpace.Set(p.Num, p.FreeSpace())
+		return true, nil
+	})
 	if err != nil {
-		return platformerror.NewStackTraceError(err.Error(), platformerror.BinaryWriteErrorCode)
+		return nil, err
 	}
 
-	if err = t.file.Truncate(int64(bw + aw)); err != nil {
-		return platformerror.NewStackTraceError(err.Error(), platformerror.BinaryWriteErrorCode)
-	}
-	return nil
+	t.freeSpace = freeSpace
+	return freeSpace, nil
 }
 
 // Close closes the table and the primaryKeyIndex files
 func (t *Table) Close() error {
+	if err := t.pool.FlushFile(t.heap); err != nil {
+		return err
+	}
+	t.pool.Drop(t.heap)
 	if err := t.file.Close(); err != nil {
 		return platformerror.NewStackTraceError(err.Error(), platformerror.CloseErrorCode)
 	}
@@ -979,12 +1103,41 @@ func (t *Table) Close() error {
 	return nil
 }
 
-func (t *Table) pageKey(pagePos int64) string {
-	return fmt.Sprintf("%s-%d", t.Name, pagePos)
+func (t *Table) pageKey(num int64) string {
+	return fmt.Sprintf("%s-%d", t.Name, num)
 }
 
-func (t *Table) invalidateCache(pageKey string) {
-	t.lru.Remove(pageKey)
+// endOperation writes the pages changed by the operation started by the caller back and commits it, or rolls back
+// every write it made to the table and index files when err is set
+func (t *Table) endOperation(err error) error {
+	t.version++
+	if err == nil {
+		if err = t.pool.Flush(); err == nil {
+			return t.log.Commit()
+		}
+	}
+	// Pages still dirty never reached the files, the log undoes the ones written back
+	t.pool.Discard()
+	if rollbackErr := t.log.Rollback(); rollbackErr != nil {
+		return rollbackErr
+	}
+	t.ResetCache()
+	return err
+}
+
+// ResetCache drops every cached page and reloads the header, used after the table files were changed underneath it
+func (t *Table) ResetCache() {
+	t.pool.Drop(t.heap)
+	for name, idx := range t.indexes {
+		if err := idx.ResetCache(); err != nil {
+			helper.Log.Errorf("Unable to reset index %s of table %s: %s", name, t.Name, err.Error())
+		}
+	}
+	t.freeSpace = nil
+	t.version++
+	if err := t.readHeader(); err != nil {
+		helper.Log.Errorf("Unable to read the header of table %s: %s", t.Name, err.Error())
+	}
 }
 
 func (t *Table) LogIndexes() error {
@@ -1008,14 +1161,12 @@ func (t *Table) LogIndex(name string) error {
 }
 
 func (t *Table) DropIndexes() error {
-	dbPath := GetPath(t.file)
-	tableName, _ := GetTableName(t.file)
 	for name := range t.indexes {
-		idxName := dbPath + "_" + tableName + "_" + name + "_idx.bin"
-		if err := os.Remove(idxName); err != nil {
+		if err := os.Remove(t.indexFileName(name)); err != nil {
 			return err
 		}
 	}
 	t.indexes = make(map[string]*index.Index)
+	t.indexDefinitions = make(map[string]*IndexDefinition)
 	return nil
 }
@@ -0,0 +1,407 @@
+package table
+
+import (
+	"fmt"
+	stdio "io"
+	"os"
+	"path/filepath"
+	tableparser "simple-database/internal/engine/table/column/parser"
+	"simple-database/internal/engine/table/index"
+	"simple-database/internal/engine/table/page"
+	platformerror "simple-database/internal/platform/error"
+	"simple-database/internal/platform/helper"
+	"strings"
+)
+
+// VacuumExtension is appended to the files a vacuum copies a table into before they replace the table files
+const VacuumExtension = ".vacuum"
+
+// swapExtension is the extension of the file listing the copies of a finished vacuum. As long as it exists the
+// copies are complete, so a swap interrupted by a crash is finished on startup, see RecoverVacuum
+const swapExtension = ".swap"
+
+type VacuumResult struct {
+	TableName      string
+	Rows           int
+	BytesBefore    int64
+	BytesAfter     int64
+	BytesReclaimed int64
+}
+
+// Vacuum is a compacted copy of a table that has not replaced the table files yet
+type Vacuum struct {
+	table *Table
+	// version is the version of the table the copy was made from
+	version uint64
+	// files are the paths of the table files, their copies have VacuumExtension appended
+	files []string
+	rows  int
+}
+
+// PrepareVacuum copies the live records of the table into new files, packing them into as few pages as
+// possible, and builds new indexes pointing at the copied pages once every record is copied. The table files are
+// only read with ReadAt, so selects can keep running meanwhile
+func (t *Table) PrepareVacuum() (_ *Vacuum, e error) {
+	v := &Vacuum{table: t, version: t.version}
+	defer func() {
+		if e != nil {
+			v.Abort()
+		}
+	}()
+
+	heapPath := t.file.Name()
+	v.files = append(v.files, heapPath)
+	heap, err := os.Create(heapPath + VacuumExtension)
+	if err != nil {
+		return nil, platformerror.NewStackTraceError(err.Error(), platformerror.OpenFileErrorCode)
+	}
+	defer func() {
+		if err := heap.Close(); err != nil && e == nil {
+			e = platformerror.NewStackTraceError(err.Error(), platformerror.CloseErrorCode)
+		}
+	}()
+
+	indexes := make(map[string]*index.Builder)
+	for name := range t.indexes {
+		idxPath := t.indexFileName(name)
+		v.files = append(v.files, idxPath)
+		// A copy left behind by an interrupted vacuum must not be added to
+		if err = os.Remove(idxPath + VacuumExtension); err != nil && !os.IsNotExist(err) {
+			return nil, platformerror.NewStackTraceError(err.Error(), platformerror.DeleteFileErrorCode)
+		}
+		indexes[name] = index.NewBuilder(idxPath+VacuumExtension, t.indexDefinitions[name].Unique, t.sortMemory)
+	}
+	defer func() {
+		for _, idx := range indexes {
+			idx.Close()
+		}
+	}()
+
+	// Column definitions are copied as they are and followed by the index definitions, the header is written once
+	// the pages are known
+	columnsPos := t.header.columnsPos()
+	columnsEnd, err := t.endOfColumnDefinitions(columnsPos, t.header.FirstPage)
+	if err != nil {
+		return nil, err
+	}
+	columns := stdio.NewSectionReader(t.file.File, columnsPos, columnsEnd-columnsPos)
+	if _, err = heap.Seek(HeaderSize, stdio.SeekStart); err != nil {
+		return nil, platformerror.NewStackTraceError(err.Error(), platformerror.FileSeekErrorCode)
+	}
+	if _, err = stdio.Copy(heap, columns); err != nil {
+		return nil, platformerror.NewStackTraceError(err.Error(), platformerror.BinaryWriteErrorCode)
+	}
+	definitions, err := t.marshalIndexDefinitions()
+	if err != nil {
+		return nil, err
+	}
+	if _, err = heap.Write(definitions); err != nil {
+		return nil, platformerror.NewStackTraceError(err.Error(), platformerror.BinaryWriteErrorCode)
+	}
+	header := NewHeader(firstPageAfter(HeaderSize + columnsEnd - columnsPos + int64(len(definitions))))
+
+	copied := &vacuumHeap{file: heap, header: header, next: header.FirstPage / PageSize}
+	p := page.New(copied.allocate())
+
+	primaryKeyColumnName := t.getPrimaryKeyColumnName()
+	err = t.scanRecords(func(value tableparser.RecordValue) error {
+		record, err := t.marshalRecord(value, copied)
+		if err != nil {
+			return err
+		}
+		if len(record) > page.MaxRecordSize {
+			return platformerror.NewStackTraceError(fmt.Sprintf("Record of %d bytes does not fit in a page of table %s, at most %d bytes",
+				len(record), t.Name, page.MaxRecordSize), platformerror.RecordTooLargeErrorCode)
+		}
+		slot, ok := p.Insert(record)
+		if !ok {
+			if err := copied.write(p.Num, p.Bytes()); err != nil {
+				return err
+			}
+			p = page.New(copied.allocate())
+			slot, _ = p.Insert(record)
+		}
+
+		for name, idx := range indexes {
+			item := t.indexDefinitions[name].item(value, value[primaryKeyColumnName], p.Num, slot)
+			if err := idx.Add(item); err != nil {
+				return err
+			}
+		}
+		v.rows++
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	// The last page is written even without records when overflow pages follow it, the pages must not have gaps
+	if p.SlotCount() > 0 || p.Num+1 < copied.next {
+		if err = copied.write(p.Num, p.Bytes()); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, idx := range indexes {
+		if err = idx.Build(); err != nil {
+			return nil, err
+		}
+	}
+
+	header.RowCount = uint64(v.rows)
+	header.NextRowId = max(t.header.NextRowId, header.RowCount)
+	b, err := header.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	if _, err = heap.WriteAt(b, 0); err != nil {
+		return nil, platformerror.NewStackTraceError(err.Error(), platformerror.BinaryWriteErrorCode)
+	}
+
+	if err = heap.Sync(); err != nil {
+		return nil, platformerror.NewStackTraceError(err.Error(), platformerror.BinaryWriteErrorCode)
+	}
+	return v, nil
+}
+
+// vacuumHeap is the table file a vacuum copies records into. Pages are numbered in the order they are allocated
+type vacuumHeap struct {
+	file   *os.File
+	header *Header
+	// next is the number of the next page to allocate
+	next int64
+}
+
+func (h *vacuumHeap) allocate() int64 {
+	h.next++
+	return h.next - 1
+}
+
+func (h *vacuumHeap) write(num int64, b []byte) error {
+	if _, err := h.file.WriteAt(b, num*PageSize); err != nil {
+		return platformerror.NewStackTraceError(err.Error(), platformerror.BinaryWriteErrorCode)
+	}
+	h.header.LastPage = max(h.header.LastPage, num*PageSize)
+	return nil
+}
+
+func (h *vacuumHeap) writeOverflow(data []byte) (int64, error) {
+	first := h.next
+	for start := 0; start == 0 || start < len(data); start += page.OverflowCapacity {
+		num := h.allocate()
+		var next int64
+		if start+page.OverflowCapacity < len(data) {
+			next = num + 1
+		}
+		if err := h.write(num, page.EncodeOverflow(next, data[start:min(start+page.OverflowCapacity, len(data))])); err != nil {
+			return 0, err
+		}
+	}
+	return first, nil
+}
+
+// scanRecords calls fn with the values of every record of the table. The table file is only read with ReadAt
+func (t *Table) scanRecords(fn func(value tableparser.RecordValue) error) error {
+	if t.header.Version < slottedPagesVersion {
+		return t.scanLegacyRecords(fn)
+	}
+	return t.forEachPage(func(p *page.Page) (bool, error) {
+		for slot := uint16(0); slot < p.SlotCount(); slot++ {
+			if _, ok := p.Record(slot); !ok {
+				continue
+			}
+			rawRecord, err := t.parseRecord(p, slot)
+			if err != nil {
+				return false, err
+			}
+			if err = fn(rawRecord.Record); err != nil {
+				return false, err
+			}
+		}
+		return true, nil
+	})
+}
+
+// scanLegacyRecords reads the records of a file written before slotted pages, where pages of any length follow
+// each other and deleted records are kept in place with their own type
+func (t *Table) scanLegacyRecords(fn func(value tableparser.RecordValue) error) error {
+	stat, err := t.file.File.Stat()
+	if err != nil {
+		return platformerror.NewStackTraceError(err.Error(), platformerror.OpenFileErrorCode)
+	}
+	section := stdio.NewSectionReader(t.file.File, t.header.FirstPage, stat.Size()-t.header.FirstPage)
+	recordParser := tableparser.NewRecordParser(section, t.ColumnNames)
+	for {
+		if _, err = recordParser.Parse(); err != nil {
+			if err == stdio.EOF {
+				return nil
+			}
+			return err
+		}
+		if err = fn(recordParser.Value.Record); err != nil {
+			return err
+		}
+	}
+}
+
+// FinishVacuum replaces the table files with the copy made by PrepareVacuum. The caller must make sure nothing
+// else uses the table meanwhile. If the table was changed after the copy was made, it is copied again first
+func (t *Table) FinishVacuum(v *Vacuum) (*VacuumResult, error) {
+	if v.version != t.version {
+		helper.Log.Infof("Table %s changed while being vacuumed, copying it again", t.Name)
+		v.Abort()
+		var err error
+		if v, err = t.PrepareVacuum(); err != nil {
+			return nil, err
+		}
+	}
+
+	before, err := filesSize(v.files)
+	if err != nil {
+		v.Abort()
+		return nil, err
+	}
+
+	// The log must not hold images of files that are about to be replaced
+	if err = t.log.Checkpoint(); err != nil {
+		v.Abort()
+		return nil, err
+	}
+
+	swapPath := filepath.Join(filepath.Dir(t.file.Name()), t.Name+swapExtension)
+	if err = writeSwapFile(swapPath, v.files); err != nil {
+		v.Abort()
+		return nil, err
+	}
+
+	heapPath := t.file.Name()
+	version := t.version
+	if err = t.Close(); err != nil {
+		return nil, err
+	}
+	if err = completeSwap(swapPath); err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(heapPath, os.O_RDWR, 0777)
+	if err != nil {
+		return nil, platformerror.NewStackTraceError(err.Error(), platformerror.OpenFileErrorCode)
+	}
+	reopened, err := NewTable(f, t.log, t.pool)
+	if err != nil {
+		return nil, err
+	}
+	reopened.sortMemory = t.sortMemory
+	*t = *reopened
+	t.version = version + 1
+
+	after, err := filesSize(v.files)
+	if err != nil {
+		return nil, err
+	}
+
+	helper.Log.Infof("Vacuumed table %s: %d rows, %d bytes reclaimed", t.Name, v.rows, before-after)
+	return &VacuumResult{
+		TableName:      t.Name,
+		Rows:           v.rows,
+		BytesBefore:    before,
+		BytesAfter:     after,
+		BytesReclaimed: before - after,
+	}, nil
+}
+
+// Abort removes the copy
+func (v *Vacuum) Abort() {
+	for _, f := range v.files {
+		if err := os.Remove(f + VacuumExtension); err != nil && !os.IsNotExist(err) {
+			helper.Log.Warnf("Unable to remove %s: %s", f+VacuumExtension, err.Error())
+		}
+	}
+}
+
+// Table returns the table the copy was made from
+func (v *Vacuum) Table() *Table {
+	return v.table
+}
+
+// RecoverVacuum finishes the swaps of vacuums interrupted after their copy was complete and removes the copies
+// of vacuums interrupted before. It must run before the tables of dir are opened
+func RecoverVacuum(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return platformerror.NewStackTraceError(err.Error(), platformerror.OpenFileErrorCode)
+	}
+	for _, e := range entries {
+		if strings.HasSuffix(e.Name(), swapExtension) {
+			helper.Log.Infof("Finishing interrupted vacuum of %s", strings.TrimSuffix(e.Name(), swapExtension))
+			if err = completeSwap(filepath.Join(dir, e.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	for _, e := range entries {
+		if strings.HasSuffix(e.Name(), VacuumExtension) {
+			if err = os.Remove(filepath.Join(dir, e.Name())); err != nil && !os.IsNotExist(err) {
+				return platformerror.NewStackTraceError(err.Error(), platformerror.DeleteFileErrorCode)
+			}
+		}
+	}
+	return nil
+}
+
+func writeSwapFile(path string, files []string) error {
+	names := make([]string, 0, len(files))
+	for _, f := range files {
+		names = append(names, filepath.Base(f))
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return platformerror.NewStackTraceError(err.Error(), platformerror.OpenFileErrorCode)
+	}
+	if _, err = f.WriteString(strings.Join(names, "\n")); err != nil {
+		_ = f.Close()
+		return platformerror.NewStackTraceError(err.Error(), platformerror.BinaryWriteErrorCode)
+	}
+	if err = f.Sync(); err != nil {
+		_ = f.Close()
+		return platformerror.NewStackTraceError(err.Error(), platformerror.BinaryWriteErrorCode)
+	}
+	if err = f.Close(); err != nil {
+		return platformerror.NewStackTraceError(err.Error(), platformerror.CloseErrorCode)
+	}
+	return nil
+}
+
+// completeSwap moves every copy listed in the swap file over its original and removes the swap file. Copies
+// that were already moved are skipped, so it can run again after a crash
+func completeSwap(swapPath string) error {
+	content, err := os.ReadFile(swapPath)
+	if err != nil {
+		return platformerror.NewStackTraceError(err.Error(), platformerror.OpenFileErrorCode)
+	}
+	dir := filepath.Dir(swapPath)
+	for _, name := range strings.Split(string(content), "\n") {
+		if name == "" {
+			continue
+		}
+		target := filepath.Join(dir, name)
+		if err = os.Rename(target+VacuumExtension, target); err != nil && !os.IsNotExist(err) {
+			return platformerror.NewStackTraceError(err.Error(), platformerror.OpenFileErrorCode)
+		}
+	}
+	if err = os.Remove(swapPath); err != nil {
+		return platformerror.NewStackTraceError(err.Error(), platformerror.DeleteFileErrorCode)
+	}
+	return nil
+}
+
+func filesSize(files []string) (int64, error) {
+	var size int64
+	for _, f := range files {
+		stat, err := os.Stat(f)
+		if err != nil {
+			return 0, platformerror.NewStackTraceError(err.Error(), platformerror.OpenFileErrorCode)
+		}
+		size += stat.Size()
+	}
+	return size, nil
+}
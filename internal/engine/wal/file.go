@@ -0,0 +1,120 @@
+package wal
+
+import (
+	"fmt"
+	stdio "io"
+	"os"
+	"path/filepath"
+	"simple-database/internal/platform/datatype"
+	platformerror "simple-database/internal/platform/error"
+)
+
+// File wraps a data file so every mutation made while an operation is running is recorded in the log before
+// it reaches the disk. Reads and seeks go straight to the underlying os.File
+type File struct {
+	*os.File
+	log  *Log
+	name string
+	size int64
+}
+
+// NewFile wraps f and registers it in log. A nil log disables logging for the file
+func NewFile(f *os.File, log *Log) (*File, error) {
+	stat, err := f.Stat()
+	if err != nil {
+		return nil, platformerror.NewStackTraceError(err.Error(), platformerror.OpenFileErrorCode)
+	}
+	wf := &File{File: f, log: log, name: filepath.Base(f.Name()), size: stat.Size()}
+	log.register(wf)
+	return wf, nil
+}
+
+// Write writes b at the current offset and advances it, like os.File.Write
+func (f *File) Write(b []byte) (int, error) {
+	offset, err := f.File.Seek(0, stdio.SeekCurrent)
+	if err != nil {
+		return 0, platformerror.NewStackTraceError(err.Error(), platformerror.FileSeekErrorCode)
+	}
+	n, err := f.WriteAt(b, offset)
+	if _, seekErr := f.File.Seek(offset+int64(n), stdio.SeekStart); seekErr != nil && err == nil {
+		err = platformerror.NewStackTraceError(seekErr.Error(), platformerror.FileSeekErrorCode)
+	}
+	return n, err
+}
+
+func (f *File) WriteAt(b []byte, offset int64) (int, error) {
+	err := f.log.logMutation(func() (*record, error) {
+		before, err := f.readRange(offset, int64(len(b)))
+		if err != nil {
+			return nil, err
+		}
+		return &record{
+			kind:    datatype.TypeWALWrite,
+			file:    f.name,
+			offset:  offset,
+			oldSize: f.size,
+			before:  before,
+			after:   b,
+			target:  f,
+		}, nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := f.File.WriteAt(b, offset)
+	if end := offset + int64(n); end > f.size {
+		f.size = end
+	}
+	return n, err
+}
+
+func (f *File) Truncate(size int64) error {
+	err := f.log.logMutation(func() (*record, error) {
+		before, err := f.readRange(size, f.size-size)
+		if err != nil {
+			return nil, err
+		}
+		return &record{
+			kind:    datatype.TypeWALTruncate,
+			file:    f.name,
+			offset:  size,
+			oldSize: f.size,
+			before:  before,
+			target:  f,
+		}, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := f.File.Truncate(size); err != nil {
+		return err
+	}
+	f.size = size
+	return nil
+}
+
+func (f *File) Close() error {
+	f.log.unregister(f)
+	return f.File.Close()
+}
+
+// readRange returns the bytes currently stored in [offset, offset+length), clipped to the end of the file
+func (f *File) readRange(offset, length int64) ([]byte, error) {
+	if offset >= f.size || length <= 0 {
+		return []byte{}, nil
+	}
+	if offset+length > f.size {
+		length = f.size - offset
+	}
+	buf := make([]byte, length)
+	n, err := f.File.ReadAt(buf, offset)
+	if err != nil && err != stdio.EOF {
+		return nil, platformerror.NewStackTraceError(err.Error(), platformerror.BinaryReadErrorCode)
+	}
+	if int64(n) != length {
+		return nil, platformerror.NewStackTraceError(fmt.Sprintf("Expected %d, got %d", length, n), platformerror.IncompleteReadErrorCode)
+	}
+	return buf, nil
+}
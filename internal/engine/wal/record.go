@@ -0,0 +1,166 @@
+package wal
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	stdio "io"
+	"simple-database/internal/platform/datatype"
+	platformerror "simple-database/internal/platform/error"
+)
+
+// record is one entry of the log. Write and truncate records carry the before and after images of the
+// affected byte range so an operation can be undone or replayed; commit and abort records only carry the txID
+type record struct {
+	kind byte
+	txID uint64
+	// file is the base name of the data file relative to the database directory
+	file string
+	// offset is the write position, or the new size for a truncate record
+	offset int64
+	// oldSize is the size of the file before the mutation was applied
+	oldSize int64
+	before  []byte
+	after   []byte
+
+	// target is the open file the record was produced by. It is only set for records of the running transaction
+	target *File
+}
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// MarshalBinary encodes the record as type, length, payload followed by a CRC32C of everything before it
+func (r *record) MarshalBinary() ([]byte, error) {
+	payload := bytes.Buffer{}
+	if err := binary.Write(&payload, binary.LittleEndian, r.txID); err != nil {
+		return nil, platformerror.NewStackTraceError(err.Error(), platformerror.BinaryWriteErrorCode)
+	}
+
+	if r.kind == datatype.TypeWALWrite || r.kind == datatype.TypeWALTruncate {
+		fields := []any{
+			uint32(len(r.file)), []byte(r.file),
+			r.offset, r.oldSize,
+			uint32(len(r.before)), r.before,
+			uint32(len(r.after)), r.after,
+		}
+		for _, f := range fields {
+			if err := binary.Write(&payload, binary.LittleEndian, f); err != nil {
+				return nil, platformerror.NewStackTraceError(err.Error(), platformerror.BinaryWriteErrorCode)
+			}
+		}
+	}
+
+	buf := bytes.Buffer{}
+	buf.WriteByte(r.kind)
+	if err := binary.Write(&buf, binary.LittleEndian, uint32(payload.Len())); err != nil {
+		return nil, platformerror.NewStackTraceError(err.Error(), platformerror.BinaryWriteErrorCode)
+	}
+	buf.Write(payload.Bytes())
+	if err := binary.Write(&buf, binary.LittleEndian, crc32.Checksum(buf.Bytes(), crcTable)); err != nil {
+		return nil, platformerror.NewStackTraceError(err.Error(), platformerror.BinaryWriteErrorCode)
+	}
+	return buf.Bytes(), nil
+}
+
+// readRecord reads the next record from r. A torn or corrupted tail is reported as stdio.EOF because it can
+// only be the last record being appended when the process died
+func readRecord(r stdio.Reader) (*record, error) {
+	meta := make([]byte, datatype.LenMeta)
+	if _, err := stdio.ReadFull(r, meta); err != nil {
+		return nil, stdio.EOF
+	}
+	length := binary.LittleEndian.Uint32(meta[datatype.LenByte:])
+
+	rest := make([]byte, int(length)+datatype.LenInt32)
+	if _, err := stdio.ReadFull(r, rest); err != nil {
+		return nil, stdio.EOF
+	}
+	payload := rest[:length]
+	checksum := binary.LittleEndian.Uint32(rest[length:])
+
+	crc := crc32.Update(crc32.Checksum(meta, crcTable), crcTable, payload)
+	if crc != checksum {
+		return nil, stdio.EOF
+	}
+
+	rec := &record{kind: meta[0]}
+	if err := rec.unmarshalPayload(payload); err != nil {
+		return nil, err
+	}
+	return rec, nil
+}
+
+func (r *record) unmarshalPayload(payload []byte) error {
+	reader := bytes.NewReader(payload)
+	if err := binary.Read(reader, binary.LittleEndian, &r.txID); err != nil {
+		return platformerror.NewStackTraceError(err.Error(), platformerror.WALReadErrorCode)
+	}
+
+	switch r.kind {
+	case datatype.TypeWALCommit, datatype.TypeWALAbort:
+		return nil
+	case datatype.TypeWALWrite, datatype.TypeWALTruncate:
+	default:
+		return platformerror.NewStackTraceError(fmt.Sprintf("Unknown WAL record type %d", r.kind), platformerror.WALReadErrorCode)
+	}
+
+	readBytes := func() ([]byte, error) {
+		var l uint32
+		if err := binary.Read(reader, binary.LittleEndian, &l); err != nil {
+			return nil, platformerror.NewStackTraceError(err.Error(), platformerror.WALReadErrorCode)
+		}
+		b := make([]byte, l)
+		if _, err := stdio.ReadFull(reader, b); err != nil {
+			return nil, platformerror.NewStackTraceError(err.Error(), platformerror.WALReadErrorCode)
+		}
+		return b, nil
+	}
+
+	name, err := readBytes()
+	if err != nil {
+		return err
+	}
+	r.file = string(name)
+
+	if err = binary.Read(reader, binary.LittleEndian, &r.offset); err != nil {
+		return platformerror.NewStackTraceError(err.Error(), platformerror.WALReadErrorCode)
+	}
+	if err = binary.Read(reader, binary.LittleEndian, &r.oldSize); err != nil {
+		return platformerror.NewStackTraceError(err.Error(), platformerror.WALReadErrorCode)
+	}
+	if r.before, err = readBytes(); err != nil {
+		return err
+	}
+	if r.after, err = readBytes(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// redo applies the after image of the record to f
+func (r *record) redo(f writerTruncater) error {
+	if r.kind == datatype.TypeWALTruncate {
+		return f.Truncate(r.offset)
+	}
+	_, err := f.WriteAt(r.after, r.offset)
+	return err
+}
+
+// undo restores the before image of the record in f, shrinking the file back if the mutation grew it
+func (r *record) undo(f writerTruncater) error {
+	if len(r.before) > 0 {
+		if _, err := f.WriteAt(r.before, r.offset); err != nil {
+			return err
+		}
+	}
+	if r.kind == datatype.TypeWALWrite && r.offset+int64(len(r.after)) > r.oldSize {
+		return f.Truncate(r.oldSize)
+	}
+	return nil
+}
+
+type writerTruncater interface {
+	WriteAt(b []byte, off int64) (int, error)
+	Truncate(size int64) error
+}
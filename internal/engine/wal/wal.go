@@ -0,0 +1,448 @@
+package wal
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	stdio "io"
+	"os"
+	"path/filepath"
+	"simple-database/internal/engine/table/page"
+	"simple-database/internal/platform/datatype"
+	platformerror "simple-database/internal/platform/error"
+	"simple-database/internal/platform/helper"
+	"slices"
+	"sync"
+)
+
+// FileName is the name of the log inside the database directory
+const FileName = "wal.log"
+
+// CheckpointSize is the size the log may reach before it is truncated at the end of a transaction
+const CheckpointSize = 16 * 1024 * 1024
+
+// Log is a per-database write-ahead log. Every mutation of a table or index file made inside a transaction is
+// appended with its before and after images before it is applied, so a transaction that did not reach its
+// commit record can be undone on startup.
+//
+// Transactions nest: Begin inside a running transaction opens a savepoint that Commit releases and Rollback
+// undoes on its own. Only the outermost Commit writes the commit record. The log is shared by the files of every
+// table and index of a database, mu guards its state.
+type Log struct {
+	mu    sync.Mutex
+	file  *os.File
+	dir   string
+	files map[string]*File
+
+	nextTxID uint64
+	txID     uint64
+	// scopes holds, for every open Begin, the position in records at which it started
+	scopes []int
+	// records are the mutations of the running transaction, needed to roll it back without re-reading the log
+	records []*record
+	// synced holds, for every page written by the running transaction, the bytes of the page whose before images
+	// are already synced to the log
+	synced map[pageKey]spans
+}
+
+// pageKey is a page of a data file, numbered by its offset in page.Size
+type pageKey struct {
+	file string
+	num  int64
+}
+
+// span is the byte range [start, end) of a file
+type span struct {
+	start, end int64
+}
+
+// spans are byte ranges of a page, sorted and disjoint
+type spans []span
+
+// covers reports whether o lies within one of the ranges
+func (s spans) covers(o span) bool {
+	for _, r := range s {
+		if r.start <= o.start && o.end <= r.end {
+			return true
+		}
+	}
+	return false
+}
+
+// add returns the ranges with o added, merged with the ranges it overlaps or touches
+func (s spans) add(o span) spans {
+	merged := make(spans, 0, len(s)+1)
+	for _, r := range s {
+		if r.end < o.start || o.end < r.start {
+			merged = append(merged, r)
+			continue
+		}
+		o = span{start: min(r.start, o.start), end: max(r.end, o.end)}
+	}
+	merged = append(merged, o)
+	slices.SortFunc(merged, func(a, b span) int {
+		return cmp.Compare(a.start, b.start)
+	})
+	return merged
+}
+
+// Open opens or creates the log in dir. Call Recover before any data file of dir is opened
+func Open(dir string) (*Log, error) {
+	f, err := os.OpenFile(filepath.Join(dir, FileName), os.O_RDWR|os.O_CREATE, 0777)
+	if err != nil {
+		return nil, platformerror.NewStackTraceError(err.Error(), platformerror.OpenFileErrorCode)
+	}
+	return &Log{
+		file:     f,
+		dir:      dir,
+		files:    make(map[string]*File),
+		nextTxID: 1,
+	}, nil
+}
+
+// InTransaction reports whether mutations are currently being logged
+func (l *Log) InTransaction() bool {
+	if l == nil {
+		return false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.scopes) > 0
+}
+
+// Begin starts a transaction, or a savepoint if one is already running
+func (l *Log) Begin() {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.scopes) == 0 {
+		l.txID = l.nextTxID
+		l.nextTxID++
+		l.records = make([]*record, 0)
+		l.synced = make(map[pageKey]spans)
+	}
+	l.scopes = append(l.scopes, len(l.records))
+}
+
+// Commit closes the innermost Begin. The outermost one makes the transaction durable
+func (l *Log) Commit() error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.scopes) == 0 {
+		return platformerror.NewStackTraceError("Commit without a running transaction", platformerror.WALWriteErrorCode)
+	}
+	l.scopes = l.scopes[:len(l.scopes)-1]
+	if len(l.scopes) > 0 {
+		return nil
+	}
+
+	err := l.finish(datatype.TypeWALCommit)
+	if err != nil {
+		return err
+	}
+	if err = l.sync(); err != nil {
+		return err
+	}
+	return l.checkpointIfNeeded()
+}
+
+// Rollback undoes every mutation made since the innermost Begin and closes it. Rolling back the outermost
+// Begin aborts the transaction
+func (l *Log) Rollback() error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	if len(l.scopes) == 0 {
+		l.mu.Unlock()
+		return platformerror.NewStackTraceError("Rollback without a running transaction", platformerror.WALWriteErrorCode)
+	}
+	start := l.scopes[len(l.scopes)-1]
+	undone := make([]*record, len(l.records)-start)
+	copy(undone, l.records[start:])
+	l.mu.Unlock()
+
+	// The undo writes are logged as regular mutations of the transaction, so that a later commit replays them
+	// and a crash before the abort record undoes them together with what they compensate. They go through the
+	// files, which take the lock themselves
+	for i := len(undone) - 1; i >= 0; i-- {
+		if err := undone[i].undo(undone[i].target); err != nil {
+			return platformerror.NewStackTraceError(err.Error(), platformerror.WALWriteErrorCode)
+		}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.scopes = l.scopes[:len(l.scopes)-1]
+	if len(l.scopes) > 0 {
+		return nil
+	}
+	return l.finish(datatype.TypeWALAbort)
+}
+
+func (l *Log) finish(kind byte) error {
+	err := l.write(&record{kind: kind, txID: l.txID})
+	l.records = nil
+	l.synced = nil
+	l.txID = 0
+	return err
+}
+
+// logMutation appends the record made by mutation when a transaction is running. The data file is changed once it
+// returns, so the log is synced first unless the before image of the bytes the record changes is already on disk:
+// a page must not be overwritten before the log can undo it
+func (l *Log) logMutation(mutation func() (*record, error)) error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.scopes) == 0 {
+		return nil
+	}
+	rec, err := mutation()
+	if err != nil {
+		return err
+	}
+	if err = l.append(rec); err != nil {
+		return err
+	}
+
+	// A truncate drops bytes no earlier record may hold, the log is always synced before it
+	if rec.kind == datatype.TypeWALTruncate {
+		return l.sync()
+	}
+	// A write crossing pages is split by page, the log is synced unless the part of every page already is
+	parts := make(map[pageKey]span)
+	covered := true
+	for start, end := rec.offset, rec.offset+int64(len(rec.after)); start < end; {
+		key := pageKey{file: rec.file, num: start / page.Size}
+		part := span{start: start, end: min(end, (key.num+1)*page.Size)}
+		parts[key] = part
+		covered = covered && l.synced[key].covers(part)
+		start = part.end
+	}
+	if covered {
+		return nil
+	}
+	if err = l.sync(); err != nil {
+		return err
+	}
+	for key, part := range parts {
+		l.synced[key] = l.synced[key].add(part)
+	}
+	return nil
+}
+
+func (l *Log) sync() error {
+	if err := l.file.Sync(); err != nil {
+		return platformerror.NewStackTraceError(err.Error(), platformerror.WALWriteErrorCode)
+	}
+	return nil
+}
+
+func (l *Log) append(rec *record) error {
+	rec.txID = l.txID
+	if err := l.write(rec); err != nil {
+		return err
+	}
+	l.records = append(l.records, rec)
+	return nil
+}
+
+func (l *Log) write(rec *record) error {
+	b, err := rec.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	n, err := l.file.Write(b)
+	if err != nil {
+		return platformerror.NewStackTraceError(err.Error(), platformerror.WALWriteErrorCode)
+	}
+	if n != len(b) {
+		return platformerror.NewStackTraceError(fmt.Sprintf("Expected %d, got %d", len(b), n), platformerror.WALWriteErrorCode)
+	}
+	return nil
+}
+
+func (l *Log) register(f *File) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.files[f.name] = f
+}
+
+func (l *Log) unregister(f *File) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if registered, ok := l.files[f.name]; ok && registered == f {
+		delete(l.files, f.name)
+	}
+}
+
+func (l *Log) checkpointIfNeeded() error {
+	stat, err := l.file.Stat()
+	if err != nil {
+		return platformerror.NewStackTraceError(err.Error(), platformerror.WALWriteErrorCode)
+	}
+	if stat.Size() < CheckpointSize {
+		return nil
+	}
+	return l.checkpoint()
+}
+
+// Checkpoint flushes every registered data file and empties the log. It is a no-op while a transaction runs
+func (l *Log) Checkpoint() error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.checkpoint()
+}
+
+func (l *Log) checkpoint() error {
+	if len(l.scopes) > 0 {
+		return nil
+	}
+	for _, f := range l.files {
+		if err := f.Sync(); err != nil {
+			return platformerror.NewStackTraceError(err.Error(), platformerror.WALWriteErrorCode)
+		}
+	}
+	return l.reset()
+}
+
+func (l *Log) reset() error {
+	if err := l.file.Truncate(0); err != nil {
+		return platformerror.NewStackTraceError(err.Error(), platformerror.WALWriteErrorCode)
+	}
+	if _, err := l.file.Seek(0, stdio.SeekStart); err != nil {
+		return platformerror.NewStackTraceError(err.Error(), platformerror.FileSeekErrorCode)
+	}
+	return l.file.Sync()
+}
+
+// Recover replays committed and aborted transactions and undoes, newest first, the mutations of transactions that
+// never reached a commit or abort record. An aborted transaction is replayed with the writes of its rollback, logged
+// before its abort record, as those writes may not have reached the data files. The log is read up to its first
+// torn record, so a transaction whose commit or abort record is read has all of its records read too. It opens the
+// data files itself, so it must run before the tables are loaded
+func (l *Log) Recover() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, err := l.file.Seek(0, stdio.SeekStart); err != nil {
+		return platformerror.NewStackTraceError(err.Error(), platformerror.FileSeekErrorCode)
+	}
+
+	records := make([]*record, 0)
+	finished := make(map[uint64]byte)
+	for {
+		rec, err := readRecord(l.file)
+		if err != nil {
+			if errors.Is(err, stdio.EOF) {
+				break
+			}
+			return err
+		}
+		if rec.kind == datatype.TypeWALCommit || rec.kind == datatype.TypeWALAbort {
+			finished[rec.txID] = rec.kind
+			continue
+		}
+		records = append(records, rec)
+	}
+
+	files := make(map[string]*os.File)
+	defer func() {
+		for _, f := range files {
+			_ = f.Close()
+		}
+	}()
+	open := func(name string) (*os.File, error) {
+		if f, ok := files[name]; ok {
+			return f, nil
+		}
+		f, err := os.OpenFile(filepath.Join(l.dir, name), os.O_RDWR, 0777)
+		if err != nil {
+			if os.IsNotExist(err) {
+				// The file was dropped after the record was written
+				return nil, nil
+			}
+			return nil, platformerror.NewStackTraceError(err.Error(), platformerror.OpenFileErrorCode)
+		}
+		files[name] = f
+		return f, nil
+	}
+
+	redone, undone := 0, 0
+	for _, rec := range records {
+		if _, ok := finished[rec.txID]; !ok {
+			continue
+		}
+		f, err := open(rec.file)
+		if err != nil {
+			return err
+		}
+		if f == nil {
+			continue
+		}
+		if err = rec.redo(f); err != nil {
+			return platformerror.NewStackTraceError(err.Error(), platformerror.RecoveryErrorCode)
+		}
+		redone++
+	}
+
+	for i := len(records) - 1; i >= 0; i-- {
+		rec := records[i]
+		if _, ok := finished[rec.txID]; ok {
+			continue
+		}
+		f, err := open(rec.file)
+		if err != nil {
+			return err
+		}
+		if f == nil {
+			continue
+		}
+		if err = rec.undo(f); err != nil {
+			return platformerror.NewStackTraceError(err.Error(), platformerror.RecoveryErrorCode)
+		}
+		undone++
+	}
+
+	for _, f := range files {
+		if err := f.Sync(); err != nil {
+			return platformerror.NewStackTraceError(err.Error(), platformerror.RecoveryErrorCode)
+		}
+	}
+	if redone > 0 || undone > 0 {
+		helper.Log.Infof("WAL recovery in %s: %d mutations redone, %d mutations undone", l.dir, redone, undone)
+	}
+	return l.reset()
+}
+
+// Close checkpoints and closes the log
+func (l *Log) Close() error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := l.checkpoint(); err != nil {
+		return err
+	}
+	if err := l.file.Close(); err != nil {
+		return platformerror.NewStackTraceError(err.Error(), platformerror.CloseErrorCode)
+	}
+	return nil
+}
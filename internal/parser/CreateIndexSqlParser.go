@@ -0,0 +1,63 @@
+package parser
+
+import (
+	"simple-database/internal/engine"
+	configs "simple-database/internal/parser/grammar/createindex/configs"
+
+	"github.com/antlr4-go/antlr/v4"
+)
+
+type CreateIndexCommandASTVisitor struct {
+}
+
+func NewCreateIndexCommandASTVisitor() *CreateIndexCommandASTVisitor {
+	return &CreateIndexCommandASTVisitor{}
+}
+
+func (v *CreateIndexCommandASTVisitor) Visit(tree antlr.ParseTree) interface{}         { return tree.Accept(v) }
+func (v *CreateIndexCommandASTVisitor) VisitChildren(_ antlr.RuleNode) interface{}     { return nil }
+func (v *CreateIndexCommandASTVisitor) VisitTerminal(_ antlr.TerminalNode) interface{} { return nil }
+func (v *CreateIndexCommandASTVisitor) VisitErrorNode(_ antlr.ErrorNode) interface{}   { return nil }
+
+func (v *CreateIndexCommandASTVisitor) VisitQuery(ctx *configs.QueryContext) interface{} {
+	if stmt := ctx.CreateIndexStatement(); stmt != nil {
+		return v.Visit(stmt)
+	}
+	return nil
+}
+
+func (v *CreateIndexCommandASTVisitor) VisitCreateIndexStatement(ctx *configs.CreateIndexStatementContext) interface{} {
+	command := engine.CreateIndexCommand{
+		IndexName: v.Visit(ctx.IndexName()).(string),
+		TableName: v.Visit(ctx.TableName()).(string),
+		Unique:    ctx.UNIQUE() != nil,
+	}
+	for _, col := range ctx.AllColumnName() {
+		command.ColumnNames = append(command.ColumnNames, v.Visit(col).(string))
+	}
+	if include := ctx.IncludeClause(); include != nil {
+		command.IncludeColumnNames = v.Visit(include).([]string)
+	}
+
+	return command
+}
+
+func (v *CreateIndexCommandASTVisitor) VisitIncludeClause(ctx *configs.IncludeClauseContext) interface{} {
+	columns := make([]string, 0)
+	for _, col := range ctx.AllColumnName() {
+		columns = append(columns, v.Visit(col).(string))
+	}
+	return columns
+}
+
+func (v *CreateIndexCommandASTVisitor) VisitIndexName(ctx *configs.IndexNameContext) interface{} {
+	return ctx.GetText()
+}
+
+func (v *CreateIndexCommandASTVisitor) VisitTableName(ctx *configs.TableNameContext) interface{} {
+	return ctx.GetText()
+}
+
+func (v *CreateIndexCommandASTVisitor) VisitColumnName(ctx *configs.ColumnNameContext) interface{} {
+	return ctx.GetText()
+}
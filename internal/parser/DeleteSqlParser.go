@@ -45,7 +45,24 @@ func (v *DeleteCommandASTVisitor) VisitExpression(ctx *configs.ExpressionContext
 }
 
 func (v *DeleteCommandASTVisitor) VisitPredicate(ctx *configs.PredicateContext) interface{} {
+	if ctx.Expression() != nil {
+		return v.Visit(ctx.Expression())
+	}
+
 	left := v.Visit(ctx.Operand(0))
+	switch {
+	case ctx.BETWEEN() != nil:
+		// operand BETWEEN operand AND operand, the right side holds both bounds
+		bounds := []any{v.Visit(ctx.Operand(1)), v.Visit(ctx.Operand(2))}
+		return &evaluator.Expression{Left: left, Op: datatype.OperatorBetween, Right: bounds}
+	case ctx.IN() != nil:
+		values := make([]any, 0, len(ctx.AllOperand())-1)
+		for _, operand := range ctx.AllOperand()[1:] {
+			values = append(values, v.Visit(operand))
+		}
+		return &evaluator.Expression{Left: left, Op: datatype.OperatorIn, Right: values}
+	}
+
 	right := v.Visit(ctx.Operand(1))
 	op := ctx.Comparator().GetText()
 
@@ -0,0 +1,46 @@
+package parser
+
+import (
+	"simple-database/internal/engine"
+	configs "simple-database/internal/parser/grammar/dropindex/configs"
+
+	"github.com/antlr4-go/antlr/v4"
+)
+
+type DropIndexCommandASTVisitor struct {
+}
+
+func NewDropIndexCommandASTVisitor() *DropIndexCommandASTVisitor {
+	return &DropIndexCommandASTVisitor{}
+}
+
+func (v *DropIndexCommandASTVisitor) Visit(tree antlr.ParseTree) interface{}         { return tree.Accept(v) }
+func (v *DropIndexCommandASTVisitor) VisitChildren(_ antlr.RuleNode) interface{}     { return nil }
+func (v *DropIndexCommandASTVisitor) VisitTerminal(_ antlr.TerminalNode) interface{} { return nil }
+func (v *DropIndexCommandASTVisitor) VisitErrorNode(_ antlr.ErrorNode) interface{}   { return nil }
+
+func (v *DropIndexCommandASTVisitor) VisitQuery(ctx *configs.QueryContext) interface{} {
+	if stmt := ctx.DropIndexStatement(); stmt != nil {
+		return v.Visit(stmt)
+	}
+	return nil
+}
+
+func (v *DropIndexCommandASTVisitor) VisitDropIndexStatement(ctx *configs.DropIndexStatementContext) interface{} {
+	command := engine.DropIndexCommand{}
+	command.IndexName = v.Visit(ctx.IndexName()).(string)
+	// The table is only needed when several tables have an index of that name
+	if ctx.TableName() != nil {
+		command.TableName = v.Visit(ctx.TableName()).(string)
+	}
+
+	return command
+}
+
+func (v *DropIndexCommandASTVisitor) VisitIndexName(ctx *configs.IndexNameContext) interface{} {
+	return ctx.GetText()
+}
+
+func (v *DropIndexCommandASTVisitor) VisitTableName(ctx *configs.TableNameContext) interface{} {
+	return ctx.GetText()
+}
@@ -5,11 +5,15 @@ import (
 	"simple-database/internal/engine"
 	"simple-database/internal/engine/table"
 	creategrammar "simple-database/internal/parser/grammar/create/configs"
+	createindexgrammar "simple-database/internal/parser/grammar/createindex/configs"
 	deletegrammar "simple-database/internal/parser/grammar/delete/configs"
 	dropgrammar "simple-database/internal/parser/grammar/drop/configs"
+	dropindexgrammar "simple-database/internal/parser/grammar/dropindex/configs"
 	insertgrammar "simple-database/internal/parser/grammar/insert/configs"
 	selectgrammar "simple-database/internal/parser/grammar/select/configs"
+	transactiongrammar "simple-database/internal/parser/grammar/transaction/configs"
 	updategrammar "simple-database/internal/parser/grammar/update/configs"
+	vacuumgrammar "simple-database/internal/parser/grammar/vacuum/configs"
 
 	platformerror "simple-database/internal/platform/error"
 
@@ -243,3 +247,139 @@ func ParseDropTable(sql string) (engine.DropTableCommand, error) {
 	// 6. Cast to your type and UpdateCommand
 	return result.(engine.DropTableCommand), nil
 }
+
+func ParseTransaction(sql string) (engine.TransactionCommand, error) {
+	// 1. Turn raw string into ANTLR input
+	is := antlr.NewInputStream(sql)
+
+	// 2. Lexing: characters → tokens
+	lexer := transactiongrammar.NewTransactionSqlGrammarLexer(is)
+	stream := antlr.NewCommonTokenStream(lexer, antlr.TokenDefaultChannel)
+
+	// 3. Parsing: tokens → parse tree
+	parser := transactiongrammar.NewTransactionSqlGrammarParser(stream)
+	parser.BuildParseTrees = true
+	listener := NewErrorListener()
+	parser.RemoveErrorListeners()
+	parser.AddErrorListener(listener)
+
+	// 4. Parse starting rule (entry point)
+	tree := parser.Query()
+	if len(listener.Errors) > 0 {
+		return engine.TransactionCommand{},
+			platformerror.NewStackTraceError(fmt.Sprintf("Syntax error: %v", listener.Errors), platformerror.ParsingGrammarErrorCode)
+	}
+
+	// 5. Walk parse a tree and build your AST
+	visitor := NewTransactionCommandASTVisitor()
+	result := tree.Accept(visitor)
+
+	if result == nil {
+		return engine.TransactionCommand{}, nil
+	}
+
+	// 6. Cast to your type and return
+	return result.(engine.TransactionCommand), nil
+}
+
+func ParseVacuum(sql string) (engine.VacuumCommand, error) {
+	// 1. Turn raw string into ANTLR input
+	is := antlr.NewInputStream(sql)
+
+	// 2. Lexing: characters → tokens
+	lexer := vacuumgrammar.NewVacuumSqlGrammarLexer(is)
+	stream := antlr.NewCommonTokenStream(lexer, antlr.TokenDefaultChannel)
+
+	// 3. Parsing: tokens → parse tree
+	parser := vacuumgrammar.NewVacuumSqlGrammarParser(stream)
+	parser.BuildParseTrees = true
+	listener := NewErrorListener()
+	parser.RemoveErrorListeners()
+	parser.AddErrorListener(listener)
+
+	// 4. Parse starting rule (entry point)
+	tree := parser.Query()
+	if len(listener.Errors) > 0 {
+		return engine.VacuumCommand{},
+			platformerror.NewStackTraceError(fmt.Sprintf("Syntax error: %v", listener.Errors), platformerror.ParsingGrammarErrorCode)
+	}
+
+	// 5. Walk parse a tree and build your AST
+	visitor := NewVacuumCommandASTVisitor()
+	result := tree.Accept(visitor)
+
+	if result == nil {
+		return engine.VacuumCommand{}, nil
+	}
+
+	// 6. Cast to your type and return
+	return result.(engine.VacuumCommand), nil
+}
+
+func ParseCreateIndex(sql string) (engine.CreateIndexCommand, error) {
+	// 1. Turn raw string into ANTLR input
+	is := antlr.NewInputStream(sql)
+
+	// 2. Lexing: characters → tokens
+	lexer := createindexgrammar.NewCreateIndexSqlGrammarLexer(is)
+	stream := antlr.NewCommonTokenStream(lexer, antlr.TokenDefaultChannel)
+
+	// 3. Parsing: tokens → parse tree
+	parser := createindexgrammar.NewCreateIndexSqlGrammarParser(stream)
+	parser.BuildParseTrees = true
+	listener := NewErrorListener()
+	parser.RemoveErrorListeners()
+	parser.AddErrorListener(listener)
+
+	// 4. Parse starting rule (entry point)
+	tree := parser.Query()
+	if len(listener.Errors) > 0 {
+		return engine.CreateIndexCommand{},
+			platformerror.NewStackTraceError(fmt.Sprintf("Syntax error: %v", listener.Errors), platformerror.ParsingGrammarErrorCode)
+	}
+
+	// 5. Walk parse a tree and build your AST
+	visitor := NewCreateIndexCommandASTVisitor()
+	result := tree.Accept(visitor)
+
+	if result == nil {
+		return engine.CreateIndexCommand{}, nil
+	}
+
+	// 6. Cast to your type and return
+	return result.(engine.CreateIndexCommand), nil
+}
+
+func ParseDropIndex(sql string) (engine.DropIndexCommand, error) {
+	// 1. Turn raw string into ANTLR input
+	is := antlr.NewInputStream(sql)
+
+	// 2. Lexing: characters → tokens
+	lexer := dropindexgrammar.NewDropIndexSqlGrammarLexer(is)
+	stream := antlr.NewCommonTokenStream(lexer, antlr.TokenDefaultChannel)
+
+	// 3. Parsing: tokens → parse tree
+	parser := dropindexgrammar.NewDropIndexSqlGrammarParser(stream)
+	parser.BuildParseTrees = true
+	listener := NewErrorListener()
+	parser.RemoveErrorListeners()
+	parser.AddErrorListener(listener)
+
+	// 4. Parse starting rule (entry point)
+	tree := parser.Query()
+	if len(listener.Errors) > 0 {
+		return engine.DropIndexCommand{},
+			platformerror.NewStackTraceError(fmt.Sprintf("Syntax error: %v", listener.Errors), platformerror.ParsingGrammarErrorCode)
+	}
+
+	// 5. Walk parse a tree and build your AST
+	visitor := NewDropIndexCommandASTVisitor()
+	result := tree.Accept(visitor)
+
+	if result == nil {
+		return engine.DropIndexCommand{}, nil
+	}
+
+	// 6. Cast to your type and return
+	return result.(engine.DropIndexCommand), nil
+}
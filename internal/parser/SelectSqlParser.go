@@ -5,6 +5,7 @@ import (
 	configs "simple-database/internal/parser/grammar/select/configs"
 	"simple-database/internal/platform/datatype"
 	"simple-database/internal/platform/evaluator"
+	"slices"
 	"strconv"
 	"strings"
 
@@ -12,6 +13,10 @@ import (
 )
 
 type SelectCommandASTVisitor struct {
+	// aggregates holds the aggregates found in the select list, HAVING and ORDER BY, each once
+	aggregates []table.Aggregate
+	// columns holds the columns the select list reads outside of aggregates, each once
+	columns []string
 }
 
 func NewSelectCommandASTVisitor() *SelectCommandASTVisitor {
@@ -45,7 +50,24 @@ func (v *SelectCommandASTVisitor) VisitExpression(ctx *configs.ExpressionContext
 }
 
 func (v *SelectCommandASTVisitor) VisitPredicate(ctx *configs.PredicateContext) interface{} {
+	if ctx.Expression() != nil {
+		return v.Visit(ctx.Expression())
+	}
+
 	left := v.Visit(ctx.Operand(0))
+	switch {
+	case ctx.BETWEEN() != nil:
+		// operand BETWEEN operand AND operand, the right side holds both bounds
+		bounds := []any{v.Visit(ctx.Operand(1)), v.Visit(ctx.Operand(2))}
+		return &evaluator.Expression{Left: left, Op: datatype.OperatorBetween, Right: bounds}
+	case ctx.IN() != nil:
+		values := make([]any, 0, len(ctx.AllOperand())-1)
+		for _, operand := range ctx.AllOperand()[1:] {
+			values = append(values, v.Visit(operand))
+		}
+		return &evaluator.Expression{Left: left, Op: datatype.OperatorIn, Right: values}
+	}
+
 	right := v.Visit(ctx.Operand(1))
 	op := ctx.Comparator().GetText()
 
@@ -57,6 +79,11 @@ func (v *SelectCommandASTVisitor) VisitOperand(ctx *configs.OperandContext) inte
 		return v.Visit(ctx.Column())
 	}
 
+	// Aggregate, compared by the name of its value in the groups
+	if ctx.Aggregate() != nil {
+		return v.Visit(ctx.Aggregate()).(table.Aggregate).Name()
+	}
+
 	// Column
 	if ctx.Column() != nil {
 		return v.Visit(ctx.Column())
@@ -128,18 +155,36 @@ func (v *SelectCommandASTVisitor) VisitQuery(ctx *configs.QueryContext) interfac
 }
 
 func (v *SelectCommandASTVisitor) VisitSelectStatement(ctx *configs.SelectStatementContext) interface{} {
-	command := table.SelectCommand{}
-	selectColumns := v.Visit(ctx.SelectList())
-	command.SelectColumns = selectColumns.([]string)
+	command := table.SelectCommand{SelectColumns: []string{"*"}}
+	if projections := v.Visit(ctx.SelectList()).([]table.Projection); projections != nil {
+		command.Projections = projections
+		command.SelectColumns = v.columns
+	}
 
-	tableName := v.Visit(ctx.TableName())
-	command.TableName = tableName.(string)
+	from := v.Visit(ctx.TableReference()).(table.Join)
+	command.TableName, command.TableAlias = from.TableName, from.Alias
+	for _, join := range ctx.AllJoinClause() {
+		command.Joins = append(command.Joins, v.Visit(join).(table.Join))
+	}
 
 	if ctx.WhereClause() != nil {
 		expression := v.Visit(ctx.WhereClause())
 		command.Expression = expression.(*evaluator.Expression)
 	}
 
+	if ctx.GroupByClause() != nil {
+		command.GroupBy = v.Visit(ctx.GroupByClause()).([]string)
+	}
+
+	if ctx.HavingClause() != nil {
+		command.Having = v.Visit(ctx.HavingClause()).(*evaluator.Expression)
+	}
+
+	if ctx.OrderByClause() != nil {
+		command.OrderBy = v.Visit(ctx.OrderByClause()).([]table.OrderBy)
+	}
+	command.Aggregates = v.aggregates
+
 	if ctx.LimitClause() != nil {
 		l := v.Visit(ctx.LimitClause())
 		limit, _ := strconv.Atoi(l.(string))
@@ -149,22 +194,158 @@ func (v *SelectCommandASTVisitor) VisitSelectStatement(ctx *configs.SelectStatem
 	return command
 }
 
+// VisitTableReference returns the name and the alias of a table in a Join
+func (v *SelectCommandASTVisitor) VisitTableReference(ctx *configs.TableReferenceContext) interface{} {
+	reference := table.Join{TableName: v.Visit(ctx.TableName()).(string)}
+	if ctx.IDENTIFIER() != nil {
+		reference.Alias = ctx.IDENTIFIER().GetText()
+	}
+	return reference
+}
+
+func (v *SelectCommandASTVisitor) VisitJoinClause(ctx *configs.JoinClauseContext) interface{} {
+	join := v.Visit(ctx.TableReference()).(table.Join)
+	join.Type = table.JoinTypeInner
+	if ctx.JoinType() != nil {
+		join.Type = v.Visit(ctx.JoinType()).(string)
+	}
+	join.On = v.Visit(ctx.Expression()).(*evaluator.Expression)
+	return join
+}
+
+func (v *SelectCommandASTVisitor) VisitJoinType(ctx *configs.JoinTypeContext) interface{} {
+	switch {
+	case ctx.LEFT() != nil:
+		return table.JoinTypeLeft
+	case ctx.RIGHT() != nil:
+		return table.JoinTypeRight
+	}
+	return table.JoinTypeInner
+}
+
 func (v *SelectCommandASTVisitor) VisitWhereClause(ctx *configs.WhereClauseContext) interface{} {
 	return v.Visit(ctx.Expression())
 }
 
+func (v *SelectCommandASTVisitor) VisitGroupByClause(ctx *configs.GroupByClauseContext) interface{} {
+	cols := make([]string, 0, len(ctx.AllColumn()))
+	for _, col := range ctx.AllColumn() {
+		cols = append(cols, col.GetText())
+	}
+	return cols
+}
+
+func (v *SelectCommandASTVisitor) VisitHavingClause(ctx *configs.HavingClauseContext) interface{} {
+	return v.Visit(ctx.Expression())
+}
+
+func (v *SelectCommandASTVisitor) VisitOrderByClause(ctx *configs.OrderByClauseContext) interface{} {
+	orderBy := make([]table.OrderBy, 0, len(ctx.AllOrderItem()))
+	for _, item := range ctx.AllOrderItem() {
+		orderBy = append(orderBy, v.Visit(item).(table.OrderBy))
+	}
+	return orderBy
+}
+
+func (v *SelectCommandASTVisitor) VisitOrderItem(ctx *configs.OrderItemContext) interface{} {
+	if ctx.Aggregate() != nil {
+		return table.OrderBy{Column: v.Visit(ctx.Aggregate()).(table.Aggregate).Name(), Descending: ctx.DESC() != nil}
+	}
+	return table.OrderBy{Column: ctx.Column().GetText(), Descending: ctx.DESC() != nil}
+}
+
+// VisitAggregateArgument returns the column of an aggregate and the type it is converted to
+func (v *SelectCommandASTVisitor) VisitAggregateArgument(ctx *configs.AggregateArgumentContext) interface{} {
+	argument := table.Aggregate{Column: ctx.Column().GetText()}
+	if ctx.TypeName() != nil {
+		argument.Cast = datatype.TypeByName(ctx.TypeName().GetText())
+	}
+	return argument
+}
+
+// VisitAggregate returns the aggregate and adds it to the aggregates of the select
+func (v *SelectCommandASTVisitor) VisitAggregate(ctx *configs.AggregateContext) interface{} {
+	aggregate := table.Aggregate{Function: strings.ToUpper(ctx.AggregateFunction().GetText())}
+	if ctx.AggregateArgument() != nil {
+		argument := v.Visit(ctx.AggregateArgument()).(table.Aggregate)
+		aggregate.Column, aggregate.Cast = argument.Column, argument.Cast
+	}
+	for _, a := range v.aggregates {
+		if a == aggregate {
+			return aggregate
+		}
+	}
+	v.aggregates = append(v.aggregates, aggregate)
+	return aggregate
+}
+
 func (v *SelectCommandASTVisitor) VisitSelectList(ctx *configs.SelectListContext) interface{} {
 	if ctx.STAR() != nil {
-		return []string{"*"}
+		return []table.Projection(nil)
 	}
 
-	// case: column (',' column)*
-	cols := make([]string, 0, len(ctx.AllColumn()))
-	for _, col := range ctx.AllColumn() {
-		cols = append(cols, col.GetText())
+	// case: selectItem (',' selectItem)*
+	projections := make([]table.Projection, 0, len(ctx.AllSelectItem()))
+	for _, item := range ctx.AllSelectItem() {
+		projections = append(projections, v.Visit(item).(table.Projection))
 	}
 
-	return cols
+	return projections
+}
+
+// VisitSelectItem returns the value of a select item named by its alias. Without one a column or an aggregate is
+// named as itself and anything else as its text
+func (v *SelectCommandASTVisitor) VisitSelectItem(ctx *configs.SelectItemContext) interface{} {
+	valueCtx := ctx.ValueExpression().(*configs.ValueExpressionContext)
+	projection := table.Projection{Name: valueCtx.GetText(), Expression: v.Visit(valueCtx)}
+	if valueCtx.Column() != nil || valueCtx.Aggregate() != nil {
+		projection.Name = projection.Expression.(string)
+	}
+	if ctx.AS() != nil {
+		projection.Name = ctx.IDENTIFIER().GetText()
+	}
+	return projection
+}
+
+// VisitValueExpression returns the name of a column or of an aggregate, a value, an arithmetic expression or a
+// function call
+func (v *SelectCommandASTVisitor) VisitValueExpression(ctx *configs.ValueExpressionContext) interface{} {
+	switch {
+	case ctx.Column() != nil:
+		col := ctx.Column().GetText()
+		if !slices.Contains(v.columns, col) {
+			v.columns = append(v.columns, col)
+		}
+		return col
+	case ctx.Aggregate() != nil:
+		return v.Visit(ctx.Aggregate()).(table.Aggregate).Name()
+	case ctx.FunctionCall() != nil:
+		return v.Visit(ctx.FunctionCall())
+	case ctx.TypedLiteral() != nil:
+		return v.Visit(ctx.TypedLiteral())
+	case ctx.TypeName() != nil:
+		// typeName ( valueExpression ) converts the value
+		return evaluator.Function{Name: strings.ToUpper(ctx.TypeName().GetText()), Args: []any{v.Visit(ctx.ValueExpression(0))}}
+	case ctx.LPAREN() != nil:
+		return v.Visit(ctx.ValueExpression(0))
+	}
+
+	// valueExpression operator valueExpression
+	pt, ok := ctx.GetChild(1).(antlr.ParseTree)
+	if !ok {
+		panic("child is not a ParseTree")
+	}
+	left := v.Visit(ctx.ValueExpression(0))
+	right := v.Visit(ctx.ValueExpression(1))
+	return &evaluator.Expression{Left: left, Op: datatype.FromSymbol(pt.GetText()), Right: right}
+}
+
+func (v *SelectCommandASTVisitor) VisitFunctionCall(ctx *configs.FunctionCallContext) interface{} {
+	args := make([]any, 0, len(ctx.AllValueExpression()))
+	for _, arg := range ctx.AllValueExpression() {
+		args = append(args, v.Visit(arg))
+	}
+	return evaluator.Function{Name: strings.ToUpper(ctx.IDENTIFIER().GetText()), Args: args}
 }
 
 func (v *SelectCommandASTVisitor) VisitComparator(ctx *configs.ComparatorContext) interface{} {
@@ -0,0 +1,52 @@
+package parser
+
+import (
+	"simple-database/internal/engine"
+	configs "simple-database/internal/parser/grammar/transaction/configs"
+
+	"github.com/antlr4-go/antlr/v4"
+)
+
+type TransactionCommandASTVisitor struct {
+}
+
+func NewTransactionCommandASTVisitor() *TransactionCommandASTVisitor {
+	return &TransactionCommandASTVisitor{}
+}
+
+func (v *TransactionCommandASTVisitor) Visit(tree antlr.ParseTree) interface{}         { return tree.Accept(v) }
+func (v *TransactionCommandASTVisitor) VisitChildren(_ antlr.RuleNode) interface{}     { return nil }
+func (v *TransactionCommandASTVisitor) VisitTerminal(_ antlr.TerminalNode) interface{} { return nil }
+func (v *TransactionCommandASTVisitor) VisitErrorNode(_ antlr.ErrorNode) interface{}   { return nil }
+
+func (v *TransactionCommandASTVisitor) VisitQuery(ctx *configs.QueryContext) interface{} {
+	if ctx.GetChildCount() == 0 {
+		return nil
+	}
+
+	if stmt := ctx.TransactionStatement(); stmt != nil {
+		return v.Visit(stmt)
+	}
+
+	// Fallback if grammar is complex
+	child, ok := ctx.GetChild(0).(antlr.ParseTree)
+	if !ok {
+		return nil
+	}
+	return v.Visit(child)
+}
+
+func (v *TransactionCommandASTVisitor) VisitTransactionStatement(ctx *configs.TransactionStatementContext) interface{} {
+	command := engine.TransactionCommand{}
+
+	switch {
+	case ctx.BEGIN() != nil:
+		command.Statement = engine.TransactionBegin
+	case ctx.COMMIT() != nil:
+		command.Statement = engine.TransactionCommit
+	case ctx.ROLLBACK() != nil:
+		command.Statement = engine.TransactionRollback
+	}
+
+	return command
+}
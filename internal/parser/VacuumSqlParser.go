@@ -0,0 +1,48 @@
+package parser
+
+import (
+	"simple-database/internal/engine"
+	configs "simple-database/internal/parser/grammar/vacuum/configs"
+
+	"github.com/antlr4-go/antlr/v4"
+)
+
+type VacuumCommandASTVisitor struct {
+}
+
+func NewVacuumCommandASTVisitor() *VacuumCommandASTVisitor {
+	return &VacuumCommandASTVisitor{}
+}
+
+func (v *VacuumCommandASTVisitor) Visit(tree antlr.ParseTree) interface{}         { return tree.Accept(v) }
+func (v *VacuumCommandASTVisitor) VisitChildren(_ antlr.RuleNode) interface{}     { return nil }
+func (v *VacuumCommandASTVisitor) VisitTerminal(_ antlr.TerminalNode) interface{} { return nil }
+func (v *VacuumCommandASTVisitor) VisitErrorNode(_ antlr.ErrorNode) interface{}   { return nil }
+
+func (v *VacuumCommandASTVisitor) VisitQuery(ctx *configs.QueryContext) interface{} {
+	if ctx.GetChildCount() == 0 {
+		return nil
+	}
+
+	if stmt := ctx.VacuumStatement(); stmt != nil {
+		return v.Visit(stmt)
+	}
+
+	// Fallback if grammar is complex
+	child, ok := ctx.GetChild(0).(antlr.ParseTree)
+	if !ok {
+		return nil
+	}
+	return v.Visit(child)
+}
+
+func (v *VacuumCommandASTVisitor) VisitVacuumStatement(ctx *configs.VacuumStatementContext) interface{} {
+	command := engine.VacuumCommand{}
+	command.TableName = v.Visit(ctx.TableName()).(string)
+
+	return command
+}
+
+func (v *VacuumCommandASTVisitor) VisitTableName(ctx *configs.TableNameContext) interface{} {
+	return ctx.GetText()
+}
@@ -2,6 +2,7 @@ package datatype
 
 import (
 	"fmt"
+	platformerror "simple-database/internal/platform/error"
 	"simple-database/internal/platform/helper"
 )
 
@@ -17,26 +18,128 @@ const (
 	OperatorAnd            Operator = "And"
 	OperatorOr             Operator = "Or"
 	OperatorNot            Operator = "Not"
+	// OperatorBetween compares a value with the two bounds of a range, both included
+	OperatorBetween Operator = "Between"
+	// OperatorIn compares a value with each value of a list
+	OperatorIn Operator = "In"
+	// OperatorAdd, OperatorSubtract, OperatorMultiply and OperatorDivide compute a number from two numbers
+	OperatorAdd      Operator = "Add"
+	OperatorSubtract Operator = "Subtract"
+	OperatorMultiply Operator = "Multiply"
+	OperatorDivide   Operator = "Divide"
 )
 
 var symbolOperatorMap = map[string]Operator{
-	"=":   OperatorEqual,
-	">":   OperatorGreater,
-	"<":   OperatorLess,
-	">=":  OperatorGreaterOrEqual,
-	"<=":  OperatorLessOrEqual,
-	"!=":  OperatorNotEqual,
-	"AND": OperatorAnd,
-	"OR":  OperatorOr,
-	"NOT": OperatorNot,
+	"=":       OperatorEqual,
+	">":       OperatorGreater,
+	"<":       OperatorLess,
+	">=":      OperatorGreaterOrEqual,
+	"<=":      OperatorLessOrEqual,
+	"!=":      OperatorNotEqual,
+	"AND":     OperatorAnd,
+	"OR":      OperatorOr,
+	"NOT":     OperatorNot,
+	"BETWEEN": OperatorBetween,
+	"IN":      OperatorIn,
+	"+":       OperatorAdd,
+	"-":       OperatorSubtract,
+	"*":       OperatorMultiply,
+	"/":       OperatorDivide,
 }
 
 func FromSymbol(symbol string) Operator {
 	return symbolOperatorMap[symbol]
 }
 
+// IsArithmetic reports whether op computes a number
+func IsArithmetic(op Operator) bool {
+	return op == OperatorAdd || op == OperatorSubtract || op == OperatorMultiply || op == OperatorDivide
+}
+
+// Arithmetic computes a op b. Numbers of the same type keep it, other integers are computed as int64 and numbers
+// mixing floats and integers as float64. An operand without a value, nil, gives nil
+func Arithmetic(a, b any, op Operator) (any, error) {
+	if a == nil || b == nil {
+		return nil, nil
+	}
+	switch va := a.(type) {
+	case int32:
+		if vb, ok := b.(int32); ok {
+			return arithmetic(va, vb, op)
+		}
+	case int64:
+		if vb, ok := b.(int64); ok {
+			return arithmetic(va, vb, op)
+		}
+	case float32:
+		if vb, ok := b.(float32); ok {
+			return arithmetic(va, vb, op)
+		}
+	case float64:
+		if vb, ok := b.(float64); ok {
+			return arithmetic(va, vb, op)
+		}
+	}
+	ia, aInt := toInt64(a)
+	ib, bInt := toInt64(b)
+	if aInt && bInt {
+		return arithmetic(ia, ib, op)
+	}
+	fa, aOk := toFloat64(a)
+	fb, bOk := toFloat64(b)
+	if !aOk || !bOk {
+		return nil, platformerror.NewStackTraceError(fmt.Sprintf("Cannot compute %T %s %T", a, op, b), platformerror.InvalidDataTypeErrorCode)
+	}
+	return arithmetic(fa, fb, op)
+}
+
+func toInt64(v any) (int64, bool) {
+	switch n := v.(type) {
+	case int32:
+		return int64(n), true
+	case int64:
+		return n, true
+	}
+	return 0, false
+}
+
+func toFloat64(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float32:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	i, ok := toInt64(v)
+	return float64(i), ok
+}
+
+type number interface {
+	~int32 | ~int64 | ~float32 | ~float64
+}
+
+func arithmetic[T number](a, b T, op Operator) (any, error) {
+	switch op {
+	case OperatorAdd:
+		return a + b, nil
+	case OperatorSubtract:
+		return a - b, nil
+	case OperatorMultiply:
+		return a * b, nil
+	case OperatorDivide:
+		if b == 0 && !helper.IsFloatingPoint(a) {
+			return nil, platformerror.NewStackTraceError(fmt.Sprintf("Division of %v by zero", a), platformerror.InvalidDataTypeErrorCode)
+		}
+		return a / b, nil
+	}
+	return nil, platformerror.NewStackTraceError(fmt.Sprintf("Unknown arithmetic operator %s", op), platformerror.UnknownOperatorErrorCode)
+}
+
+// Compare reports whether a op b holds. A value compared to nil, a column without a value, never matches
 func Compare(a, b any, op Operator) bool {
 	switch va := a.(type) {
+	case nil:
+		return false
 	case int:
 		vb, ok := b.(int)
 		return ok && compareScalar(va, vb, op)
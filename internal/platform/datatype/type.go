@@ -1,5 +1,12 @@
 package datatype
 
+import (
+	"fmt"
+	platformerror "simple-database/internal/platform/error"
+	"strconv"
+	"strings"
+)
+
 //goland:noinspection GoUnusedConst
 const (
 	TypeInt64            byte = 10
@@ -10,6 +17,9 @@ const (
 	TypeBool             byte = 4
 	TypeInt32            byte = 5
 	TypeByteArray        byte = 6
+	TypeOverflowString   byte = 7
+	TypeIndexDefinition  byte = 97
+	TypeTableHeader      byte = 98
 	TypeColumnDefinition byte = 99
 	TypeRecord           byte = 100
 	TypeDeletedRecord    byte = 101
@@ -17,6 +27,11 @@ const (
 	TypePage             byte = 255
 	TypeIndex            byte = 254
 	TypeIndexItem        byte = 253
+	TypeOverflowPage     byte = 252
+	TypeWALWrite         byte = 110
+	TypeWALTruncate      byte = 111
+	TypeWALCommit        byte = 112
+	TypeWALAbort         byte = 113
 )
 
 const (
@@ -42,3 +57,79 @@ func IsScalar(v any) bool {
 		return false
 	}
 }
+
+// typeNames holds the types a value can be converted to, by the name used in a query
+var typeNames = map[byte]string{
+	TypeInt32:   "INT32",
+	TypeInt64:   "INT64",
+	TypeFloat32: "FLOAT32",
+	TypeFloat64: "FLOAT64",
+	TypeString:  "STRING",
+}
+
+// TypeName returns the name of typ in a query, false when values cannot be converted to it
+func TypeName(typ byte) (string, bool) {
+	name, ok := typeNames[typ]
+	return name, ok
+}
+
+// TypeByName returns the type called name in a query, 0 when there is none
+func TypeByName(name string) byte {
+	for typ, n := range typeNames {
+		if strings.EqualFold(n, name) {
+			return typ
+		}
+	}
+	return 0
+}
+
+// Cast converts value to the type typ, 0 keeps it. Numbers are converted to each other and to strings, strings
+// holding a number to numbers. nil stays nil
+func Cast(value any, typ byte) (any, error) {
+	if typ == 0 || value == nil {
+		return value, nil
+	}
+	if typ == TypeString {
+		return fmt.Sprint(value), nil
+	}
+	var i int64
+	var f float64
+	isInt := false
+	switch v := value.(type) {
+	case int32:
+		i, isInt = int64(v), true
+	case int64:
+		i, isInt = v, true
+	case float32:
+		f = float64(v)
+	case float64:
+		f = v
+	case string:
+		var err error
+		if i, err = strconv.ParseInt(v, 10, 64); err == nil {
+			isInt = true
+		} else if f, err = strconv.ParseFloat(v, 64); err != nil {
+			return nil, platformerror.NewStackTraceError(fmt.Sprintf("Cannot convert %q to %s", v, typeNames[typ]),
+				platformerror.InvalidDataTypeErrorCode)
+		}
+	default:
+		return nil, platformerror.NewStackTraceError(fmt.Sprintf("Cannot convert %T to %s", value, typeNames[typ]),
+			platformerror.InvalidDataTypeErrorCode)
+	}
+	if isInt {
+		f = float64(i)
+	} else {
+		i = int64(f)
+	}
+	switch typ {
+	case TypeInt32:
+		return int32(i), nil
+	case TypeInt64:
+		return i, nil
+	case TypeFloat32:
+		return float32(f), nil
+	case TypeFloat64:
+		return f, nil
+	}
+	return nil, platformerror.NewStackTraceError(fmt.Sprintf("Cannot convert to type %d", typ), platformerror.UnknownDatatypeErrorCode)
+}
@@ -1,6 +1,7 @@
 package error
 
 import (
+	"errors"
 	"fmt"
 	"runtime"
 )
@@ -35,6 +36,16 @@ const (
 	DeleteFileErrorCode
 	ParsingGrammarErrorCode
 	UnknownCommandErrorCode
+	WALWriteErrorCode
+	WALReadErrorCode
+	RecoveryErrorCode
+	TransactionErrorCode
+	TableNotExistsErrorCode
+	InvalidHeaderErrorCode
+	RecordTooLargeErrorCode
+	ChecksumMismatchErrorCode
+	IndexAlreadyExistsErrorCode
+	IndexNotExistsErrorCode
 )
 
 // StackTraceError wraps any error and captures a stack trace
@@ -53,3 +64,12 @@ func NewStackTraceError(msg string, errorCode Code) *StackTraceError {
 func (e *StackTraceError) Error() string {
 	return fmt.Sprintf("%s\nStack trace:\n%s", e.Msg, e.Stack)
 }
+
+// Message returns the message of err without the stack trace of a StackTraceError
+func Message(err error) string {
+	var stackTraceError *StackTraceError
+	if errors.As(err, &stackTraceError) {
+		return stackTraceError.Msg
+	}
+	return err.Error()
+}
@@ -98,7 +98,24 @@ func (e *SimpleEvaluator) Eval(expr Expression, row map[string]any) bool {
 	case datatype.OperatorOr:
 		return left.(bool) || right.(bool)
 	case datatype.OperatorNotEqual:
-		return left.(bool) != right.(bool)
+		// Values of different types never match, as in Compare
+		if l, ok := left.(bool); ok {
+			r, ok := right.(bool)
+			return ok && l != r
+		}
+		return datatype.Compare(left, right, expr.Op)
+	case datatype.OperatorBetween:
+		// The right side holds the lower and the upper bound
+		bounds := right.([]any)
+		return datatype.Compare(left, e.evalValue(bounds[0], row), datatype.OperatorGreaterOrEqual) &&
+			datatype.Compare(left, e.evalValue(bounds[1], row), datatype.OperatorLessOrEqual)
+	case datatype.OperatorIn:
+		for _, v := range right.([]any) {
+			if datatype.Compare(left, e.evalValue(v, row), datatype.OperatorEqual) {
+				return true
+			}
+		}
+		return false
 	case datatype.OperatorNot:
 		return !left.(bool)
 	default:
@@ -0,0 +1,136 @@
+package evaluator
+
+import (
+	"fmt"
+	"simple-database/internal/platform/datatype"
+	platformerror "simple-database/internal/platform/error"
+	"strings"
+	"unicode/utf8"
+)
+
+// Function is a call of a scalar function, UPPER, LOWER, LENGTH or ABS, or of a conversion to a type named as in a
+// query, such as FLOAT64, on the values of its arguments
+type Function struct {
+	Name string
+	Args []any
+}
+
+// functionArgs holds the number of arguments of every function but the conversions, which take one
+var functionArgs = map[string]int{
+	"UPPER":  1,
+	"LOWER":  1,
+	"LENGTH": 1,
+	"ABS":    1,
+}
+
+// Check returns an error when the function is unknown or called with the wrong number of arguments
+func (f Function) Check() error {
+	args, ok := functionArgs[f.Name]
+	if !ok && datatype.TypeByName(f.Name) != 0 {
+		args, ok = 1, true
+	}
+	if !ok {
+		return platformerror.NewStackTraceError(fmt.Sprintf("Unknown function %s", f.Name), platformerror.UnknownOperatorErrorCode)
+	}
+	if len(f.Args) != args {
+		return platformerror.NewStackTraceError(fmt.Sprintf("%s takes %d arguments, got %d", f.Name, args, len(f.Args)),
+			platformerror.UnknownOperatorErrorCode)
+	}
+	return nil
+}
+
+// call computes the function on the values of its arguments, nil when its argument has no value
+func (f Function) call(args []any) (any, error) {
+	if err := f.Check(); err != nil {
+		return nil, err
+	}
+	arg := args[0]
+	if arg == nil {
+		return nil, nil
+	}
+	switch f.Name {
+	case "UPPER", "LOWER", "LENGTH":
+		s, ok := arg.(string)
+		if !ok {
+			return nil, platformerror.NewStackTraceError(fmt.Sprintf("%s takes a string, got %T", f.Name, arg), platformerror.InvalidDataTypeErrorCode)
+		}
+		switch f.Name {
+		case "UPPER":
+			return strings.ToUpper(s), nil
+		case "LOWER":
+			return strings.ToLower(s), nil
+		}
+		return int64(utf8.RuneCountInString(s)), nil
+	case "ABS":
+		switch n := arg.(type) {
+		case int32:
+			return max(n, -n), nil
+		case int64:
+			return max(n, -n), nil
+		case float32:
+			return max(n, -n), nil
+		case float64:
+			return max(n, -n), nil
+		}
+		return nil, platformerror.NewStackTraceError(fmt.Sprintf("ABS takes a number, got %T", arg), platformerror.InvalidDataTypeErrorCode)
+	}
+	return datatype.Cast(arg, datatype.TypeByName(f.Name))
+}
+
+// Columns returns the names the value v reads from a row: the strings found in it, columns or not
+func Columns(v any) []string {
+	switch x := v.(type) {
+	case string:
+		return []string{x}
+	case Expression:
+		return append(Columns(x.Left), Columns(x.Right)...)
+	case *Expression:
+		if x == nil {
+			return nil
+		}
+		return append(Columns(x.Left), Columns(x.Right)...)
+	case Function:
+		return Columns(x.Args)
+	case []any:
+		// The values of IN and the bounds of BETWEEN
+		columns := make([]string, 0)
+		for _, value := range x {
+			columns = append(columns, Columns(value)...)
+		}
+		return columns
+	}
+	return nil
+}
+
+// Value computes v for row: an arithmetic expression or a function is computed from the values of its operands, a
+// comparison is evaluated, a string naming a column of row is its value and anything else is returned as it is
+func (e *SimpleEvaluator) Value(v any, row map[string]any) (any, error) {
+	switch x := v.(type) {
+	case *Expression:
+		return e.Value(*x, row)
+	case Expression:
+		if !datatype.IsArithmetic(x.Op) {
+			return e.Eval(x, row), nil
+		}
+		left, err := e.Value(x.Left, row)
+		if err != nil {
+			return nil, err
+		}
+		right, err := e.Value(x.Right, row)
+		if err != nil {
+			return nil, err
+		}
+		return datatype.Arithmetic(left, right, x.Op)
+	case Function:
+		args := make([]any, 0, len(x.Args))
+		for _, arg := range x.Args {
+			val, err := e.Value(arg, row)
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, val)
+		}
+		return x.call(args)
+	}
+	return e.evalValue(v, row), nil
+}
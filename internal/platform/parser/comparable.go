@@ -0,0 +1,71 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	platformerror "simple-database/internal/platform/error"
+)
+
+const (
+	signBit32 = uint32(1) << 31
+	signBit64 = uint64(1) << 63
+)
+
+// MarshalComparableBinary encodes the value so that comparing encodings with bytes.Compare orders them as the
+// values. Integers are written big-endian with the sign bit flipped. Floats are written big-endian with the sign
+// bit flipped when positive and every bit flipped when negative. Strings have each 0x00 escaped as 0x00 0xff and
+// end with 0x00 0x01, so that no encoded string is the prefix of another
+func (m *ValueMarshaler[T]) MarshalComparableBinary() ([]byte, error) {
+	switch v := any(m.Value).(type) {
+	case int32:
+		return binary.BigEndian.AppendUint32(nil, uint32(v)^signBit32), nil
+	case int64:
+		return binary.BigEndian.AppendUint64(nil, uint64(v)^signBit64), nil
+	case float32:
+		if v == 0 {
+			// -0 and 0 are equal
+			v = 0
+		}
+		bits := math.Float32bits(v)
+		if bits&signBit32 != 0 {
+			bits = ^bits
+		} else {
+			bits |= signBit32
+		}
+		return binary.BigEndian.AppendUint32(nil, bits), nil
+	case float64:
+		if v == 0 {
+			v = 0
+		}
+		bits := math.Float64bits(v)
+		if bits&signBit64 != 0 {
+			bits = ^bits
+		} else {
+			bits |= signBit64
+		}
+		return binary.BigEndian.AppendUint64(nil, bits), nil
+	case string:
+		buf := bytes.Buffer{}
+		buf.Grow(len(v) + 2)
+		for i := 0; i < len(v); i++ {
+			if v[i] == 0x00 {
+				buf.Write([]byte{0x00, 0xff})
+			} else {
+				buf.WriteByte(v[i])
+			}
+		}
+		buf.Write([]byte{0x00, 0x01})
+		return buf.Bytes(), nil
+	case byte:
+		return []byte{v}, nil
+	case bool:
+		if v {
+			return []byte{1}, nil
+		}
+		return []byte{0}, nil
+	default:
+		return nil, platformerror.NewStackTraceError(fmt.Sprintf("Unknown data type %T", v), platformerror.UnknownDatatypeErrorCode)
+	}
+}
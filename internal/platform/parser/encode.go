@@ -182,8 +182,12 @@ func (u *TLVUnmarshaler[T]) UnmarshalBinary(data []byte) error {
 	}
 	u.length = intUnmarshaler.Value
 	u.BytesRead += datatype.LenInt32
-	// value
-	if err := u.unmarshaler.UnmarshalBinary(data[u.BytesRead:]); err != nil {
+	// value, a string takes every byte it is given so it only gets its own
+	if uint32(len(data)) < u.BytesRead+u.length {
+		return platformerror.NewStackTraceError(fmt.Sprintf("Expected %d value bytes, got %d", u.length, uint32(len(data))-u.BytesRead),
+			platformerror.IncompleteReadErrorCode)
+	}
+	if err := u.unmarshaler.UnmarshalBinary(data[u.BytesRead : u.BytesRead+u.length]); err != nil {
 		return err
 	}
 	u.Value = u.unmarshaler.Value
@@ -3,6 +3,7 @@ package server
 import (
 	"context"
 	"encoding/json"
+	"io"
 	"net/http"
 	"simple-database/internal/commandhandler"
 	"simple-database/internal/platform/helper"
@@ -10,6 +11,10 @@ import (
 	"time"
 )
 
+// SelectWriteTimeout is how long the response of a select may take to be written, its select blocks the statements
+// changing the tables until then
+const SelectWriteTimeout = 30 * time.Second
+
 var (
 	mux  *http.ServeMux
 	once sync.Once
@@ -17,6 +22,8 @@ var (
 
 type QueryRequest struct {
 	Sql string `json:"query"`
+	// TransactionId runs the query inside a transaction started by a previous BEGIN request
+	TransactionId string `json:"transactionId,omitempty"`
 }
 
 func QueryHandler(w http.ResponseWriter, r *http.Request) {
@@ -58,12 +65,21 @@ func handleQuery(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	result, err := handler.Execute(input.Sql)
+	var result interface{}
+	if input.TransactionId != "" {
+		result, err = handler.ExecuteInTransaction(input.Sql, input.TransactionId)
+	} else {
+		result, err = handler.Execute(input.Sql)
+	}
 	if err != nil {
 		helper.Log.Errorf("Error executing sql command handler: %s", err.Error())
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	if rows, ok := result.(*commandhandler.Rows); ok {
+		writeRows(w, rows)
+		return
+	}
 
 	w.WriteHeader(http.StatusCreated)
 	err = json.NewEncoder(w).Encode(result)
@@ -73,6 +89,69 @@ func handleQuery(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// selectSummary is a table.SelectResult without its rows, written after the rows of a select. Error is set when
+// reading the rows failed after the first ones were written
+type selectSummary struct {
+	AccessType     string
+	RowsInspected  int
+	SortType       string
+	GroupType      string
+	JoinStrategies []string
+	Extra          string
+	Error          string `json:",omitempty"`
+}
+
+// writeRows writes the rows of a select as they are pulled, in the shape of a table.SelectResult, so they are never
+// all held in memory. How the rows were found is known once they were read, so it comes after them. The rows hold a
+// lock of the handler until they are closed, so a client reading slowly gets SelectWriteTimeout to read them all
+// before the response is cut
+func writeRows(w http.ResponseWriter, rows *commandhandler.Rows) {
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Now().Add(SelectWriteTimeout)); err != nil {
+		helper.Log.Warnf("Rows written without a deadline: %s", err.Error())
+	}
+	w.WriteHeader(http.StatusCreated)
+	encoder := json.NewEncoder(w)
+	_, err := io.WriteString(w, `{"Rows":[`)
+	var nextErr error
+	for n := 0; err == nil; n++ {
+		row, ok, e := rows.Next()
+		if e != nil || !ok {
+			nextErr = e
+			break
+		}
+		if n > 0 {
+			if _, err = io.WriteString(w, ","); err != nil {
+				break
+			}
+		}
+		err = encoder.Encode(row)
+	}
+	result, closeErr := rows.Close()
+	if err != nil {
+		helper.Log.Errorf("Error writing rows: %s", err.Error())
+		return
+	}
+	if nextErr == nil {
+		nextErr = closeErr
+	}
+
+	summary := selectSummary{AccessType: result.AccessType, RowsInspected: result.RowsInspected, SortType: result.SortType,
+		GroupType: result.GroupType, JoinStrategies: result.JoinStrategies, Extra: result.Extra}
+	if nextErr != nil {
+		helper.Log.Errorf("Error reading rows: %s", nextErr.Error())
+		summary.Error = nextErr.Error()
+	}
+	b, err := json.Marshal(summary)
+	if err != nil {
+		helper.Log.Errorf("Error writing rows: %s", err.Error())
+		return
+	}
+	// The fields of the summary follow the rows inside the same object
+	if _, err = io.WriteString(w, "],"+string(b[1:])+"\n"); err != nil {
+		helper.Log.Errorf("Error writing rows: %s", err.Error())
+	}
+}
+
 func GetServer() *http.ServeMux {
 	once.Do(func() {
 		mux = http.NewServeMux()
@@ -0,0 +1,104 @@
+package test
+
+import (
+	"os"
+	"simple-database/internal/engine"
+	"simple-database/internal/engine/table"
+	"simple-database/internal/engine/table/column"
+	"simple-database/internal/platform/datatype"
+	"simple-database/internal/platform/evaluator"
+	"slices"
+	"testing"
+)
+
+func TestBetweenInAndNotEqual(t *testing.T) {
+	_ = os.RemoveAll("data/between_in_test")
+	db, err := engine.NewDatabase("between_in_test")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	id, _ := column.NewColumn("id", datatype.TypeInt64, column.PrimaryKey)
+	age, _ := column.NewColumn("age", datatype.TypeInt32, column.UsingIndex)
+	score, _ := column.NewColumn("score", datatype.TypeInt32, column.Normal)
+	people, err := db.CreateTable(engine.CreateTableCommand{
+		TableName: "people",
+		Columns:   table.Columns{"id": id, "age": age, "score": score},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := int64(0); i < 500; i++ {
+		record := map[string]any{"id": i, "age": int32(i % 50), "score": int32(0)}
+		if _, err = people.Insert(table.InsertCommand{TableName: "people", Record: record}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	between := &evaluator.Expression{Left: "age", Op: datatype.OperatorBetween, Right: []any{int32(10), int32(19)}}
+	in := &evaluator.Expression{Left: "age", Op: datatype.OperatorIn, Right: []any{int32(7), int32(3), int32(7), int32(60)}}
+	notEqual := &evaluator.Expression{Left: "age", Op: datatype.OperatorNotEqual, Right: int32(7)}
+
+	tests := []struct {
+		name       string
+		expression *evaluator.Expression
+		rows       int
+		// ages are the ages of the rows in the order they are returned, by runs of equal ages
+		ages []int32
+	}{
+		{"between", between, 100, []int32{10, 11, 12, 13, 14, 15, 16, 17, 18, 19}},
+		{"in", in, 20, []int32{3, 7}},
+		{"not equal", notEqual, 490, nil},
+		{"between with a tighter bound", &evaluator.Expression{
+			Left:  *between,
+			Op:    datatype.OperatorAnd,
+			Right: evaluator.Expression{Left: "age", Op: datatype.OperatorLess, Right: int32(12)},
+		}, 20, []int32{10, 11}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := people.Select(table.SelectCommand{TableName: "people", Expression: test.expression, Limit: table.UnlimitedSize})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.AccessType != table.AccessTypeIndex || len(result.Rows) != test.rows || result.RowsInspected != test.rows {
+				t.Fatalf("Expected %d rows inspected through the index, got %d of %d inspected with %s", test.rows,
+					len(result.Rows), result.RowsInspected, result.AccessType)
+			}
+			e := evaluator.SimpleEvaluator{}
+			ages := make([]int32, 0)
+			for _, row := range result.Rows {
+				if !e.Eval(*test.expression, row.Record) {
+					t.Errorf("Row %v does not match", row.Record)
+				}
+				if age := row.Record["age"].(int32); len(ages) == 0 || ages[len(ages)-1] != age {
+					ages = append(ages, age)
+				}
+			}
+			if test.ages != nil && !slices.Equal(ages, test.ages) {
+				t.Errorf("Expected the ages %v in order, got %v", test.ages, ages)
+			}
+		})
+	}
+
+	// Updates and deletes find their records the same way
+	n, err := people.Update(table.UpdateCommand{TableName: "people", Expression: between, Record: map[string]any{"score": int32(1)}})
+	if err != nil || n != 100 {
+		t.Fatalf("Expected 100 updated rows, got %d: %v", n, err)
+	}
+	deleted, err := people.Delete(table.DeleteCommand{TableName: "people", Expression: in})
+	if err != nil || len(deleted.DeletedRecords) != 20 {
+		t.Fatalf("Expected 20 deleted rows, got %v: %v", deleted, err)
+	}
+	result, err := people.Select(table.SelectCommand{
+		TableName:  "people",
+		Expression: &evaluator.Expression{Left: "score", Op: datatype.OperatorEqual, Right: int32(1)},
+		Limit:      table.UnlimitedSize,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Rows) != 100 || people.RowCount() != 480 {
+		t.Errorf("Expected 100 updated rows of 480, got %d of %d", len(result.Rows), people.RowCount())
+	}
+}
@@ -3,6 +3,7 @@ package test
 import (
 	"encoding/binary"
 	"fmt"
+	"math/rand"
 	"os"
 	"simple-database/internal/engine/table/btree"
 	"testing"
@@ -87,3 +88,74 @@ func TestBree(t *testing.T) {
 	}
 
 }
+
+func TestBTreeCursor(t *testing.T) {
+	_ = os.RemoveAll("data/cursor_test")
+	_ = os.MkdirAll("data/cursor_test", 0777)
+	b, err := btree.Open("data/cursor_test/tree")
+	if err != nil {
+		t.Fatalf("Failed to open btree: %v", err)
+	}
+	defer b.Close()
+
+	key := func(i int) []byte {
+		buf := make([]byte, 8)
+		binary.BigEndian.PutUint64(buf, uint64(i))
+		return buf
+	}
+	value := func(k btree.Key) int {
+		return int(binary.BigEndian.Uint64(k.K))
+	}
+
+	// Insert 2000 keys out of order, then remove the even ones so that leaves are merged and borrowed from
+	for _, i := range rand.New(rand.NewSource(1)).Perm(2000) {
+		if err = b.Insert(key(i), key(i)); err != nil {
+			t.Fatalf("Insert failed at %d: %v", i, err)
+		}
+	}
+	for _, i := range rand.New(rand.NewSource(2)).Perm(2000) {
+		if i%2 == 0 {
+			if err = b.Remove(key(i)); err != nil {
+				t.Fatalf("Remove failed at %d: %v", i, err)
+			}
+		}
+	}
+	if problems := b.Check(); len(problems) != 0 {
+		t.Fatalf("Expected a valid tree, got %v", problems)
+	}
+
+	// 1. Seek stops at the first key greater than or equal to the one sought, Next walks every following key
+	c := b.NewCursor()
+	ok, err := c.Seek(key(100))
+	if err != nil || !ok || value(c.Key()) != 101 {
+		t.Fatalf("Expected Seek(100) to find 101, got %v %v", ok, err)
+	}
+	count := 0
+	for ; ok && err == nil; ok, err = c.Next() {
+		if value(c.Key()) != 101+2*count {
+			t.Fatalf("Expected %d, got %d", 101+2*count, value(c.Key()))
+		}
+		count++
+	}
+	if err != nil || count != 950 {
+		t.Errorf("Expected 950 keys from 101 on, got %d %v", count, err)
+	}
+
+	// 2. Prev walks back from the end, past the first key the cursor is not valid
+	if ok, err = c.Prev(); err != nil || !ok || value(c.Key()) != 1999 {
+		t.Fatalf("Expected Prev at the end to find 1999, got %v %v", ok, err)
+	}
+	if ok, err = c.Seek(key(5000)); err != nil || ok {
+		t.Fatalf("Expected no key from 5000 on, got %v %v", ok, err)
+	}
+	count = 0
+	for ok, err = c.Last(); ok && err == nil; ok, err = c.Prev() {
+		count++
+	}
+	if err != nil || count != 1000 || c.Valid() {
+		t.Errorf("Expected 1000 keys walking back, got %d %v", count, err)
+	}
+	if ok, err = c.First(); err != nil || !ok || value(c.Key()) != 1 {
+		t.Errorf("Expected the first key to be 1, got %v %v", ok, err)
+	}
+}
@@ -0,0 +1,163 @@
+package test
+
+import (
+	"bytes"
+	"os"
+	"simple-database/internal/engine"
+	"simple-database/internal/engine/buffer"
+	"simple-database/internal/engine/table"
+	"simple-database/internal/engine/table/column"
+	"simple-database/internal/engine/table/page"
+	"simple-database/internal/platform/datatype"
+	"simple-database/internal/platform/evaluator"
+	"strings"
+	"testing"
+)
+
+// memoryFile keeps pages in memory and counts the writes reaching it
+type memoryFile struct {
+	pages  map[int64][]byte
+	writes int
+}
+
+func (f *memoryFile) ReadPage(num int64, data []byte) error {
+	copy(data, f.pages[num])
+	return nil
+}
+
+func (f *memoryFile) WritePage(num int64, data []byte) error {
+	f.pages[num] = bytes.Clone(data)
+	f.writes++
+	return nil
+}
+
+func TestBufferPool(t *testing.T) {
+	f := &memoryFile{pages: map[int64][]byte{0: {'a'}, 1: {'b'}, 2: {'c'}}}
+	pool := buffer.NewPool(2*16, 16)
+
+	// 1. A pinned page stays while others are evicted, a dirty one is written back when it is evicted
+	pinned, err := pool.Fetch(f, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = pool.Write(f, 1, []byte{'B'}); err != nil {
+		t.Fatal(err)
+	}
+	if f.writes != 0 {
+		t.Errorf("Expected the write to stay in the pool, got %d writes", f.writes)
+	}
+	frame, err := pool.Fetch(f, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool.Unpin(frame, false)
+	if f.writes != 1 || f.pages[1][0] != 'B' {
+		t.Errorf("Expected page 1 to be written back when evicted, got %d writes", f.writes)
+	}
+	if pinned.Data()[0] != 'a' {
+		t.Errorf("Expected the pinned page to keep its data")
+	}
+	pool.Unpin(pinned, false)
+
+	frame, err = pool.Fetch(f, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool.Unpin(frame, false)
+	stats := pool.Stats()
+	if stats.Hits != 1 || stats.Misses != 2 || stats.Evictions != 1 || stats.Pages != 2 {
+		t.Errorf("Expected 1 hit, 2 misses, 1 eviction and 2 pages, got %+v", stats)
+	}
+
+	// 2. Discarded pages lose their changes, flushed ones reach the file
+	if err = pool.Write(f, 0, []byte{'X'}); err != nil {
+		t.Fatal(err)
+	}
+	pool.Discard()
+	if err = pool.Write(f, 2, []byte{'C'}); err != nil {
+		t.Fatal(err)
+	}
+	if err = pool.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if f.pages[0][0] != 'a' || f.pages[2][0] != 'C' {
+		t.Errorf("Expected page 0 to be discarded and page 2 to be flushed, got %c and %c", f.pages[0][0], f.pages[2][0])
+	}
+	if stats = pool.Stats(); stats.Dirty != 0 {
+		t.Errorf("Expected no dirty pages, got %d", stats.Dirty)
+	}
+}
+
+func TestBufferPoolDatabase(t *testing.T) {
+	_ = os.RemoveAll("data/buffer_pool_test")
+
+	// A pool of a few pages forces pages of the table and its indexes to be evicted and written back
+	db, err := engine.NewDatabaseWithBufferPool("buffer_pool_test", 8*page.Size)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	id, _ := column.NewColumn("id", datatype.TypeInt64, column.PrimaryKey)
+	name, _ := column.NewColumn("name", datatype.TypeString, column.UsingIndex)
+	users, err := db.CreateTable(engine.CreateTableCommand{TableName: "users", Columns: table.Columns{"id": id, "name": name}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := int64(0); i < 500; i++ {
+		record := map[string]any{"id": i, "name": strings.Repeat(string(rune('a'+i%26)), 100) + string(rune('a'+i/26))}
+		if _, err = users.Insert(table.InsertCommand{TableName: "users", Record: record}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 1. A failed insert leaves nothing behind
+	record := map[string]any{"id": int64(3), "name": "duplicate"}
+	if _, err = users.Insert(table.InsertCommand{TableName: "users", Record: record}); err == nil {
+		t.Fatal("Expected a duplicate primary key to fail")
+	}
+	if stats := db.BufferPoolStats(); stats.Dirty != 0 || stats.Evictions == 0 || stats.WriteBacks == 0 {
+		t.Errorf("Expected evictions, write-backs and no dirty pages, got %+v", stats)
+	}
+	result, err := users.Check()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.ProblemCount() != 0 || result.Rows != 500 {
+		t.Errorf("Expected 500 rows without problems, got %d rows and %v %v", result.Rows, result.Problems, result.IndexProblems)
+	}
+
+	// 2. Selects report their hits and misses
+	find := func() *table.SelectResult {
+		selectResult, err := users.Select(table.SelectCommand{
+			TableName:  "users",
+			Expression: &evaluator.Expression{Left: "id", Op: datatype.OperatorEqual, Right: int64(250)},
+			Limit:      table.UnlimitedSize,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(selectResult.Rows) != 1 {
+			t.Fatalf("Expected 1 row, got %d", len(selectResult.Rows))
+		}
+		return selectResult
+	}
+	find()
+	if extra := find().Extra; !strings.HasPrefix(extra, "Buffer pool: ") || !strings.HasSuffix(extra, " 0 misses") {
+		t.Errorf("Expected the second select to only hit the pool, got %s", extra)
+	}
+
+	// 3. Written pages are on disk once the database is reopened
+	if err = db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	db, err = engine.NewDatabase("buffer_pool_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if result, err = db.Tables["users"].Check(); err != nil {
+		t.Fatal(err)
+	}
+	if result.ProblemCount() != 0 || result.Rows != 500 {
+		t.Errorf("Expected 500 rows without problems after reopening, got %d rows and %v %v", result.Rows, result.Problems, result.IndexProblems)
+	}
+}
@@ -0,0 +1,216 @@
+package test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"simple-database/internal/engine/table/btree"
+	"simple-database/internal/engine/table/index"
+	platformerror "simple-database/internal/platform/error"
+	"strings"
+	"testing"
+)
+
+func TestBTreeBuilder(t *testing.T) {
+	_ = os.RemoveAll("data/bulk_load_test")
+	_ = os.MkdirAll("data/bulk_load_test", 0777)
+	key := func(i int) []byte {
+		buf := make([]byte, 8)
+		binary.BigEndian.PutUint64(buf, uint64(i))
+		return buf
+	}
+
+	// Sizes around a leaf and around a level, so that the last nodes are merged, split or left as they are
+	for _, size := range []int{0, 1, 19, 35, 36, 40, 70, 71, 1000, 1260, 10000} {
+		t.Run(fmt.Sprintf("%d keys", size), func(t *testing.T) {
+			name := fmt.Sprintf("data/bulk_load_test/tree_%d", size)
+			builder, err := btree.NewBuilder(name, btree.DefaultFillFactor)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < size; i++ {
+				if err = builder.Add(key(i), key(i)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err = builder.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			b, err := btree.Open(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer b.Close()
+			if problems := b.Check(); len(problems) != 0 {
+				t.Fatalf("Expected a valid tree, got %v", problems)
+			}
+			keys, err := b.Keys()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(keys) != size {
+				t.Fatalf("Expected %d keys, got %d", size, len(keys))
+			}
+			for i, k := range keys {
+				if !bytes.Equal(k.K, key(i)) || !bytes.Equal(k.V, key(i)) {
+					t.Fatalf("Expected key %d at position %d, got %v", i, i, k.K)
+				}
+			}
+
+			// The tree is changed as any other
+			for i := 0; i < size; i += 3 {
+				if err = b.Remove(key(i)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for i := size; i < size+100; i++ {
+				if err = b.Insert(key(i), key(i)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if problems := b.Check(); len(problems) != 0 {
+				t.Fatalf("Expected a valid tree after changes, got %v", problems)
+			}
+			if n, err := b.Size(); err != nil || n != int64(size-(size+2)/3+100) {
+				t.Errorf("Expected %d keys after changes, got %d: %v", size-(size+2)/3+100, n, err)
+			}
+		})
+	}
+
+	// Filled leaves take fewer pages than a tree grown by inserts
+	inserted, err := btree.Open("data/bulk_load_test/inserted")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer inserted.Close()
+	for i := 0; i < 10000; i++ {
+		if err = inserted.Insert(key(i), key(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	built, err := btree.Open("data/bulk_load_test/tree_10000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer built.Close()
+	if built.Pager.Count() >= inserted.Pager.Count() {
+		t.Errorf("Expected fewer pages than the %d of inserts, got %d", inserted.Pager.Count(), built.Pager.Count())
+	}
+
+	// Values too large for a node are moved to overflow pages
+	builder, err := btree.NewBuilder("data/bulk_load_test/overflow", btree.DefaultFillFactor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 200; i++ {
+		if err = builder.Add(key(i), bytes.Repeat([]byte{byte(i)}, 1000+i*50)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = builder.Close(); err != nil {
+		t.Fatal(err)
+	}
+	overflow, err := btree.Open("data/bulk_load_test/overflow")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer overflow.Close()
+	if problems := overflow.Check(); len(problems) != 0 {
+		t.Fatalf("Expected a valid tree, got %v", problems)
+	}
+	for i := 0; i < 200; i++ {
+		k, found, err := overflow.Get(key(i))
+		if err != nil || !found || !bytes.Equal(k.V, bytes.Repeat([]byte{byte(i)}, 1000+i*50)) {
+			t.Fatalf("Expected the value of key %d, got %d bytes: %v", i, len(k.V), err)
+		}
+	}
+
+	// Keys out of order and files that are not empty are rejected
+	builder, err = btree.NewBuilder("data/bulk_load_test/unordered", btree.DefaultFillFactor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = builder.Add(key(2), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err = builder.Add(key(2), nil); err == nil {
+		t.Error("Expected an error for a key added twice")
+	}
+	if err = builder.Add(key(1), nil); err == nil {
+		t.Error("Expected an error for a key added after a greater one")
+	}
+	_ = builder.Close()
+	if _, err = btree.NewBuilder("data/bulk_load_test/tree_1000", btree.DefaultFillFactor); err == nil {
+		t.Error("Expected an error for a tree built over another")
+	}
+}
+
+func TestIndexBuilder(t *testing.T) {
+	_ = os.RemoveAll("data/index_builder_test")
+	_ = os.MkdirAll("data/index_builder_test", 0777)
+	runs := func() []string {
+		files, _ := filepath.Glob("data/index_builder_test/*.tmp")
+		return files
+	}
+
+	// Items added in reverse order take several runs of 4 KiB, which are merged in key order and removed
+	builder := index.NewBuilder("data/index_builder_test/built_idx.bin", true, 4096)
+	for i := int64(999); i >= 0; i-- {
+		if err := builder.Add(index.NewItem(fmt.Sprintf("name_%03d", i), i, i, 0)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(runs()) < 2 {
+		t.Fatalf("Expected the items to be written to several runs, got %v", runs())
+	}
+	if err := builder.Build(); err != nil {
+		t.Fatal(err)
+	}
+	if files := runs(); len(files) != 0 {
+		t.Errorf("Expected the runs to be removed, got %v", files)
+	}
+	idx := index.NewIndex("data/index_builder_test/built_idx.bin", true, nil, nil)
+	defer idx.Close()
+	if problems := idx.Check(); len(problems) != 0 {
+		t.Fatalf("Expected a valid index, got %v", problems)
+	}
+	read := make([]int64, 0)
+	err := idx.ScanRange(index.Range{}, func(item index.Item) (bool, error) {
+		read = append(read, item.Page)
+		return true, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(read) != 1000 {
+		t.Fatalf("Expected 1000 items, got %d", len(read))
+	}
+	for i, page := range read {
+		if page != int64(i) {
+			t.Fatalf("Expected the item of page %d at position %d, got %d", i, i, page)
+		}
+	}
+
+	// Values found twice in different runs still break a unique index
+	builder = index.NewBuilder("data/index_builder_test/duplicate_idx.bin", true, 4096)
+	for i := int64(0); i < 1000; i++ {
+		if err = builder.Add(index.NewItem(fmt.Sprintf("name_%03d", i%600), i, i, 0)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var stackTraceError *platformerror.StackTraceError
+	err = builder.Build()
+	if !errors.As(err, &stackTraceError) || stackTraceError.ErrorCode != platformerror.UniqueKeyViolationErrorCode {
+		t.Fatalf("Expected a unique key violation, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "name_000") {
+		t.Errorf("Expected the duplicated value in the error, got %s", err.Error())
+	}
+	if files := runs(); len(files) != 0 {
+		t.Errorf("Expected the runs to be removed, got %v", files)
+	}
+}
@@ -0,0 +1,150 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"simple-database/internal/engine"
+	"simple-database/internal/engine/table"
+	"simple-database/internal/engine/table/btree"
+	"simple-database/internal/engine/table/column"
+	"simple-database/internal/engine/table/index"
+	"simple-database/internal/engine/table/page"
+	"simple-database/internal/platform/datatype"
+	"simple-database/internal/platform/evaluator"
+	"strings"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	_ = os.RemoveAll("data/check_test")
+
+	db, err := engine.NewDatabase("check_test")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	id, _ := column.NewColumn("id", datatype.TypeInt64, column.PrimaryKey)
+	name, _ := column.NewColumn("name", datatype.TypeString, column.UsingIndex)
+	_, err = db.CreateTable(engine.CreateTableCommand{TableName: "users", Columns: table.Columns{"id": id, "name": name}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	users := db.Tables["users"]
+	nameOf := func(i int64) string {
+		return strings.Repeat(string(rune('a'+i%26)), 50+int(i))
+	}
+	for i := int64(0); i < 300; i++ {
+		record := map[string]any{"id": i, "name": nameOf(i)}
+		if _, err = users.Insert(table.InsertCommand{TableName: "users", Record: record}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err = users.Delete(table.DeleteCommand{
+		TableName:  "users",
+		Expression: &evaluator.Expression{Left: "id", Op: datatype.OperatorLess, Right: int64(120)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = users.Update(table.UpdateCommand{
+		TableName:  "users",
+		Expression: &evaluator.Expression{Left: "id", Op: datatype.OperatorGreaterOrEqual, Right: int64(280)},
+		Record:     map[string]any{"name": strings.Repeat("u", 900)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 1. A table changed through the engine has no problems
+	result, err := users.Check()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.ProblemCount() != 0 {
+		t.Fatalf("Expected no problems, got %v %v", result.Problems, result.IndexProblems)
+	}
+	if result.Rows != 180 || result.IndexEntries["name"] != 180 {
+		t.Errorf("Expected 180 rows and index entries, got %d and %d", result.Rows, result.IndexEntries["name"])
+	}
+	if err = db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 2. A missing entry and an entry pointing at a deleted record are found, then fixed by rebuilding the index
+	indexPath := filepath.Join("data", "check_test", "_users_name_idx.bin")
+	tree, err := btree.Open(indexPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, _ := index.NewItemKey(nameOf(200), int64(200)).MarshalBinary()
+	if err = tree.Remove(key); err != nil {
+		t.Fatal(err)
+	}
+	key, _ = index.NewItemKey(nameOf(5), int64(5)).MarshalBinary()
+	item, _ := index.NewItem(nameOf(5), int64(5), 1000, 3).MarshalBinary()
+	if err = tree.Insert(key, item); err != nil {
+		t.Fatal(err)
+	}
+	if err = tree.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err = engine.NewDatabase("check_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	users = db.Tables["users"]
+	if result, err = users.Check(); err != nil {
+		t.Fatal(err)
+	}
+	problems := result.IndexProblems["name"]
+	if len(problems) != 2 || !strings.Contains(problems[0], "page 1000 slot 3") || !strings.Contains(problems[1], "has no entry") {
+		t.Errorf("Expected a dangling and a missing entry, got %v", problems)
+	}
+	if err = users.RebuildIndexes(); err != nil {
+		t.Fatal(err)
+	}
+	if result, err = users.Check(); err != nil {
+		t.Fatal(err)
+	}
+	if result.ProblemCount() != 0 || result.IndexEntries["name"] != 180 {
+		t.Errorf("Expected a clean index of 180 entries, got %d entries and %v", result.IndexEntries["name"], result.IndexProblems)
+	}
+	selectResult, err := users.Select(table.SelectCommand{
+		TableName:  "users",
+		Expression: &evaluator.Expression{Left: "name", Op: datatype.OperatorEqual, Right: nameOf(200)},
+		Limit:      table.UnlimitedSize,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(selectResult.Rows) != 1 {
+		t.Errorf("Expected row 200 to be found through the rebuilt index, got %d rows", len(selectResult.Rows))
+	}
+	if err = db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 3. A damaged page is reported
+	heapPath := filepath.Join("data", "check_test", "users"+table.FileExtension)
+	f, err := os.OpenFile(heapPath, os.O_RDWR, 0777)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = f.WriteAt([]byte{0xff, 0xff}, page.Size+100); err != nil {
+		t.Fatal(err)
+	}
+	if err = f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	db, err = engine.NewDatabase("check_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if result, err = db.Tables["users"].Check(); err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Problems) == 0 || !strings.HasPrefix(result.Problems[0], "Page 1 cannot be read") {
+		t.Errorf("Expected page 1 to be reported, got %v", result.Problems)
+	}
+}
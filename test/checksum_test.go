@@ -0,0 +1,105 @@
+package test
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"simple-database/internal/engine"
+	"simple-database/internal/engine/table"
+	"simple-database/internal/engine/table/btree"
+	"simple-database/internal/engine/table/column"
+	"simple-database/internal/engine/table/page"
+	"simple-database/internal/platform/datatype"
+	platformerror "simple-database/internal/platform/error"
+	"simple-database/internal/platform/evaluator"
+	"strings"
+	"testing"
+)
+
+func TestChecksum(t *testing.T) {
+	_ = os.RemoveAll("data/checksum_test")
+
+	db, err := engine.NewDatabase("checksum_test")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	id, _ := column.NewColumn("id", datatype.TypeInt64, column.PrimaryKey)
+	payload, _ := column.NewColumn("payload", datatype.TypeString, column.Normal)
+	_, err = db.CreateTable(engine.CreateTableCommand{TableName: "users", Columns: table.Columns{"id": id, "payload": payload}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := int64(0); i < 100; i++ {
+		record := map[string]any{"id": i, "payload": strings.Repeat("p", 100)}
+		if _, err = db.Tables["users"].Insert(table.InsertCommand{TableName: "users", Record: record}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	corrupt := func(path string, offset int64) {
+		f, err := os.OpenFile(path, os.O_RDWR, 0777)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		b := make([]byte, 1)
+		if _, err = f.ReadAt(b, offset); err != nil {
+			t.Fatal(err)
+		}
+		b[0] ^= 0xff
+		if _, err = f.WriteAt(b, offset); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expectChecksumError := func(err error, name string, num int64) {
+		var stackTraceError *platformerror.StackTraceError
+		if !errors.As(err, &stackTraceError) || stackTraceError.ErrorCode != platformerror.ChecksumMismatchErrorCode {
+			t.Fatalf("Expected a checksum mismatch error, got %v", err)
+		}
+		if !strings.Contains(stackTraceError.Msg, name) || !strings.Contains(stackTraceError.Msg, fmt.Sprintf("Page %d ", num)) {
+			t.Errorf("Expected the error to name %s and page %d, got %s", name, num, stackTraceError.Msg)
+		}
+	}
+
+	// 1. A damaged heap page is reported instead of being parsed
+	path := filepath.Join("data", "checksum_test", "users"+table.FileExtension)
+	stat, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := stat.Size()/page.Size - 1
+	corrupt(path, last*page.Size+100)
+
+	db, err = engine.NewDatabase("checksum_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Tables["users"].Select(table.SelectCommand{
+		TableName:  "users",
+		Expression: &evaluator.Expression{Left: "payload", Op: datatype.OperatorEqual, Right: strings.Repeat("p", 100)},
+		Limit:      table.UnlimitedSize,
+	})
+	expectChecksumError(err, "users", last)
+	if err = db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 2. So is a damaged index page
+	indexes, err := filepath.Glob(filepath.Join("data", "checksum_test", "*_idx.bin"))
+	if err != nil || len(indexes) != 1 {
+		t.Fatalf("Expected 1 index file, got %v (%v)", indexes, err)
+	}
+	// The root, page 0 of the tree, follows the header page
+	corrupt(indexes[0], btree.PageSize+10)
+	b, err := btree.Open(indexes[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	_, _, err = b.Get([]byte("0"))
+	expectChecksumError(err, filepath.Base(indexes[0]), 0)
+}
@@ -0,0 +1,114 @@
+package test
+
+import (
+	"errors"
+	"os"
+	"simple-database/internal/engine"
+	"simple-database/internal/engine/table"
+	"simple-database/internal/engine/table/column"
+	"simple-database/internal/platform/datatype"
+	platformerror "simple-database/internal/platform/error"
+	"simple-database/internal/platform/evaluator"
+	"testing"
+)
+
+func TestCompositeIndex(t *testing.T) {
+	_ = os.RemoveAll("data/composite_index_test")
+	db, err := engine.NewDatabase("composite_index_test")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	id, _ := column.NewColumn("id", datatype.TypeInt64, column.PrimaryKey)
+	tenantId, _ := column.NewColumn("tenant_id", datatype.TypeInt32, column.Normal)
+	createdAt, _ := column.NewColumn("created_at", datatype.TypeInt64, column.Normal)
+	_, err = db.CreateTable(engine.CreateTableCommand{
+		TableName: "orders",
+		Columns:   table.Columns{"id": id, "tenant_id": tenantId, "created_at": createdAt},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 10 tenants with 40 orders each, created_at runs from 0 to 39 within a tenant
+	for i := int64(0); i < 400; i++ {
+		record := map[string]any{"id": i, "tenant_id": int32(i % 10), "created_at": i / 10}
+		if _, err = db.Tables["orders"].Insert(table.InsertCommand{TableName: "orders", Record: record}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = db.CreateIndex(engine.CreateIndexCommand{IndexName: "orders_tenant_created", TableName: "orders", ColumnNames: []string{"tenant_id", "created_at"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	selectWhere := func(expression *evaluator.Expression) *table.SelectResult {
+		result, err := db.Tables["orders"].Select(table.SelectCommand{
+			TableName:  "orders",
+			Expression: expression,
+			Limit:      table.UnlimitedSize,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+	tenant := evaluator.Expression{Left: "tenant_id", Op: datatype.OperatorEqual, Right: int32(3)}
+	created := evaluator.Expression{Left: "created_at", Op: datatype.OperatorGreater, Right: int64(29)}
+
+	tests := []struct {
+		name       string
+		expression *evaluator.Expression
+		accessType string
+		rows       int
+		inspected  int
+	}{
+		{"leading equality and next range", &evaluator.Expression{Left: tenant, Op: datatype.OperatorAnd, Right: created}, table.AccessTypeIndex, 10, 10},
+		{"range first in the clause", &evaluator.Expression{Left: created, Op: datatype.OperatorAnd, Right: tenant}, table.AccessTypeIndex, 10, 10},
+		{"leading column only", &tenant, table.AccessTypeIndex, 40, 40},
+		{"leading column range", &evaluator.Expression{Left: "tenant_id", Op: datatype.OperatorGreaterOrEqual, Right: int32(8)}, table.AccessTypeIndex, 80, 80},
+		{"second column only", &created, table.AccessTypeAll, 100, 400},
+		{"or", &evaluator.Expression{Left: tenant, Op: datatype.OperatorOr, Right: created}, table.AccessTypeAll, 130, 400},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result := selectWhere(test.expression)
+			if result.AccessType != test.accessType || len(result.Rows) != test.rows || result.RowsInspected != test.inspected {
+				t.Errorf("Expected %d rows, %d inspected with %s, got %d rows, %d inspected with %s", test.rows,
+					test.inspected, test.accessType, len(result.Rows), result.RowsInspected, result.AccessType)
+			}
+		})
+	}
+
+	// A unique composite index only rejects a tuple it already holds
+	err = db.CreateIndex(engine.CreateIndexCommand{IndexName: "orders_unique", TableName: "orders", ColumnNames: []string{"created_at", "tenant_id"}, Unique: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	record := map[string]any{"id": int64(400), "tenant_id": int32(3), "created_at": int64(5)}
+	_, err = db.Tables["orders"].Insert(table.InsertCommand{TableName: "orders", Record: record})
+	var stackTraceError *platformerror.StackTraceError
+	if !errors.As(err, &stackTraceError) || stackTraceError.ErrorCode != platformerror.UniqueKeyViolationErrorCode {
+		t.Fatalf("Expected a unique key violation, got %v", err)
+	}
+	record["created_at"] = int64(40)
+	if _, err = db.Tables["orders"].Insert(table.InsertCommand{TableName: "orders", Record: record}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if db, err = engine.NewDatabase("composite_index_test"); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	result, err := db.Tables["orders"].Check()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.ProblemCount() != 0 || result.IndexEntries["orders_tenant_created"] != 401 || result.IndexEntries["orders_unique"] != 401 {
+		t.Errorf("Expected a clean table with 401 entries per index, got %v %v %v", result.Problems, result.IndexProblems, result.IndexEntries)
+	}
+	if result := selectWhere(&evaluator.Expression{Left: tenant, Op: datatype.OperatorAnd, Right: created}); result.AccessType != table.AccessTypeIndex || len(result.Rows) != 11 {
+		t.Errorf("Expected 11 rows through the index after reopening, got %d with %s", len(result.Rows), result.AccessType)
+	}
+}
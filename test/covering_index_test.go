@@ -0,0 +1,129 @@
+package test
+
+import (
+	"fmt"
+	"os"
+	"simple-database/internal/engine"
+	"simple-database/internal/engine/table"
+	"simple-database/internal/engine/table/column"
+	"simple-database/internal/platform/datatype"
+	"simple-database/internal/platform/evaluator"
+	"testing"
+)
+
+func TestCoveringIndex(t *testing.T) {
+	_ = os.RemoveAll("data/covering_index_test")
+	db, err := engine.NewDatabase("covering_index_test")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	id, _ := column.NewColumn("id", datatype.TypeInt64, column.PrimaryKey)
+	category, _ := column.NewColumn("category", datatype.TypeInt32, column.Normal)
+	price, _ := column.NewColumn("price", datatype.TypeInt64, column.Normal)
+	name, _ := column.NewColumn("name", datatype.TypeString, column.Normal)
+	_, err = db.CreateTable(engine.CreateTableCommand{
+		TableName: "products",
+		Columns:   table.Columns{"id": id, "category": category, "price": price, "name": name},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := int64(0); i < 200; i++ {
+		record := map[string]any{"id": i, "category": int32(i % 10), "price": i * 100, "name": fmt.Sprintf("product %d", i)}
+		if _, err = db.Tables["products"].Insert(table.InsertCommand{TableName: "products", Record: record}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = db.CreateIndex(engine.CreateIndexCommand{IndexName: "products_category", TableName: "products",
+		ColumnNames: []string{"category"}, IncludeColumnNames: []string{"price"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	categoryEqual := evaluator.Expression{Left: "category", Op: datatype.OperatorEqual, Right: int32(3)}
+	selectWhere := func(columns []string, expression *evaluator.Expression) *table.SelectResult {
+		result, err := db.Tables["products"].Select(table.SelectCommand{
+			TableName:     "products",
+			SelectColumns: columns,
+			Expression:    expression,
+			Limit:         table.UnlimitedSize,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+	// expectPrices checks the rows of category 3 and that each holds the price stored for its id
+	expectPrices := func(result *table.SelectResult, accessType string, rows int, priceOf func(id int64) int64) {
+		t.Helper()
+		if result.AccessType != accessType || len(result.Rows) != rows {
+			t.Fatalf("Expected %d rows with %s, got %d with %s", rows, accessType, len(result.Rows), result.AccessType)
+		}
+		for _, row := range result.Rows {
+			id := row.Record["id"].(int64)
+			if id%10 != 3 || row.Record["price"] != priceOf(id) {
+				t.Errorf("Unexpected row %v", row.Record)
+			}
+		}
+	}
+	initialPrice := func(id int64) int64 { return id * 100 }
+
+	// 1. Columns stored in the index are read from it alone, the where clause included
+	expectPrices(selectWhere([]string{"id", "price"}, &categoryEqual), table.AccessTypeIndexOnly, 20, initialPrice)
+	expensive := &evaluator.Expression{
+		Left:  categoryEqual,
+		Op:    datatype.OperatorAnd,
+		Right: evaluator.Expression{Left: "price", Op: datatype.OperatorGreaterOrEqual, Right: int64(10000)},
+	}
+	expectPrices(selectWhere([]string{"id", "price"}, expensive), table.AccessTypeIndexOnly, 10, initialPrice)
+
+	// 2. A column the index does not hold, or every column, needs the records
+	if result := selectWhere([]string{"id", "name"}, &categoryEqual); result.AccessType != table.AccessTypeIndex || len(result.Rows) != 20 {
+		t.Errorf("Expected 20 rows through the index, got %d with %s", len(result.Rows), result.AccessType)
+	}
+	if result := selectWhere([]string{"*"}, &categoryEqual); result.AccessType != table.AccessTypeIndex || len(result.Rows) != 20 {
+		t.Errorf("Expected 20 rows through the index, got %d with %s", len(result.Rows), result.AccessType)
+	}
+	withName := &evaluator.Expression{
+		Left:  categoryEqual,
+		Op:    datatype.OperatorAnd,
+		Right: evaluator.Expression{Left: "name", Op: datatype.OperatorEqual, Right: "product 13"},
+	}
+	if result := selectWhere([]string{"price"}, withName); result.AccessType != table.AccessTypeIndex || len(result.Rows) != 1 {
+		t.Errorf("Expected 1 row through the index, got %d with %s", len(result.Rows), result.AccessType)
+	}
+
+	// 3. Updating an included column rewrites the values stored in the index
+	_, err = db.Tables["products"].Update(table.UpdateCommand{
+		TableName:  "products",
+		Expression: &evaluator.Expression{Left: "id", Op: datatype.OperatorEqual, Right: int64(13)},
+		Record:     map[string]any{"price": int64(1)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	updatedPrice := func(id int64) int64 {
+		if id == 13 {
+			return 1
+		}
+		return id * 100
+	}
+	expectPrices(selectWhere([]string{"id", "price"}, &categoryEqual), table.AccessTypeIndexOnly, 20, updatedPrice)
+
+	// 4. The included columns are stored with the definition
+	if err = db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if db, err = engine.NewDatabase("covering_index_test"); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	expectPrices(selectWhere([]string{"id", "price"}, &categoryEqual), table.AccessTypeIndexOnly, 20, updatedPrice)
+	result, err := db.Tables["products"].Check()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.ProblemCount() != 0 || result.IndexEntries["products_category"] != 200 {
+		t.Errorf("Expected a clean table with 200 index entries, got %v %v %v", result.Problems, result.IndexProblems, result.IndexEntries)
+	}
+}
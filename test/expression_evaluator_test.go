@@ -34,6 +34,26 @@ func TestEvaluator(t *testing.T) {
 			expr: evaluator.Expression{Left: "d", Op: datatype.OperatorLess, Right: 2},
 			want: true,
 		},
+		{
+			name: "bool not equal",
+			expr: evaluator.Expression{Left: "e", Op: datatype.OperatorNotEqual, Right: false},
+			want: true,
+		},
+		{
+			name: "bool not equal to nil",
+			expr: evaluator.Expression{Left: "e", Op: datatype.OperatorNotEqual, Right: nil},
+			want: false,
+		},
+		{
+			name: "bool not equal to an int",
+			expr: evaluator.Expression{Left: "e", Op: datatype.OperatorNotEqual, Right: 1},
+			want: false,
+		},
+		{
+			name: "bool not equal to a string",
+			expr: evaluator.Expression{Left: "e", Op: datatype.OperatorNotEqual, Right: "yes"},
+			want: false,
+		},
 	}
 
 	row := map[string]any{}
@@ -41,6 +61,7 @@ func TestEvaluator(t *testing.T) {
 	row["b"] = 5
 	row["c"] = 5
 	row["d"] = 1
+	row["e"] = true
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -0,0 +1,56 @@
+package test
+
+import (
+	"os"
+	"simple-database/internal/engine"
+	"simple-database/internal/engine/table"
+	"simple-database/internal/engine/table/column"
+	"testing"
+)
+
+// fixtureColumn is a column of a table created by createTable
+type fixtureColumn struct {
+	name     string
+	dataType byte
+	opts     int32
+}
+
+// openDatabase opens the database name without the data of earlier runs, it is closed once the test ends
+func openDatabase(t *testing.T, name string) *engine.Database {
+	t.Helper()
+	_ = os.RemoveAll("data/" + name)
+	db, err := engine.NewDatabase(name)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("Failed to close database: %v", err)
+		}
+	})
+	return db
+}
+
+// createTable creates the table name of db and inserts the records returned by record for i from first to last
+func createTable(t *testing.T, db *engine.Database, name string, columns []fixtureColumn, first, last int64,
+	record func(i int64) map[string]any) *table.Table {
+	t.Helper()
+	cols := make(table.Columns)
+	for _, c := range columns {
+		col, err := column.NewColumn(c.name, c.dataType, c.opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cols[c.name] = col
+	}
+	if _, err := db.CreateTable(engine.CreateTableCommand{TableName: name, Columns: cols}); err != nil {
+		t.Fatal(err)
+	}
+	created := db.Tables[name]
+	for i := first; i <= last; i++ {
+		if _, err := created.Insert(table.InsertCommand{TableName: name, Record: record(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return created
+}
@@ -0,0 +1,101 @@
+package test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"simple-database/internal/engine/table/btree"
+	"testing"
+)
+
+func TestBTreeFreePages(t *testing.T) {
+	_ = os.RemoveAll("data/free_pages_test")
+	_ = os.MkdirAll("data/free_pages_test", 0777)
+	name := "data/free_pages_test/tree"
+	b, err := btree.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := b.Close(); err != nil {
+			t.Errorf("Failed to close btree: %v", err)
+		}
+	}()
+	key := func(i int) []byte {
+		buf := make([]byte, 8)
+		binary.BigEndian.PutUint64(buf, uint64(i))
+		return buf
+	}
+	// value makes every tenth value too large for a node, so removing its key frees an overflow chain too
+	value := func(i int) []byte {
+		if i%10 == 0 {
+			return bytes.Repeat([]byte{byte(i)}, 2*btree.PageSize)
+		}
+		return key(i)
+	}
+	expectValid := func() {
+		t.Helper()
+		if problems := b.Check(); len(problems) != 0 {
+			t.Fatalf("Expected a valid tree, got %v", problems)
+		}
+	}
+
+	// 1. A growing tree uses every page of its file
+	for i := 0; i < 3000; i++ {
+		if err = b.Insert(key(i), value(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expectValid()
+	full := b.Pager.Stats()
+	if full.Free != 0 || full.Used != full.Total {
+		t.Fatalf("Expected no free pages, got %+v", full)
+	}
+
+	// 2. Merged nodes and the overflow chains of removed keys are freed
+	for i := 0; i < 3000; i++ {
+		if i%100 == 0 {
+			continue
+		}
+		if err = b.Remove(key(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expectValid()
+	emptied := b.Pager.Stats()
+	if emptied.Total != full.Total || emptied.Free == 0 || emptied.Used+emptied.Free != emptied.Total || emptied.Used > full.Used/10 {
+		t.Fatalf("Expected most of the %d pages to be free, got %+v", full.Total, emptied)
+	}
+
+	// 3. The free-list is kept in the file
+	if err = b.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if b, err = btree.Open(name); err != nil {
+		t.Fatal(err)
+	}
+	if stats := b.Pager.Stats(); stats != emptied {
+		t.Fatalf("Expected %+v after reopening, got %+v", emptied, stats)
+	}
+	expectValid()
+
+	// 4. Free pages are used again before the file grows
+	for i := 0; i < 3000; i++ {
+		if i%100 == 0 {
+			continue
+		}
+		if err = b.Insert(key(i), value(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expectValid()
+	if stats := b.Pager.Stats(); stats.Total > full.Total+full.Total/10 || stats.Free >= emptied.Free {
+		t.Errorf("Expected the %d free pages to be used again within %d pages, got %+v", emptied.Free, full.Total, stats)
+	}
+	for i := 0; i < 3000; i++ {
+		k, found, err := b.Get(key(i))
+		if err != nil || !found || !bytes.Equal(k.V, value(i)) {
+			t.Fatalf("Expected the value of key %d, got %v: %v", i, found, err)
+		}
+	}
+}
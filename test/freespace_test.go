@@ -0,0 +1,118 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"simple-database/internal/engine"
+	"simple-database/internal/engine/table"
+	"simple-database/internal/engine/table/column"
+	"simple-database/internal/platform/datatype"
+	"simple-database/internal/platform/evaluator"
+	"strings"
+	"testing"
+)
+
+func TestFreeSpaceReuse(t *testing.T) {
+	_ = os.RemoveAll("data/freespace_test")
+
+	db, err := engine.NewDatabase("freespace_test")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	id, _ := column.NewColumn("id", datatype.TypeInt64, column.PrimaryKey)
+	name, _ := column.NewColumn("name", datatype.TypeString, column.Normal)
+	group, _ := column.NewColumn("group", datatype.TypeInt32, column.UsingIndex)
+	users, err := db.CreateTable(engine.CreateTableCommand{
+		TableName: "users",
+		Columns:   table.Columns{"id": id, "name": name, "group": group},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	insert := func(i int64, n string) {
+		record := map[string]any{"id": i, "name": n, "group": int32(i % 2)}
+		if _, err := users.Insert(table.InsertCommand{TableName: "users", Record: record}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fileSize := func() int64 {
+		stat, err := os.Stat(filepath.Join("data", "freespace_test", "users"+table.FileExtension))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return stat.Size()
+	}
+	countGroup := func(value int32) int {
+		result, err := users.Select(table.SelectCommand{
+			TableName:  "users",
+			Expression: &evaluator.Expression{Left: "group", Op: datatype.OperatorEqual, Right: value},
+			Limit:      table.UnlimitedSize,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(result.Rows)
+	}
+
+	for i := int64(0); i < 200; i++ {
+		insert(i, strings.Repeat("a", 20))
+	}
+	size := fileSize()
+
+	// 1. Records of the same size go into the holes left by deleted ones
+	_, err = users.Delete(table.DeleteCommand{
+		TableName:  "users",
+		Expression: &evaluator.Expression{Left: "group", Op: datatype.OperatorEqual, Right: int32(0)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := int64(200); i < 300; i += 2 {
+		insert(i, strings.Repeat("b", 20))
+	}
+	if fileSize() != size {
+		t.Errorf("Expected file size to stay %d, got %d", size, fileSize())
+	}
+
+	// 2. A hole is split between smaller records
+	_, err = users.Delete(table.DeleteCommand{
+		TableName:  "users",
+		Expression: &evaluator.Expression{Left: "group", Op: datatype.OperatorEqual, Right: int32(1)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := int64(301); i < 501; i += 2 {
+		insert(i, "c")
+	}
+	if fileSize() != size {
+		t.Errorf("Expected file size to stay %d, got %d", size, fileSize())
+	}
+	if n := countGroup(0); n != 50 {
+		t.Errorf("Expected 50 rows in group 0, got %d", n)
+	}
+	if n := countGroup(1); n != 100 {
+		t.Errorf("Expected 100 rows in group 1, got %d", n)
+	}
+
+	// 3. Updates rewrite records in place of their old version
+	_, err = users.Update(table.UpdateCommand{
+		TableName:  "users",
+		Expression: &evaluator.Expression{Left: "group", Op: datatype.OperatorEqual, Right: int32(1)},
+		Record:     map[string]any{"name": "d"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fileSize() != size {
+		t.Errorf("Expected file size to stay %d, got %d", size, fileSize())
+	}
+	if n := countGroup(1); n != 100 {
+		t.Errorf("Expected 100 rows in group 1, got %d", n)
+	}
+
+	if err = db.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
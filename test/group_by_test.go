@@ -0,0 +1,186 @@
+package test
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"simple-database/internal/engine"
+	"simple-database/internal/engine/table"
+	"simple-database/internal/engine/table/column"
+	"simple-database/internal/platform/datatype"
+	platformerror "simple-database/internal/platform/error"
+	"simple-database/internal/platform/evaluator"
+	"testing"
+)
+
+func TestGroupBy(t *testing.T) {
+	db := openDatabase(t, "group_by_test")
+	emp := createTable(t, db, "emp", []fixtureColumn{
+		{"id", datatype.TypeInt64, column.PrimaryKey}, {"dept", datatype.TypeInt32, column.Normal},
+		{"salary", datatype.TypeInt64, column.Normal}, {"name", datatype.TypeString, column.Normal},
+	}, 0, 999, func(i int64) map[string]any {
+		return map[string]any{"id": i, "dept": int32(i % 7), "salary": i * 37 % 101, "name": fmt.Sprintf("employee %d", i%250)}
+	})
+	type stats struct {
+		count, sum, min, max int64
+	}
+	expected := make(map[int32]*stats)
+	for i := int64(0); i < 1000; i++ {
+		s, ok := expected[int32(i%7)]
+		if !ok {
+			s = &stats{min: 1 << 62}
+			expected[int32(i%7)] = s
+		}
+		s.count++
+		s.sum += i * 37 % 101
+		s.min, s.max = min(s.min, i*37%101), max(s.max, i*37%101)
+	}
+
+	count := table.Aggregate{Function: table.AggregateCount}
+	avg := table.Aggregate{Function: table.AggregateAvg, Column: "salary", Cast: datatype.TypeFloat64}
+	aggregates := []table.Aggregate{count, avg, {Function: table.AggregateSum, Column: "salary"},
+		{Function: table.AggregateMin, Column: "salary"}, {Function: table.AggregateMax, Column: "salary"}}
+	// Departments 0 to 5 have 143 employees, department 6 has 142
+	having := &evaluator.Expression{Left: "COUNT(*)", Op: datatype.OperatorGreater, Right: int64(142)}
+	selectGroups := func(command table.SelectCommand) *table.SelectResult {
+		t.Helper()
+		command.TableName = "emp"
+		if command.Limit == 0 {
+			command.Limit = table.UnlimitedSize
+		}
+		result, err := emp.Select(command)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+	// expectGroups checks the aggregates of every department in the result and how they were computed
+	expectGroups := func(result *table.SelectResult, groups int, groupType string) {
+		t.Helper()
+		if len(result.Rows) != groups || result.GroupType != groupType {
+			t.Fatalf("Expected %d groups computed by %s, got %d by %s", groups, groupType, len(result.Rows), result.GroupType)
+		}
+		for _, row := range result.Rows {
+			s := expected[row.Record["dept"].(int32)]
+			if row.Record["COUNT(*)"] != s.count || row.Record["SUM(salary)"] != s.sum || row.Record["MIN(salary)"] != s.min ||
+				row.Record["MAX(salary)"] != s.max || row.Record["AVG(FLOAT64(salary))"] != float64(s.sum)/float64(s.count) {
+				t.Errorf("Expected %+v, got %v", *s, row.Record)
+			}
+		}
+	}
+
+	// 1. Groups are kept in a hash table and filtered by HAVING
+	hashed := selectGroups(table.SelectCommand{SelectColumns: []string{"dept"}, GroupBy: []string{"dept"}, Aggregates: aggregates, Having: having})
+	expectGroups(hashed, 6, table.GroupTypeHash)
+	if hashed.AccessType != table.AccessTypeAll || hashed.RowsInspected != 1000 {
+		t.Errorf("Expected the 1000 rows of the table to be read, got %d with %s", hashed.RowsInspected, hashed.AccessType)
+	}
+
+	// 2. Past the memory limit the groups are written to runs and merged
+	emp.SetSortMemory(2048)
+	spilled := selectGroups(table.SelectCommand{GroupBy: []string{"name"}, Aggregates: []table.Aggregate{count}})
+	if len(spilled.Rows) != 250 || spilled.GroupType != table.GroupTypeExternal {
+		t.Fatalf("Expected 250 groups computed by %s, got %d by %s", table.GroupTypeExternal, len(spilled.Rows), spilled.GroupType)
+	}
+	for _, row := range spilled.Rows {
+		if row.Record["COUNT(*)"] != int64(4) {
+			t.Fatalf("Expected 4 employees of each name, got %v", row.Record)
+		}
+	}
+	spilled = selectGroups(table.SelectCommand{SelectColumns: []string{"dept"}, GroupBy: []string{"dept", "name"},
+		Aggregates: []table.Aggregate{{Function: table.AggregateSum, Column: "salary"}}})
+	// Every employee has a name of its own within a department
+	if spilled.GroupType != table.GroupTypeExternal || len(spilled.Rows) != 1000 {
+		t.Fatalf("Expected 1000 groups computed by %s, got %d by %s", table.GroupTypeExternal, len(spilled.Rows), spilled.GroupType)
+	}
+	if runs, _ := filepath.Glob("data/group_by_test/*_sort_*.tmp"); len(runs) != 0 {
+		t.Errorf("Expected the runs to be removed, got %v", runs)
+	}
+	emp.SetSortMemory(table.DefaultSortMemory)
+
+	// 3. Groups are sorted by ORDER BY, aggregates included
+	sorted := selectGroups(table.SelectCommand{SelectColumns: []string{"dept"}, GroupBy: []string{"dept"}, Aggregates: aggregates,
+		OrderBy: []table.OrderBy{{Column: "COUNT(*)"}, {Column: "dept", Descending: true}}})
+	expectGroups(sorted, 7, table.GroupTypeHash)
+	if sorted.SortType != table.SortTypeMemory || sorted.Rows[0].Record["dept"] != int32(6) || sorted.Rows[1].Record["dept"] != int32(5) {
+		t.Errorf("Expected department 6 then 5 sorted in memory, got %v then %v by %s", sorted.Rows[0].Record, sorted.Rows[1].Record, sorted.SortType)
+	}
+
+	// 4. Rows read from an index in the order of the group columns are grouped as they come
+	err := db.CreateIndex(engine.CreateIndexCommand{IndexName: "emp_dept_salary", TableName: "emp", ColumnNames: []string{"dept", "salary"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	streamed := selectGroups(table.SelectCommand{SelectColumns: []string{"dept"}, GroupBy: []string{"dept"}, Aggregates: aggregates, Having: having})
+	expectGroups(streamed, 6, table.GroupTypeStream)
+	if streamed.AccessType != table.AccessTypeIndexOnly {
+		t.Errorf("Expected %s, got %s", table.AccessTypeIndexOnly, streamed.AccessType)
+	}
+	streamed = selectGroups(table.SelectCommand{SelectColumns: []string{"dept"}, GroupBy: []string{"dept"}, Aggregates: aggregates, Limit: 2})
+	expectGroups(streamed, 2, table.GroupTypeStream)
+	if streamed.RowsInspected >= 1000 || streamed.Rows[1].Record["dept"] != int32(1) {
+		t.Errorf("Expected the select to stop after department 1, got %v with %d rows inspected", streamed.Rows[1].Record, streamed.RowsInspected)
+	}
+	ordered := selectGroups(table.SelectCommand{SelectColumns: []string{"dept"}, GroupBy: []string{"dept"}, Aggregates: aggregates,
+		OrderBy: []table.OrderBy{{Column: "dept"}}})
+	expectGroups(ordered, 7, table.GroupTypeStream)
+	if ordered.SortType != table.SortTypeIndex {
+		t.Errorf("Expected %s, got %s", table.SortTypeIndex, ordered.SortType)
+	}
+	// Columns matched with = do not break the order of the others
+	bySalary := selectGroups(table.SelectCommand{SelectColumns: []string{"salary"}, GroupBy: []string{"salary"}, Aggregates: []table.Aggregate{count},
+		Expression: &evaluator.Expression{Left: "dept", Op: datatype.OperatorEqual, Right: int32(3)}})
+	if bySalary.GroupType != table.GroupTypeStream || bySalary.AccessType != table.AccessTypeIndexOnly || len(bySalary.Rows) == 0 {
+		t.Errorf("Expected groups streamed from the index, got %d by %s with %s", len(bySalary.Rows), bySalary.GroupType, bySalary.AccessType)
+	}
+
+	// 5. Aggregates without GROUP BY make one group, even of no rows
+	total := selectGroups(table.SelectCommand{Aggregates: aggregates[:3],
+		Expression: &evaluator.Expression{Left: "dept", Op: datatype.OperatorEqual, Right: int32(3)}})
+	s := expected[3]
+	if len(total.Rows) != 1 || total.Rows[0].Record["COUNT(*)"] != s.count || total.Rows[0].Record["SUM(salary)"] != s.sum {
+		t.Errorf("Expected one group of %+v, got %v", *s, total.Rows)
+	}
+	none := selectGroups(table.SelectCommand{Aggregates: aggregates[:3],
+		Expression: &evaluator.Expression{Left: "dept", Op: datatype.OperatorEqual, Right: int32(100)}})
+	if len(none.Rows) != 1 || none.Rows[0].Record["COUNT(*)"] != int64(0) || none.Rows[0].Record["SUM(salary)"] != nil {
+		t.Errorf("Expected one empty group, got %v", none.Rows)
+	}
+
+	// 6. Columns outside of the groups and unknown aggregates are rejected
+	invalid := []table.SelectCommand{
+		{SelectColumns: []string{"name"}, GroupBy: []string{"dept"}, Aggregates: []table.Aggregate{count}},
+		{SelectColumns: []string{"*"}, Aggregates: []table.Aggregate{count}},
+		{GroupBy: []string{"dept"}, Aggregates: []table.Aggregate{count}, Having: &evaluator.Expression{Left: "SUM(salary)", Op: datatype.OperatorGreater, Right: int64(1)}},
+		{GroupBy: []string{"dept"}, Aggregates: []table.Aggregate{{Function: table.AggregateSum}}},
+		{GroupBy: []string{"dept"}, Aggregates: []table.Aggregate{{Function: table.AggregateSum, Column: "name", Cast: datatype.TypeInt64}}},
+	}
+	for _, command := range invalid {
+		command.TableName, command.Limit = "emp", table.UnlimitedSize
+		if _, err = emp.Select(command); err == nil {
+			t.Errorf("Expected an error for %+v", command)
+		}
+	}
+
+	// 7. MIN and MAX are rejected on a column whose values have no order, unless they are cast first, joined or not
+	createTable(t, db, "flags", []fixtureColumn{
+		{"id", datatype.TypeInt64, column.PrimaryKey}, {"active", datatype.TypeBool, column.Normal},
+	}, 1, 0, nil)
+	joinFlags := []table.Join{{Type: table.JoinTypeInner, TableName: "flags",
+		On: &evaluator.Expression{Left: "emp.id", Op: datatype.OperatorEqual, Right: "flags.id"}}}
+	for _, command := range []table.SelectCommand{
+		{TableName: "flags", Aggregates: []table.Aggregate{{Function: table.AggregateMin, Column: "active"}}},
+		{TableName: "flags", GroupBy: []string{"id"}, Aggregates: []table.Aggregate{{Function: table.AggregateMax, Column: "active"}}},
+		{TableName: "emp", Joins: joinFlags, Aggregates: []table.Aggregate{{Function: table.AggregateMax, Column: "flags.active"}}},
+	} {
+		command.Limit = table.UnlimitedSize
+		var stackTraceError *platformerror.StackTraceError
+		if _, err = db.Select(command); !errors.As(err, &stackTraceError) || stackTraceError.ErrorCode != platformerror.ColumnViolationErrorCode {
+			t.Errorf("Expected a column violation for %+v, got %v", command.Aggregates, err)
+		}
+	}
+	cast := table.Aggregate{Function: table.AggregateMin, Column: "active", Cast: datatype.TypeString}
+	if _, err = db.Select(table.SelectCommand{TableName: "flags", Aggregates: []table.Aggregate{cast}, Limit: table.UnlimitedSize}); err != nil {
+		t.Errorf("Expected %s to be computed, got %v", cast.Name(), err)
+	}
+}
@@ -0,0 +1,91 @@
+package test
+
+import (
+	"os"
+	"simple-database/internal/engine"
+	"simple-database/internal/engine/table"
+	"simple-database/internal/engine/table/column"
+	"simple-database/internal/platform/datatype"
+	"simple-database/internal/platform/evaluator"
+	"strings"
+	"testing"
+)
+
+func TestTableHeader(t *testing.T) {
+	_ = os.RemoveAll("data/header_test")
+
+	db, err := engine.NewDatabase("header_test")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	for _, name := range []string{"users", "orders"} {
+		id, _ := column.NewColumn("id", datatype.TypeInt64, column.PrimaryKey)
+		payload, _ := column.NewColumn("payload", datatype.TypeString, column.Normal)
+		_, err = db.CreateTable(engine.CreateTableCommand{TableName: name, Columns: table.Columns{"id": id, "payload": payload}})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	insert := func(db *engine.Database, name string, i int64) {
+		record := map[string]any{"id": i, "payload": strings.Repeat(name[:1], 100)}
+		if _, err := db.Tables[name].Insert(table.InsertCommand{TableName: name, Record: record}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	count := func(db *engine.Database, name string) int {
+		result, err := db.Tables[name].Select(table.SelectCommand{
+			TableName:  name,
+			Expression: &evaluator.Expression{Left: "payload", Op: datatype.OperatorEqual, Right: strings.Repeat(name[:1], 100)},
+			Limit:      table.UnlimitedSize,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(result.Rows)
+	}
+
+	// 1. Inserting into two tables in turn keeps each one's pages apart
+	for i := int64(0); i < 100; i++ {
+		insert(db, "users", i)
+		insert(db, "orders", i)
+	}
+	for _, name := range []string{"users", "orders"} {
+		if n := count(db, name); n != 100 {
+			t.Errorf("Expected 100 rows in %s, got %d", name, n)
+		}
+	}
+
+	// 2. The header survives a restart, so new pages are appended after the existing ones
+	if err = db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	db, err = engine.NewDatabase("header_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := int64(100); i < 200; i++ {
+		insert(db, "users", i)
+	}
+	if n := count(db, "users"); n != 200 {
+		t.Errorf("Expected 200 rows in users, got %d", n)
+	}
+	if n := db.Tables["users"].RowCount(); n != 200 {
+		t.Errorf("Expected a row count of 200, got %d", n)
+	}
+
+	_, err = db.Tables["orders"].Delete(table.DeleteCommand{
+		TableName:  "orders",
+		Expression: &evaluator.Expression{Left: "id", Op: datatype.OperatorLess, Right: int64(40)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := db.Tables["orders"].RowCount(); n != 60 {
+		t.Errorf("Expected a row count of 60, got %d", n)
+	}
+
+	if err = db.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,149 @@
+package test
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"simple-database/internal/engine"
+	"simple-database/internal/engine/table"
+	"simple-database/internal/engine/table/column"
+	"simple-database/internal/platform/datatype"
+	platformerror "simple-database/internal/platform/error"
+	"simple-database/internal/platform/evaluator"
+	"testing"
+)
+
+func TestCreateAndDropIndex(t *testing.T) {
+	_ = os.RemoveAll("data/index_ddl_test")
+	db, err := engine.NewDatabase("index_ddl_test")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	id, _ := column.NewColumn("id", datatype.TypeInt64, column.PrimaryKey)
+	email, _ := column.NewColumn("email", datatype.TypeString, column.Normal)
+	age, _ := column.NewColumn("age", datatype.TypeInt32, column.Normal)
+	_, err = db.CreateTable(engine.CreateTableCommand{
+		TableName: "users",
+		Columns:   table.Columns{"id": id, "email": email, "age": age},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := int64(0); i < 300; i++ {
+		record := map[string]any{"id": i, "email": fmt.Sprintf("user%d@example.com", i), "age": int32(i % 50)}
+		if _, err = db.Tables["users"].Insert(table.InsertCommand{TableName: "users", Record: record}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expectCode := func(err error, code platformerror.Code) {
+		var stackTraceError *platformerror.StackTraceError
+		if !errors.As(err, &stackTraceError) || stackTraceError.ErrorCode != code {
+			t.Fatalf("Expected error code %d, got %v", code, err)
+		}
+	}
+	selectWhere := func(left string, op datatype.Operator, value any) *table.SelectResult {
+		result, err := db.Tables["users"].Select(table.SelectCommand{
+			TableName:  "users",
+			Expression: &evaluator.Expression{Left: left, Op: op, Right: value},
+			Limit:      table.UnlimitedSize,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+	expectClean := func(entries map[string]int) {
+		result, err := db.Tables["users"].Check()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.ProblemCount() != 0 {
+			t.Fatalf("Expected no problems, got %v %v", result.Problems, result.IndexProblems)
+		}
+		for name, n := range entries {
+			if result.IndexEntries[name] != n {
+				t.Errorf("Expected %d entries in index %s, got %d", n, name, result.IndexEntries[name])
+			}
+		}
+	}
+	agePath := filepath.Join("data", "index_ddl_test", "_users_users_age_idx.bin")
+	emailPath := filepath.Join("data", "index_ddl_test", "_users_users_email_idx.bin")
+
+	// 1. A unique index over duplicated values fails during the backfill and leaves nothing behind
+	err = db.CreateIndex(engine.CreateIndexCommand{IndexName: "users_age", TableName: "users", ColumnNames: []string{"age"}, Unique: true})
+	expectCode(err, platformerror.UniqueKeyViolationErrorCode)
+	if db.Tables["users"].HasIndex("users_age") {
+		t.Error("Expected no index users_age after a failed backfill")
+	}
+	if _, err = os.Stat(agePath); !os.IsNotExist(err) {
+		t.Errorf("Expected no file for index users_age, got %v", err)
+	}
+	if result := selectWhere("age", datatype.OperatorEqual, int32(7)); result.AccessType != table.AccessTypeAll || len(result.Rows) != 6 {
+		t.Errorf("Expected 6 rows through a full scan, got %d with %s", len(result.Rows), result.AccessType)
+	}
+
+	// 2. Indexes are backfilled from the rows already in the table and used by selects
+	if err = db.CreateIndex(engine.CreateIndexCommand{IndexName: "users_age", TableName: "users", ColumnNames: []string{"age"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err = db.CreateIndex(engine.CreateIndexCommand{IndexName: "users_email", TableName: "users", ColumnNames: []string{"email"}, Unique: true}); err != nil {
+		t.Fatal(err)
+	}
+	err = db.CreateIndex(engine.CreateIndexCommand{IndexName: "users_email", TableName: "users", ColumnNames: []string{"age"}})
+	expectCode(err, platformerror.IndexAlreadyExistsErrorCode)
+	if result := selectWhere("age", datatype.OperatorEqual, int32(7)); result.AccessType != table.AccessTypeIndex || result.RowsInspected != 6 {
+		t.Errorf("Expected 6 rows inspected through the index, got %d with %s", result.RowsInspected, result.AccessType)
+	}
+	expectClean(map[string]int{"users_age": 300, "users_email": 300})
+
+	// 3. Writes maintain the new indexes, the unique one rejects a value it already holds
+	record := map[string]any{"id": int64(300), "email": "user5@example.com", "age": int32(7)}
+	_, err = db.Tables["users"].Insert(table.InsertCommand{TableName: "users", Record: record})
+	expectCode(err, platformerror.UniqueKeyViolationErrorCode)
+	record["email"] = "new@example.com"
+	if _, err = db.Tables["users"].Insert(table.InsertCommand{TableName: "users", Record: record}); err != nil {
+		t.Fatal(err)
+	}
+	expectClean(map[string]int{"users_age": 301, "users_email": 301})
+
+	// 4. The definitions are stored with the table, vacuum keeps them
+	if err = db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if db, err = engine.NewDatabase("index_ddl_test"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = db.Vacuum(engine.VacuumCommand{TableName: "users"}); err != nil {
+		t.Fatal(err)
+	}
+	if result := selectWhere("email", datatype.OperatorEqual, "new@example.com"); result.AccessType != table.AccessTypeIndex || len(result.Rows) != 1 {
+		t.Errorf("Expected 1 row through the index after reopening, got %d with %s", len(result.Rows), result.AccessType)
+	}
+	expectClean(map[string]int{"users_age": 301, "users_email": 301})
+
+	// 5. Dropping an index keeps the table and its other indexes
+	if err = db.DropIndex(engine.DropIndexCommand{IndexName: "users_email"}); err != nil {
+		t.Fatal(err)
+	}
+	expectCode(db.DropIndex(engine.DropIndexCommand{IndexName: "users_email"}), platformerror.IndexNotExistsErrorCode)
+	expectCode(db.DropIndex(engine.DropIndexCommand{IndexName: "id", TableName: "users"}), platformerror.ColumnViolationErrorCode)
+	if _, err = os.Stat(emailPath); !os.IsNotExist(err) {
+		t.Errorf("Expected the file of index users_email to be removed, got %v", err)
+	}
+	if err = db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if db, err = engine.NewDatabase("index_ddl_test"); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if db.Tables["users"].HasIndex("users_email") || !db.Tables["users"].HasIndex("users_age") {
+		t.Error("Expected only index users_age to be left after reopening")
+	}
+	if result := selectWhere("email", datatype.OperatorEqual, "new@example.com"); result.AccessType != table.AccessTypeAll || len(result.Rows) != 1 {
+		t.Errorf("Expected 1 row through a full scan, got %d with %s", len(result.Rows), result.AccessType)
+	}
+	expectClean(map[string]int{"users_age": 301})
+}
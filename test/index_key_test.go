@@ -0,0 +1,201 @@
+package test
+
+import (
+	"bytes"
+	"math"
+	"os"
+	"simple-database/internal/engine"
+	"simple-database/internal/engine/table"
+	"simple-database/internal/engine/table/column"
+	"simple-database/internal/engine/table/index"
+	"simple-database/internal/platform/datatype"
+	"simple-database/internal/platform/evaluator"
+	"simple-database/internal/platform/parser"
+	"strings"
+	"testing"
+)
+
+func TestComparableKeys(t *testing.T) {
+	sorted := [][]any{
+		{int32(math.MinInt32), int32(-5), int32(-1), int32(0), int32(1), int32(300), int32(math.MaxInt32)},
+		{int64(math.MinInt64), int64(-70000), int64(-1), int64(0), int64(2), int64(math.MaxInt64)},
+		{float32(math.Inf(-1)), float32(-2.5), float32(-0.1), float32(0), float32(1e-9), float32(3), float32(math.Inf(1))},
+		{math.Inf(-1), -1e300, -2.5, -math.SmallestNonzeroFloat64, 0.0, 0.5, 1e300, math.Inf(1)},
+		{"", "\x00", "\x00\x00", "\x00a", "a", "a\x00", "a\x00b", "ab", "b"},
+	}
+
+	// 1. Encodings compare as their values
+	for _, values := range sorted {
+		for i := 1; i < len(values); i++ {
+			prev, err := (&parser.ValueMarshaler[any]{Value: values[i-1]}).MarshalComparableBinary()
+			if err != nil {
+				t.Fatal(err)
+			}
+			cur, err := (&parser.ValueMarshaler[any]{Value: values[i]}).MarshalComparableBinary()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if bytes.Compare(prev, cur) >= 0 {
+				t.Errorf("Expected %v to sort before %v, got %x and %x", values[i-1], values[i], prev, cur)
+			}
+			if bytes.HasPrefix(cur, prev) {
+				t.Errorf("Expected %v not to be a prefix of %v", values[i-1], values[i])
+			}
+		}
+	}
+	negativeZero, _ := (&parser.ValueMarshaler[any]{Value: math.Copysign(0, -1)}).MarshalComparableBinary()
+	zero, _ := (&parser.ValueMarshaler[any]{Value: 0.0}).MarshalComparableBinary()
+	if !bytes.Equal(negativeZero, zero) {
+		t.Errorf("Expected -0 and 0 to be encoded the same, got %x and %x", negativeZero, zero)
+	}
+
+	// 2. Range queries through an index return the same rows as the values
+	_ = os.RemoveAll("data/index_key_test")
+	db, err := engine.NewDatabase("index_key_test")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	id, _ := column.NewColumn("id", datatype.TypeInt64, column.PrimaryKey)
+	age, _ := column.NewColumn("age", datatype.TypeInt32, column.UsingIndex)
+	score, _ := column.NewColumn("score", datatype.TypeFloat64, column.UsingIndex)
+	readings, err := db.CreateTable(engine.CreateTableCommand{
+		TableName: "readings",
+		Columns:   table.Columns{"id": id, "age": age, "score": score},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := int64(-50); i < 50; i++ {
+		record := map[string]any{"id": i, "age": int32(i), "score": float64(i) / 4}
+		if _, err = readings.Insert(table.InsertCommand{TableName: "readings", Record: record}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		column string
+		op     datatype.Operator
+		value  any
+		rows   int
+	}{
+		{"id", datatype.OperatorLess, int64(-10), 40},
+		{"id", datatype.OperatorGreaterOrEqual, int64(-10), 60},
+		{"age", datatype.OperatorGreater, int32(-5), 54},
+		{"age", datatype.OperatorLessOrEqual, int32(-5), 46},
+		{"score", datatype.OperatorLess, -2.5, 40},
+		{"score", datatype.OperatorGreater, 0.1, 49},
+		{"score", datatype.OperatorEqual, -12.5, 1},
+	}
+	for _, test := range tests {
+		result, err := readings.Select(table.SelectCommand{
+			TableName:  "readings",
+			Expression: &evaluator.Expression{Left: test.column, Op: test.op, Right: test.value},
+			Limit:      table.UnlimitedSize,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.AccessType != table.AccessTypeIndex || len(result.Rows) != test.rows {
+			t.Errorf("Expected %d rows for %s %v %v through the index, got %d with %s", test.rows, test.column, test.op, test.value, len(result.Rows), result.AccessType)
+		}
+	}
+}
+
+func TestIndexKeyRanges(t *testing.T) {
+	_ = os.RemoveAll("data/index_range_test")
+	db, err := engine.NewDatabase("index_range_test")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	id, _ := column.NewColumn("id", datatype.TypeInt64, column.PrimaryKey)
+	name, _ := column.NewColumn("name", datatype.TypeString, column.UsingIndex)
+	users, err := db.CreateTable(engine.CreateTableCommand{TableName: "users", Columns: table.Columns{"id": id, "name": name}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Values that start with one another, each held by 3 rows with ids before and after the others
+	names := []string{"a", "a\x00", "a\x00b", "ab", "abc", "ab\xff", "b", strings.Repeat("a", 3000)}
+	for i, value := range names {
+		for _, n := range []int64{-1000, 0, 1000} {
+			record := map[string]any{"id": n + int64(i), "name": value}
+			if _, err = users.Insert(table.InsertCommand{TableName: "users", Record: record}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	// Only the rows matching are read from the index
+	tests := []struct {
+		op    datatype.Operator
+		value string
+		rows  int
+	}{
+		{datatype.OperatorEqual, "a", 3},
+		{datatype.OperatorEqual, "ab", 3},
+		{datatype.OperatorEqual, "aa", 0},
+		{datatype.OperatorGreater, "a", 21},
+		{datatype.OperatorGreater, "ab", 9},
+		{datatype.OperatorGreaterOrEqual, "ab", 12},
+		{datatype.OperatorLess, "ab", 12},
+		{datatype.OperatorLessOrEqual, "ab", 15},
+		{datatype.OperatorLessOrEqual, "a\x00", 6},
+		{datatype.OperatorGreaterOrEqual, strings.Repeat("a", 3000), 15},
+		{datatype.OperatorNotEqual, "ab", 21},
+		{datatype.OperatorNotEqual, "aa", 24},
+	}
+	for _, test := range tests {
+		result, err := users.Select(table.SelectCommand{
+			TableName:  "users",
+			Expression: &evaluator.Expression{Left: "name", Op: test.op, Right: test.value},
+			Limit:      table.UnlimitedSize,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(result.Rows) != test.rows || result.RowsInspected != test.rows {
+			t.Errorf("Expected %d rows for name %v %.10q, got %d of %d inspected", test.rows, test.op, test.value, len(result.Rows), result.RowsInspected)
+		}
+	}
+
+	// Ranges sharing keys are read once, in key order
+	idx := index.NewIndex("data/index_range_test/overlap_idx.bin", false, nil, nil)
+	defer idx.Close()
+	for i := int64(0); i < 100; i++ {
+		if err = idx.Add(index.NewItem(int32(i), i, i, 0)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ranges := []index.Range{
+		{Lower: &index.Bound{Value: int32(50), Inclusive: true}, Upper: &index.Bound{Value: int32(70)}},
+		{Lower: &index.Bound{Value: int32(10)}, Upper: &index.Bound{Value: int32(60), Inclusive: true}},
+		{Lower: &index.Bound{Value: int32(90), Inclusive: true}},
+		{Equal: []any{int32(95)}},
+	}
+	read := make([]int64, 0)
+	err = idx.ScanRanges(ranges, func(item index.Item) (bool, error) {
+		read = append(read, item.Page)
+		return true, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(read) != 69 || read[0] != 11 || read[58] != 69 || read[59] != 90 || read[68] != 99 {
+		t.Errorf("Expected the items 11 to 69 then 90 to 99, got %v", read)
+	}
+	for i := 1; i < len(read); i++ {
+		if read[i] <= read[i-1] {
+			t.Errorf("Expected item %d after %d", read[i], read[i-1])
+		}
+	}
+	in := &evaluator.Expression{Left: "name", Op: datatype.OperatorIn, Right: []any{"ab", "a", "a\x00", "ab", "a"}}
+	result, err := users.Select(table.SelectCommand{TableName: "users", Expression: in, Limit: table.UnlimitedSize})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Rows) != 9 || result.RowsInspected != 9 || result.Rows[0].Record["name"] != "a" || result.Rows[8].Record["name"] != "ab" {
+		t.Errorf("Expected the 9 rows of a, a\\x00 and ab in that order, got %d of %d inspected", len(result.Rows), result.RowsInspected)
+	}
+}
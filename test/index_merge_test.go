@@ -0,0 +1,110 @@
+package test
+
+import (
+	"fmt"
+	"os"
+	"simple-database/internal/engine"
+	"simple-database/internal/engine/table"
+	"simple-database/internal/engine/table/column"
+	"simple-database/internal/platform/datatype"
+	"simple-database/internal/platform/evaluator"
+	"testing"
+)
+
+func TestIndexMerge(t *testing.T) {
+	_ = os.RemoveAll("data/index_merge_test")
+	db, err := engine.NewDatabase("index_merge_test")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	id, _ := column.NewColumn("id", datatype.TypeInt64, column.PrimaryKey)
+	age, _ := column.NewColumn("age", datatype.TypeInt32, column.Normal)
+	city, _ := column.NewColumn("city", datatype.TypeString, column.Normal)
+	score, _ := column.NewColumn("score", datatype.TypeInt32, column.Normal)
+	_, err = db.CreateTable(engine.CreateTableCommand{
+		TableName: "people",
+		Columns:   table.Columns{"id": id, "age": age, "city": city, "score": score},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := int64(0); i < 500; i++ {
+		record := map[string]any{"id": i, "age": int32(i % 50), "city": fmt.Sprintf("city %d", i%7), "score": int32(i)}
+		if _, err = db.Tables["people"].Insert(table.InsertCommand{TableName: "people", Record: record}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, col := range []string{"age", "city"} {
+		err = db.CreateIndex(engine.CreateIndexCommand{IndexName: "people_" + col, TableName: "people", ColumnNames: []string{col}})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	compare := func(col string, op datatype.Operator, value any) evaluator.Expression {
+		return evaluator.Expression{Left: col, Op: op, Right: value}
+	}
+	join := func(op datatype.Operator, expressions ...evaluator.Expression) evaluator.Expression {
+		e := expressions[0]
+		for _, right := range expressions[1:] {
+			e = evaluator.Expression{Left: e, Op: op, Right: right}
+		}
+		return e
+	}
+	and := func(expressions ...evaluator.Expression) evaluator.Expression {
+		return join(datatype.OperatorAnd, expressions...)
+	}
+	or := func(expressions ...evaluator.Expression) evaluator.Expression {
+		return join(datatype.OperatorOr, expressions...)
+	}
+
+	tests := []struct {
+		name       string
+		expression evaluator.Expression
+		limit      uint32
+		accessType string
+		rows       int
+		inspected  int
+	}{
+		{"bounds merged into one range", and(compare("age", datatype.OperatorGreater, int32(10)), compare("age", datatype.OperatorLess, int32(20))),
+			table.UnlimitedSize, table.AccessTypeIndex, 90, 90},
+		{"tightest bounds kept", and(compare("age", datatype.OperatorGreaterOrEqual, int32(10)), compare("age", datatype.OperatorGreater, int32(15)),
+			compare("age", datatype.OperatorLessOrEqual, int32(20)), compare("age", datatype.OperatorLess, int32(40))),
+			table.UnlimitedSize, table.AccessTypeIndex, 50, 50},
+		{"empty range", and(compare("age", datatype.OperatorGreater, int32(30)), compare("age", datatype.OperatorLess, int32(20))),
+			table.UnlimitedSize, table.AccessTypeIndex, 0, 0},
+		{"and across indexes", and(compare("age", datatype.OperatorEqual, int32(7)), compare("city", datatype.OperatorEqual, "city 3")),
+			table.UnlimitedSize, table.AccessTypeIndexIntersection, 1, 1},
+		{"or across indexes", or(compare("age", datatype.OperatorEqual, int32(7)), compare("city", datatype.OperatorEqual, "city 3")),
+			table.UnlimitedSize, table.AccessTypeIndexUnion, 80, 80},
+		{"or with a limit", or(compare("age", datatype.OperatorEqual, int32(7)), compare("city", datatype.OperatorEqual, "city 3")),
+			5, table.AccessTypeIndexUnion, 5, 5},
+		{"or below and", and(or(compare("age", datatype.OperatorEqual, int32(7)), compare("age", datatype.OperatorEqual, int32(9))),
+			compare("score", datatype.OperatorGreaterOrEqual, int32(250))), table.UnlimitedSize, table.AccessTypeIndexUnion, 10, 20},
+		{"or with an unindexed side", or(compare("age", datatype.OperatorEqual, int32(7)), compare("score", datatype.OperatorEqual, int32(1))),
+			table.UnlimitedSize, table.AccessTypeAll, 11, 500},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := db.Tables["people"].Select(table.SelectCommand{
+				TableName:  "people",
+				Expression: &test.expression,
+				Limit:      test.limit,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.AccessType != test.accessType || len(result.Rows) != test.rows || result.RowsInspected != test.inspected {
+				t.Errorf("Expected %d rows, %d inspected with %s, got %d rows, %d inspected with %s", test.rows,
+					test.inspected, test.accessType, len(result.Rows), result.RowsInspected, result.AccessType)
+			}
+			e := evaluator.SimpleEvaluator{}
+			for _, row := range result.Rows {
+				if !e.Eval(test.expression, row.Record) {
+					t.Errorf("Row %v does not match", row.Record)
+				}
+			}
+		})
+	}
+}
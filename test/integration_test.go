@@ -6,7 +6,6 @@ import (
 	"os"
 	"runtime/pprof"
 	"simple-database/internal/commandhandler"
-	"simple-database/internal/engine/table"
 	"simple-database/internal/platform/helper"
 	"testing"
 	"time"
@@ -74,14 +73,18 @@ func TestRunAll(t *testing.T) {
 			fmt.Printf("Select age\n")
 			start := time.Now()
 			sql := fmt.Sprintf("SELECT * FROM users WHERE age <= INT32(129) LIMIT 10000000")
-			resultSet, e := handler.Execute(sql)
+			rows, e := handler.Execute(sql)
+			if e != nil {
+				log.Fatal(e)
+			}
+			resultSet, e := rows.(*commandhandler.Rows).Collect()
 			if e != nil {
 				log.Fatal(e)
 			}
 
 			elapsed := time.Since(start)
-			fmt.Printf("Select age value %d: %s for %v\n", i, elapsed, resultSet.(*table.SelectResult))
-			for idx, result := range resultSet.(*table.SelectResult).Rows {
+			fmt.Printf("Select age value %d: %s for %v\n", i, elapsed, resultSet)
+			for idx, result := range resultSet.Rows {
 				fmt.Printf("%d: %v\n", idx, result)
 			}
 		}
@@ -92,14 +95,18 @@ func TestRunAll(t *testing.T) {
 			fmt.Printf("Select record\n")
 			start := time.Now()
 			sql := fmt.Sprintf("SELECT * FROM users WHERE record <= INT32(129) LIMIT 10000000")
-			resultSet, e := handler.Execute(sql)
+			rows, e := handler.Execute(sql)
+			if e != nil {
+				log.Fatal(e)
+			}
+			resultSet, e := rows.(*commandhandler.Rows).Collect()
 			if e != nil {
 				log.Fatal(e)
 			}
 
 			elapsed := time.Since(start)
-			fmt.Printf("Select age value %d: %s for %v\n", i, elapsed, resultSet.(*table.SelectResult))
-			for idx, result := range resultSet.(*table.SelectResult).Rows {
+			fmt.Printf("Select age value %d: %s for %v\n", i, elapsed, resultSet)
+			for idx, result := range resultSet.Rows {
 				fmt.Printf("%d: %v\n", idx, result)
 			}
 		}
@@ -0,0 +1,206 @@
+package test
+
+import (
+	"fmt"
+	"simple-database/internal/engine/table"
+	"simple-database/internal/engine/table/column"
+	"simple-database/internal/platform/datatype"
+	"simple-database/internal/platform/evaluator"
+	"slices"
+	"testing"
+)
+
+func TestJoin(t *testing.T) {
+	db := openDatabase(t, "join_test")
+	// Users 1 to 10, users 6 to 10 have no orders. Orders 31 and 32 belong to no user
+	createTable(t, db, "users", []fixtureColumn{
+		{"id", datatype.TypeInt64, column.PrimaryKey}, {"name", datatype.TypeString, column.Normal},
+		{"city", datatype.TypeString, column.Normal},
+	}, 1, 10, func(i int64) map[string]any {
+		return map[string]any{"id": i, "name": fmt.Sprintf("user %d", i), "city": []string{"Paris", "Oslo"}[i%2]}
+	})
+	createTable(t, db, "orders", []fixtureColumn{
+		{"id", datatype.TypeInt64, column.PrimaryKey}, {"user_id", datatype.TypeInt64, column.Normal},
+		{"amount", datatype.TypeInt64, column.Normal},
+	}, 1, 32, func(i int64) map[string]any {
+		user := i%5 + 1
+		if i > 30 {
+			user = 99
+		}
+		return map[string]any{"id": i, "user_id": user, "amount": i * 10}
+	})
+	on := func(left, right string) *evaluator.Expression {
+		return &evaluator.Expression{Left: left, Op: datatype.OperatorEqual, Right: right}
+	}
+	selectJoin := func(command table.SelectCommand) *table.SelectResult {
+		t.Helper()
+		if command.Limit == 0 {
+			command.Limit = table.UnlimitedSize
+		}
+		if command.SelectColumns == nil {
+			command.SelectColumns = []string{"*"}
+		}
+		result, err := db.Select(command)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+	// expectJoin checks the number of rows and the strategy of the join, and that every row pairs an order with its user
+	expectJoin := func(result *table.SelectResult, rows int, strategy string) {
+		t.Helper()
+		if len(result.Rows) != rows || !slices.Equal(result.JoinStrategies, []string{strategy}) {
+			t.Fatalf("Expected %d rows joined by %s, got %d by %v", rows, strategy, len(result.Rows), result.JoinStrategies)
+		}
+		for _, row := range result.Rows {
+			user, order := row.Record["u.id"], row.Record["o.user_id"]
+			if user != nil && order != nil && user != order {
+				t.Fatalf("Expected the order of the user, got %v", row.Record)
+			}
+			if user != nil && row.Record["u.name"] != fmt.Sprintf("user %d", user) {
+				t.Fatalf("Expected the name of user %d, got %v", user, row.Record)
+			}
+		}
+	}
+
+	// 1. Without an index on the joined column the joined table is hashed
+	inner := selectJoin(table.SelectCommand{TableName: "users", TableAlias: "u",
+		Joins: []table.Join{{Type: table.JoinTypeInner, TableName: "orders", Alias: "o", On: on("u.id", "o.user_id")}}})
+	expectJoin(inner, 30, table.JoinStrategyHash)
+	if len(inner.Rows[0].Record) != 6 {
+		t.Errorf("Expected the 6 columns of both tables named after them, got %v", inner.Rows[0].Record)
+	}
+
+	// 2. An index of the joined table is looked up for every row
+	looked := selectJoin(table.SelectCommand{TableName: "orders", TableAlias: "o",
+		Joins: []table.Join{{Type: table.JoinTypeInner, TableName: "users", Alias: "u", On: on("o.user_id", "u.id")}}})
+	expectJoin(looked, 30, table.JoinStrategyIndexNestedLoop)
+
+	// 3. Outer joins keep the rows without a match, the columns of the other side are nil
+	left := selectJoin(table.SelectCommand{TableName: "users", TableAlias: "u",
+		Joins: []table.Join{{Type: table.JoinTypeLeft, TableName: "orders", Alias: "o", On: on("u.id", "o.user_id")}}})
+	expectJoin(left, 35, table.JoinStrategyHash)
+	alone := 0
+	for _, row := range left.Rows {
+		if row.Record["o.id"] == nil {
+			alone++
+			if row.Record["u.id"].(int64) <= 5 {
+				t.Errorf("Expected user %v to have orders", row.Record["u.id"])
+			}
+		}
+	}
+	if alone != 5 {
+		t.Errorf("Expected 5 users without orders, got %d", alone)
+	}
+	// Rows without a value sort first, also through runs on disk
+	db.Tables["users"].SetSortMemory(1024)
+	sorted := selectJoin(table.SelectCommand{TableName: "users", TableAlias: "u",
+		Joins:   []table.Join{{Type: table.JoinTypeLeft, TableName: "orders", Alias: "o", On: on("u.id", "o.user_id")}},
+		OrderBy: []table.OrderBy{{Column: "o.amount"}, {Column: "u.id"}}})
+	db.Tables["users"].SetSortMemory(table.DefaultSortMemory)
+	expectJoin(sorted, 35, table.JoinStrategyHash)
+	if sorted.SortType != table.SortTypeExternal || sorted.Rows[0].Record["u.id"] != int64(6) || sorted.Rows[4].Record["o.amount"] != nil ||
+		sorted.Rows[5].Record["o.amount"] != int64(10) {
+		t.Errorf("Expected users 6 to 10 then order 1 sorted by %s, got %v then %v by %s", table.SortTypeExternal,
+			sorted.Rows[0].Record, sorted.Rows[5].Record, sorted.SortType)
+	}
+	right := selectJoin(table.SelectCommand{TableName: "users", TableAlias: "u",
+		Joins: []table.Join{{Type: table.JoinTypeRight, TableName: "orders", Alias: "o", On: on("u.id", "o.user_id")}}})
+	expectJoin(right, 32, table.JoinStrategyHash)
+	rightLooked := selectJoin(table.SelectCommand{TableName: "orders", TableAlias: "o",
+		Joins: []table.Join{{Type: table.JoinTypeRight, TableName: "users", Alias: "u", On: on("o.user_id", "u.id")}}})
+	expectJoin(rightLooked, 35, table.JoinStrategyIndexNestedLoop)
+
+	// 4. ON without = reads the joined table again for every row
+	before := &evaluator.Expression{Left: "o.user_id", Op: datatype.OperatorLess, Right: "u.id"}
+	nested := selectJoin(table.SelectCommand{TableName: "users", TableAlias: "u",
+		Joins: []table.Join{{Type: table.JoinTypeInner, TableName: "orders", Alias: "o", On: before}}})
+	// User u is joined to the 6 orders of each user below it, only users 1 to 5 have orders
+	expected := 0
+	for u := 1; u <= 10; u++ {
+		expected += 6 * min(u-1, 5)
+	}
+	if len(nested.Rows) != expected || !slices.Equal(nested.JoinStrategies, []string{table.JoinStrategyNestedLoop}) {
+		t.Errorf("Expected %d rows joined by %s, got %d by %v", expected, table.JoinStrategyNestedLoop, len(nested.Rows), nested.JoinStrategies)
+	}
+
+	// 5. WHERE filters the joined rows, columns of a single table are named alone
+	paris := selectJoin(table.SelectCommand{TableName: "users", TableAlias: "u",
+		SelectColumns: []string{"name", "amount"},
+		Expression:    &evaluator.Expression{Left: "city", Op: datatype.OperatorEqual, Right: "Paris"},
+		Joins:         []table.Join{{Type: table.JoinTypeInner, TableName: "orders", Alias: "o", On: on("u.id", "o.user_id")}},
+		OrderBy:       []table.OrderBy{{Column: "amount", Descending: true}},
+		Limit:         3})
+	// Users 2 and 4 live in Paris, their last orders are 28, 26 and 23
+	if len(paris.Rows) != 3 || paris.Rows[0].Record["amount"] != int64(280) || paris.Rows[2].Record["amount"] != int64(230) ||
+		len(paris.Rows[0].Record) != 2 {
+		t.Errorf("Expected the 3 largest orders of Paris, got %v", paris.Rows)
+	}
+
+	// 6. Joined rows are grouped, aggregates skip the columns without values
+	count := table.Aggregate{Function: table.AggregateCount, Column: "o.id"}
+	sum := table.Aggregate{Function: table.AggregateSum, Column: "o.amount"}
+	grouped := selectJoin(table.SelectCommand{TableName: "users", TableAlias: "u",
+		SelectColumns: []string{"u.id"}, GroupBy: []string{"u.id"}, Aggregates: []table.Aggregate{count, sum},
+		Joins:   []table.Join{{Type: table.JoinTypeLeft, TableName: "orders", Alias: "o", On: on("u.id", "o.user_id")}},
+		OrderBy: []table.OrderBy{{Column: "u.id"}}})
+	if len(grouped.Rows) != 10 {
+		t.Fatalf("Expected 10 users, got %d", len(grouped.Rows))
+	}
+	for i, row := range grouped.Rows {
+		orders, total := int64(0), any(nil)
+		if i < 5 {
+			// User i + 1 has orders i + 5k for k from 0 to 5, or 5 to 30 for user 1
+			first := int64(i)
+			if first == 0 {
+				first = 5
+			}
+			orders, total = 6, (6*first+75)*10
+		}
+		if row.Record["u.id"] != int64(i+1) || row.Record[count.Name()] != orders || row.Record[sum.Name()] != total {
+			t.Errorf("Expected %d orders worth %v for user %d, got %v", orders, total, i+1, row.Record)
+		}
+	}
+
+	// 7. A table joined to itself needs an alias
+	self := selectJoin(table.SelectCommand{TableName: "users", TableAlias: "a", SelectColumns: []string{"a.id", "b.id"},
+		Joins: []table.Join{{Type: table.JoinTypeInner, TableName: "users", Alias: "b", On: on("a.city", "b.city")}}})
+	if len(self.Rows) != 50 {
+		t.Errorf("Expected 50 pairs of users of the same city, got %d", len(self.Rows))
+	}
+
+	// 8. Joins are chained, each choosing its strategy
+	chained := selectJoin(table.SelectCommand{TableName: "users", TableAlias: "u", SelectColumns: []string{"u.id", "v.name"},
+		Joins: []table.Join{
+			{Type: table.JoinTypeLeft, TableName: "orders", Alias: "o", On: on("u.id", "o.user_id")},
+			{Type: table.JoinTypeInner, TableName: "users", Alias: "v", On: on("v.id", "o.user_id")},
+		}})
+	if len(chained.Rows) != 30 || !slices.Equal(chained.JoinStrategies, []string{table.JoinStrategyHash, table.JoinStrategyIndexNestedLoop}) {
+		t.Errorf("Expected 30 rows joined by Hash then IndexNestedLoop, got %d by %v", len(chained.Rows), chained.JoinStrategies)
+	}
+	for _, row := range chained.Rows {
+		if row.Record["v.name"] != fmt.Sprintf("user %d", row.Record["u.id"]) {
+			t.Fatalf("Expected the user joined to itself through its orders, got %v", row.Record)
+		}
+	}
+
+	// 9. Ambiguous and unknown columns and tables are rejected
+	invalid := []table.SelectCommand{
+		{TableName: "users", SelectColumns: []string{"id"},
+			Joins: []table.Join{{Type: table.JoinTypeInner, TableName: "orders", On: on("users.id", "orders.user_id")}}},
+		{TableName: "users", SelectColumns: []string{"*"},
+			Joins: []table.Join{{Type: table.JoinTypeInner, TableName: "users", On: on("users.id", "users.id")}}},
+		{TableName: "users", SelectColumns: []string{"*"},
+			Joins: []table.Join{{Type: table.JoinTypeInner, TableName: "payments", On: on("users.id", "payments.user_id")}}},
+		{TableName: "users", SelectColumns: []string{"*"},
+			Joins: []table.Join{{Type: table.JoinTypeInner, TableName: "orders", On: on("users.id", "orders.customer")}}},
+		{TableName: "users", SelectColumns: []string{"*"},
+			Joins: []table.Join{{Type: "FULL", TableName: "orders", On: on("users.id", "orders.user_id")}}},
+	}
+	for _, command := range invalid {
+		command.Limit = table.UnlimitedSize
+		if _, err := db.Select(command); err == nil {
+			t.Errorf("Expected an error for %+v", command)
+		}
+	}
+}
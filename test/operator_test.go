@@ -0,0 +1,214 @@
+package test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"simple-database/internal/engine"
+	"simple-database/internal/engine/table"
+	"simple-database/internal/engine/table/column"
+	"simple-database/internal/platform/datatype"
+	"simple-database/internal/platform/evaluator"
+	"testing"
+)
+
+func TestOperators(t *testing.T) {
+	_ = os.RemoveAll("data/operator_test")
+	db, err := engine.NewDatabase("operator_test")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	id, _ := column.NewColumn("id", datatype.TypeInt64, column.PrimaryKey)
+	grp, _ := column.NewColumn("grp", datatype.TypeInt32, column.Normal)
+	name, _ := column.NewColumn("name", datatype.TypeString, column.Normal)
+	_, err = db.CreateTable(engine.CreateTableCommand{
+		TableName: "rows",
+		Columns:   table.Columns{"id": id, "grp": grp, "name": name},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	groupId, _ := column.NewColumn("id", datatype.TypeInt32, column.PrimaryKey)
+	label, _ := column.NewColumn("label", datatype.TypeString, column.Normal)
+	_, err = db.CreateTable(engine.CreateTableCommand{
+		TableName: "groups",
+		Columns:   table.Columns{"id": groupId, "label": label},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := db.Tables["rows"]
+	for i := int64(1); i <= 1000; i++ {
+		record := map[string]any{"id": i, "grp": int32(i % 10), "name": fmt.Sprintf("row %d", i)}
+		if _, err = rows.Insert(table.InsertCommand{TableName: "rows", Record: record}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := int32(0); i < 5; i++ {
+		record := map[string]any{"id": i, "label": fmt.Sprintf("group %d", i)}
+		if _, err = db.Tables["groups"].Insert(table.InsertCommand{TableName: "groups", Record: record}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// pull opens the operators of command and returns its first n rows, or all of them when n is 0, and its result
+	// once they are closed
+	pull := func(command table.SelectCommand, n int) ([]map[string]any, *table.SelectResult) {
+		t.Helper()
+		if command.TableName == "" {
+			command.TableName = "rows"
+		}
+		if command.Limit == 0 {
+			command.Limit = table.UnlimitedSize
+		}
+		op, result, err := db.Query(command)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = op.Open(); err != nil {
+			t.Fatal(err)
+		}
+		records := make([]map[string]any, 0)
+		for n == 0 || len(records) < n {
+			row, ok, err := op.Next()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !ok {
+				break
+			}
+			records = append(records, row.Record)
+		}
+		if err = op.Close(); err != nil {
+			t.Fatal(err)
+		}
+		return records, result
+	}
+
+	// 1. Records are read as rows are pulled, without a limit too
+	scanned, result := pull(table.SelectCommand{SelectColumns: []string{"*"}}, 5)
+	if len(scanned) != 5 || result.AccessType != table.AccessTypeAll || result.RowsInspected != 5 {
+		t.Fatalf("Expected 5 rows out of 5 records read, got %d rows out of %d read by %s", len(scanned), result.RowsInspected, result.AccessType)
+	}
+	filtered, result := pull(table.SelectCommand{SelectColumns: []string{"id"},
+		Expression: &evaluator.Expression{Left: "grp", Op: datatype.OperatorEqual, Right: int32(3)}}, 2)
+	if len(filtered) != 2 || filtered[1]["id"] != int64(13) || result.RowsInspected != 13 {
+		t.Fatalf("Expected rows 3 and 13 out of 13 records read, got %v out of %d", filtered, result.RowsInspected)
+	}
+	indexed, result := pull(table.SelectCommand{SelectColumns: []string{"id", "name"},
+		Expression: &evaluator.Expression{Left: "id", Op: datatype.OperatorGreater, Right: int64(500)}}, 3)
+	if len(indexed) != 3 || indexed[0]["id"] != int64(501) || result.AccessType != table.AccessTypeIndex || result.RowsInspected != 3 {
+		t.Fatalf("Expected rows from 501 found through the index, got %v out of %d read by %s", indexed, result.RowsInspected, result.AccessType)
+	}
+
+	// 2. A sort reads every row before returning the first one, its runs are removed once it is closed even when
+	// its rows were not all pulled
+	rows.SetSortMemory(4096)
+	orderBy := []table.OrderBy{{Column: "name", Descending: true}}
+	sorted, result := pull(table.SelectCommand{SelectColumns: []string{"id", "name"}, OrderBy: orderBy}, 0)
+	if len(sorted) != 1000 || result.SortType != table.SortTypeExternal || result.RowsInspected != 1000 {
+		t.Fatalf("Expected 1000 rows sorted externally, got %d sorted by %s", len(sorted), result.SortType)
+	}
+	for i := 1; i < len(sorted); i++ {
+		if sorted[i-1]["name"].(string) < sorted[i]["name"].(string) {
+			t.Fatalf("Expected %v before %v", sorted[i], sorted[i-1])
+		}
+	}
+	if first, _ := pull(table.SelectCommand{SelectColumns: []string{"id", "name"}, OrderBy: orderBy}, 1); first[0]["id"] != int64(999) {
+		t.Errorf("Expected row 999 first, got %v", first[0])
+	}
+	if runs, _ := filepath.Glob("data/operator_test/*_sort_*.tmp"); len(runs) != 0 {
+		t.Errorf("Expected the runs to be removed, found %v", runs)
+	}
+	rows.SetSortMemory(table.DefaultSortMemory)
+
+	// 3. Groups are returned once their rows were read, how they were computed is known once they are closed
+	count := table.Aggregate{Function: table.AggregateCount}
+	groups, result := pull(table.SelectCommand{SelectColumns: []string{"grp"}, GroupBy: []string{"grp"},
+		Aggregates: []table.Aggregate{count}, OrderBy: []table.OrderBy{{Column: "grp"}}}, 0)
+	if len(groups) != 10 || result.GroupType != table.GroupTypeHash || groups[9]["grp"] != int32(9) || groups[9][count.Name()] != int64(100) {
+		t.Fatalf("Expected 10 groups of 100 rows computed in a hash table, got %v by %s", groups, result.GroupType)
+	}
+
+	// 4. A join returns the joined rows of each row as it is read, LEFT returns the rows matching none in turn. Rows
+	// of groups 5 to 9 find no row of groups
+	joined, result := pull(table.SelectCommand{SelectColumns: []string{"rows.id", "label"}, TableName: "rows",
+		Joins: []table.Join{{Type: table.JoinTypeLeft, TableName: "groups",
+			On: &evaluator.Expression{Left: "grp", Op: datatype.OperatorEqual, Right: "groups.id"}}}}, 10)
+	if len(joined) != 10 || result.RowsInspected != 10+5 || result.JoinStrategies[0] != table.JoinStrategyIndexNestedLoop {
+		t.Fatalf("Expected 10 rows joined through the index, got %d out of %d records read by %v", len(joined), result.RowsInspected,
+			result.JoinStrategies)
+	}
+	for _, row := range joined {
+		i := row["rows.id"].(int64)
+		if want := fmt.Sprintf("group %d", i%10); i%10 < 5 && row["label"] != want || i%10 >= 5 && row["label"] != nil {
+			t.Errorf("Expected the label of row %d, got %v", i, row)
+		}
+	}
+
+	// 5. Selects return the rows of their operators
+	commands := []table.SelectCommand{
+		{SelectColumns: []string{"grp", "name"}, Expression: &evaluator.Expression{Left: "id", Op: datatype.OperatorLessOrEqual, Right: int64(50)},
+			OrderBy: []table.OrderBy{{Column: "grp"}, {Column: "name"}}, Limit: 20},
+		{SelectColumns: []string{"grp"}, GroupBy: []string{"grp"}, Aggregates: []table.Aggregate{count},
+			Having: &evaluator.Expression{Left: "grp", Op: datatype.OperatorGreater, Right: int32(6)}},
+	}
+	for _, command := range commands {
+		command.TableName = "rows"
+		if command.Limit == 0 {
+			command.Limit = table.UnlimitedSize
+		}
+		selected, err := rows.Select(command)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pulled, _ := pull(command, 0)
+		if len(pulled) != len(selected.Rows) {
+			t.Fatalf("Expected %d rows, got %d", len(selected.Rows), len(pulled))
+		}
+		for i, row := range selected.Rows {
+			if fmt.Sprint(row.Record) != fmt.Sprint(pulled[i]) {
+				t.Errorf("Expected %v at %d, got %v", row.Record, i, pulled[i])
+			}
+		}
+	}
+}
+
+func TestIndexScanLimit(t *testing.T) {
+	_ = os.RemoveAll("data/index_scan_test")
+
+	db, err := engine.NewDatabase("index_scan_test")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	id, _ := column.NewColumn("id", datatype.TypeInt64, column.PrimaryKey)
+	users, err := db.CreateTable(engine.CreateTableCommand{TableName: "users", Columns: table.Columns{"id": id}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := int64(0); i < 500; i++ {
+		if _, err = users.Insert(table.InsertCommand{TableName: "users", Record: map[string]any{"id": i}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Only the rows up to the limit are read, in key order
+	result, err := users.Select(table.SelectCommand{
+		TableName:  "users",
+		Expression: &evaluator.Expression{Left: "id", Op: datatype.OperatorGreaterOrEqual, Right: int64(100)},
+		Limit:      5,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.AccessType != table.AccessTypeIndex || result.RowsInspected != 5 || len(result.Rows) != 5 {
+		t.Fatalf("Expected 5 rows read through the index, got %s with %d inspected and %d rows", result.AccessType, result.RowsInspected, len(result.Rows))
+	}
+	for i, row := range result.Rows {
+		if row.Record["id"] != int64(100+i) {
+			t.Errorf("Expected row %d to have id %d, got %v", i, 100+i, row.Record["id"])
+		}
+	}
+}
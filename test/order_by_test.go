@@ -0,0 +1,131 @@
+package test
+
+import (
+	"fmt"
+	"path/filepath"
+	"simple-database/internal/engine"
+	"simple-database/internal/engine/table"
+	"simple-database/internal/engine/table/column"
+	"simple-database/internal/platform/datatype"
+	"simple-database/internal/platform/evaluator"
+	"testing"
+)
+
+func TestOrderBy(t *testing.T) {
+	db := openDatabase(t, "order_by_test")
+	emp := createTable(t, db, "emp", []fixtureColumn{
+		{"id", datatype.TypeInt64, column.PrimaryKey}, {"dept", datatype.TypeInt32, column.Normal},
+		{"salary", datatype.TypeInt64, column.Normal}, {"name", datatype.TypeString, column.Normal},
+	}, 0, 499, func(i int64) map[string]any {
+		return map[string]any{"id": i, "dept": int32(i % 7), "salary": i * 37 % 101, "name": fmt.Sprintf("employee %d", i)}
+	})
+	err := db.CreateIndex(engine.CreateIndexCommand{IndexName: "emp_dept_salary", TableName: "emp",
+		ColumnNames: []string{"dept", "salary"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deptEqual := &evaluator.Expression{Left: "dept", Op: datatype.OperatorEqual, Right: int32(3)}
+	selectOrdered := func(columns []string, expression *evaluator.Expression, limit uint32, orderBy ...table.OrderBy) *table.SelectResult {
+		t.Helper()
+		result, err := emp.Select(table.SelectCommand{TableName: "emp", SelectColumns: columns, Expression: expression,
+			Limit: limit, OrderBy: orderBy})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+	// expectOrder checks the number of rows, how they were sorted and that every row comes before the next one
+	expectOrder := func(result *table.SelectResult, rows int, sortType string, before func(a, b map[string]any) bool) {
+		t.Helper()
+		if len(result.Rows) != rows || result.SortType != sortType {
+			t.Fatalf("Expected %d rows sorted by %s, got %d sorted by %s", rows, sortType, len(result.Rows), result.SortType)
+		}
+		for i := 1; i < len(result.Rows); i++ {
+			if !before(result.Rows[i-1].Record, result.Rows[i].Record) {
+				t.Fatalf("Row %v comes before %v", result.Rows[i-1].Record, result.Rows[i].Record)
+			}
+		}
+	}
+	salaryDescThenId := func(a, b map[string]any) bool {
+		if a["salary"] != b["salary"] {
+			return a["salary"].(int64) > b["salary"].(int64)
+		}
+		return a["id"].(int64) < b["id"].(int64)
+	}
+	salaryOrderBy := []table.OrderBy{{Column: "salary", Descending: true}, {Column: "id"}}
+
+	// 1. Rows that fit in memory are sorted there, on several columns
+	inMemory := selectOrdered([]string{"*"}, nil, table.UnlimitedSize, salaryOrderBy...)
+	expectOrder(inMemory, 500, table.SortTypeMemory, salaryDescThenId)
+	if inMemory.AccessType != table.AccessTypeAll {
+		t.Errorf("Expected %s, got %s", table.AccessTypeAll, inMemory.AccessType)
+	}
+
+	// 2. Past the sort memory of the database rows are sorted in runs written to disk, merged into the same order
+	db.SetSortMemory(4096)
+	external := selectOrdered([]string{"*"}, nil, table.UnlimitedSize, salaryOrderBy...)
+	expectOrder(external, 500, table.SortTypeExternal, salaryDescThenId)
+	for i := range external.Rows {
+		if external.Rows[i].Record["id"] != inMemory.Rows[i].Record["id"] || external.Rows[i].Record["name"] != inMemory.Rows[i].Record["name"] {
+			t.Fatalf("Expected row %d to be %v, got %v", i, inMemory.Rows[i].Record, external.Rows[i].Record)
+		}
+	}
+	limited := selectOrdered([]string{"*"}, nil, 10, salaryOrderBy...)
+	expectOrder(limited, 10, table.SortTypeExternal, salaryDescThenId)
+	if runs, _ := filepath.Glob("data/order_by_test/*_sort_*.tmp"); len(runs) != 0 {
+		t.Errorf("Expected the runs to be removed, got %v", runs)
+	}
+	db.SetSortMemory(table.DefaultSortMemory)
+
+	// 3. An index whose items come in the order of the select is read instead of sorting, up to the limit
+	bySalary := func(a, b map[string]any) bool {
+		return a["dept"] == int32(3) && b["dept"] == int32(3) && a["salary"].(int64) <= b["salary"].(int64)
+	}
+	indexed := selectOrdered([]string{"*"}, deptEqual, 5, table.OrderBy{Column: "salary"})
+	expectOrder(indexed, 5, table.SortTypeIndex, bySalary)
+	if indexed.AccessType != table.AccessTypeIndex || indexed.RowsInspected != 5 {
+		t.Errorf("Expected 5 rows inspected through the index, got %d with %s", indexed.RowsInspected, indexed.AccessType)
+	}
+	// Columns matched with = may be sorted on anywhere
+	indexed = selectOrdered([]string{"*"}, deptEqual, table.UnlimitedSize, table.OrderBy{Column: "salary"},
+		table.OrderBy{Column: "dept"}, table.OrderBy{Column: "id"})
+	expectOrder(indexed, 71, table.SortTypeIndex, bySalary)
+
+	// 4. Without a where clause the whole index is read when the select has a limit
+	byDeptAndSalary := func(a, b map[string]any) bool {
+		if a["dept"] != b["dept"] {
+			return a["dept"].(int32) < b["dept"].(int32)
+		}
+		return a["salary"].(int64) <= b["salary"].(int64)
+	}
+	indexed = selectOrdered([]string{"*"}, nil, 10, table.OrderBy{Column: "dept"}, table.OrderBy{Column: "salary"})
+	expectOrder(indexed, 10, table.SortTypeIndex, byDeptAndSalary)
+	if indexed.RowsInspected != 10 {
+		t.Errorf("Expected 10 rows inspected, got %d", indexed.RowsInspected)
+	}
+	// or when it covers the select
+	covered := selectOrdered([]string{"id", "dept", "salary"}, nil, table.UnlimitedSize, table.OrderBy{Column: "dept"},
+		table.OrderBy{Column: "salary"})
+	expectOrder(covered, 500, table.SortTypeIndex, byDeptAndSalary)
+	if covered.AccessType != table.AccessTypeIndexOnly {
+		t.Errorf("Expected %s, got %s", table.AccessTypeIndexOnly, covered.AccessType)
+	}
+	// and the table is read and sorted otherwise
+	sorted := selectOrdered([]string{"*"}, nil, table.UnlimitedSize, table.OrderBy{Column: "dept"}, table.OrderBy{Column: "salary"})
+	expectOrder(sorted, 500, table.SortTypeMemory, byDeptAndSalary)
+
+	// 5. An order the index does not give is sorted
+	sorted = selectOrdered([]string{"*"}, deptEqual, table.UnlimitedSize, table.OrderBy{Column: "salary", Descending: true})
+	expectOrder(sorted, 71, table.SortTypeMemory, func(a, b map[string]any) bool { return bySalary(b, a) })
+	if sorted.AccessType != table.AccessTypeIndex {
+		t.Errorf("Expected %s, got %s", table.AccessTypeIndex, sorted.AccessType)
+	}
+
+	// 6. Unknown columns are rejected
+	_, err = emp.Select(table.SelectCommand{TableName: "emp", SelectColumns: []string{"*"}, Limit: table.UnlimitedSize,
+		OrderBy: []table.OrderBy{{Column: "missing"}}})
+	if err == nil {
+		t.Error("Expected an error for an unknown column")
+	}
+}
@@ -0,0 +1,196 @@
+package test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"simple-database/internal/engine"
+	"simple-database/internal/engine/table"
+	"simple-database/internal/engine/table/btree"
+	"simple-database/internal/engine/table/column"
+	"simple-database/internal/platform/datatype"
+	"simple-database/internal/platform/evaluator"
+	"strings"
+	"testing"
+)
+
+func TestOverflow(t *testing.T) {
+	_ = os.RemoveAll("data/overflow_test")
+
+	db, err := engine.NewDatabase("overflow_test")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	id, _ := column.NewColumn("id", datatype.TypeInt64, column.PrimaryKey)
+	title, _ := column.NewColumn("title", datatype.TypeString, column.UsingIndex)
+	description, _ := column.NewColumn("description", datatype.TypeString, column.Normal)
+	books, err := db.CreateTable(engine.CreateTableCommand{
+		TableName: "books",
+		Columns:   table.Columns{"id": id, "title": title, "description": description},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Titles are large enough for index nodes to need overflow pages, descriptions span several heap pages
+	titleOf := func(i int64) string {
+		return fmt.Sprintf("%04d", i) + strings.Repeat("t", 600)
+	}
+	descriptionOf := func(i int64, c string) string {
+		return strings.Repeat(c, 10000+int(i))
+	}
+	insert := func(i int64) {
+		record := map[string]any{"id": i, "title": titleOf(i), "description": descriptionOf(i, "d")}
+		if _, err := books.Insert(table.InsertCommand{TableName: "books", Record: record}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	find := func(books *table.Table, i int64) *table.SelectResult {
+		result, err := books.Select(table.SelectCommand{
+			TableName:  "books",
+			Expression: &evaluator.Expression{Left: "title", Op: datatype.OperatorEqual, Right: titleOf(i)},
+			Limit:      table.UnlimitedSize,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+	fileSize := func() int64 {
+		stat, err := os.Stat(filepath.Join("data", "overflow_test", "books"+table.FileExtension))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return stat.Size()
+	}
+
+	for i := int64(0); i < 100; i++ {
+		insert(i)
+	}
+
+	// 1. Large values are read back whole, through the index and through a full scan
+	for _, i := range []int64{0, 42, 99} {
+		result := find(books, i)
+		if len(result.Rows) != 1 {
+			t.Fatalf("Expected 1 row for title %d, got %d", i, len(result.Rows))
+		}
+		if result.Rows[0].Record["description"] != descriptionOf(i, "d") {
+			t.Errorf("Expected the description of row %d to be read back", i)
+		}
+	}
+	result, err := books.Select(table.SelectCommand{
+		TableName:  "books",
+		Expression: &evaluator.Expression{Left: "description", Op: datatype.OperatorEqual, Right: descriptionOf(7, "d")},
+		Limit:      table.UnlimitedSize,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Rows) != 1 || result.Rows[0].Record["id"] != int64(7) {
+		t.Errorf("Expected row 7 through a full scan, got %v rows", len(result.Rows))
+	}
+
+	// 2. Pages of deleted and updated values are reused
+	size := fileSize()
+	_, err = books.Delete(table.DeleteCommand{
+		TableName:  "books",
+		Expression: &evaluator.Expression{Left: "id", Op: datatype.OperatorLess, Right: int64(50)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := int64(0); i < 50; i++ {
+		insert(i)
+	}
+	_, err = books.Update(table.UpdateCommand{
+		TableName:  "books",
+		Expression: &evaluator.Expression{Left: "id", Op: datatype.OperatorGreaterOrEqual, Right: int64(90)},
+		Record:     map[string]any{"description": descriptionOf(0, "u")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fileSize() != size {
+		t.Errorf("Expected file size to stay %d, got %d", size, fileSize())
+	}
+	if result := find(books, 95); len(result.Rows) != 1 || result.Rows[0].Record["description"] != descriptionOf(0, "u") {
+		t.Errorf("Expected row 95 to be updated")
+	}
+
+	// 3. Values survive a vacuum and a restart
+	if _, err = db.Vacuum(engine.VacuumCommand{TableName: "books"}); err != nil {
+		t.Fatal(err)
+	}
+	if err = db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	db, err = engine.NewDatabase("overflow_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, i := range []int64{3, 60, 95} {
+		result := find(db.Tables["books"], i)
+		if len(result.Rows) != 1 {
+			t.Fatalf("Expected 1 row for title %d, got %d", i, len(result.Rows))
+		}
+	}
+	if err = db.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBTreeOverflow(t *testing.T) {
+	_ = os.RemoveAll("data/btree_overflow")
+	_ = os.MkdirAll("data/btree_overflow", 0777)
+	b, err := btree.Open("data/btree_overflow/tree")
+	if err != nil {
+		t.Fatalf("Failed to open btree: %v", err)
+	}
+
+	key := func(i int) []byte {
+		return []byte(fmt.Sprintf("%04d", i) + strings.Repeat("k", 5000))
+	}
+	for i := 0; i < 200; i++ {
+		if err := b.Insert(key(i), []byte(strings.Repeat("v", i))); err != nil {
+			t.Fatalf("Insert failed at index %d: %v", i, err)
+		}
+	}
+	for i := 0; i < 200; i += 10 {
+		if err := b.Remove(key(i)); err != nil {
+			t.Fatalf("Remove failed at index %d: %v", i, err)
+		}
+	}
+	if err = b.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err = btree.Open("data/btree_overflow/tree")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	size, err := b.Size()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size != 180 {
+		t.Errorf("Expected size to be 180, got %d", size)
+	}
+	k, found, err := b.Get(key(123))
+	if err != nil || !found {
+		t.Fatalf("Expected key 123 to be found: %v", err)
+	}
+	if string(k.V) != strings.Repeat("v", 123) {
+		t.Errorf("Expected the value of key 123 to be read back, got %d bytes", len(k.V))
+	}
+	if _, found, _ = b.Get(key(120)); found {
+		t.Error("Expected key 120 to be removed")
+	}
+	greater, err := b.GreaterThan(key(189))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(greater) != 9 {
+		t.Errorf("Expected 9 keys greater than 189, got %d", len(greater))
+	}
+}
@@ -0,0 +1,69 @@
+package test
+
+import (
+	"bytes"
+	"simple-database/internal/engine/table/page"
+	"testing"
+)
+
+func TestSlottedPage(t *testing.T) {
+	p := page.New(3)
+	if p.Offset() != 3*page.Size {
+		t.Errorf("Expected offset %d, got %d", 3*page.Size, p.Offset())
+	}
+
+	// 1. Records are added until the page is full
+	record := bytes.Repeat([]byte{'a'}, 100)
+	slots := make([]uint16, 0)
+	for {
+		slot, ok := p.Insert(record)
+		if !ok {
+			break
+		}
+		slots = append(slots, slot)
+	}
+	if len(slots) != (page.Size-page.HeaderSize-page.ChecksumSize)/(100+page.SlotSize) {
+		t.Errorf("Expected %d records, got %d", (page.Size-page.HeaderSize-page.ChecksumSize)/(100+page.SlotSize), len(slots))
+	}
+
+	// 2. A deleted slot is reused and the records around it keep their slot
+	if !p.Delete(slots[5]) {
+		t.Fatal("Expected slot 5 to be deleted")
+	}
+	if _, ok := p.Record(slots[5]); ok {
+		t.Error("Expected slot 5 to be empty")
+	}
+	slot, ok := p.Insert(bytes.Repeat([]byte{'b'}, 100))
+	if !ok || slot != slots[5] {
+		t.Errorf("Expected slot %d to be reused, got %d (%v)", slots[5], slot, ok)
+	}
+
+	// 3. A record grows in its slot with the room left by others, moving records inside the page
+	p.Delete(slots[1])
+	p.Delete(slots[2])
+	grown := bytes.Repeat([]byte{'c'}, 250)
+	if !p.Update(slots[3], grown) {
+		t.Fatal("Expected the record to grow in place")
+	}
+	if r, _ := p.Record(slots[3]); !bytes.Equal(r, grown) {
+		t.Error("Expected the grown record to be read back")
+	}
+	if r, _ := p.Record(slots[5]); !bytes.Equal(r, bytes.Repeat([]byte{'b'}, 100)) {
+		t.Error("Expected the other records to be unchanged")
+	}
+	if p.Update(slots[4], bytes.Repeat([]byte{'d'}, page.Size)) {
+		t.Error("Expected a record larger than the page to be refused")
+	}
+
+	// 4. The content survives a round trip
+	loaded, err := page.Load(3, p.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.SlotCount() != p.SlotCount() || loaded.FreeSpace() != p.FreeSpace() {
+		t.Errorf("Expected %d slots and %d free bytes, got %d and %d", p.SlotCount(), p.FreeSpace(), loaded.SlotCount(), loaded.FreeSpace())
+	}
+	if _, err = page.Load(3, make([]byte, page.Size)); err == nil {
+		t.Error("Expected an error loading a page without a page type")
+	}
+}
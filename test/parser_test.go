@@ -1,8 +1,10 @@
 package test
 
 import (
+	"simple-database/internal/engine/table"
 	"simple-database/internal/parser"
 	"simple-database/internal/platform/datatype"
+	"simple-database/internal/platform/evaluator"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -68,6 +70,111 @@ func TestParseSelect(t *testing.T) {
 	}
 }
 
+func TestParseSelect_BetweenAndIn(t *testing.T) {
+	sql := "SELECT * FROM users WHERE age BETWEEN INT32(18) AND INT32(30) AND id IN (INT64(1), INT64(2), INT64(3))"
+
+	selectCommand, err := parser.ParseSelect(sql)
+	require.NoError(t, err)
+
+	if selectCommand.Expression.Op != datatype.OperatorAnd {
+		t.Fatalf("Expected And, got %s", selectCommand.Expression.Op)
+	}
+	between := selectCommand.Expression.Left.(*evaluator.Expression)
+	if between.Op != datatype.OperatorBetween || between.Left != "age" {
+		t.Errorf("Expected age Between, got %s %s", between.Left, between.Op)
+	}
+	require.Equal(t, []any{int32(18), int32(30)}, between.Right)
+	in := selectCommand.Expression.Right.(*evaluator.Expression)
+	if in.Op != datatype.OperatorIn || in.Left != "id" {
+		t.Errorf("Expected id In, got %s %s", in.Left, in.Op)
+	}
+	require.Equal(t, []any{int64(1), int64(2), int64(3)}, in.Right)
+}
+
+func TestParseSelect_OrderBy(t *testing.T) {
+	sql := "SELECT * FROM users WHERE age > INT32(18) ORDER BY age DESC, name ASC, id LIMIT 10"
+
+	selectCommand, err := parser.ParseSelect(sql)
+	require.NoError(t, err)
+
+	expected := []table.OrderBy{{Column: "age", Descending: true}, {Column: "name"}, {Column: "id"}}
+	require.Equal(t, expected, selectCommand.OrderBy)
+	if selectCommand.Limit != 10 {
+		t.Errorf("Expected 10, got %d", selectCommand.Limit)
+	}
+}
+
+func TestParseSelect_GroupBy(t *testing.T) {
+	sql := "SELECT dept, COUNT(*), AVG(FLOAT64(salary)) FROM emp GROUP BY dept HAVING COUNT(*) > INT64(5) ORDER BY MAX(salary) DESC"
+
+	selectCommand, err := parser.ParseSelect(sql)
+	require.NoError(t, err)
+
+	require.Equal(t, []string{"dept"}, selectCommand.SelectColumns)
+	require.Equal(t, []string{"dept"}, selectCommand.GroupBy)
+	expected := []table.Aggregate{
+		{Function: table.AggregateCount},
+		{Function: table.AggregateAvg, Column: "salary", Cast: datatype.TypeFloat64},
+		{Function: table.AggregateMax, Column: "salary"},
+	}
+	require.Equal(t, expected, selectCommand.Aggregates)
+	if selectCommand.Having.Left != "COUNT(*)" || selectCommand.Having.Op != datatype.OperatorGreater || selectCommand.Having.Right != int64(5) {
+		t.Errorf("Expected COUNT(*) > 5, got %v", selectCommand.Having)
+	}
+	require.Equal(t, []table.OrderBy{{Column: "MAX(salary)", Descending: true}}, selectCommand.OrderBy)
+}
+
+func TestParseSelect_Projection(t *testing.T) {
+	sql := "SELECT price * qty AS total, UPPER(name), id FROM items WHERE id > INT64(1) ORDER BY total DESC"
+
+	selectCommand, err := parser.ParseSelect(sql)
+	require.NoError(t, err)
+
+	require.Equal(t, []string{"price", "qty", "name", "id"}, selectCommand.SelectColumns)
+	expected := []table.Projection{
+		{Name: "total", Expression: &evaluator.Expression{Left: "price", Op: datatype.OperatorMultiply, Right: "qty"}},
+		{Name: "UPPER(name)", Expression: evaluator.Function{Name: "UPPER", Args: []any{"name"}}},
+		{Name: "id", Expression: "id"},
+	}
+	require.Equal(t, expected, selectCommand.Projections)
+	require.Equal(t, []table.OrderBy{{Column: "total", Descending: true}}, selectCommand.OrderBy)
+}
+
+func TestParseSelect_Join(t *testing.T) {
+	sql := "SELECT u.name, o.amount FROM users u JOIN orders AS o ON u.id = o.user_id LEFT OUTER JOIN payments ON payments.order_id = o.id WHERE o.amount > INT64(10)"
+
+	selectCommand, err := parser.ParseSelect(sql)
+	require.NoError(t, err)
+
+	require.Equal(t, "users", selectCommand.TableName)
+	require.Equal(t, "u", selectCommand.TableAlias)
+	require.Equal(t, []string{"u.name", "o.amount"}, selectCommand.SelectColumns)
+	expected := []table.Join{
+		{Type: table.JoinTypeInner, TableName: "orders", Alias: "o",
+			On: &evaluator.Expression{Left: "u.id", Op: datatype.OperatorEqual, Right: "o.user_id"}},
+		{Type: table.JoinTypeLeft, TableName: "payments",
+			On: &evaluator.Expression{Left: "payments.order_id", Op: datatype.OperatorEqual, Right: "o.id"}},
+	}
+	require.Equal(t, expected, selectCommand.Joins)
+	if selectCommand.Expression.Left != "o.amount" || selectCommand.Expression.Right != int64(10) {
+		t.Errorf("Expected o.amount > 10, got %v", selectCommand.Expression)
+	}
+}
+
+func TestParseDelete_NotEqual(t *testing.T) {
+	sql := "DELETE FROM users WHERE age != INT32(18)"
+
+	deleteCommand, err := parser.ParseDelete(sql)
+	require.NoError(t, err)
+
+	if deleteCommand.Expression.Op != datatype.OperatorNotEqual {
+		t.Errorf("Expected NotEqual, got %s", deleteCommand.Expression.Op)
+	}
+	if deleteCommand.Expression.Right != int32(18) {
+		t.Errorf("Expected 18, got %v", deleteCommand.Expression.Right)
+	}
+}
+
 func TestParseUpdate_WithWhere(t *testing.T) {
 	sql := "UPDATE age SET a = int32(1), b = int32(2), c = int32(3) WHERE id > int32(1) LIMIT 100"
 
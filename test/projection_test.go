@@ -0,0 +1,131 @@
+package test
+
+import (
+	"fmt"
+	"simple-database/internal/engine/table"
+	"simple-database/internal/engine/table/column"
+	"simple-database/internal/platform/datatype"
+	"simple-database/internal/platform/evaluator"
+	"strings"
+	"testing"
+)
+
+func TestProjection(t *testing.T) {
+	db := openDatabase(t, "projection_test")
+	items := createTable(t, db, "items", []fixtureColumn{
+		{"id", datatype.TypeInt64, column.PrimaryKey}, {"name", datatype.TypeString, column.Normal},
+		{"price", datatype.TypeInt64, column.Normal}, {"qty", datatype.TypeInt32, column.Normal},
+	}, 1, 100, func(i int64) map[string]any {
+		return map[string]any{"id": i, "name": fmt.Sprintf("item %d", i), "price": i * 10, "qty": int32(i % 5)}
+	})
+	selectItems := func(command table.SelectCommand) *table.SelectResult {
+		t.Helper()
+		command.TableName = "items"
+		if command.Limit == 0 {
+			command.Limit = table.UnlimitedSize
+		}
+		result, err := items.Select(command)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+	total := table.Projection{Name: "total", Expression: &evaluator.Expression{
+		Left:  "price",
+		Op:    datatype.OperatorMultiply,
+		Right: evaluator.Function{Name: "INT64", Args: []any{"qty"}},
+	}}
+
+	// 1. Rows only hold the selected columns
+	columns := selectItems(table.SelectCommand{SelectColumns: []string{"id", "name"}, Limit: 10})
+	for _, row := range columns.Rows {
+		if len(row.Record) != 2 || row.Record["name"] != fmt.Sprintf("item %d", row.Record["id"]) {
+			t.Fatalf("Expected the id and the name, got %v", row.Record)
+		}
+	}
+
+	// 2. Computed values are named by their projection
+	projections := []table.Projection{
+		{Name: "item", Expression: "id"},
+		total,
+		{Name: "UPPER(name)", Expression: evaluator.Function{Name: "UPPER", Args: []any{"name"}}},
+		{Name: "length", Expression: evaluator.Function{Name: "LENGTH", Args: []any{"name"}}},
+		{Name: "half", Expression: &evaluator.Expression{
+			Left:  evaluator.Function{Name: "FLOAT64", Args: []any{"price"}},
+			Op:    datatype.OperatorDivide,
+			Right: float64(20),
+		}},
+	}
+	computed := selectItems(table.SelectCommand{SelectColumns: []string{"id", "name", "price", "qty"}, Projections: projections,
+		Expression: &evaluator.Expression{Left: "id", Op: datatype.OperatorLessOrEqual, Right: int64(20)}})
+	if len(computed.Rows) != 20 {
+		t.Fatalf("Expected 20 rows, got %d", len(computed.Rows))
+	}
+	for _, row := range computed.Rows {
+		i := row.Record["item"].(int64)
+		itemName := fmt.Sprintf("item %d", i)
+		if len(row.Record) != 5 || row.Record["total"] != i*10*(i%5) || row.Record["UPPER(name)"] != strings.ToUpper(itemName) ||
+			row.Record["length"] != int64(len(itemName)) || row.Record["half"] != float64(i)/2 {
+			t.Errorf("Expected the values of item %d, got %v", i, row.Record)
+		}
+	}
+
+	// 3. ORDER BY may name a computed projection
+	sorted := selectItems(table.SelectCommand{SelectColumns: []string{"id", "price", "qty"},
+		Projections: []table.Projection{{Name: "id", Expression: "id"}, total},
+		OrderBy:     []table.OrderBy{{Column: "total", Descending: true}, {Column: "id"}}, Limit: 3})
+	if len(sorted.Rows) != 3 || sorted.SortType != table.SortTypeMemory {
+		t.Fatalf("Expected 3 rows sorted in memory, got %d by %s", len(sorted.Rows), sorted.SortType)
+	}
+	for i, want := range []int64{99, 94, 89} {
+		if len(sorted.Rows[i].Record) != 2 || sorted.Rows[i].Record["id"] != want {
+			t.Errorf("Expected item %d at %d, got %v", want, i, sorted.Rows[i].Record)
+		}
+	}
+
+	// 4. Aggregates of a grouped select can be renamed and computed on
+	count := table.Aggregate{Function: table.AggregateCount}
+	sum := table.Aggregate{Function: table.AggregateSum, Column: "price"}
+	grouped := selectItems(table.SelectCommand{SelectColumns: []string{"qty"}, GroupBy: []string{"qty"},
+		Aggregates: []table.Aggregate{count, sum},
+		Projections: []table.Projection{
+			{Name: "qty", Expression: "qty"},
+			{Name: "items", Expression: count.Name()},
+			{Name: "average", Expression: &evaluator.Expression{Left: sum.Name(), Op: datatype.OperatorDivide, Right: count.Name()}},
+		},
+		OrderBy: []table.OrderBy{{Column: "qty"}}})
+	if len(grouped.Rows) != 5 {
+		t.Fatalf("Expected 5 groups, got %d", len(grouped.Rows))
+	}
+	for i, row := range grouped.Rows {
+		// Items of quantity q are q, q + 5, ..., q + 95, or 5 to 100 for 0
+		first := int64(i)
+		if first == 0 {
+			first = 5
+		}
+		if len(row.Record) != 3 || row.Record["qty"] != int32(i) || row.Record["items"] != int64(20) ||
+			row.Record["average"] != (first+first+95)*10/2 {
+			t.Errorf("Expected the group of quantity %d, got %v", i, row.Record)
+		}
+	}
+
+	// 5. Unknown columns and functions are rejected
+	invalid := []table.SelectCommand{
+		{SelectColumns: []string{"id", "colour"}},
+		{SelectColumns: []string{"name"}, Projections: []table.Projection{{Name: "x", Expression: evaluator.Function{Name: "REVERSE", Args: []any{"name"}}}}},
+		{SelectColumns: []string{"name"}, Projections: []table.Projection{{Name: "x", Expression: evaluator.Function{Name: "UPPER"}}}},
+		{SelectColumns: []string{"id"}, OrderBy: []table.OrderBy{{Column: "total"}}},
+	}
+	for _, command := range invalid {
+		command.TableName, command.Limit = "items", table.UnlimitedSize
+		if _, err := items.Select(command); err == nil {
+			t.Errorf("Expected an error for %+v", command)
+		}
+	}
+	// A computed value that cannot be computed fails the select
+	_, err := items.Select(table.SelectCommand{TableName: "items", Limit: table.UnlimitedSize, SelectColumns: []string{"price"},
+		Projections: []table.Projection{{Name: "x", Expression: &evaluator.Expression{Left: "price", Op: datatype.OperatorDivide, Right: int64(0)}}}})
+	if err == nil {
+		t.Error("Expected an error for a division by zero")
+	}
+}
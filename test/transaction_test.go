@@ -0,0 +1,97 @@
+package test
+
+import (
+	"os"
+	"simple-database/internal/engine"
+	"simple-database/internal/engine/table"
+	"simple-database/internal/engine/table/column"
+	"simple-database/internal/platform/datatype"
+	"simple-database/internal/platform/evaluator"
+	"testing"
+)
+
+func TestTransactionRollback(t *testing.T) {
+	_ = os.RemoveAll("data/tx_test")
+
+	db, err := engine.NewDatabase("tx_test")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	id, _ := column.NewColumn("id", datatype.TypeInt64, column.PrimaryKey)
+	balance, _ := column.NewColumn("balance", datatype.TypeInt64, column.UsingIndex)
+	accounts, err := db.CreateTable(engine.CreateTableCommand{
+		TableName: "accounts",
+		Columns:   table.Columns{"id": id, "balance": balance},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		record := map[string]any{"id": int64(i), "balance": int64(100)}
+		if _, err = accounts.Insert(table.InsertCommand{TableName: "accounts", Record: record}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	countBalance := func(value int64) int {
+		result, err := accounts.Select(table.SelectCommand{
+			TableName:  "accounts",
+			Expression: &evaluator.Expression{Left: "balance", Op: datatype.OperatorEqual, Right: value},
+			Limit:      table.UnlimitedSize,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(result.Rows)
+	}
+
+	// 1. Changes made inside a rolled back transaction disappear from the table and its indexes
+	if err = db.Begin(); err != nil {
+		t.Fatal(err)
+	}
+	update := table.UpdateCommand{
+		TableName:  "accounts",
+		Expression: &evaluator.Expression{Left: "id", Op: datatype.OperatorLess, Right: int64(5)},
+		Record:     map[string]any{"balance": int64(50)},
+	}
+	if _, err = accounts.Update(update); err != nil {
+		t.Fatal(err)
+	}
+	insert := table.InsertCommand{TableName: "accounts", Record: map[string]any{"id": int64(10), "balance": int64(50)}}
+	if _, err = accounts.Insert(insert); err != nil {
+		t.Fatal(err)
+	}
+	if n := countBalance(50); n != 6 {
+		t.Errorf("Expected 6 rows inside the transaction, got %d", n)
+	}
+	if err = db.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+	if n := countBalance(50); n != 0 {
+		t.Errorf("Expected 0 rows after rollback, got %d", n)
+	}
+	if n := countBalance(100); n != 10 {
+		t.Errorf("Expected 10 rows after rollback, got %d", n)
+	}
+
+	// 2. A failing statement only undoes itself
+	if err = db.Begin(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = accounts.Insert(insert); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = accounts.Insert(insert); err == nil {
+		t.Errorf("Expected duplicate primary key to fail")
+	}
+	if err = db.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if n := countBalance(50); n != 1 {
+		t.Errorf("Expected 1 row after commit, got %d", n)
+	}
+
+	if err = db.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
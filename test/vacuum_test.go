@@ -0,0 +1,121 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"simple-database/internal/engine"
+	"simple-database/internal/engine/table"
+	"simple-database/internal/engine/table/column"
+	"simple-database/internal/platform/datatype"
+	"simple-database/internal/platform/evaluator"
+	"strings"
+	"testing"
+)
+
+func TestVacuum(t *testing.T) {
+	_ = os.RemoveAll("data/vacuum_test")
+
+	db, err := engine.NewDatabase("vacuum_test")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	id, _ := column.NewColumn("id", datatype.TypeInt64, column.PrimaryKey)
+	name, _ := column.NewColumn("name", datatype.TypeString, column.Normal)
+	group, _ := column.NewColumn("group", datatype.TypeInt32, column.UsingIndex)
+	users, err := db.CreateTable(engine.CreateTableCommand{
+		TableName: "users",
+		Columns:   table.Columns{"id": id, "name": name, "group": group},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	insert := func(i int64) {
+		record := map[string]any{"id": i, "name": strings.Repeat("a", 50), "group": int32(i % 3)}
+		if _, err := users.Insert(table.InsertCommand{TableName: "users", Record: record}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	count := func(users *table.Table, left string, op datatype.Operator, value any) int {
+		result, err := users.Select(table.SelectCommand{
+			TableName:  "users",
+			Expression: &evaluator.Expression{Left: left, Op: op, Right: value},
+			Limit:      table.UnlimitedSize,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(result.Rows)
+	}
+	heapPath := filepath.Join("data", "vacuum_test", "users"+table.FileExtension)
+
+	for i := int64(0); i < 300; i++ {
+		insert(i)
+	}
+	_, err = users.Delete(table.DeleteCommand{
+		TableName:  "users",
+		Expression: &evaluator.Expression{Left: "group", Op: datatype.OperatorGreater, Right: int32(0)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	before, _ := os.Stat(heapPath)
+
+	// 1. The heap shrinks and the rebuilt indexes point at the new pages
+	result, err := db.Vacuum(engine.VacuumCommand{TableName: "users"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	after, _ := os.Stat(heapPath)
+	if result.Rows != 100 {
+		t.Errorf("Expected 100 rows copied, got %d", result.Rows)
+	}
+	if result.BytesReclaimed <= 0 || after.Size() >= before.Size() {
+		t.Errorf("Expected the table to shrink from %d bytes, got %d (%d reclaimed)", before.Size(), after.Size(), result.BytesReclaimed)
+	}
+	if n := count(users, "group", datatype.OperatorEqual, int32(0)); n != 100 {
+		t.Errorf("Expected 100 rows through the index, got %d", n)
+	}
+	if n := count(users, "id", datatype.OperatorGreaterOrEqual, int64(150)); n != 50 {
+		t.Errorf("Expected 50 rows through the primary key, got %d", n)
+	}
+	if n := count(users, "name", datatype.OperatorEqual, strings.Repeat("a", 50)); n != 100 {
+		t.Errorf("Expected 100 rows through a full scan, got %d", n)
+	}
+
+	// 2. A table changed between the copy and the swap is copied again
+	v, err := db.PrepareVacuum(engine.VacuumCommand{TableName: "users"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	insert(1000)
+	result, err = db.FinishVacuum(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Rows != 101 {
+		t.Errorf("Expected 101 rows copied, got %d", result.Rows)
+	}
+
+	// 3. A copy left behind by an interrupted vacuum is removed on startup
+	if _, err = db.PrepareVacuum(engine.VacuumCommand{TableName: "users"}); err != nil {
+		t.Fatal(err)
+	}
+	if err = db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	db, err = engine.NewDatabase("vacuum_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Stat(heapPath + table.VacuumExtension); !os.IsNotExist(err) {
+		t.Errorf("Expected the copy to be removed, got %v", err)
+	}
+	if n := count(db.Tables["users"], "group", datatype.OperatorEqual, int32(1)); n != 1 {
+		t.Errorf("Expected 1 row in group 1 after reopening, got %d", n)
+	}
+
+	if err = db.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
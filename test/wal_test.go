@@ -0,0 +1,240 @@
+package test
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"simple-database/internal/engine/wal"
+	"sync"
+	"testing"
+)
+
+func TestWALRecovery(t *testing.T) {
+	dir := "data/test/wal"
+	_ = os.RemoveAll(dir)
+	_ = os.MkdirAll(dir, 0777)
+
+	log, err := wal.Open(dir)
+	if err != nil {
+		t.Fatalf("Failed to open log: %v", err)
+	}
+	f, err := os.Create(dir + "/table.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := wal.NewFile(f, log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = file.Write([]byte("hello world")); err != nil {
+		t.Fatal(err)
+	}
+
+	// 1. A committed transaction survives
+	log.Begin()
+	if _, err = file.WriteAt([]byte("HELLO"), 0); err != nil {
+		t.Fatal(err)
+	}
+	if err = log.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 2. An unfinished transaction is undone on the next start, including growing and shrinking the file
+	log.Begin()
+	if _, err = file.WriteAt([]byte("WORLD!!!!"), 6); err != nil {
+		t.Fatal(err)
+	}
+	if err = file.Truncate(3); err != nil {
+		t.Fatal(err)
+	}
+
+	recovered, err := wal.Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = recovered.Recover(); err != nil {
+		t.Fatalf("Failed to recover: %v", err)
+	}
+	content, _ := os.ReadFile(dir + "/table.bin")
+	if !bytes.Equal(content, []byte("HELLO world")) {
+		t.Errorf("Expected %q after recovery, got %q", "HELLO world", content)
+	}
+
+	// 3. Rolling back a savepoint keeps the writes made before it
+	f, err = os.OpenFile(dir+"/table.bin", os.O_RDWR, 0777)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err = wal.NewFile(f, recovered)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recovered.Begin()
+	_, _ = file.WriteAt([]byte("J"), 0)
+	recovered.Begin()
+	_, _ = file.WriteAt([]byte("this write is rolled back"), 0)
+	if err = recovered.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+	if err = recovered.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	content, _ = os.ReadFile(dir + "/table.bin")
+	if !bytes.Equal(content, []byte("JELLO world")) {
+		t.Errorf("Expected %q after rollback, got %q", "JELLO world", content)
+	}
+
+	if err = file.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err = recovered.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWALSharedByFiles(t *testing.T) {
+	dir := "data/test/wal_shared"
+	_ = os.RemoveAll(dir)
+	_ = os.MkdirAll(dir, 0777)
+
+	log, err := wal.Open(dir)
+	if err != nil {
+		t.Fatalf("Failed to open log: %v", err)
+	}
+	page := bytes.Repeat([]byte{'a'}, 4096)
+
+	// 1. The files of several tables write pages, each twice, inside one transaction at the same time, as other
+	// files are opened and closed
+	log.Begin()
+	files := make([]*wal.File, 8)
+	var wg sync.WaitGroup
+	for i := range files {
+		f, err := os.Create(fmt.Sprintf("%s/table_%d.bin", dir, i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if files[i], err = wal.NewFile(f, log); err != nil {
+			t.Fatal(err)
+		}
+		wg.Add(1)
+		go func(file *wal.File) {
+			defer wg.Done()
+			for num := int64(0); num < 4; num++ {
+				for _, b := range []byte("bc") {
+					if _, err := file.WriteAt(bytes.Repeat([]byte{b}, 4096), num*4096); err != nil {
+						t.Error(err)
+					}
+				}
+			}
+			other, err := os.Create(file.Name() + ".tmp")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if temp, err := wal.NewFile(other, log); err == nil {
+				_ = temp.Close()
+			}
+		}(files[i])
+	}
+	wg.Wait()
+
+	// 2. The transaction is undone on the next start, every file back to empty
+	recovered, err := wal.Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = recovered.Recover(); err != nil {
+		t.Fatalf("Failed to recover: %v", err)
+	}
+	for i := range files {
+		if content, _ := os.ReadFile(files[i].Name()); len(content) != 0 {
+			t.Errorf("Expected %s to be empty after recovery, got %d bytes", files[i].Name(), len(content))
+		}
+	}
+
+	// 3. Once committed, the pages written by every file stay
+	recovered.Begin()
+	for i := range files {
+		f, err := os.OpenFile(files[i].Name(), os.O_RDWR, 0777)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if files[i], err = wal.NewFile(f, recovered); err != nil {
+			t.Fatal(err)
+		}
+		wg.Add(1)
+		go func(file *wal.File) {
+			defer wg.Done()
+			if _, err := file.WriteAt(page, 0); err != nil {
+				t.Error(err)
+			}
+		}(files[i])
+	}
+	wg.Wait()
+	if err = recovered.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	for i := range files {
+		if content, _ := os.ReadFile(files[i].Name()); !bytes.Equal(content, page) {
+			t.Errorf("Expected the page of %s to be written, got %d bytes", files[i].Name(), len(content))
+		}
+		_ = files[i].Close()
+	}
+	if err = recovered.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWALRecoveryAfterRollback(t *testing.T) {
+	dir := "data/test/wal_rollback"
+	_ = os.RemoveAll(dir)
+	_ = os.MkdirAll(dir, 0777)
+
+	log, err := wal.Open(dir)
+	if err != nil {
+		t.Fatalf("Failed to open log: %v", err)
+	}
+	f, err := os.Create(dir + "/table.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := wal.NewFile(f, log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = file.Write([]byte("hello world")); err != nil {
+		t.Fatal(err)
+	}
+
+	// A rolled back transaction whose undo writes did not reach the data file before a crash, only its log
+	log.Begin()
+	if _, err = file.WriteAt([]byte("HELLO"), 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = file.WriteAt([]byte("WORLD!!!"), 6); err != nil {
+		t.Fatal(err)
+	}
+	if err = log.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(dir+"/table.bin", []byte("HELLO WORLD!!!"), 0777); err != nil {
+		t.Fatal(err)
+	}
+
+	// Recovery replays the transaction with its undo writes
+	recovered, err := wal.Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = recovered.Recover(); err != nil {
+		t.Fatalf("Failed to recover: %v", err)
+	}
+	content, _ := os.ReadFile(dir + "/table.bin")
+	if !bytes.Equal(content, []byte("hello world")) {
+		t.Errorf("Expected %q after recovery, got %q", "hello world", content)
+	}
+	_ = file.Close()
+	if err = recovered.Close(); err != nil {
+		t.Fatal(err)
+	}
+}